@@ -0,0 +1,99 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// キーは "ak_<プレフィックス>_<シークレット>" の形式
+// プレフィックスは一覧でキーを見分けるため、およびハッシュを照合するキーを探すために保存する
+const (
+	keyPrefix    = "ak"
+	prefixBytes  = 4
+	secretBytes  = 24
+	keySeparator = "_"
+)
+
+const (
+	// ScopeRead 参照(GET/HEAD/OPTIONS)のみ行える
+	ScopeRead = "read"
+	// ScopeWrite 参照に加えて作成・更新・削除を行える
+	ScopeWrite = "write"
+	// ScopeAdmin 組織の管理者の操作を行える。発行したユーザーが管理者である必要がある
+	ScopeAdmin = "admin"
+)
+
+var validScopes = map[string]bool{
+	ScopeRead:  true,
+	ScopeWrite: true,
+	ScopeAdmin: true,
+}
+
+// Generate 新しいキーと、保存するプレフィックスおよびハッシュを返す
+func Generate() (key, prefix, hash string, err error) {
+	b := make([]byte, prefixBytes+secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	prefix = hex.EncodeToString(b[:prefixBytes])
+	key = strings.Join([]string{keyPrefix, prefix, hex.EncodeToString(b[prefixBytes:])}, keySeparator)
+	return key, prefix, Hash(key), nil
+}
+
+// Parse キーからプレフィックスを取り出す。形式が正しくない場合はfalseを返す
+func Parse(key string) (string, bool) {
+	parts := strings.Split(key, keySeparator)
+	if len(parts) != 3 || parts[0] != keyPrefix ||
+		len(parts[1]) != prefixBytes*2 || len(parts[2]) != secretBytes*2 {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// Hash 保存用のハッシュ。キーは十分な長さの乱数のため、パスワードと違い低速なハッシュは使わない
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify キーが保存したハッシュと一致するかを一定時間で比較する
+func Verify(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(Hash(key)), []byte(hash)) == 1
+}
+
+// ValidScopes 全て定義済みのスコープで、1つ以上指定されているかどうか
+func ValidScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, s := range scopes {
+		if !validScopes[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// HasScope スコープを持っているかどうか
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsMethod スコープでHTTPメソッドのリクエストを行えるかどうか
+func AllowsMethod(scopes []string, method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return HasScope(scopes, ScopeRead) || HasScope(scopes, ScopeWrite)
+	default:
+		return HasScope(scopes, ScopeWrite)
+	}
+}
@@ -0,0 +1,87 @@
+package apikey_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/apikey"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	key, prefix, hash, err := apikey.Generate()
+	require.NoError(t, err)
+	assert.Regexp(t, `^ak_[0-9a-f]{8}_[0-9a-f]{48}$`, key)
+
+	t.Run("キーからプレフィックスを取り出せること", func(t *testing.T) {
+		got, ok := apikey.Parse(key)
+		assert.True(t, ok)
+		assert.Equal(t, prefix, got)
+	})
+	t.Run("キーがハッシュと一致すること", func(t *testing.T) {
+		assert.True(t, apikey.Verify(key, hash))
+		assert.False(t, apikey.Verify(key+"0", hash))
+	})
+	t.Run("異なるキーが生成されること", func(t *testing.T) {
+		other, _, _, err := apikey.Generate()
+		require.NoError(t, err)
+		assert.NotEqual(t, key, other)
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{name: "空文字", key: ""},
+		{name: "接頭辞が違う", key: "sk_0123abcd_" + "0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{name: "プレフィックスの長さが違う", key: "ak_0123_" + "0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{name: "シークレットが無い", key: "ak_0123abcd_"},
+		{name: "JWT", key: "eyJhbGciOiJIUzI1NiJ9.e30.sig"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name+"の場合は形式が不正になること", func(t *testing.T) {
+			t.Parallel()
+			_, ok := apikey.Parse(tt.key)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestScopes(t *testing.T) {
+	t.Parallel()
+
+	t.Run("定義済みのスコープのみ有効であること", func(t *testing.T) {
+		assert.True(t, apikey.ValidScopes([]string{apikey.ScopeRead, apikey.ScopeAdmin}))
+		assert.False(t, apikey.ValidScopes([]string{apikey.ScopeRead, "delete"}))
+		assert.False(t, apikey.ValidScopes(nil))
+	})
+
+	tests := []struct {
+		name   string
+		scopes []string
+		method string
+		want   bool
+	}{
+		{name: "readで参照できること", scopes: []string{apikey.ScopeRead}, method: http.MethodGet, want: true},
+		{name: "readでは更新できないこと", scopes: []string{apikey.ScopeRead}, method: http.MethodPost, want: false},
+		{name: "writeで参照できること", scopes: []string{apikey.ScopeWrite}, method: http.MethodGet, want: true},
+		{name: "writeで削除できること", scopes: []string{apikey.ScopeWrite}, method: http.MethodDelete, want: true},
+		{name: "adminのみでは参照できないこと", scopes: []string{apikey.ScopeAdmin}, method: http.MethodGet, want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, apikey.AllowsMethod(tt.scopes, tt.method))
+		})
+	}
+}
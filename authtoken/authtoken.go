@@ -0,0 +1,96 @@
+package authtoken
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposePasswordReset = "password_reset"
+	// PurposeTwoFactorLogin パスワード認証に成功し、2段階認証を待っているログイン
+	PurposeTwoFactorLogin = "two_factor_login"
+
+	VerifyEmailTTL        = 24 * time.Hour
+	PasswordResetTTL      = time.Hour
+	TwoFactorChallengeTTL = 5 * time.Minute
+
+	keyPrefix = "authtoken:"
+)
+
+var (
+	// ErrInvalidToken 署名が一致しない、期限切れ、または使用済みのトークン
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrSecretMissing 署名の鍵が空だと誰でもトークンを偽造できるため、起動時に検出する
+	ErrSecretMissing = errors.New("AUTH_TOKEN_SECRET is not set")
+)
+
+// SecretFromEnv 環境変数AUTH_TOKEN_SECRETの署名の鍵を返す。設定されていない場合はErrSecretMissingを返す
+func SecretFromEnv() (string, error) {
+	secret := os.Getenv("AUTH_TOKEN_SECRET")
+	if secret == "" {
+		return "", ErrSecretMissing
+	}
+	return secret, nil
+}
+
+// Issuer 用途ごとに一度だけ使えるトークンを発行・検証する
+// トークンは "<ID>.<署名>" の形式で、保存先にはIDをキーとして対象のユーザーIDを保存する
+// 署名を先に検証するため、改ざんされたトークンで保存先を参照することはない
+type Issuer struct {
+	store  Store
+	secret []byte
+}
+
+func NewIssuer(store Store, secret string) *Issuer {
+	return &Issuer{store: store, secret: []byte(secret)}
+}
+
+// Issue userIDに対するトークンを発行する
+func (i *Issuer) Issue(ctx context.Context, purpose, userID string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+	if err := i.store.Save(ctx, storeKey(purpose, id), userID, ttl); err != nil {
+		return "", err
+	}
+	return id + "." + i.sign(purpose, id), nil
+}
+
+// Consume トークンを検証して対象のユーザーIDを返す。検証に成功したトークンは使用済みになる
+func (i *Issuer) Consume(ctx context.Context, purpose, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(i.sign(purpose, parts[0])), []byte(parts[1])) {
+		return "", ErrInvalidToken
+	}
+	userID, err := i.store.Take(ctx, storeKey(purpose, parts[0]))
+	if err != nil {
+		return "", err
+	}
+	if userID == "" {
+		return "", ErrInvalidToken
+	}
+	return userID, nil
+}
+
+// sign 用途を含めて署名し、別の用途のトークンとして使えないようにする
+func (i *Issuer) sign(purpose, id string) string {
+	mac := hmac.New(sha256.New, i.secret)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte("."))
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func storeKey(purpose, id string) string {
+	return keyPrefix + purpose + ":" + id
+}
@@ -0,0 +1,91 @@
+package authtoken_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/authtoken"
+)
+
+func TestIssuer(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("発行したトークンから対象のユーザーIDを取得できること", func(t *testing.T) {
+		t.Parallel()
+		issuer := authtoken.NewIssuer(authtoken.NewMemoryStore(), "secret")
+		token, err := issuer.Issue(ctx, authtoken.PurposeVerifyEmail, "user-1", time.Hour)
+		require.NoError(t, err)
+
+		userID, err := issuer.Consume(ctx, authtoken.PurposeVerifyEmail, token)
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", userID)
+	})
+
+	t.Run("トークンは一度しか使えないこと", func(t *testing.T) {
+		t.Parallel()
+		issuer := authtoken.NewIssuer(authtoken.NewMemoryStore(), "secret")
+		token, err := issuer.Issue(ctx, authtoken.PurposePasswordReset, "user-1", time.Hour)
+		require.NoError(t, err)
+
+		_, err = issuer.Consume(ctx, authtoken.PurposePasswordReset, token)
+		require.NoError(t, err)
+		_, err = issuer.Consume(ctx, authtoken.PurposePasswordReset, token)
+		assert.ErrorIs(t, err, authtoken.ErrInvalidToken)
+	})
+
+	t.Run("別の用途のトークンは使えないこと", func(t *testing.T) {
+		t.Parallel()
+		issuer := authtoken.NewIssuer(authtoken.NewMemoryStore(), "secret")
+		token, err := issuer.Issue(ctx, authtoken.PurposeVerifyEmail, "user-1", time.Hour)
+		require.NoError(t, err)
+
+		_, err = issuer.Consume(ctx, authtoken.PurposePasswordReset, token)
+		assert.ErrorIs(t, err, authtoken.ErrInvalidToken)
+	})
+
+	t.Run("署名を改ざんしたトークンは使えないこと", func(t *testing.T) {
+		t.Parallel()
+		store := authtoken.NewMemoryStore()
+		token, err := authtoken.NewIssuer(store, "secret").Issue(ctx, authtoken.PurposeVerifyEmail, "user-1", time.Hour)
+		require.NoError(t, err)
+		id := strings.Split(token, ".")[0]
+
+		_, err = authtoken.NewIssuer(store, "other").Consume(ctx, authtoken.PurposeVerifyEmail, token)
+		assert.ErrorIs(t, err, authtoken.ErrInvalidToken)
+		_, err = authtoken.NewIssuer(store, "secret").Consume(ctx, authtoken.PurposeVerifyEmail, id)
+		assert.ErrorIs(t, err, authtoken.ErrInvalidToken)
+	})
+
+	t.Run("有効期限を過ぎたトークンは使えないこと", func(t *testing.T) {
+		t.Parallel()
+		now := time.Date(2022, 9, 25, 10, 0, 0, 0, time.UTC)
+		issuer := authtoken.NewIssuer(authtoken.NewMemoryStoreWithClock(func() time.Time { return now }), "secret")
+		token, err := issuer.Issue(ctx, authtoken.PurposeVerifyEmail, "user-1", time.Hour)
+		require.NoError(t, err)
+
+		now = now.Add(time.Hour)
+		_, err = issuer.Consume(ctx, authtoken.PurposeVerifyEmail, token)
+		assert.ErrorIs(t, err, authtoken.ErrInvalidToken)
+	})
+}
+
+func TestSecretFromEnv(t *testing.T) {
+	t.Run("未設定の場合はエラーになること", func(t *testing.T) {
+		t.Setenv("AUTH_TOKEN_SECRET", "")
+		_, err := authtoken.SecretFromEnv()
+		assert.ErrorIs(t, err, authtoken.ErrSecretMissing)
+	})
+
+	t.Run("設定した値を返すこと", func(t *testing.T) {
+		t.Setenv("AUTH_TOKEN_SECRET", "secret")
+		secret, err := authtoken.SecretFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, "secret", secret)
+	})
+}
@@ -0,0 +1,76 @@
+package authtoken
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+// Store トークンの保存先
+// Takeは値の取得と削除を同時に行い、同じキーを2回取得できないようにする。存在しない場合は空文字を返す
+type Store interface {
+	Save(ctx context.Context, key, value string, ttl time.Duration) error
+	Take(ctx context.Context, key string) (string, error)
+}
+
+// RedisStore Redisのキーの有効期限でTTLを管理する
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Save(ctx context.Context, key, value string, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string) (string, error) {
+	value, err := s.client.GetDel(ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return value, err
+}
+
+// MemoryStore テストやローカル実行用のインメモリの保存先
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithClock(time.Now)
+}
+
+// NewMemoryStoreWithClock 有効期限の判定に使う現在日時を差し替える
+func NewMemoryStoreWithClock(now func() time.Time) *MemoryStore {
+	return &MemoryStore{entries: map[string]memoryEntry{}, now: now}
+}
+
+func (s *MemoryStore) Save(_ context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = memoryEntry{value: value, expiresAt: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	delete(s.entries, key)
+	if !ok || !s.now().Before(entry.expiresAt) {
+		return "", nil
+	}
+	return entry.value, nil
+}
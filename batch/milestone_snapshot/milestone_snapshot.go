@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/urfave/cli/v2"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func run(args []string) error {
+	app := &cli.App{
+		Name:  "milestoneスナップショットバッチ",
+		Usage: "期間中のmilestoneごとに当日の残issue数・ストーリーポイントを記録する",
+		Action: func(c *cli.Context) error {
+			conn := db.Init()
+			now := time.Now()
+			today := now.Format("2006-01-02")
+
+			var milestones []models.Milestone
+			if err := conn.Where("start_date <= ?", today).
+				Where("due_date IS NULL OR due_date >= ?", today).
+				Find(&milestones).Error; err != nil {
+				return err
+			}
+
+			for _, milestone := range milestones {
+				var issues []models.Issue
+				if err := conn.Where("milestone_id = ?", milestone.ID).Find(&issues).Error; err != nil {
+					return err
+				}
+				snapshot := models.NewMilestoneSnapshot(milestone.ID, now, issues)
+				if err := saveSnapshot(conn, snapshot, now); err != nil {
+					return err
+				}
+				fmt.Printf("milestone %s: 未完了 %d 件 / 完了 %d 件\n",
+					milestone.ID, snapshot.OpenCount, snapshot.ClosedCount)
+			}
+			fmt.Printf("%d 件のmilestoneのスナップショットを記録しました\n", len(milestones))
+			return nil
+		},
+	}
+
+	err := app.Run(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return nil
+}
+
+// saveSnapshot 同日のスナップショットがあれば上書きし、無ければ作成する
+func saveSnapshot(conn *gorm.DB, snapshot models.MilestoneSnapshot, now time.Time) error {
+	var existing models.MilestoneSnapshot
+	err := conn.Where("milestone_id = ? AND snapshot_date = ?",
+		snapshot.MilestoneID, snapshot.SnapshotDate.Format("2006-01-02")).First(&existing).Error
+	switch {
+	case gorm.IsRecordNotFoundError(err):
+		snapshot.CreatedAt = now
+		snapshot.UpdatedAt = now
+		return conn.Create(&snapshot).Error
+	case err != nil:
+		return err
+	}
+	return conn.Table("milestone_snapshots").Where("id = ?", existing.ID).Updates(map[string]interface{}{
+		"open_count":    snapshot.OpenCount,
+		"closed_count":  snapshot.ClosedCount,
+		"open_points":   snapshot.OpenPoints,
+		"closed_points": snapshot.ClosedPoints,
+		"updated_at":    now,
+	}).Error
+}
+
+func main() {
+	fmt.Println("milestoneスナップショットバッチを開始します。")
+	if err := run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("milestoneスナップショットバッチを終了します。")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/urfave/cli/v2"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/outbox"
+)
+
+func run(args []string) error {
+	app := &cli.App{
+		Name:  "アウトボックスリレーバッチ",
+		Usage: "未発行のアウトボックスをRedis Streamsへ発行する",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "redis-addr",
+				Value:   "localhost:6379",
+				Usage:   "発行先のRedisのアドレス",
+				EnvVars: []string{"REDIS_ADDR"},
+			},
+			&cli.IntFlag{
+				Name:  "batch-size",
+				Value: outbox.DefaultBatchSize,
+				Usage: "1回の実行で発行する最大件数",
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Value: 0,
+				Usage: "指定した場合はこの間隔で発行を繰り返す",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			zapLogger, err := logger.NewLogger(false)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = zapLogger.Sync() }()
+
+			client := redis.NewClient(&redis.Options{Addr: c.String("redis-addr")})
+			defer client.Close()
+
+			relay := outbox.NewRelay(db.Init(), outbox.NewRedisStreamBroker(client), zapLogger)
+			relay.BatchSize = c.Int("batch-size")
+			for {
+				count, err := relay.RelayPending(context.Background())
+				fmt.Printf("アウトボックスを %d 件発行しました\n", count)
+				if c.Duration("interval") <= 0 {
+					return err
+				}
+				if err != nil {
+					fmt.Printf("発行に失敗しました: %s\n", err)
+				}
+				time.Sleep(c.Duration("interval"))
+			}
+		},
+	}
+
+	err := app.Run(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return nil
+}
+
+func main() {
+	fmt.Println("アウトボックスリレーバッチを開始します。")
+	if err := run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("アウトボックスリレーバッチを終了します。")
+}
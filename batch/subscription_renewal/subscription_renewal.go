@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/urfave/cli/v2"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func run(args []string) error {
+	app := &cli.App{
+		Name:  "定額課金更新バッチ",
+		Usage: "契約期間が終了した会員を更新または失効させる",
+		Action: func(c *cli.Context) error {
+			dbConn := db.Init()
+			now := time.Now()
+
+			var members []models.SubscriptionMember
+			if err := dbConn.
+				Where("member_status IN (?)", []models.MemberStatus{models.Premium, models.Basic}).
+				Where("member_end_date <= ?", now).
+				Find(&members).Error; err != nil {
+				return err
+			}
+
+			var renewed, expired int
+			for i := range members {
+				member := members[i]
+				if err := dbConn.Transaction(func(tx *gorm.DB) error {
+					reason, err := processMember(tx, &member, now)
+					if err != nil {
+						return err
+					}
+					if reason == models.HistoryReasonRenewed {
+						renewed++
+					} else {
+						expired++
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("会員を %d 件更新、%d 件失効しました\n", renewed, expired)
+			return nil
+		},
+	}
+
+	err := app.Run(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return nil
+}
+
+// processMember 1会員分の更新/失効を行い、履歴の理由を返す
+// 期間が大きく過ぎている場合は現在日時を超えるまで更新を繰り返す
+func processMember(tx *gorm.DB, member *models.SubscriptionMember, now time.Time) (string, error) {
+	fromStatus := member.MemberStatus
+	fromPlanID := member.PlanID
+
+	var plan models.SubscriptionPlan
+	if !member.CancelAtPeriodEnd && member.PlanID != "" {
+		if err := tx.Where("id = ?", member.PlanID).First(&plan).Error; err != nil &&
+			!gorm.IsRecordNotFoundError(err) {
+			return "", err
+		}
+	}
+
+	reason := models.HistoryReasonExpired
+	if plan.ID != "" && plan.PeriodDays > 0 {
+		for member.IsExpired(now) {
+			member.Renew(&plan)
+		}
+		reason = models.HistoryReasonRenewed
+	} else {
+		member.Expire()
+	}
+
+	if err := tx.Save(member).Error; err != nil {
+		return "", err
+	}
+	history := models.NewSubscriptionMemberHistory(member, fromStatus, fromPlanID, reason)
+	if err := tx.Create(history).Error; err != nil {
+		return "", err
+	}
+	return reason, nil
+}
+
+func main() {
+	fmt.Println("定額課金更新バッチを開始します。")
+	if err := run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("定額課金更新バッチを終了します。")
+}
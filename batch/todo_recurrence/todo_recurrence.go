@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/urfave/cli/v2"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func run(args []string) error {
+	app := &cli.App{
+		Name:  "TODO繰り返し作成バッチ",
+		Usage: "期限を過ぎた繰り返しTODOの次回分を作成する",
+		Action: func(c *cli.Context) error {
+			dbConn := db.Init()
+			now := time.Now()
+
+			var todos []models.Todo
+			if err := dbConn.Preload("ChecklistItems").
+				Where("recurrence_rule <> '' AND recurred_at IS NULL AND due_at <= ?", now).
+				Find(&todos).Error; err != nil {
+				return err
+			}
+
+			var count int
+			for i := range todos {
+				todo := todos[i]
+				if err := dbConn.Transaction(func(tx *gorm.DB) error {
+					created, err := recur(tx, &todo, now)
+					if created {
+						count++
+					}
+					return err
+				}); err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("繰り返しTODOを %d 件作成しました\n", count)
+			return nil
+		},
+	}
+
+	err := app.Run(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return nil
+}
+
+// recur 次回分のTODOとチェックリストを作成し、元のTODOを作成済みにする
+// 同時に実行された場合に二重に作成しないよう、作成済みへの更新を先に行う
+func recur(tx *gorm.DB, todo *models.Todo, now time.Time) (bool, error) {
+	result := tx.Table("todos").Where("id = ? AND recurred_at IS NULL", todo.ID).
+		Updates(map[string]interface{}{"recurred_at": now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	next := todo.NextRecurrence(now)
+	if next == nil {
+		return false, nil
+	}
+	items := next.ChecklistItems
+	next.ChecklistItems = nil
+	if err := tx.Create(next).Error; err != nil {
+		return false, err
+	}
+	for i := range items {
+		items[i].TodoID = next.ID
+		if err := tx.Create(&items[i]).Error; err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func main() {
+	fmt.Println("TODO繰り返し作成バッチを開始します。")
+	if err := run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("TODO繰り返し作成バッチを終了します。")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/urfave/cli/v2"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func run(args []string) error {
+	app := &cli.App{
+		Name:  "TODOリマインドバッチ",
+		Usage: "リマインド日時を過ぎた未完了のTODOについて担当ユーザーへ通知する",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "limit",
+				Value: 100,
+				Usage: "1回の実行で通知する最大件数",
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Value: 0,
+				Usage: "指定した場合はこの間隔で通知を繰り返す",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			dbConn := db.Init()
+			for {
+				count, err := remindDue(dbConn, time.Now(), c.Int("limit"))
+				if err != nil {
+					return err
+				}
+				fmt.Printf("TODOのリマインドを %d 件通知しました\n", count)
+
+				if c.Duration("interval") <= 0 {
+					return nil
+				}
+				time.Sleep(c.Duration("interval"))
+			}
+		},
+	}
+
+	err := app.Run(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return nil
+}
+
+// remindDue 未通知のTODOごとに通知を登録し、通知済みにする
+// 通知の登録と通知済みの更新は同じトランザクションで行い、二重に通知しないようにする
+func remindDue(dbConn *gorm.DB, now time.Time, limit int) (int, error) {
+	var todos []models.Todo
+	if err := dbConn.
+		Where("remind_at <= ? AND reminded_at IS NULL", now).
+		Where("status NOT IN (?)", []models.TodoStatus{models.TodoStatusDone, models.TodoStatusClosed}).
+		Order("remind_at").Limit(limit).Find(&todos).Error; err != nil {
+		return 0, err
+	}
+
+	var count int
+	for i := range todos {
+		todo := todos[i]
+		if todo.UserID == nil || *todo.UserID == "" {
+			continue
+		}
+		if err := dbConn.Transaction(func(tx *gorm.DB) error {
+			result := tx.Table("todos").Where("id = ? AND reminded_at IS NULL", todo.ID).
+				Updates(map[string]interface{}{"reminded_at": now})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			if err := tx.Create(models.NewTodoReminderNotification(&todo, now)).Error; err != nil {
+				return err
+			}
+			count++
+			return nil
+		}); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+func main() {
+	fmt.Println("TODOリマインドバッチを開始します。")
+	if err := run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("TODOリマインドバッチを終了します。")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/webhook"
+)
+
+func run(args []string) error {
+	app := &cli.App{
+		Name:  "Webhook配信バッチ",
+		Usage: "送信予定時刻を過ぎたWebhookを配信し、失敗したものは再試行を予約する",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "limit",
+				Value: 100,
+				Usage: "1回の実行で配信する最大件数",
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Value: 0,
+				Usage: "指定した場合はこの間隔で配信を繰り返す",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			zapLogger, err := logger.NewLogger(false)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = zapLogger.Sync() }()
+
+			dispatcher := webhook.NewDispatcher(db.Init(),
+				&http.Client{Timeout: 10 * time.Second}, zapLogger)
+			for {
+				count, err := dispatcher.DeliverDue(context.Background(), c.Int("limit"))
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Webhookを %d 件配信しました\n", count)
+
+				if c.Duration("interval") <= 0 {
+					return nil
+				}
+				time.Sleep(c.Duration("interval"))
+			}
+		},
+	}
+
+	err := app.Run(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return nil
+}
+
+func main() {
+	fmt.Println("Webhook配信バッチを開始します。")
+	if err := run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Webhook配信バッチを終了します。")
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/jinzhu/gorm"
 	// コメント書かないとLintエラーになる
 	_ "github.com/jinzhu/gorm/dialects/mysql"
+
+	"github.com/AI1411/golang-admin-api/models"
 )
 
 func Init() *gorm.DB {
@@ -12,5 +14,6 @@ func Init() *gorm.DB {
 		panic("failed to connect db")
 	}
 	db.LogMode(false)
+	models.RegisterTenantCallbacks(db)
 	return db
 }
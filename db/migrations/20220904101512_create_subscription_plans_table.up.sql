@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS `subscription_plans`;
+CREATE TABLE `subscription_plans`
+(
+    id            char(36)                              NOT NULL comment 'ID',
+    plan_name     varchar(64)                           NOT NULL comment 'プラン名',
+    member_status varchar(64) default 'basic'           NOT NULL comment '付与する会員ステータス',
+    price         int unsigned default 0                NOT NULL comment '期間あたりの料金',
+    period_days   int unsigned default 30               NOT NULL comment '契約期間(日)',
+    created_at    timestamp   default current_timestamp NOT NULL comment '作成日時',
+    updated_at    timestamp   default current_timestamp NOT NULL comment '更新日時',
+    PRIMARY KEY (id),
+    KEY index_member_status (member_status)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = '定額課金プラン';
@@ -0,0 +1,5 @@
+ALTER TABLE `subscription_members`
+    ADD COLUMN plan_id              char(36)                NULL comment 'プランID' AFTER user_id,
+    ADD COLUMN cancel_at_period_end boolean default false   NOT NULL comment '期間終了時解約フラグ' AFTER member_end_date,
+    ADD KEY index_plan_id (plan_id),
+    ADD KEY index_member_end_date (member_end_date);
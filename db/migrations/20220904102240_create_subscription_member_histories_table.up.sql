@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS `subscription_member_histories`;
+CREATE TABLE `subscription_member_histories`
+(
+    id                     integer auto_increment primary key    NOT NULL comment 'ID',
+    subscription_member_id char(36)                              NOT NULL comment '定額課金ユーザID',
+    from_status            varchar(64)                           NOT NULL comment '変更前ステータス',
+    to_status              varchar(64)                           NOT NULL comment '変更後ステータス',
+    from_plan_id           char(36)                              NULL comment '変更前プランID',
+    to_plan_id             char(36)                              NULL comment '変更後プランID',
+    reason                 varchar(64)                           NOT NULL comment '変更理由',
+    created_at             timestamp   default current_timestamp NOT NULL comment '作成日時',
+    KEY index_subscription_member_id (subscription_member_id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = '定額課金ステータス変更履歴';
@@ -0,0 +1,24 @@
+DROP TABLE IF EXISTS `payments`;
+CREATE TABLE `payments`
+(
+    id              char(36)                              NOT NULL comment 'ID',
+    order_id        char(36)                              NOT NULL comment '注文ID',
+    provider        varchar(64)                           NOT NULL comment '決済プロバイダ',
+    transaction_id  varchar(128)                          NOT NULL comment 'プロバイダ取引ID',
+    amount          mediumint unsigned                    NOT NULL comment 'オーソリ金額',
+    captured_amount mediumint unsigned default 0          NOT NULL comment '売上確定金額',
+    refunded_amount mediumint unsigned default 0          NOT NULL comment '返金金額',
+    payment_status  varchar(64)                           NOT NULL comment '決済ステータス',
+    failure_reason  varchar(255)                          NULL comment '失敗理由',
+    created_at      timestamp   default current_timestamp NOT NULL comment '作成日時',
+    updated_at      timestamp   default current_timestamp NOT NULL comment '更新日時',
+    PRIMARY KEY (id),
+    KEY index_payments_on_transaction_id (transaction_id),
+    KEY index_payments_on_order_id (order_id),
+    KEY index_payments_on_payment_status (payment_status)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = '決済テーブル';
@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS `refunds`;
+CREATE TABLE `refunds`
+(
+    id          char(36)                              NOT NULL comment 'ID',
+    order_id    char(36)                              NOT NULL comment '注文ID',
+    refund_type varchar(64)                           NOT NULL comment '返金種別(refund/return)',
+    amount      mediumint unsigned                    NOT NULL comment '返金額',
+    reason      varchar(255)                          NULL comment '理由',
+    created_at  timestamp   default current_timestamp NOT NULL comment '作成日時',
+    PRIMARY KEY (id),
+    KEY index_refunds_on_order_id (order_id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = '返金テーブル';
@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS `refund_items`;
+CREATE TABLE `refund_items`
+(
+    id              char(36)           NOT NULL comment 'ID',
+    refund_id       char(36)           NOT NULL comment '返金ID',
+    order_detail_id char(36)           NOT NULL comment '注文詳細ID',
+    product_id      char(36)           NOT NULL comment '商品ID',
+    quantity        int unsigned       NOT NULL comment '数量',
+    amount          mediumint unsigned NOT NULL comment '返金額',
+    PRIMARY KEY (id),
+    KEY index_refund_items_on_refund_id (refund_id),
+    KEY index_refund_items_on_order_detail_id (order_detail_id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = '返金明細テーブル';
@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS `webhook_subscriptions`;
+CREATE TABLE `webhook_subscriptions`
+(
+    id          char(36)                              NOT NULL comment 'ID',
+    url         varchar(255)                          NOT NULL comment '送信先URL',
+    event_types varchar(255)                          NOT NULL comment '購読イベント(カンマ区切り)',
+    secret      varchar(128)                          NOT NULL comment '署名シークレット',
+    is_active   boolean     default true              NOT NULL comment '有効フラグ',
+    created_at  timestamp   default current_timestamp NOT NULL comment '作成日時',
+    updated_at  timestamp   default current_timestamp NOT NULL comment '更新日時',
+    PRIMARY KEY (id),
+    KEY index_webhook_subscriptions_on_is_active (is_active)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'Webhook購読テーブル';
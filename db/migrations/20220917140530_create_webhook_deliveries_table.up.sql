@@ -0,0 +1,24 @@
+DROP TABLE IF EXISTS `webhook_deliveries`;
+CREATE TABLE `webhook_deliveries`
+(
+    id              char(36)                              NOT NULL comment 'ID',
+    subscription_id char(36)                              NOT NULL comment 'Webhook購読ID',
+    event_id        char(36)                              NOT NULL comment 'イベントID',
+    event_type      varchar(64)                           NOT NULL comment 'イベント種別',
+    payload         text                                  NOT NULL comment '送信内容',
+    delivery_status varchar(64) default 'pending'         NOT NULL comment '配信ステータス',
+    attempts        int unsigned default 0                NOT NULL comment '試行回数',
+    next_attempt_at timestamp                             NULL comment '次回試行日時',
+    last_error      varchar(255)                          NULL comment '直近のエラー',
+    delivered_at    timestamp                             NULL comment '配信完了日時',
+    created_at      timestamp   default current_timestamp NOT NULL comment '作成日時',
+    updated_at      timestamp   default current_timestamp NOT NULL comment '更新日時',
+    PRIMARY KEY (id),
+    KEY index_webhook_deliveries_on_subscription_id (subscription_id),
+    KEY index_webhook_deliveries_on_status_and_next_attempt_at (delivery_status, next_attempt_at)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'Webhook配信キュー';
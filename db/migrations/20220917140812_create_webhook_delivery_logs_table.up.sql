@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS `webhook_delivery_logs`;
+CREATE TABLE `webhook_delivery_logs`
+(
+    id            integer auto_increment primary key  NOT NULL comment 'ID',
+    delivery_id   char(36)                            NOT NULL comment 'Webhook配信ID',
+    attempt       int unsigned                        NOT NULL comment '試行回数',
+    status_code   int unsigned default 0              NOT NULL comment 'HTTPステータス',
+    response_body text                                NULL comment 'レスポンス本文',
+    error         varchar(255)                        NULL comment 'エラー',
+    duration_ms   int unsigned default 0              NOT NULL comment '所要時間(ms)',
+    created_at    timestamp default current_timestamp NOT NULL comment '作成日時',
+    KEY index_webhook_delivery_logs_on_delivery_id (delivery_id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'Webhook配信ログ';
@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS `outbox_events`;
+CREATE TABLE `outbox_events`
+(
+    id           bigint unsigned auto_increment        NOT NULL comment 'ID',
+    topic        varchar(64)                           NOT NULL comment 'トピック',
+    aggregate_id char(36)                              NOT NULL comment '発生元のID',
+    dedup_key    varchar(255)                          NOT NULL comment '重複排除キー',
+    payload      text                                  NOT NULL comment 'イベント内容',
+    attempts     int unsigned default 0                NOT NULL comment '発行試行回数',
+    last_error   varchar(255)                          NULL comment '直近のエラー',
+    published_at timestamp                             NULL comment '発行日時',
+    created_at   timestamp    default current_timestamp NOT NULL comment '作成日時',
+    PRIMARY KEY (id),
+    UNIQUE KEY unique_outbox_events_on_dedup_key (dedup_key),
+    KEY index_outbox_events_on_published_at (published_at)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'アウトボックス';
@@ -0,0 +1,6 @@
+ALTER TABLE `issues`
+    ADD COLUMN project_id char(36) NULL comment 'プロジェクトID' AFTER user_id,
+    ADD COLUMN epic_id    integer  NULL comment 'エピックID' AFTER milestone_id,
+    ADD KEY index_project_id (project_id),
+    ADD KEY index_milestone_id (milestone_id),
+    ADD KEY index_epic_id (epic_id);
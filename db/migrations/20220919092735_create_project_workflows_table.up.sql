@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS `project_workflows`;
+CREATE TABLE `project_workflows`
+(
+    project_id char(36)                              NOT NULL comment 'プロジェクトID',
+    statuses   varchar(255)                          NOT NULL comment 'Issueステータスの並び(カンマ区切り)',
+    created_at timestamp default current_timestamp   NOT NULL comment '作成日時',
+    updated_at timestamp default current_timestamp   NOT NULL comment '更新日時',
+    PRIMARY KEY (project_id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'プロジェクトのIssueワークフロー';
@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS `comments`;
+CREATE TABLE `comments`
+(
+    id          char(36)                              NOT NULL comment 'ID',
+    target_type varchar(32)                           NOT NULL comment '対象種別(issue/epic)',
+    target_id   varchar(36)                           NOT NULL comment '対象ID',
+    user_id     char(36)                              NULL comment '投稿者ID',
+    body        text                                  NOT NULL comment '本文(markdown)',
+    created_at  timestamp default current_timestamp   NOT NULL comment '作成日時',
+    updated_at  timestamp default current_timestamp   NOT NULL comment '更新日時',
+    PRIMARY KEY (id),
+    KEY index_comments_on_target (target_type, target_id, created_at),
+    KEY index_comments_on_user_id (user_id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'コメント';
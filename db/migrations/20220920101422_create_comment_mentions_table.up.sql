@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS `comment_mentions`;
+CREATE TABLE `comment_mentions`
+(
+    id         bigint unsigned auto_increment        NOT NULL comment 'ID',
+    comment_id char(36)                              NOT NULL comment 'コメントID',
+    user_id    char(36)                              NOT NULL comment 'メンションされたユーザID',
+    email      varchar(64)                           NOT NULL comment 'メンションされたメールアドレス',
+    created_at timestamp default current_timestamp   NOT NULL comment '作成日時',
+    PRIMARY KEY (id),
+    UNIQUE KEY unique_comment_mentions_on_comment_id_and_user_id (comment_id, user_id),
+    KEY index_comment_mentions_on_user_id (user_id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'コメントのメンション';
@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS `activity_events`;
+CREATE TABLE `activity_events`
+(
+    id          bigint unsigned auto_increment        NOT NULL comment 'ID',
+    target_type varchar(32)                           NOT NULL comment '対象種別(issue/epic)',
+    target_id   varchar(36)                           NOT NULL comment '対象ID',
+    user_id     char(36)                              NULL comment '変更したユーザID',
+    field       varchar(32)                           NOT NULL comment '変更項目',
+    from_value  varchar(64)                           NULL comment '変更前',
+    to_value    varchar(64)                           NULL comment '変更後',
+    created_at  timestamp default current_timestamp   NOT NULL comment '作成日時',
+    PRIMARY KEY (id),
+    KEY index_activity_events_on_target (target_type, target_id, created_at)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'issue・epicの変更履歴';
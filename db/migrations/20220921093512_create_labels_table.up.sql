@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS `labels`;
+CREATE TABLE `labels`
+(
+    id          char(36)                              NOT NULL comment 'ID',
+    project_id  char(36)                              NOT NULL comment 'プロジェクトID',
+    name        varchar(64)                           NOT NULL comment 'ラベル名',
+    color       char(7)     default '#888888'         NOT NULL comment '表示色',
+    description varchar(255)                          NULL comment '説明',
+    created_at  timestamp   default current_timestamp NOT NULL comment '作成日時',
+    updated_at  timestamp   default current_timestamp NOT NULL comment '更新日時',
+    PRIMARY KEY (id),
+    UNIQUE KEY unique_labels_on_project_id_and_name (project_id, name)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'ラベル';
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS `epic_labels`;
+CREATE TABLE `epic_labels`
+(
+    epic_id  integer  NOT NULL comment 'エピックID',
+    label_id char(36) NOT NULL comment 'ラベルID',
+    PRIMARY KEY (epic_id, label_id),
+    KEY index_epic_labels_on_label_id (label_id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'エピックのラベル';
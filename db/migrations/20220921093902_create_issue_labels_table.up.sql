@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS `issue_labels`;
+CREATE TABLE `issue_labels`
+(
+    issue_id char(36) NOT NULL comment 'IssueID',
+    label_id char(36) NOT NULL comment 'ラベルID',
+    PRIMARY KEY (issue_id, label_id),
+    KEY index_issue_labels_on_label_id (label_id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'Issueのラベル';
@@ -0,0 +1,13 @@
+INSERT INTO `labels` (id, project_id, name, color, description, created_at, updated_at)
+SELECT UUID(), e.project_id, e.label, '#888888', NULL, NOW(), NOW()
+FROM (SELECT DISTINCT project_id, label
+      FROM `epics`
+      WHERE label IS NOT NULL
+        AND label <> ''
+        AND project_id IS NOT NULL) e
+WHERE NOT EXISTS(SELECT 1 FROM `labels` l WHERE l.project_id = e.project_id AND l.name = e.label);
+
+INSERT IGNORE INTO `epic_labels` (epic_id, label_id)
+SELECT e.id, l.id
+FROM `epics` e
+         INNER JOIN `labels` l ON l.project_id = e.project_id AND l.name = e.label;
@@ -0,0 +1,4 @@
+ALTER TABLE `milestones`
+    ADD COLUMN start_date date NULL comment '開始日' AFTER project_id,
+    ADD COLUMN due_date   date NULL comment '期日' AFTER start_date,
+    ADD KEY index_milestones_on_due_date (due_date);
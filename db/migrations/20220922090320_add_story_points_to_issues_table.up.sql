@@ -0,0 +1,2 @@
+ALTER TABLE `issues`
+    ADD COLUMN story_points int unsigned NULL comment 'ストーリーポイント' AFTER issue_status;
@@ -0,0 +1,20 @@
+DROP TABLE IF EXISTS `milestone_snapshots`;
+CREATE TABLE `milestone_snapshots`
+(
+    id            bigint unsigned auto_increment        NOT NULL comment 'ID',
+    milestone_id  char(36)                              NOT NULL comment 'マイルストーンID',
+    snapshot_date date                                  NOT NULL comment '集計日',
+    open_count    int unsigned default 0                NOT NULL comment '未完了のIssue数',
+    closed_count  int unsigned default 0                NOT NULL comment '完了したIssue数',
+    open_points   int unsigned default 0                NOT NULL comment '未完了のストーリーポイント',
+    closed_points int unsigned default 0                NOT NULL comment '完了したストーリーポイント',
+    created_at    timestamp    default current_timestamp NOT NULL comment '作成日時',
+    updated_at    timestamp    default current_timestamp NOT NULL comment '更新日時',
+    PRIMARY KEY (id),
+    UNIQUE KEY unique_milestone_snapshots_on_milestone_id_and_date (milestone_id, snapshot_date)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'マイルストーンの日次スナップショット';
@@ -0,0 +1,4 @@
+ALTER TABLE `issues`
+    ADD COLUMN board_rank varchar(255) collate utf8mb4_bin default '' NOT NULL comment 'ボード上の並び順' AFTER story_points,
+    ADD COLUMN version    int unsigned default 1                   NOT NULL comment 'バージョン(楽観ロック)' AFTER board_rank,
+    ADD KEY index_issues_on_project_id_and_status_and_board_rank (project_id, issue_status, board_rank);
@@ -0,0 +1,8 @@
+ALTER TABLE `todos`
+    ADD COLUMN due_at          DATETIME                NULL comment '期限' AFTER user_id,
+    ADD COLUMN remind_at       DATETIME                NULL comment 'リマインド日時' AFTER due_at,
+    ADD COLUMN reminded_at     DATETIME                NULL comment 'リマインド通知日時' AFTER remind_at,
+    ADD COLUMN recurrence_rule varchar(16) default ''  NOT NULL comment '繰り返し(daily/weekly/monthly)' AFTER reminded_at,
+    ADD COLUMN recurred_at     DATETIME                NULL comment '次回分の作成日時' AFTER recurrence_rule,
+    ADD KEY index_todos_on_due_at (due_at),
+    ADD KEY index_todos_on_remind_at (remind_at);
@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS `todo_checklist_items`;
+CREATE TABLE `todo_checklist_items`
+(
+    id         bigint unsigned auto_increment        NOT NULL comment 'ID',
+    todo_id    int unsigned                          NOT NULL comment 'TODO ID',
+    title      varchar(64)                           NOT NULL comment 'タイトル',
+    is_done    boolean      default false            NOT NULL comment '完了フラグ',
+    position   int          default 0                NOT NULL comment '表示順',
+    created_at timestamp    default current_timestamp NOT NULL comment '作成日時',
+    updated_at timestamp    default current_timestamp NOT NULL comment '更新日時',
+    PRIMARY KEY (id),
+    KEY index_todo_checklist_items_on_todo_id (todo_id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'TODOのチェックリスト';
@@ -0,0 +1,20 @@
+DROP TABLE IF EXISTS `notifications`;
+CREATE TABLE `notifications`
+(
+    id                bigint unsigned auto_increment        NOT NULL comment 'ID',
+    user_id           char(36)                              NOT NULL comment 'ユーザーID',
+    notification_type varchar(64)                           NOT NULL comment '通知種別',
+    title             varchar(255)                          NOT NULL comment 'タイトル',
+    body              text                                  NOT NULL comment '本文',
+    target_type       varchar(64)  default ''               NOT NULL comment '対象の種別',
+    target_id         varchar(64)  default ''               NOT NULL comment '対象のID',
+    read_at           timestamp                             NULL comment '既読日時',
+    created_at        timestamp    default current_timestamp NOT NULL comment '作成日時',
+    PRIMARY KEY (id),
+    KEY index_notifications_on_user_id_and_read_at (user_id, read_at)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = '通知';
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS `calendar_feeds`;
+CREATE TABLE `calendar_feeds`
+(
+    user_id    char(36)                              NOT NULL comment 'ユーザーID',
+    token_hash char(64)                              NOT NULL comment 'トークンのハッシュ',
+    created_at timestamp default current_timestamp NOT NULL comment '作成日時',
+    updated_at timestamp default current_timestamp NOT NULL comment '更新日時',
+    PRIMARY KEY (user_id),
+    UNIQUE KEY unique_calendar_feeds_on_token_hash (token_hash)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'カレンダー配信用トークン';
@@ -0,0 +1,11 @@
+-- 主キーを追加する前に、同じIDで重複して登録されたグループを最初に登録された1行にまとめる
+ALTER TABLE `user_groups`
+    ADD COLUMN dedup_seq int unsigned NOT NULL AUTO_INCREMENT UNIQUE;
+DELETE duplicated
+FROM `user_groups` duplicated
+         JOIN `user_groups` kept ON kept.id = duplicated.id AND kept.dedup_seq < duplicated.dedup_seq;
+ALTER TABLE `user_groups`
+    DROP COLUMN dedup_seq,
+    ADD PRIMARY KEY (id),
+    ADD COLUMN parent_id char(36) NULL comment '親グループID' AFTER group_name,
+    ADD KEY index_user_groups_on_parent_id (parent_id);
@@ -0,0 +1,8 @@
+-- 一意キーを追加する前に、同じグループへの重複した所属を最初に登録された1行にまとめる
+DELETE duplicated
+FROM `group_user` duplicated
+         JOIN `group_user` kept
+              ON kept.group_id = duplicated.group_id AND kept.user_id = duplicated.user_id AND kept.id < duplicated.id;
+ALTER TABLE `group_user`
+    ADD COLUMN role varchar(16) default 'member' NOT NULL comment 'グループ内の役割(owner/member)' AFTER user_id,
+    ADD UNIQUE KEY unique_group_user_on_group_id_and_user_id (group_id, user_id);
@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS `organizations`;
+CREATE TABLE `organizations`
+(
+    id         char(36)                            NOT NULL comment 'ID',
+    name       varchar(64)                         NOT NULL comment '組織名',
+    created_at timestamp default current_timestamp NOT NULL comment '作成日時',
+    updated_at timestamp default current_timestamp NOT NULL comment '更新日時',
+    PRIMARY KEY (id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = '組織';
+
+-- 組織の導入前から存在するデータはこの組織に所属させる
+INSERT INTO `organizations` (id, name, created_at, updated_at)
+VALUES ('00000000-0000-4000-8000-000000000001', 'default', NOW(), NOW());
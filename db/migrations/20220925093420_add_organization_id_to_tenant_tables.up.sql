@@ -0,0 +1,58 @@
+-- 既存のレコードと組織を指定せずに作成したレコードはデフォルトの組織に所属させる
+ALTER TABLE `users`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_users_on_organization_id (organization_id);
+ALTER TABLE `todos`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_todos_on_organization_id (organization_id);
+ALTER TABLE `products`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_products_on_organization_id (organization_id);
+ALTER TABLE `orders`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_orders_on_organization_id (organization_id);
+ALTER TABLE `order_details`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_order_details_on_organization_id (organization_id);
+ALTER TABLE `coupons`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_coupons_on_organization_id (organization_id);
+ALTER TABLE `user_groups`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_user_groups_on_organization_id (organization_id);
+ALTER TABLE `milestones`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_milestones_on_organization_id (organization_id);
+ALTER TABLE `epics`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_epics_on_organization_id (organization_id);
+ALTER TABLE `projects`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_projects_on_organization_id (organization_id);
+ALTER TABLE `issues`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_issues_on_organization_id (organization_id);
+ALTER TABLE `labels`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_labels_on_organization_id (organization_id);
+ALTER TABLE `comments`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_comments_on_organization_id (organization_id);
+ALTER TABLE `subscription_plans`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_subscription_plans_on_organization_id (organization_id);
+ALTER TABLE `subscription_members`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_subscription_members_on_organization_id (organization_id);
+ALTER TABLE `payments`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_payments_on_organization_id (organization_id);
+ALTER TABLE `refunds`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_refunds_on_organization_id (organization_id);
+ALTER TABLE `webhook_subscriptions`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_webhook_subscriptions_on_organization_id (organization_id);
+ALTER TABLE `webhook_deliveries`
+    ADD COLUMN organization_id char(36) default '00000000-0000-4000-8000-000000000001' NOT NULL comment '組織ID' AFTER id,
+    ADD KEY index_webhook_deliveries_on_organization_id (organization_id);
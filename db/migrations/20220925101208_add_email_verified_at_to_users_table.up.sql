@@ -0,0 +1,7 @@
+ALTER TABLE `users`
+    ADD COLUMN email_verified_at timestamp NULL comment 'メールアドレス確認日時' AFTER password;
+
+-- メールアドレスの確認を導入する前に登録したユーザーは確認済みとして扱う
+UPDATE `users`
+SET email_verified_at = created_at
+WHERE email_verified_at IS NULL;
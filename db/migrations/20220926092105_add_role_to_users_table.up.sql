@@ -0,0 +1,15 @@
+ALTER TABLE `users`
+    ADD COLUMN role varchar(16) default 'member' NOT NULL comment '組織内の役割(admin/member)' AFTER email_verified_at;
+
+-- 組織を管理できるユーザーがいなくならないよう、組織ごとに最初に登録されたユーザーを管理者にする
+-- 他の既存のユーザーはメンバーのままにする。登録日時が同じ場合はIDの小さいユーザーを選ぶ
+UPDATE `users` u
+    JOIN (SELECT MIN(candidates.id) AS id
+          FROM `users` candidates
+                   JOIN (SELECT organization_id, MIN(created_at) AS created_at
+                         FROM `users`
+                         GROUP BY organization_id) oldest
+                        ON oldest.organization_id = candidates.organization_id
+                            AND oldest.created_at = candidates.created_at
+          GROUP BY candidates.organization_id) admins ON admins.id = u.id
+SET u.role = 'admin';
@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS `login_attempts`;
+CREATE TABLE `login_attempts`
+(
+    id             bigint unsigned auto_increment      NOT NULL comment 'ID',
+    user_id        char(36)                            NULL comment 'ユーザーID',
+    email          varchar(255)                        NOT NULL comment '入力されたメールアドレス',
+    ip_address     varchar(45)                         NOT NULL comment 'IPアドレス',
+    user_agent     varchar(255)                        NOT NULL comment 'User-Agent',
+    succeeded      tinyint(1)                          NOT NULL comment '成功したかどうか',
+    failure_reason varchar(32)                         NOT NULL default '' comment '失敗理由',
+    created_at     timestamp default current_timestamp NOT NULL comment '作成日時',
+    PRIMARY KEY (id),
+    KEY index_login_attempts_on_user_id_and_created_at (user_id, created_at),
+    KEY index_login_attempts_on_email_and_created_at (email, created_at),
+    KEY index_login_attempts_on_ip_address_and_created_at (ip_address, created_at)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'ログイン試行の監査記録';
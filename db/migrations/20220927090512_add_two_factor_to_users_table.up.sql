@@ -0,0 +1,4 @@
+ALTER TABLE `users`
+    ADD COLUMN two_factor_secret     varchar(64) default '' NOT NULL comment 'TOTPの秘密鍵(Base32)' AFTER role,
+    ADD COLUMN two_factor_last_step  bigint      default 0  NOT NULL comment '最後に使われたTOTPのステップ番号' AFTER two_factor_secret,
+    ADD COLUMN two_factor_enabled_at timestamp              NULL comment '2段階認証を有効にした日時' AFTER two_factor_last_step;
@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS `recovery_codes`;
+CREATE TABLE `recovery_codes`
+(
+    id         bigint unsigned auto_increment      NOT NULL comment 'ID',
+    user_id    char(36)                            NOT NULL comment 'ユーザーID',
+    code_hash  char(64)                            NOT NULL comment 'コードのSHA-256ハッシュ',
+    used_at    timestamp                           NULL comment '使用日時',
+    created_at timestamp default current_timestamp NOT NULL comment '作成日時',
+    PRIMARY KEY (id),
+    UNIQUE KEY index_recovery_codes_on_user_id_and_code_hash (user_id, code_hash)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = '2段階認証のリカバリーコード';
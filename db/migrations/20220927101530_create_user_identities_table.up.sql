@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS `user_identities`;
+CREATE TABLE `user_identities`
+(
+    id         bigint unsigned auto_increment      NOT NULL comment 'ID',
+    user_id    char(36)                            NOT NULL comment 'ユーザーID',
+    provider   varchar(64)                         NOT NULL comment 'IDプロバイダー名',
+    subject    varchar(255)                        NOT NULL comment 'IDプロバイダーでのユーザー識別子(sub)',
+    email      varchar(255)                        NOT NULL comment '最後のログイン時のメールアドレス',
+    created_at timestamp default current_timestamp NOT NULL comment '作成日時',
+    updated_at timestamp default current_timestamp NOT NULL comment '更新日時',
+    PRIMARY KEY (id),
+    UNIQUE KEY index_user_identities_on_provider_and_subject (provider, subject),
+    KEY index_user_identities_on_user_id (user_id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = '外部IDプロバイダーのアカウントとユーザーの紐付け';
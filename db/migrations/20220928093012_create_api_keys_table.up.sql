@@ -0,0 +1,24 @@
+DROP TABLE IF EXISTS `api_keys`;
+CREATE TABLE `api_keys`
+(
+    id              char(36)                            NOT NULL comment 'ID',
+    organization_id char(36)                            NOT NULL comment '組織ID',
+    user_id         char(36)                            NOT NULL comment '発行したユーザーID',
+    name            varchar(64)                         NOT NULL comment '名前',
+    prefix          char(8)                             NOT NULL comment 'キーを見分けるための先頭部分',
+    key_hash        char(64)                            NOT NULL comment 'キーのSHA-256ハッシュ',
+    scopes          varchar(64)                         NOT NULL comment 'カンマ区切りのスコープ(read/write/admin)',
+    expires_at      timestamp                           NULL comment '有効期限',
+    last_used_at    timestamp                           NULL comment '最終利用日時',
+    revoked_at      timestamp                           NULL comment '失効日時',
+    created_at      timestamp default current_timestamp NOT NULL comment '作成日時',
+    updated_at      timestamp default current_timestamp NOT NULL comment '更新日時',
+    PRIMARY KEY (id),
+    UNIQUE KEY index_api_keys_on_prefix (prefix),
+    KEY index_api_keys_on_organization_id_and_user_id (organization_id, user_id)
+) ENGINE = InnoDB
+  DEFAULT character
+      set = 'utf8mb4'
+  collate = 'utf8mb4_general_ci'
+    COMMENT
+        = 'システム連携用のAPIキー';
@@ -0,0 +1,9 @@
+-- 同時に行われた更新の上書きを防ぐため、更新するたびに1つ進め、ETagにも使う
+ALTER TABLE `users`
+    ADD COLUMN version int unsigned default 1 NOT NULL comment 'バージョン(楽観ロック)' AFTER two_factor_enabled_at;
+ALTER TABLE `coupons`
+    ADD COLUMN version int unsigned default 1 NOT NULL comment 'バージョン(楽観ロック)' AFTER is_premium;
+ALTER TABLE `orders`
+    ADD COLUMN version int unsigned default 1 NOT NULL comment 'バージョン(楽観ロック)' AFTER remarks;
+ALTER TABLE `projects`
+    ADD COLUMN version int unsigned default 1 NOT NULL comment 'バージョン(楽観ロック)' AFTER project_description;
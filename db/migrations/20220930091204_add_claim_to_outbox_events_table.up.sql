@@ -0,0 +1,5 @@
+-- 複数のリレーが同じイベントを発行しないよう、発行前に取得したリレーを記録する
+ALTER TABLE `outbox_events`
+    ADD COLUMN claim_token   char(36)  NULL comment '取得したリレーの識別子' AFTER last_error,
+    ADD COLUMN claimed_until timestamp NULL comment '取得の有効期限' AFTER claim_token,
+    ADD KEY index_outbox_events_on_claim_token (claim_token);
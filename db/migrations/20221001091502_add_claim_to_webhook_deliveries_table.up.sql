@@ -0,0 +1,5 @@
+-- 複数の配信処理が同じWebhookを送信しないよう、送信前に取得した配信処理を記録する
+ALTER TABLE `webhook_deliveries`
+    ADD COLUMN claim_token   char(36)  NULL comment '取得した配信処理の識別子' AFTER delivered_at,
+    ADD COLUMN claimed_until timestamp NULL comment '取得の有効期限' AFTER claim_token,
+    ADD KEY index_webhook_deliveries_on_claim_token (claim_token);
@@ -0,0 +1,4 @@
+-- 決済プロバイダでの返金をトランザクションの外で行うため、返金の進捗を記録する
+ALTER TABLE `refunds`
+    ADD COLUMN refund_status varchar(64) default 'completed' NOT NULL comment '返金ステータス(pending/completed/failed)' AFTER refund_type,
+    ADD KEY index_refunds_on_order_id_and_refund_status (order_id, refund_status);
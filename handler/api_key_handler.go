@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/apikey"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+type APIKeyHandler struct {
+	Db            *gorm.DB
+	logger        *zap.Logger
+	uuidGenerator models.UUIDGenerator
+}
+
+func NewAPIKeyHandler(db *gorm.DB, logger *zap.Logger, uuidGenerator models.UUIDGenerator) *APIKeyHandler {
+	return &APIKeyHandler{
+		Db:            db,
+		logger:        logger,
+		uuidGenerator: uuidGenerator,
+	}
+}
+
+type createAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=64" example:"nightly batch"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1" example:"read"`
+	ExpiresAt *time.Time `json:"expires_at" example:"2023-01-01T00:00:00+09:00"`
+}
+
+type apiKeyCreatedResponse struct {
+	models.APIKey
+	// Key 作成時のみ返却されるAPIキー。"Authorization: ApiKey <Key>" で使う
+	Key string `json:"key" example:"ak_0123abcd_xxxx"`
+}
+
+type apiKeysResponse struct {
+	Total   int             `json:"total"`
+	APIKeys []models.APIKey `json:"api_keys"`
+}
+
+// GetAPIKeys @title APIキー一覧
+// @id GetAPIKeys
+// @tags apiKeys
+// @version バージョン(1.0)
+// @description 自分が発行したAPIキーの一覧を取得する。キーそのものは含まない
+// @Summary APIキー一覧取得
+// @Produce json
+// @Success 200 {object} apiKeysResponse
+// @Failure 500 {object} errorResponse
+// @Router /apiKeys [GET]
+func (h *APIKeyHandler) GetAPIKeys(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	keys := []models.APIKey{}
+	if err := tenantDB(ctx, h.Db).Where("user_id = ?", appcontext.GetUserID(ctx)).
+		Order("created_at desc").Find(&keys).Error; err != nil {
+		h.logger.Error("failed to get api keys", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get api keys", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, apiKeysResponse{
+		Total:   len(keys),
+		APIKeys: keys,
+	})
+}
+
+// CreateAPIKey @title APIキー発行
+// @id CreateAPIKey
+// @tags apiKeys
+// @version バージョン(1.0)
+// @description 自分として呼び出すAPIキーを発行する。キーは作成時のレスポンスでのみ返却される
+// @description スコープはread(参照)、write(作成・更新・削除)、admin(管理者の操作)。adminは管理者のみ指定できる
+// @description APIキーでの認証中はAPIキーを発行できない
+// @Summary APIキー発行
+// @Produce json
+// @Success 201 {object} apiKeyCreatedResponse
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /apiKeys [POST]
+// @Accept json
+// @Param createAPIKeyRequest body createAPIKeyRequest true "create api key"
+func (h *APIKeyHandler) CreateAPIKey(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	if appcontext.GetAPIKeyID(ctx) != "" {
+		ctx.JSON(http.StatusForbidden, errors.NewForbiddenError("api keys cannot be created with an api key"))
+		return
+	}
+	var req createAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if !apikey.ValidScopes(req.Scopes) {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid scopes"))
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("expires_at must be in the future"))
+		return
+	}
+	if apikey.HasScope(req.Scopes, apikey.ScopeAdmin) {
+		var user models.User
+		if err := tenantDB(ctx, h.Db).Where("id = ?", appcontext.GetUserID(ctx)).First(&user).Error; err != nil {
+			h.logger.Error("failed to get user", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get user", err))
+			return
+		}
+		if !user.IsAdmin() {
+			ctx.JSON(http.StatusForbidden, errors.NewForbiddenError("admin scope requires admin role"))
+			return
+		}
+	}
+
+	key, prefix, hash, err := apikey.Generate()
+	if err != nil {
+		h.logger.Error("failed to generate api key", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create api key", err))
+		return
+	}
+	apiKey := models.APIKey{
+		ID:        h.uuidGenerator.GenerateUUID(),
+		UserID:    appcontext.GetUserID(ctx),
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    strings.Join(req.Scopes, ","),
+		ExpiresAt: req.ExpiresAt,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := tenantDB(ctx, h.Db).Create(&apiKey).Error; err != nil {
+		h.logger.Error("failed to create api key", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create api key", err))
+		return
+	}
+	ctx.JSON(http.StatusCreated, apiKeyCreatedResponse{
+		APIKey: apiKey,
+		Key:    key,
+	})
+}
+
+// RevokeAPIKey @title APIキー失効
+// @id RevokeAPIKey
+// @tags apiKeys
+// @version バージョン(1.0)
+// @description 自分が発行したAPIキーを失効させる。失効したキーでは認証できない
+// @Summary APIキー失効
+// @Produce json
+// @Success 200 {object} models.APIKey
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /apiKeys/:id [DELETE]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *APIKeyHandler) RevokeAPIKey(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var apiKey models.APIKey
+	if err := tenantDB(ctx, h.Db).Where("id = ? AND user_id = ?", ctx.Param("id"), appcontext.GetUserID(ctx)).
+		First(&apiKey).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("api key not found"))
+			return
+		}
+		h.logger.Error("failed to get api key", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get api key", err))
+		return
+	}
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		apiKey.RevokedAt = &now
+		apiKey.UpdatedAt = now
+		if err := tenantDB(ctx, h.Db).Save(&apiKey).Error; err != nil {
+			h.logger.Error("failed to revoke api key", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to revoke api key", err))
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, apiKey)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/middleware"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/session"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	util "github.com/AI1411/golang-admin-api/util/jwt"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE users")
+	dbConn.Exec("TRUNCATE TABLE api_keys")
+	dbConn.Exec("insert into users (id, organization_id, first_name, last_name, age, email, password, role, created_at, updated_at)values('7b1e4c2d-3a5f-4e6b-8c9d-0e1f2a3b4c01','00000000-0000-4000-8000-000000000001','a','a',20,'a@example.com','','member','2022-09-28 10:00:00','2022-09-28 10:00:00');")
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	apiKeyHandler := NewAPIKeyHandler(dbConn, zapLogger, &models.RandomUUIDGenerator{})
+
+	// APIキーの発行はJWTで認証済みのユーザーとして行う
+	authorized := gin.New()
+	authorized.Use(middleware.NewTracing())
+	authorized.Use(func(ctx *gin.Context) { appcontext.SetUserIDIntoContext(ctx, userAIDForTest) })
+	authorized.Use(middleware.ResolveOrganization(dbConn))
+	authorized.POST("/apiKeys", apiKeyHandler.CreateAPIKey)
+	authorized.DELETE("/apiKeys/:id", apiKeyHandler.RevokeAPIKey)
+
+	r := gin.New()
+	r.Use(middleware.NewTracing())
+	r.Use(middleware.AuthenticateBearer(dbConn,
+		session.NewManager(session.NewMemoryStore(), session.DefaultCookieConfig, util.TokenLifetime)))
+	r.Use(middleware.ResolveOrganization(dbConn))
+	r.GET("/apiKeys", apiKeyHandler.GetAPIKeys)
+	r.POST("/apiKeys", apiKeyHandler.CreateAPIKey)
+	r.GET("/admin", middleware.RequireAdmin(dbConn), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	createKey := func(t *testing.T, scopes ...string) apiKeyCreatedResponse {
+		t.Helper()
+		jsonStr, _ := json.Marshal(map[string]interface{}{"name": "batch", "scopes": scopes})
+		rec := httptest.NewRecorder()
+		authorized.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/apiKeys", bytes.NewBuffer(jsonStr)))
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var res apiKeyCreatedResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res
+	}
+	request := func(method, path, authorization string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(`{"name":"x","scopes":["read"]}`))
+		req.Header.Set("Authorization", authorization)
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	readKey := createKey(t, "read")
+
+	t.Run("APIキーで認証できること", func(t *testing.T) {
+		rec := request(http.MethodGet, "/apiKeys", "ApiKey "+readKey.Key)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var res apiKeysResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		require.Equal(t, 1, res.Total)
+		assert.Equal(t, readKey.Prefix, res.APIKeys[0].Prefix)
+		assert.NotNil(t, res.APIKeys[0].LastUsedAt)
+	})
+
+	t.Run("readスコープでは更新系のリクエストを行えないこと", func(t *testing.T) {
+		rec := request(http.MethodPost, "/apiKeys", "ApiKey "+readKey.Key)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("APIキーでAPIキーを発行できないこと", func(t *testing.T) {
+		writeKey := createKey(t, "write")
+		rec := request(http.MethodPost, "/apiKeys", "ApiKey "+writeKey.Key)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("管理者でないユーザーはadminスコープを指定できないこと", func(t *testing.T) {
+		jsonStr, _ := json.Marshal(map[string]interface{}{"name": "batch", "scopes": []string{"admin"}})
+		rec := httptest.NewRecorder()
+		authorized.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/apiKeys", bytes.NewBuffer(jsonStr)))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("管理者の操作は権限の確認を経ること", func(t *testing.T) {
+		rec := request(http.MethodGet, "/admin", "ApiKey "+readKey.Key)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("不正なキーは401になること", func(t *testing.T) {
+		rec := request(http.MethodGet, "/apiKeys", "ApiKey "+readKey.Key[:len(readKey.Key)-1]+"x")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		rec = request(http.MethodGet, "/apiKeys", "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("失効したキーは401になること", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		authorized.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/apiKeys/"+readKey.ID, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		rec = request(http.MethodGet, "/apiKeys", "ApiKey "+readKey.Key)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
@@ -1,14 +1,19 @@
 package handler
 
 import (
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/authtoken"
+	"github.com/AI1411/golang-admin-api/loginguard"
+	"github.com/AI1411/golang-admin-api/mailer"
+	"github.com/AI1411/golang-admin-api/session"
 
 	"github.com/AI1411/golang-admin-api/util/appcontext"
-	"github.com/AI1411/golang-admin-api/util/redis"
 	"go.uber.org/zap"
 
 	"github.com/dgrijalva/jwt-go"
@@ -18,6 +23,7 @@ import (
 	"github.com/AI1411/golang-admin-api/models"
 	"github.com/AI1411/golang-admin-api/util/errors"
 	util "github.com/AI1411/golang-admin-api/util/jwt"
+	"github.com/AI1411/golang-admin-api/webhook"
 )
 
 type Claims struct {
@@ -27,12 +33,25 @@ type Claims struct {
 type AuthHandler struct {
 	Db     *gorm.DB
 	logger *zap.Logger
+	mailer mailer.Mailer
+	tokens *authtoken.Issuer
+	guard  *loginguard.Guard
+	// sessions ログインごとのセッション。JWTのjtiで参照し、削除するとそのJWTは使えなくなる
+	sessions *session.Manager
+	// baseURL メールに記載する確認・再設定画面のURLの起点
+	baseURL string
 }
 
-func NewAuthHandler(db *gorm.DB, logger *zap.Logger) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, logger *zap.Logger, m mailer.Mailer, tokens *authtoken.Issuer,
+	guard *loginguard.Guard, sessions *session.Manager, baseURL string) *AuthHandler {
 	return &AuthHandler{
-		Db:     db,
-		logger: logger,
+		Db:       db,
+		logger:   logger,
+		mailer:   m,
+		tokens:   tokens,
+		guard:    guard,
+		sessions: sessions,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
 	}
 }
 
@@ -43,11 +62,40 @@ type authRequest struct {
 	Email                string `json:"email" binding:"required"`
 	Password             string `json:"password" binding:"required"`
 	PasswordConfirmation string `json:"password_confirmation" binding:"required"`
+	OrganizationName     string `json:"organization_name" binding:"omitempty,max=64"`
 }
 
 type loginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" binding:"required,max=255"`
+	Password string `json:"password" binding:"required,max=255"`
+}
+
+type searchLoginAttemptParams struct {
+	Offset string `form:"offset,default=0" binding:"omitempty,numeric"`
+	Limit  string `form:"limit,default=20" binding:"omitempty,numeric"`
+}
+
+type loginAttemptsResponse struct {
+	Total         int                   `json:"total"`
+	LoginAttempts []models.LoginAttempt `json:"login_attempts"`
+}
+
+type updateUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin member" example:"member"`
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type emailRequest struct {
+	Email string `json:"email" binding:"required,email,max=64"`
+}
+
+type resetPasswordRequest struct {
+	Token                string `json:"token" binding:"required"`
+	Password             string `json:"password" binding:"required,min=8,max=64"`
+	PasswordConfirmation string `json:"password_confirmation" binding:"required"`
 }
 
 type meRequest struct {
@@ -72,24 +120,72 @@ func (h *AuthHandler) Register(ctx *gin.Context) {
 		return
 	}
 
-	user := models.User{
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		Age:       req.Age,
-		Email:     req.Email,
+	// 登録したユーザーは新しい組織の最初のメンバーになる
+	organization := models.Organization{
+		Name:      req.OrganizationName,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
+	organization.CreateUUID()
+	if organization.Name == "" {
+		organization.Name = req.Email
+	}
+	user := models.User{
+		OrganizationID: organization.ID,
+		Role:           models.UserRoleAdmin,
+		FirstName:      req.FirstName,
+		LastName:       req.LastName,
+		Age:            req.Age,
+		Email:          req.Email,
+		Version:        1,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
 	user.CreateUUID()
 	user.SetPassword(req.Password)
-	if err := h.Db.Create(&user).Error; err != nil {
+	if err := h.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&organization).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return webhook.Enqueue(tx.Scopes(models.OrganizationScope(organization.ID)), webhook.EventUserRegistered, webhook.UserRegistered{
+			UserID:    user.ID,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Email:     user.Email,
+		})
+	}); err != nil {
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("user failed to register", err))
 		return
 	}
+	// 確認メールを送れなくても再送できるため、登録は成功として扱う
+	if err := h.sendLinkMail(ctx, &user, authtoken.PurposeVerifyEmail); err != nil {
+		h.logger.Error("failed to send verification email", zap.Error(err),
+			zap.String("trace_id", traceID))
+	}
 
 	ctx.JSON(http.StatusOK, user)
 }
 
+// Login @title ログイン
+// @id Login
+// @tags auth
+// @version バージョン(1.0)
+// @description メールアドレスとパスワードで認証する。アカウントの有無が分からないよう、失敗時は常に同じ応答を返す
+// @description 失敗が続くと応答を遅らせ、上限に達したアカウント・IPアドレスは一定時間ロックする
+// @description 2段階認証を有効にしている場合はJWTの代わりにchallenge_tokenを返すため、/auth/2fa/verifyで認証コードを送る
+// @Summary ログイン
+// @Produce json
+// @Success 200
+// @Failure 400 {object} errorResponse
+// @Failure 401 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Router /auth/login [POST]
+// @Accept json
+// @Param loginRequest body loginRequest true "login"
 func (h *AuthHandler) Login(ctx *gin.Context) {
 	traceID := appcontext.GetTraceID(ctx)
 	var req loginRequest
@@ -101,65 +197,137 @@ func (h *AuthHandler) Login(ctx *gin.Context) {
 		return
 	}
 
-	var user models.User
-
-	err := h.Db.Where("email = ?", req.Email).First(&user).Error
-
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		ctx.JSON(http.StatusNotFound, gin.H{
-			"message": "ユーザが見つかりませんでした",
+	email := loginguard.NormalizeEmail(req.Email)
+	attempt := newLoginAttempt(ctx, email)
+	// Redisに接続できない場合もログインできなくならないよう、制限せずに続ける
+	decision, err := h.guard.Check(ctx, email, attempt.IPAddress)
+	if err != nil {
+		h.logger.Error("failed to check login attempts", zap.Error(err),
+			zap.String("trace_id", traceID))
+	}
+	if decision.Locked {
+		attempt.FailureReason = models.LoginFailureLocked
+		h.recordLoginAttempt(traceID, &attempt)
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{
+			"message": "ログインの失敗が続いたため、しばらくしてから再度お試しください",
 		})
 		return
 	}
+	if err := loginguard.Sleep(ctx.Request.Context(), decision.Delay); err != nil {
+		return
+	}
 
-	if err = user.ComparePassword(req.Password); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"message": "パスワードが間違っています",
+	var user models.User
+	err = h.Db.Where("email = ?", email).First(&user).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// 応答時間からアカウントの有無が分からないよう、存在しない場合もパスワードを比較する
+		models.CompareDummyPassword(req.Password)
+		attempt.FailureReason = models.LoginFailureUserNotFound
+	case err != nil:
+		h.logger.Error("failed to get user", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get user", err))
+		return
+	default:
+		attempt.UserID = &user.ID
+		if user.ComparePassword(req.Password) != nil {
+			attempt.FailureReason = models.LoginFailureInvalidPassword
+		}
+	}
+	if attempt.FailureReason != "" {
+		if _, err := h.guard.Fail(ctx, email, attempt.IPAddress); err != nil {
+			h.logger.Error("failed to record login failure", zap.Error(err),
+				zap.String("trace_id", traceID))
+		}
+		h.recordLoginAttempt(traceID, &attempt)
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"message": "メールアドレスまたはパスワードが間違っています",
 		})
 		return
 	}
 
-	token, err := util.GenerateJwt(user.ID)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"message": "認証に失敗しました",
+	if err := h.guard.Succeed(ctx, email); err != nil {
+		h.logger.Error("failed to reset login failures", zap.Error(err),
+			zap.String("trace_id", traceID))
+	}
+	if !user.IsEmailVerified() {
+		attempt.FailureReason = models.LoginFailureEmailNotVerified
+		h.recordLoginAttempt(traceID, &attempt)
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"message": "メールアドレスが確認されていません",
 		})
 		return
 	}
-	redis.NewSession(ctx, "jwt", token)
 
+	h.respondLogin(ctx, traceID, &user, &attempt)
+}
+
+// respondLogin 1段階目の認証に成功したユーザーのログインを進める
+// 2段階認証が済むまでJWTは発行せず、認証コードの入力に使うトークンを返す
+func (h *AuthHandler) respondLogin(ctx *gin.Context, traceID string, user *models.User, attempt *models.LoginAttempt) {
+	if !user.IsTwoFactorEnabled() {
+		h.completeLogin(ctx, traceID, user, attempt)
+		return
+	}
+	challenge, err := h.tokens.Issue(ctx, authtoken.PurposeTwoFactorLogin, user.ID, authtoken.TwoFactorChallengeTTL)
+	if err != nil {
+		h.logger.Error("failed to issue two factor challenge", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to issue two factor challenge", err))
+		return
+	}
 	ctx.JSON(http.StatusOK, gin.H{
-		"message": "認証に成功しました",
-		"value":   token,
-		"user":    user,
+		"message":             "認証コードを入力してください",
+		"two_factor_required": true,
+		"challenge_token":     challenge,
 	})
 }
 
-func (h *AuthHandler) Me(ctx *gin.Context) {
-	token := ctx.GetHeader("Authorization")
-	bearerToken := strings.Split(token, " ")[1]
-
-	id, err := util.ParseJwt(bearerToken)
+// completeLogin JWTを発行してログインを完了する
+func (h *AuthHandler) completeLogin(ctx *gin.Context, traceID string, user *models.User, attempt *models.LoginAttempt) {
+	sess, err := h.sessions.Start(ctx, user.ID)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, gin.H{
-			"message": "unauthorized!",
-		})
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+		h.logger.Error("failed to start session", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to start session", err))
 		return
 	}
-
-	if id == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{
-			"message": "unauthorized!",
+	token, err := util.GenerateJwt(user.ID, user.OrganizationID, sess.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": "認証に失敗しました",
 		})
-		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
+	attempt.Succeeded = true
+	h.recordLoginAttempt(traceID, attempt)
 
-	var user models.User
-	dbConn := db.Init()
+	res := gin.H{
+		"message": "認証に成功しました",
+		"value":   token,
+		"user":    user,
+	}
+	// 管理者の操作は2段階認証を有効にするまで行えないため、設定を促す
+	if user.RequiresTwoFactor() && !user.IsTwoFactorEnabled() {
+		res["two_factor_setup_required"] = true
+	}
+	ctx.JSON(http.StatusOK, res)
+}
 
-	if err := dbConn.Where("id = ?", id).First(&user).Error; err != nil {
+func newLoginAttempt(ctx *gin.Context, email string) models.LoginAttempt {
+	return models.LoginAttempt{
+		Email:     email,
+		IPAddress: ctx.ClientIP(),
+		UserAgent: truncateRunes(ctx.Request.UserAgent(), 255),
+		CreatedAt: time.Now(),
+	}
+}
+
+func (h *AuthHandler) Me(ctx *gin.Context) {
+	var user models.User
+	if err := h.Db.Where("id = ?", appcontext.GetUserID(ctx)).First(&user).Error; err != nil {
 		if gorm.IsRecordNotFoundError(err) {
 			ctx.AbortWithStatusJSON(http.StatusNotFound, errors.NewNotFoundError("user not found"))
 			return
@@ -174,11 +342,391 @@ func (h *AuthHandler) Me(ctx *gin.Context) {
 	})
 }
 
+// Logout Cookie、なければBearerトークンのjtiのセッションを削除する
 func (h *AuthHandler) Logout(ctx *gin.Context) {
-	if err := redis.DeleteSession(ctx, "jwt"); err != nil {
-		h.logger.Error("failed to delete session", zap.Error(err))
+	id := h.sessions.CookieSessionID(ctx)
+	if id == "" {
+		id = bearerSessionID(ctx)
+	}
+	if err := h.sessions.End(ctx, id); err != nil {
+		h.logger.Error("failed to delete session", zap.Error(err),
+			zap.String("trace_id", appcontext.GetTraceID(ctx)))
 	}
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "logout!",
 	})
 }
+
+// bearerSessionID Authorizationヘッダーのjwtのjtiを返す。検証に失敗した場合は空文字を返す
+func bearerSessionID(ctx *gin.Context) string {
+	fields := strings.Fields(ctx.GetHeader("Authorization"))
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "Bearer") {
+		return ""
+	}
+	claims, _ := util.ParseJwtClaims(fields[1])
+	if claims == nil {
+		return ""
+	}
+	return claims.Id
+}
+
+// VerifyEmail @title メールアドレス確認
+// @id VerifyEmail
+// @tags auth
+// @version バージョン(1.0)
+// @description 確認メールのトークンを検証し、メールアドレスを確認済みにする。トークンは一度だけ使える
+// @Summary メールアドレス確認
+// @Produce json
+// @Success 200
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /auth/verify-email [POST]
+// @Accept json
+// @Param verifyEmailRequest body verifyEmailRequest true "verify email"
+func (h *AuthHandler) VerifyEmail(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req verifyEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	userID, ok := h.consumeToken(ctx, traceID, authtoken.PurposeVerifyEmail, req.Token)
+	if !ok {
+		return
+	}
+	if err := h.Db.Table("users").Where("id = ? AND email_verified_at IS NULL", userID).
+		Updates(map[string]interface{}{"email_verified_at": time.Now(), "version": gorm.Expr("version + 1"), "updated_at": time.Now()}).Error; err != nil {
+		h.logger.Error("failed to verify email", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to verify email", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "メールアドレスを確認しました",
+	})
+}
+
+// ResendVerificationEmail @title 確認メール再送
+// @id ResendVerificationEmail
+// @tags auth
+// @version バージョン(1.0)
+// @description 未確認のユーザーに確認メールを再送する。登録の有無が分からないよう、常に202を返す
+// @Summary 確認メール再送
+// @Produce json
+// @Success 202
+// @Failure 400 {object} errorResponse
+// @Router /auth/verify-email/resend [POST]
+// @Accept json
+// @Param emailRequest body emailRequest true "resend verification email"
+func (h *AuthHandler) ResendVerificationEmail(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req emailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	var user models.User
+	if err := h.Db.Where("email = ?", req.Email).First(&user).Error; err == nil && !user.IsEmailVerified() {
+		if err := h.sendLinkMail(ctx, &user, authtoken.PurposeVerifyEmail); err != nil {
+			h.logger.Error("failed to send verification email", zap.Error(err),
+				zap.String("trace_id", traceID))
+		}
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"message": "確認メールを送信しました",
+	})
+}
+
+// ForgotPassword @title パスワード再設定メール送信
+// @id ForgotPassword
+// @tags auth
+// @version バージョン(1.0)
+// @description パスワード再設定用のメールを送信する。登録の有無が分からないよう、常に202を返す
+// @Summary パスワード再設定メール送信
+// @Produce json
+// @Success 202
+// @Failure 400 {object} errorResponse
+// @Router /auth/password/forgot [POST]
+// @Accept json
+// @Param emailRequest body emailRequest true "forgot password"
+func (h *AuthHandler) ForgotPassword(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req emailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	var user models.User
+	if err := h.Db.Where("email = ?", req.Email).First(&user).Error; err == nil {
+		if err := h.sendLinkMail(ctx, &user, authtoken.PurposePasswordReset); err != nil {
+			h.logger.Error("failed to send password reset email", zap.Error(err),
+				zap.String("trace_id", traceID))
+		}
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"message": "パスワード再設定メールを送信しました",
+	})
+}
+
+// ResetPassword @title パスワード再設定
+// @id ResetPassword
+// @tags auth
+// @version バージョン(1.0)
+// @description 再設定メールのトークンを検証し、パスワードを変更する。トークンは一度だけ使え、メールアドレスも確認済みになる
+// @description 既存のセッションは全て無効になる
+// @Summary パスワード再設定
+// @Produce json
+// @Success 200
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /auth/password/reset [POST]
+// @Accept json
+// @Param resetPasswordRequest body resetPasswordRequest true "reset password"
+func (h *AuthHandler) ResetPassword(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req resetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if req.Password != req.PasswordConfirmation {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"message": "パスワードが一致しません",
+		})
+		return
+	}
+	userID, ok := h.consumeToken(ctx, traceID, authtoken.PurposePasswordReset, req.Token)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	user.SetPassword(req.Password)
+	now := time.Now()
+	if err := h.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("users").Where("id = ?", userID).
+			Updates(map[string]interface{}{"password": user.Password, "version": gorm.Expr("version + 1"), "updated_at": now}).Error; err != nil {
+			return err
+		}
+		// 再設定メールを受け取れたことでメールアドレスの確認も済んだものとする
+		return tx.Table("users").Where("id = ? AND email_verified_at IS NULL", userID).
+			Updates(map[string]interface{}{"email_verified_at": now}).Error
+	}); err != nil {
+		h.logger.Error("failed to reset password", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to reset password", err))
+		return
+	}
+	// 漏えいしたパスワードで作られたセッションを使えないよう、全てのログインを無効にする
+	if err := h.sessions.RevokeAll(ctx, userID); err != nil {
+		h.logger.Error("failed to revoke sessions", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to revoke sessions", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "パスワードを再設定しました",
+	})
+}
+
+// sendLinkMail トークンを発行し、確認・再設定画面のURLを記載したメールを送信する
+// 言語はAccept-Languageヘッダーで決める
+func (h *AuthHandler) sendLinkMail(ctx *gin.Context, user *models.User, purpose string) error {
+	name, path, ttl := mailer.TemplateVerifyEmail, "/verify-email", authtoken.VerifyEmailTTL
+	if purpose == authtoken.PurposePasswordReset {
+		name, path, ttl = mailer.TemplatePasswordReset, "/password/reset", authtoken.PasswordResetTTL
+	}
+	token, err := h.tokens.Issue(ctx, purpose, user.ID, ttl)
+	if err != nil {
+		return err
+	}
+	msg, err := mailer.Render(name, mailer.LangFromAcceptLanguage(ctx.GetHeader("Accept-Language")), user.Email,
+		mailer.LinkData{
+			Name:           user.LastName + " " + user.FirstName,
+			URL:            h.baseURL + path + "?token=" + url.QueryEscape(token),
+			ExpiresInHours: int(ttl.Hours()),
+		})
+	if err != nil {
+		return err
+	}
+	return h.mailer.Send(ctx, msg)
+}
+
+// consumeToken トークンを使用済みにして対象のユーザーIDを返す。失敗した場合はレスポンスを返してfalseを返す
+func (h *AuthHandler) consumeToken(ctx *gin.Context, traceID, purpose, token string) (string, bool) {
+	userID, err := h.tokens.Consume(ctx, purpose, token)
+	switch {
+	case errors.Is(err, authtoken.ErrInvalidToken):
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError(err.Error()))
+		return "", false
+	case err != nil:
+		h.logger.Error("failed to consume token", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to consume token", err))
+		return "", false
+	}
+	return userID, true
+}
+
+// UnlockUser @title アカウントのロック解除
+// @id UnlockUser
+// @tags users
+// @version バージョン(1.0)
+// @description ログインの失敗によるアカウントのロックと失敗回数を消す。組織の管理者のみ実行できる
+// @Summary アカウントのロック解除
+// @Produce json
+// @Success 200
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /users/:id/unlock [POST]
+// @Param id path string true "ユーザーID" minlength(36) maxlength(36) format(UUID v4)
+func (h *AuthHandler) UnlockUser(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var user models.User
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&user).Error; err != nil {
+		h.abortUserLookup(ctx, traceID, err)
+		return
+	}
+	if err := h.guard.Unlock(ctx, loginguard.NormalizeEmail(user.Email)); err != nil {
+		h.logger.Error("failed to unlock user", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to unlock user", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "ロックを解除しました",
+	})
+}
+
+// GetLoginAttempts @title ログイン試行履歴
+// @id GetLoginAttempts
+// @tags users
+// @version バージョン(1.0)
+// @description ユーザーのログイン試行を新しい順に返す。組織の管理者のみ実行できる
+// @Summary ログイン試行履歴取得
+// @Produce json
+// @Success 200 {object} loginAttemptsResponse
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /users/:id/loginAttempts [GET]
+// @Param id path string true "ユーザーID" minlength(36) maxlength(36) format(UUID v4)
+// @Param offset query int false "開始位置" default(0) minimum(0)
+// @Param limit query int false "取得上限" default(20) minimum(1) maximum(100)
+func (h *AuthHandler) GetLoginAttempts(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var params searchLoginAttemptParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind query params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	var user models.User
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&user).Error; err != nil {
+		h.abortUserLookup(ctx, traceID, err)
+		return
+	}
+	attempts := []models.LoginAttempt{}
+	if err := h.Db.Where("user_id = ? OR email = ?", user.ID, loginguard.NormalizeEmail(user.Email)).Order("created_at desc, id desc").
+		Offset(params.Offset).Limit(params.Limit).Find(&attempts).Error; err != nil {
+		h.logger.Error("failed to get login attempts", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get login attempts", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, loginAttemptsResponse{
+		Total:         len(attempts),
+		LoginAttempts: attempts,
+	})
+}
+
+// UpdateUserRole @title ユーザーの権限変更
+// @id UpdateUserRole
+// @tags users
+// @version バージョン(1.0)
+// @description ユーザーを組織の管理者または一般メンバーにする。組織の管理者のみ実行でき、最後の管理者は一般メンバーにできない
+// @Summary ユーザーの権限変更
+// @Produce json
+// @Success 202 {object} models.User
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /users/:id/role [PUT]
+// @Accept json
+// @Param id path string true "ユーザーID" minlength(36) maxlength(36) format(UUID v4)
+// @Param updateUserRoleRequest body updateUserRoleRequest true "update user role"
+func (h *AuthHandler) UpdateUserRole(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req updateUserRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	var user models.User
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&user).Error; err != nil {
+		h.abortUserLookup(ctx, traceID, err)
+		return
+	}
+	if user.IsAdmin() && req.Role != models.UserRoleAdmin {
+		var admins int
+		if err := tenantDB(ctx, h.Db).Model(&models.User{}).
+			Where("role = ?", models.UserRoleAdmin).Count(&admins).Error; err != nil {
+			h.logger.Error("failed to count admins", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to count admins", err))
+			return
+		}
+		if admins <= 1 {
+			ctx.JSON(http.StatusConflict, errors.NewConflictError("organization must have at least one admin"))
+			return
+		}
+	}
+	user.Role = req.Role
+	user.UpdatedAt = time.Now()
+	if err := tenantDB(ctx, h.Db).Model(&user).Updates(map[string]interface{}{
+		"role":       user.Role,
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": user.UpdatedAt,
+	}).Error; err != nil {
+		h.logger.Error("failed to update user role", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update user role", err))
+		return
+	}
+	user.Version++
+	ctx.JSON(http.StatusAccepted, user)
+}
+
+// recordLoginAttempt 監査記録に失敗してもログインの結果は変えない
+func (h *AuthHandler) recordLoginAttempt(traceID string, attempt *models.LoginAttempt) {
+	if err := h.Db.Create(attempt).Error; err != nil {
+		h.logger.Error("failed to record login attempt", zap.Error(err),
+			zap.String("trace_id", traceID))
+	}
+}
+
+func (h *AuthHandler) abortUserLookup(ctx *gin.Context, traceID string, err error) {
+	switch err {
+	case gorm.ErrRecordNotFound:
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("user not found"))
+	default:
+		h.logger.Error("failed to get user", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get user", err))
+	}
+}
@@ -0,0 +1,346 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/ical"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+const (
+	calendarProdID  = "-//AI1411//golang-admin-api//JA"
+	calendarUIDHost = "golang-admin-api"
+	// 取り込めるファイルの上限
+	maxCalendarImportBytes = 1 << 20
+	maxCalendarImportItems = 500
+)
+
+type CalendarHandler struct {
+	Db     *gorm.DB
+	logger *zap.Logger
+}
+
+func NewCalendarHandler(db *gorm.DB, logger *zap.Logger) *CalendarHandler {
+	return &CalendarHandler{
+		Db:     db,
+		logger: logger,
+	}
+}
+
+type calendarTokenResponse struct {
+	Token string `json:"token" example:"6f1c0c2b..."`
+	URL   string `json:"url" example:"/calendar/6f1c0c2b....ics"`
+}
+
+type calendarImportResponse struct {
+	Imported int           `json:"imported"`
+	Todos    []models.Todo `json:"todos"`
+}
+
+// IssueCalendarToken @title カレンダー配信トークン発行
+// @id IssueCalendarToken
+// @tags calendar
+// @version バージョン(1.0)
+// @description ログインユーザーのカレンダー配信用トークンを発行する。発行済みの場合は作り直し、以前のURLは使えなくなる
+// @Summary カレンダー配信トークン発行
+// @Produce json
+// @Success 201 {object} calendarTokenResponse
+// @Failure 500 {object} errorResponse
+// @Router /calendar/token [POST]
+func (h *CalendarHandler) IssueCalendarToken(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	token, err := models.NewCalendarFeedToken()
+	if err != nil {
+		h.logger.Error("failed to generate calendar token", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to issue calendar token", err))
+		return
+	}
+	now := time.Now()
+	feed := models.CalendarFeed{
+		UserID:    appcontext.GetUserID(ctx),
+		TokenHash: models.HashCalendarFeedToken(token),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := tenantDB(ctx, h.Db).Save(&feed).Error; err != nil {
+		h.logger.Error("failed to save calendar token", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to issue calendar token", err))
+		return
+	}
+	ctx.JSON(http.StatusCreated, calendarTokenResponse{
+		Token: token,
+		URL:   "/calendar/" + token + ".ics",
+	})
+}
+
+// RevokeCalendarToken @title カレンダー配信トークン削除
+// @id RevokeCalendarToken
+// @tags calendar
+// @version バージョン(1.0)
+// @description ログインユーザーのカレンダー配信を停止する
+// @Summary カレンダー配信トークン削除
+// @Success 204
+// @Failure 500 {object} errorResponse
+// @Router /calendar/token [DELETE]
+func (h *CalendarHandler) RevokeCalendarToken(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	if err := tenantDB(ctx, h.Db).Where("user_id = ?", appcontext.GetUserID(ctx)).
+		Delete(&models.CalendarFeed{}).Error; err != nil {
+		h.logger.Error("failed to delete calendar token", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to revoke calendar token", err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// GetCalendarFeed @title カレンダー配信
+// @id GetCalendarFeed
+// @tags calendar
+// @version バージョン(1.0)
+// @description トークンのユーザーのtodoの期限、担当issueを含むmilestoneの期日、定額課金の更新日をiCalendar形式で返す。
+// @description カレンダーアプリから購読するため認証は不要で、トークンで利用者を特定する
+// @Summary カレンダー配信
+// @Produce text/calendar
+// @Success 200 {string} string "iCalendar"
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /calendar/:token.ics [GET]
+// @Param token path string true "配信トークン"
+func (h *CalendarHandler) GetCalendarFeed(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	token := strings.TrimSuffix(ctx.Param("token"), ".ics")
+	if token == ctx.Param("token") || token == "" {
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("calendar not found"))
+		return
+	}
+	var feed models.CalendarFeed
+	if err := tenantDB(ctx, h.Db).Where("token_hash = ?", models.HashCalendarFeedToken(token)).First(&feed).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("calendar not found"))
+		default:
+			h.logger.Error("failed to get calendar feed", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get calendar", err))
+		}
+		return
+	}
+
+	cal, err := h.buildCalendar(feed.UserID)
+	if err != nil {
+		h.logger.Error("failed to build calendar", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get calendar", err))
+		return
+	}
+	var buf bytes.Buffer
+	if err := cal.Encode(&buf, time.Now()); err != nil {
+		h.logger.Error("failed to encode calendar", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get calendar", err))
+		return
+	}
+	ctx.Data(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+}
+
+// ImportCalendar @title カレンダー取り込み
+// @id ImportCalendar
+// @tags calendar
+// @version バージョン(1.0)
+// @description .icsファイルのVTODOとVEVENTからログインユーザーのtodoを作成する。VEVENTは開始日時を期限とする
+// @Summary カレンダー取り込み
+// @Accept multipart/form-data
+// @Produce json
+// @Success 201 {object} calendarImportResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /calendar/import [POST]
+// @Param file formData file true ".icsファイル(1MBまで)"
+func (h *CalendarHandler) ImportCalendar(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("file is required"))
+		return
+	}
+	if header.Size > maxCalendarImportBytes {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("file is too large"))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		h.logger.Error("failed to open uploaded file", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to import calendar", err))
+		return
+	}
+	defer file.Close()
+
+	cal, err := ical.Parse(file, time.Local)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError(err.Error()))
+		return
+	}
+	todos := todosFromCalendar(cal, appcontext.GetUserID(ctx), time.Now())
+	if len(todos) > maxCalendarImportItems {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError(
+			fmt.Sprintf("calendar must contain at most %d items", maxCalendarImportItems)))
+		return
+	}
+
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		for i := range todos {
+			if err := tx.Create(&todos[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		h.logger.Error("failed to import calendar", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to import calendar", err))
+		return
+	}
+	ctx.JSON(http.StatusCreated, calendarImportResponse{
+		Imported: len(todos),
+		Todos:    todos,
+	})
+}
+
+// buildCalendar ユーザーの配信対象を集めてカレンダーを作る
+func (h *CalendarHandler) buildCalendar(userID string) (*ical.Calendar, error) {
+	cal := &ical.Calendar{ProdID: calendarProdID, Name: "golang-admin-api"}
+
+	var todos []models.Todo
+	if err := h.Db.Where("user_id = ? AND due_at IS NOT NULL", userID).
+		Order("due_at").Find(&todos).Error; err != nil {
+		return nil, err
+	}
+	for _, todo := range todos {
+		cal.Todos = append(cal.Todos, ical.Todo{
+			UID:          fmt.Sprintf("todo-%d@%s", todo.ID, calendarUIDHost),
+			Summary:      todo.Title,
+			Description:  todo.Body,
+			Due:          todo.DueAt,
+			Status:       icalTodoStatus(todo.Status),
+			LastModified: todo.UpdatedAt,
+		})
+	}
+
+	var milestones []models.Milestone
+	if err := h.Db.Where("due_date IS NOT NULL").
+		Where("id IN (?)", h.Db.Table("issues").Select("milestone_id").
+			Where("user_id = ? AND milestone_id <> ''", userID).SubQuery()).
+		Order("due_date").Find(&milestones).Error; err != nil {
+		return nil, err
+	}
+	for _, milestone := range milestones {
+		cal.Events = append(cal.Events, ical.Event{
+			UID:          "milestone-" + milestone.ID + "@" + calendarUIDHost,
+			Summary:      "[マイルストーン] " + milestone.MilestoneTitle,
+			Description:  milestone.MilestoneDescription,
+			Start:        *milestone.DueDate,
+			AllDay:       true,
+			LastModified: milestone.UpdatedAt,
+		})
+	}
+
+	var members []models.SubscriptionMember
+	if err := h.Db.Where("user_id = ? AND member_status IN (?)", userID,
+		[]models.MemberStatus{models.Premium, models.Basic}).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		summary := "定額課金の更新日"
+		if member.CancelAtPeriodEnd {
+			summary = "定額課金の終了日"
+		}
+		cal.Events = append(cal.Events, ical.Event{
+			UID:     "subscription-" + member.ID + "@" + calendarUIDHost,
+			Summary: summary,
+			Start:   member.MemberEndDate,
+			AllDay:  true,
+		})
+	}
+	return cal, nil
+}
+
+// todosFromCalendar 取り込んだVTODO・VEVENTをtodoに変換する
+// タイトルと本文はtodoの入力上限に合わせて切り詰める
+func todosFromCalendar(cal *ical.Calendar, userID string, now time.Time) []models.Todo {
+	todos := make([]models.Todo, 0, len(cal.Todos)+len(cal.Events))
+	newTodo := func(summary, description, status string, due *time.Time) models.Todo {
+		title := truncateRunes(strings.TrimSpace(summary), 64)
+		if title == "" {
+			title = "(無題)"
+		}
+		body := truncateRunes(strings.TrimSpace(description), 64)
+		if body == "" {
+			body = title
+		}
+		return models.Todo{
+			Title:     title,
+			Body:      body,
+			Status:    status,
+			UserID:    &userID,
+			DueAt:     due,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	}
+	for _, td := range cal.Todos {
+		todos = append(todos, newTodo(td.Summary, td.Description, todoStatusFromICal(td.Status), td.Due))
+	}
+	for _, ev := range cal.Events {
+		start := ev.Start
+		todos = append(todos, newTodo(ev.Summary, ev.Description, string(models.TodoStatusNew), &start))
+	}
+	return todos
+}
+
+func icalTodoStatus(status string) string {
+	switch models.TodoStatus(status) {
+	case models.TodoStatusProcessing:
+		return ical.TodoStatusInProcess
+	case models.TodoStatusDone:
+		return ical.TodoStatusCompleted
+	case models.TodoStatusClosed:
+		return ical.TodoStatusCancelled
+	default:
+		return ical.TodoStatusNeedsAction
+	}
+}
+
+func todoStatusFromICal(status string) string {
+	switch status {
+	case ical.TodoStatusInProcess:
+		return string(models.TodoStatusProcessing)
+	case ical.TodoStatusCompleted:
+		return string(models.TodoStatusDone)
+	case ical.TodoStatusCancelled:
+		return string(models.TodoStatusClosed)
+	default:
+		return string(models.TodoStatusNew)
+	}
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,401 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+type CommentHandler struct {
+	Db            *gorm.DB
+	logger        *zap.Logger
+	uuidGenerator models.UUIDGenerator
+}
+
+func NewCommentHandler(db *gorm.DB, logger *zap.Logger, uuidGenerator models.UUIDGenerator) *CommentHandler {
+	return &CommentHandler{
+		Db:            db,
+		logger:        logger,
+		uuidGenerator: uuidGenerator,
+	}
+}
+
+type commentRequest struct {
+	Body string `json:"body" binding:"required,max=10000" example:"@taro@example.com レビューお願いします"`
+}
+
+type commentsResponse struct {
+	Total    int              `json:"total"`
+	Comments []models.Comment `json:"comments"`
+}
+
+type timelineResponse struct {
+	Total    int                   `json:"total"`
+	Timeline []models.TimelineItem `json:"timeline"`
+}
+
+// GetIssueComments @title issueコメント一覧
+// @id GetIssueComments
+// @tags comments
+// @version バージョン(1.0)
+// @description issueのコメントを投稿順に返す
+// @Summary issueコメント一覧取得
+// @Produce json
+// @Success 200 {object} commentsResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /issues/:id/comments [GET]
+// @Param id path string true "issue ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *CommentHandler) GetIssueComments(ctx *gin.Context) {
+	h.getComments(ctx, models.CommentTargetIssue)
+}
+
+// GetEpicComments @title epicコメント一覧
+// @id GetEpicComments
+// @tags comments
+// @version バージョン(1.0)
+// @description epicのコメントを投稿順に返す
+// @Summary epicコメント一覧取得
+// @Produce json
+// @Success 200 {object} commentsResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /epics/:id/comments [GET]
+// @Param id path int true "epic ID"
+func (h *CommentHandler) GetEpicComments(ctx *gin.Context) {
+	h.getComments(ctx, models.CommentTargetEpic)
+}
+
+// CreateIssueComment @title issueコメント投稿
+// @id CreateIssueComment
+// @tags comments
+// @version バージョン(1.0)
+// @description issueにmarkdownのコメントを投稿する。「@メールアドレス」で記述したユーザはメンションとして記録される
+// @Summary issueコメント投稿
+// @Produce json
+// @Success 201 {object} models.Comment
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /issues/:id/comments [POST]
+// @Accept json
+// @Param commentRequest body commentRequest true "create comment"
+// @Param id path string true "issue ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *CommentHandler) CreateIssueComment(ctx *gin.Context) {
+	h.createComment(ctx, models.CommentTargetIssue)
+}
+
+// CreateEpicComment @title epicコメント投稿
+// @id CreateEpicComment
+// @tags comments
+// @version バージョン(1.0)
+// @description epicにmarkdownのコメントを投稿する。「@メールアドレス」で記述したユーザはメンションとして記録される
+// @Summary epicコメント投稿
+// @Produce json
+// @Success 201 {object} models.Comment
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /epics/:id/comments [POST]
+// @Accept json
+// @Param commentRequest body commentRequest true "create comment"
+// @Param id path int true "epic ID"
+func (h *CommentHandler) CreateEpicComment(ctx *gin.Context) {
+	h.createComment(ctx, models.CommentTargetEpic)
+}
+
+// UpdateComment @title コメント編集
+// @id UpdateComment
+// @tags comments
+// @version バージョン(1.0)
+// @description コメントの本文を編集し、メンションを更新する。投稿者以外は編集できない
+// @Summary コメント編集
+// @Produce json
+// @Success 202 {object} models.Comment
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /comments/:id [PUT]
+// @Accept json
+// @Param commentRequest body commentRequest true "update comment"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *CommentHandler) UpdateComment(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	comment, ok := h.findOwnComment(ctx, traceID)
+	if !ok {
+		return
+	}
+	var req commentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+
+	comment.Body = req.Body
+	comment.UpdatedAt = time.Now()
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("comments").Where("id = ?", comment.ID).Updates(map[string]interface{}{
+			"body":       comment.Body,
+			"updated_at": comment.UpdatedAt,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(models.CommentMention{}).Error; err != nil {
+			return err
+		}
+		return saveCommentMentions(tx, comment)
+	}); err != nil {
+		h.logger.Error("failed to update comment", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update comment", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, comment)
+}
+
+// DeleteComment @title コメント削除
+// @id DeleteComment
+// @tags comments
+// @version バージョン(1.0)
+// @description コメントを削除する。投稿者以外は削除できない
+// @Summary コメント削除
+// @Success 204
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /comments/:id [DELETE]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *CommentHandler) DeleteComment(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	comment, ok := h.findOwnComment(ctx, traceID)
+	if !ok {
+		return
+	}
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(models.CommentMention{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", comment.ID).Delete(models.Comment{}).Error
+	}); err != nil {
+		h.logger.Error("failed to delete comment", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to delete comment", err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// GetIssueTimeline @title issueタイムライン
+// @id GetIssueTimeline
+// @tags comments
+// @version バージョン(1.0)
+// @description issueのコメントとステータス・担当者・マイルストーンの変更履歴を時系列で返す
+// @Summary issueタイムライン取得
+// @Produce json
+// @Success 200 {object} timelineResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /issues/:id/timeline [GET]
+// @Param id path string true "issue ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *CommentHandler) GetIssueTimeline(ctx *gin.Context) {
+	h.getTimeline(ctx, models.CommentTargetIssue)
+}
+
+// GetEpicTimeline @title epicタイムライン
+// @id GetEpicTimeline
+// @tags comments
+// @version バージョン(1.0)
+// @description epicのコメントとステータス・担当者・マイルストーンの変更履歴を時系列で返す
+// @Summary epicタイムライン取得
+// @Produce json
+// @Success 200 {object} timelineResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /epics/:id/timeline [GET]
+// @Param id path int true "epic ID"
+func (h *CommentHandler) GetEpicTimeline(ctx *gin.Context) {
+	h.getTimeline(ctx, models.CommentTargetEpic)
+}
+
+func (h *CommentHandler) getComments(ctx *gin.Context, targetType models.CommentTargetType) {
+	traceID := appcontext.GetTraceID(ctx)
+	targetID := ctx.Param("id")
+	if !h.existsTarget(ctx, traceID, targetType, targetID) {
+		return
+	}
+	comments, err := findComments(tenantDB(ctx, h.Db), targetType, targetID)
+	if err != nil {
+		h.logger.Error("failed to get comments", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get comments", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, commentsResponse{
+		Total:    len(comments),
+		Comments: comments,
+	})
+}
+
+func (h *CommentHandler) createComment(ctx *gin.Context, targetType models.CommentTargetType) {
+	traceID := appcontext.GetTraceID(ctx)
+	targetID := ctx.Param("id")
+	if !h.existsTarget(ctx, traceID, targetType, targetID) {
+		return
+	}
+	var req commentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+
+	comment := models.Comment{
+		ID:         h.uuidGenerator.GenerateUUID(),
+		TargetType: targetType,
+		TargetID:   targetID,
+		UserID:     appcontext.GetUserID(ctx),
+		Body:       req.Body,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		return saveCommentMentions(tx, &comment)
+	}); err != nil {
+		h.logger.Error("failed to create comment", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create comment", err))
+		return
+	}
+	ctx.JSON(http.StatusCreated, comment)
+}
+
+func (h *CommentHandler) getTimeline(ctx *gin.Context, targetType models.CommentTargetType) {
+	traceID := appcontext.GetTraceID(ctx)
+	targetID := ctx.Param("id")
+	if !h.existsTarget(ctx, traceID, targetType, targetID) {
+		return
+	}
+	comments, err := findComments(tenantDB(ctx, h.Db), targetType, targetID)
+	if err != nil {
+		h.logger.Error("failed to get comments", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get timeline", err))
+		return
+	}
+	var events []models.ActivityEvent
+	if err := tenantDB(ctx, h.Db).Where("target_type = ? AND target_id = ?", targetType, targetID).
+		Order("created_at").Order("id").Find(&events).Error; err != nil {
+		h.logger.Error("failed to get activity events", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get timeline", err))
+		return
+	}
+	timeline := models.BuildTimeline(comments, events)
+	ctx.JSON(http.StatusOK, timelineResponse{
+		Total:    len(timeline),
+		Timeline: timeline,
+	})
+}
+
+// existsTarget コメント対象のissue/epicが存在するか確認し、存在しない場合はレスポンスを返す
+func (h *CommentHandler) existsTarget(ctx *gin.Context, traceID string, targetType models.CommentTargetType,
+	targetID string,
+) bool {
+	table, message := "issues", "issue not found"
+	if targetType == models.CommentTargetEpic {
+		table, message = "epics", "epic not found"
+	}
+	var count int
+	if err := tenantDB(ctx, h.Db).Table(table).Where("id = ?", targetID).Count(&count).Error; err != nil {
+		h.logger.Error("failed to find comment target", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to find "+string(targetType), err))
+		return false
+	}
+	if count == 0 {
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError(message))
+		return false
+	}
+	return true
+}
+
+// findOwnComment コメントを取得し、ログインユーザが投稿者でない場合はレスポンスを返す
+func (h *CommentHandler) findOwnComment(ctx *gin.Context, traceID string) (*models.Comment, bool) {
+	var comment models.Comment
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&comment).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("comment not found"))
+		default:
+			h.logger.Error("failed to get comment", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get comment", err))
+		}
+		return nil, false
+	}
+	if comment.UserID != appcontext.GetUserID(ctx) {
+		ctx.JSON(http.StatusForbidden, errors.NewForbiddenError("only the author can modify the comment"))
+		return nil, false
+	}
+	return &comment, true
+}
+
+func findComments(db *gorm.DB, targetType models.CommentTargetType, targetID string) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := db.Where("target_type = ? AND target_id = ?", targetType, targetID).
+		Preload("Mentions").
+		Order("created_at").
+		Find(&comments).Error
+	return comments, err
+}
+
+// saveCommentMentions 本文のメンションを登録済みユーザに解決して保存する
+// 該当するユーザがいないメールアドレスは無視する
+func saveCommentMentions(tx *gorm.DB, comment *models.Comment) error {
+	comment.Mentions = []models.CommentMention{}
+	emails := models.ParseMentions(comment.Body)
+	if len(emails) == 0 {
+		return nil
+	}
+	var users []models.User
+	if err := tx.Where("email IN (?)", emails).Find(&users).Error; err != nil {
+		return err
+	}
+	for _, u := range users {
+		mention := models.CommentMention{
+			CommentID: comment.ID,
+			UserID:    u.ID,
+			Email:     u.Email,
+			CreatedAt: time.Now(),
+		}
+		if err := tx.Create(&mention).Error; err != nil {
+			return err
+		}
+		comment.Mentions = append(comment.Mentions, mention)
+	}
+	return nil
+}
+
+// saveActivityEvents issue・epicの変更履歴を保存する
+func saveActivityEvents(tx *gorm.DB, events []models.ActivityEvent) error {
+	for i := range events {
+		if err := tx.Create(&events[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
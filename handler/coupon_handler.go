@@ -11,7 +11,15 @@ import (
 	"github.com/jinzhu/gorm"
 
 	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/outbox"
 	"github.com/AI1411/golang-admin-api/util/errors"
+	"github.com/AI1411/golang-admin-api/webhook"
+)
+
+var (
+	errCouponNotFound        = errors.New("coupon not found")
+	errCouponUserNotFound    = errors.New("user not found")
+	errCouponAlreadyAcquired = errors.New("coupon already acquired")
 )
 
 type CouponHandler struct {
@@ -121,7 +129,7 @@ func (h *CouponHandler) GetAllCoupon(ctx *gin.Context) {
 		return
 	}
 	var coupons []models.Coupon
-	query := createCouponQueryBuilder(params, h)
+	query := createCouponQueryBuilder(ctx, params, h)
 	if err := query.Find(&coupons).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get coupons", err))
 		return
@@ -136,20 +144,22 @@ func (h *CouponHandler) GetAllCoupon(ctx *gin.Context) {
 // @id GetCouponDetail
 // @tags coupons
 // @version バージョン(1.0)
-// @description coupon詳細を返す
+// @description coupon詳細を返す。ETagを返し、If-None-Matchが一致する場合は304を返す
 // @Summary coupon詳細取得
 // @Produce json
 // @Success 200 {object} couponResponseItem
+// @Success 304
 // @Failure 400 {object} errorResponse
 // @Failure 404 {object} errorResponse
 // @Failure 500 {object} errorResponse
 // @Router /coupons/:id [GET]
 // @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param If-None-Match header string false "取得済みのETag"
 func (h *CouponHandler) GetCouponDetail(ctx *gin.Context) {
 	var coupon models.Coupon
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&coupon).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&coupon).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to find coupon", zap.Error(err),
@@ -162,7 +172,7 @@ func (h *CouponHandler) GetCouponDetail(ctx *gin.Context) {
 		}
 		return
 	}
-	ctx.JSON(http.StatusOK, coupon)
+	respondWithETag(ctx, coupon.Version, coupon)
 }
 
 // CreateCoupon @title coupon作成
@@ -188,7 +198,8 @@ func (h *CouponHandler) CreateCoupon(ctx *gin.Context) {
 
 	traceID := appcontext.GetTraceID(ctx)
 	coupon.CreateUUID()
-	if err := h.Db.Create(&coupon).Error; err != nil {
+	coupon.Version = 1
+	if err := tenantDB(ctx, h.Db).Create(&coupon).Error; err != nil {
 		h.logger.Error("failed to create coupon", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create coupon", err))
@@ -201,21 +212,51 @@ func (h *CouponHandler) CreateCoupon(ctx *gin.Context) {
 // @id UpdateCoupon
 // @tags coupons
 // @version バージョン(1.0)
-// @description couponを編集する
+// @description couponを編集する。If-Matchが現在のETagと一致しない場合は412を返す
 // @Summary coupon編集
 // @Produce json
 // @Success 202 {object} couponResponseItem
 // @Failure 400 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 412 {object} errorResponse
 // @Failure 500 {object} errorResponse
 // @Router /coupons/:id [PUT]
 // @Accept json
 // @Param couponRequest body couponRequest true "update coupon"
 // @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param If-Match header string false "取得時のETag"
 func (h *CouponHandler) UpdateCoupon(ctx *gin.Context) {
+	h.updateCoupon(ctx, bindPut)
+}
+
+// PatchCoupon @title coupon部分編集
+// @id PatchCoupon
+// @tags coupons
+// @version バージョン(1.0)
+// @description couponを部分的に編集する。JSON Merge Patch(RFC 7396)で、nullを指定した項目は空にする
+// @description 指定した項目のみを検証し、id, version, created_at, updated_atは変更できない。If-Matchが現在のETagと一致しない場合は412を返す
+// @Summary coupon部分編集
+// @Produce json
+// @Success 202 {object} couponResponseItem
+// @Failure 400 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /coupons/:id [PATCH]
+// @Accept json
+// @Param couponRequest body couponRequest true "patch coupon"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param If-Match header string false "取得時のETag"
+func (h *CouponHandler) PatchCoupon(ctx *gin.Context) {
+	h.updateCoupon(ctx, bindMergePatch("id", "version", "created_at", "updated_at"))
+}
+
+// updateCoupon PUTとPATCHで共通の編集処理。bindで更新内容を反映する
+func (h *CouponHandler) updateCoupon(ctx *gin.Context, bind bindFunc) {
 	coupon := models.Coupon{}
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&coupon).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&coupon).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to update coupon", zap.Error(err),
@@ -228,15 +269,30 @@ func (h *CouponHandler) UpdateCoupon(ctx *gin.Context) {
 		}
 		return
 	}
-	if err := ctx.ShouldBindJSON(&coupon); err != nil {
+	if !checkIfMatch(ctx, coupon.Version) {
+		return
+	}
+	version := coupon.Version
+	if err := bind(ctx, &coupon); err != nil {
 		res := createValidateErrorResponse(err)
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
-	if err := h.Db.Save(&coupon).Error; err != nil {
+	coupon.Version = version
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := bumpVersion(tx, "coupons", coupon.ID, &coupon.Version); err != nil {
+			return err
+		}
+		return tx.Save(&coupon).Error
+	}); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			respondVersionConflict(ctx)
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update coupon", err))
 		return
 	}
+	ctx.Header("ETag", versionETag(coupon.Version))
 	ctx.JSON(http.StatusAccepted, coupon)
 }
 
@@ -249,6 +305,7 @@ func (h *CouponHandler) UpdateCoupon(ctx *gin.Context) {
 // @Produce json
 // @Success 201
 // @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
 // @Failure 500 {object} errorResponse
 // @Router /:coupon_id/users/:user_id [POST]
 // @Accept json
@@ -262,57 +319,56 @@ func (h *CouponHandler) AcquireCoupon(ctx *gin.Context) {
 	}
 
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Transaction(func(tx *gorm.DB) error {
-		var coupon models.Coupon
-		if err := h.Db.First(&coupon, "id = ?", req.CouponID).Error; err != nil {
+	err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Coupon{}, "id = ?", req.CouponID).Error; err != nil {
 			if gorm.IsRecordNotFoundError(err) {
-				h.logger.Error("failed to acquire coupon", zap.Error(err),
-					zap.String("trace_id", traceID))
-				ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("coupon not found"))
-				return err
+				return errCouponNotFound
 			}
+			return err
 		}
-
-		var user models.User
-		if err := h.Db.First(&user, "id = ?", req.UserID).Error; err != nil {
+		if err := tx.First(&models.User{}, "id = ?", req.UserID).Error; err != nil {
 			if gorm.IsRecordNotFoundError(err) {
-				h.logger.Error("failed to find user", zap.Error(err),
-					zap.String("trace_id", traceID))
-				ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("user not found"))
-				return err
+				return errCouponUserNotFound
 			}
+			return err
 		}
 
+		// 見つからない場合はまだ獲得していない
 		var couponUser models.CouponUser
-		if err := h.Db.Table("coupon_user").
-			First(&couponUser, "coupon_id = ? and user_id = ?", req.CouponID, req.UserID).
-			Error; err != nil {
-			h.logger.Error("failed to find coupon user", zap.Error(err),
-				zap.String("trace_id", traceID))
+		err := tx.Table("coupon_user").
+			First(&couponUser, "coupon_id = ? and user_id = ?", req.CouponID, req.UserID).Error
+		switch {
+		case err == nil:
+			return errCouponAlreadyAcquired
+		case !gorm.IsRecordNotFoundError(err):
 			return err
 		}
 
-		if couponUser.ID != 0 {
-			h.logger.Error("coupon already acquired", zap.Error(nil),
-				zap.String("trace_id", traceID))
-			ctx.JSON(http.StatusBadRequest,
-				errors.NewBadRequestError("failed to acquire coupon"))
-			return nil
-		}
-
-		if err := h.Db.Table("coupon_user").Create(&models.CouponUser{
+		if err := tx.Table("coupon_user").Create(&models.CouponUser{
 			CouponID: req.CouponID,
 			UserID:   req.UserID,
 			UseCount: 0,
 		}).Error; err != nil {
-			h.logger.Error("failed to acquire coupon", zap.Error(err),
-				zap.String("trace_id", traceID))
-			ctx.JSON(http.StatusInternalServerError,
-				errors.NewInternalServerError("failed to acquire coupon", err))
 			return err
 		}
-		return nil
-	}); err != nil {
+		acquired := webhook.CouponAcquired{
+			CouponID: req.CouponID,
+			UserID:   req.UserID,
+		}
+		if err := outbox.Record(tx, outbox.TopicCouponAcquired, req.CouponID,
+			outbox.DedupKey(outbox.TopicCouponAcquired, req.CouponID, req.UserID), acquired); err != nil {
+			return err
+		}
+		return webhook.Enqueue(tx, webhook.EventCouponAcquired, acquired)
+	})
+	switch {
+	case errors.Is(err, errCouponNotFound), errors.Is(err, errCouponUserNotFound):
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
+		return
+	case errors.Is(err, errCouponAlreadyAcquired):
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError(err.Error()))
+		return
+	case err != nil:
 		h.logger.Error("failed to acquire coupon", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to acquire coupon", err))
@@ -345,7 +401,7 @@ func (h *CouponHandler) DiscountedList(ctx *gin.Context) {
 
 	traceID := appcontext.GetTraceID(ctx)
 	var coupon models.Coupon
-	if err := h.Db.First(&coupon, "id = ?", req.CouponID).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).First(&coupon, "id = ?", req.CouponID).Error; err != nil {
 		if gorm.IsRecordNotFoundError(err) {
 			h.logger.Error("failed to get coupon", zap.Error(err),
 				zap.String("trace_id", traceID))
@@ -355,7 +411,7 @@ func (h *CouponHandler) DiscountedList(ctx *gin.Context) {
 	}
 
 	var user models.User
-	if err := h.Db.First(&user, "id = ?", req.UserID).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).First(&user, "id = ?", req.UserID).Error; err != nil {
 		if gorm.IsRecordNotFoundError(err) {
 			h.logger.Error("failed to find user", zap.Error(err),
 				zap.String("trace_id", traceID))
@@ -365,7 +421,7 @@ func (h *CouponHandler) DiscountedList(ctx *gin.Context) {
 	}
 
 	var couponUser models.CouponUser
-	if err := h.Db.Table("coupon_user").
+	if err := tenantDB(ctx, h.Db).Table("coupon_user").
 		First(&couponUser, "coupon_id = ? and user_id = ?", req.CouponID, req.UserID).
 		Error; err != nil {
 		h.logger.Error("failed to find coupon user", zap.Error(err),
@@ -374,7 +430,7 @@ func (h *CouponHandler) DiscountedList(ctx *gin.Context) {
 	}
 
 	var products []models.Product
-	if err := h.Db.Where(req.ProductIDs).Find(&products).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where(req.ProductIDs).Find(&products).Error; err != nil {
 		if gorm.IsRecordNotFoundError(err) {
 			h.logger.Error("failed to find products", zap.Error(err),
 				zap.String("trace_id", traceID))
@@ -396,9 +452,9 @@ func (h *CouponHandler) DiscountedList(ctx *gin.Context) {
 	return
 }
 
-func createCouponQueryBuilder(params searchCouponParams, h *CouponHandler) *gorm.DB {
+func createCouponQueryBuilder(ctx *gin.Context, params searchCouponParams, h *CouponHandler) *gorm.DB {
 	var coupons []models.Coupon
-	query := h.Db.Find(&coupons)
+	query := tenantDB(ctx, h.Db).Find(&coupons)
 
 	if params.Title != "" {
 		query = query.Where("title LIKE ?", "%"+params.Title+"%")
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/AI1411/golang-admin-api/middleware"
@@ -17,6 +18,9 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/outbox"
+	"github.com/AI1411/golang-admin-api/webhook"
 )
 
 const couponIDForTest = "090e142d-baa3-4039-9d21-cf5a1af39094"
@@ -48,6 +52,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2030-07-02T10:00:00+09:00",
 					"is_public": false,
 					"is_premium": false,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:41+09:00",
 					"updated_at": "2022-06-15T10:31:50+09:00"
 				},
@@ -64,6 +69,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2032-07-02T10:00:00+09:00",
 					"is_public": true,
 					"is_premium": true,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:12+09:00",
 					"updated_at": "2022-06-14T08:19:12+09:00"
 				}
@@ -194,6 +200,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2030-07-02T10:00:00+09:00",
 					"is_public": false,
 					"is_premium": false,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:41+09:00",
 					"updated_at": "2022-06-15T10:31:50+09:00"
 				}
@@ -223,6 +230,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2030-07-02T10:00:00+09:00",
 					"is_public": false,
 					"is_premium": false,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:41+09:00",
 					"updated_at": "2022-06-15T10:31:50+09:00"
 				}
@@ -252,6 +260,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2032-07-02T10:00:00+09:00",
 					"is_public": true,
 					"is_premium": true,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:12+09:00",
 					"updated_at": "2022-06-14T08:19:12+09:00"
 				}
@@ -281,6 +290,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2032-07-02T10:00:00+09:00",
 					"is_public": true,
 					"is_premium": true,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:12+09:00",
 					"updated_at": "2022-06-14T08:19:12+09:00"
 				}
@@ -310,6 +320,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2032-07-02T10:00:00+09:00",
 					"is_public": true,
 					"is_premium": true,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:12+09:00",
 					"updated_at": "2022-06-14T08:19:12+09:00"
 				}
@@ -339,6 +350,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2030-07-02T10:00:00+09:00",
 					"is_public": false,
 					"is_premium": false,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:41+09:00",
 					"updated_at": "2022-06-15T10:31:50+09:00"
 				}
@@ -368,6 +380,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2032-07-02T10:00:00+09:00",
 					"is_public": true,
 					"is_premium": true,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:12+09:00",
 					"updated_at": "2022-06-14T08:19:12+09:00"
 				}
@@ -397,6 +410,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2030-07-02T10:00:00+09:00",
 					"is_public": false,
 					"is_premium": false,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:41+09:00",
 					"updated_at": "2022-06-15T10:31:50+09:00"
 				}
@@ -426,6 +440,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2032-07-02T10:00:00+09:00",
 					"is_public": true,
 					"is_premium": true,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:12+09:00",
 					"updated_at": "2022-06-14T08:19:12+09:00"
 				}
@@ -455,6 +470,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2030-07-02T10:00:00+09:00",
 					"is_public": false,
 					"is_premium": false,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:41+09:00",
 					"updated_at": "2022-06-15T10:31:50+09:00"
 				}
@@ -484,6 +500,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2032-07-02T10:00:00+09:00",
 					"is_public": true,
 					"is_premium": true,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:12+09:00",
 					"updated_at": "2022-06-14T08:19:12+09:00"
 				}
@@ -513,6 +530,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2030-07-02T10:00:00+09:00",
 					"is_public": false,
 					"is_premium": false,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:41+09:00",
 					"updated_at": "2022-06-15T10:31:50+09:00"
 				}
@@ -542,6 +560,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2030-07-02T10:00:00+09:00",
 					"is_public": false,
 					"is_premium": false,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:41+09:00",
 					"updated_at": "2022-06-15T10:31:50+09:00"
 				}
@@ -571,6 +590,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2030-07-02T10:00:00+09:00",
 					"is_public": false,
 					"is_premium": false,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:41+09:00",
 					"updated_at": "2022-06-15T10:31:50+09:00"
 				}
@@ -600,6 +620,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2032-07-02T10:00:00+09:00",
 					"is_public": true,
 					"is_premium": true,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:12+09:00",
 					"updated_at": "2022-06-14T08:19:12+09:00"
 				}
@@ -629,6 +650,7 @@ var getCouponsTestCases = []struct {
 					"public_end_at": "2030-07-02T10:00:00+09:00",
 					"is_public": false,
 					"is_premium": false,
+					"version": 1,
 					"created_at": "2022-06-14T08:19:41+09:00",
 					"updated_at": "2022-06-15T10:31:50+09:00"
 				}
@@ -697,6 +719,7 @@ var getCouponDetailTestCases = []struct {
 			"public_end_at": "2030-07-02T10:00:00+09:00",
 			"is_public": false,
 			"is_premium": false,
+			"version": 1,
 			"created_at": "2022-06-14T08:19:41+09:00",
 			"updated_at": "2022-06-15T10:31:50+09:00"
 		}`,
@@ -736,3 +759,72 @@ func TestCouponDetail(t *testing.T) {
 		})
 	}
 }
+
+func TestAcquireCoupon(t *testing.T) {
+	const userID = "443b5f1c-8a3a-4485-b3bc-05e69b40b290"
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE coupons")
+	dbConn.Exec("TRUNCATE TABLE coupon_user")
+	dbConn.Exec("TRUNCATE TABLE users")
+	dbConn.Exec("TRUNCATE TABLE outbox_events")
+	dbConn.Exec("TRUNCATE TABLE webhook_subscriptions")
+	dbConn.Exec("TRUNCATE TABLE webhook_deliveries")
+	require.NoError(t, dbConn.Exec("INSERT INTO coupons (id, title, remarks, discount_amount, discount_rate, max_discount_amount, use_start_at,use_end_at, public_start_at, public_end_at, is_public, is_premium, created_at, updated_at) VALUES ('090e142d-baa3-4039-9d21-cf5a1af39094', 'coupon', 'coupon', 1000, null, null, '2022-06-01 00:00:00','2030-07-02 10:00:00', '2022-06-01 00:00:00', '2030-07-02 10:00:00', 0, 0, '2022-06-14 08:19:41','2022-06-15 10:31:50');").Error)
+	require.NoError(t, dbConn.Exec("insert into users (id, organization_id, first_name, last_name, age, email, password, role, created_at, updated_at)values('443b5f1c-8a3a-4485-b3bc-05e69b40b290','00000000-0000-4000-8000-000000000001','a','a',20,'a@example.com','','member','2022-09-28 10:00:00','2022-09-28 10:00:00');").Error)
+	require.NoError(t, dbConn.Exec("insert into webhook_subscriptions (id, organization_id, url, event_types, secret, is_active)values('6f1d2c3b-4a5e-4f60-8a7b-9c0d1e2f3a4b','00000000-0000-4000-8000-000000000001','https://example.com/hook','coupon.acquired','whsec',1);").Error)
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(middleware.NewTracing())
+	couponHandler := NewCouponHandler(dbConn, zapLogger)
+	r.POST("/coupons/acquire", couponHandler.AcquireCoupon)
+
+	acquire := func(couponID, userID string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/coupons/acquire",
+			strings.NewReader(fmt.Sprintf(`{"coupon_id":%q,"user_id":%q}`, couponID, userID)))
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("獲得するとcoupon.acquiredのアウトボックスとWebhookの配信が作成されること", func(t *testing.T) {
+		rec := acquire(couponIDForTest, userID)
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		var couponUsers []models.CouponUser
+		require.NoError(t, dbConn.Table("coupon_user").Where("coupon_id = ? AND user_id = ?", couponIDForTest, userID).Find(&couponUsers).Error)
+		assert.Len(t, couponUsers, 1)
+
+		var event models.OutboxEvent
+		require.NoError(t, dbConn.Where("topic = ?", outbox.TopicCouponAcquired).First(&event).Error)
+		assert.Equal(t, couponIDForTest, event.AggregateID)
+		assert.Equal(t, outbox.DedupKey(outbox.TopicCouponAcquired, couponIDForTest, userID), event.DedupKey)
+		assert.JSONEq(t, `{"coupon_id":"090e142d-baa3-4039-9d21-cf5a1af39094","user_id":"443b5f1c-8a3a-4485-b3bc-05e69b40b290"}`, event.Payload)
+
+		var deliveries []models.WebhookDelivery
+		require.NoError(t, dbConn.Where("event_type = ?", webhook.EventCouponAcquired).Find(&deliveries).Error)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, "6f1d2c3b-4a5e-4f60-8a7b-9c0d1e2f3a4b", deliveries[0].SubscriptionID)
+		assert.Equal(t, models.WebhookDeliveryStatusPending, deliveries[0].DeliveryStatus)
+	})
+
+	t.Run("獲得済みの場合は400になり、イベントは増えないこと", func(t *testing.T) {
+		rec := acquire(couponIDForTest, userID)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "coupon already acquired")
+
+		var count int
+		require.NoError(t, dbConn.Model(&models.OutboxEvent{}).Where("topic = ?", outbox.TopicCouponAcquired).Count(&count).Error)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("存在しないクーポンは404になること", func(t *testing.T) {
+		rec := acquire("5c3325c1-d539-42d6-b405-2af2f6b99ed9", userID)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("存在しないユーザーは404になること", func(t *testing.T) {
+		rec := acquire(couponIDForTest, "5c3325c1-d539-42d6-b405-2af2f6b99ed9")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -2,6 +2,8 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/AI1411/golang-admin-api/util/appcontext"
 	"go.uber.org/zap"
@@ -45,15 +47,17 @@ type epicResponse struct {
 }
 
 type searchEpicParams struct {
-	IsOpen      string `binding:"omitempty,boolean" form:"is_open"`
-	AuthorID    string `binding:"omitempty,len=36" form:"author_id"`
-	EpicTitle   string `binding:"omitempty,max=64" form:"epic_title"`
-	Label       string `binding:"omitempty,max=64" form:"label"`
-	MilestoneID string `binding:"omitempty,len=36" form:"milestone_id"`
-	AssigneeID  string `binding:"omitempty,len=36" form:"assignee_id"`
-	ProjectID   string `binding:"omitempty,len=36" form:"project_id"`
-	Offset      string `form:"offset,default=0" binding:"omitempty,numeric"`
-	Limit       string `form:"limit,default=10" binding:"omitempty,numeric"`
+	IsOpen      string   `binding:"omitempty,boolean" form:"is_open"`
+	AuthorID    string   `binding:"omitempty,len=36" form:"author_id"`
+	EpicTitle   string   `binding:"omitempty,max=64" form:"epic_title"`
+	Label       string   `binding:"omitempty,max=64" form:"label"`
+	MilestoneID string   `binding:"omitempty,len=36" form:"milestone_id"`
+	AssigneeID  string   `binding:"omitempty,len=36" form:"assignee_id"`
+	ProjectID   string   `binding:"omitempty,len=36" form:"project_id"`
+	LabelIDs    []string `binding:"omitempty,dive,len=36" form:"label_ids"`
+	LabelMatch  string   `binding:"omitempty,oneof=any all" form:"label_match"`
+	Offset      string   `form:"offset,default=0" binding:"omitempty,numeric"`
+	Limit       string   `form:"limit,default=10" binding:"omitempty,numeric"`
 }
 
 // GetEpics @title 一覧取得
@@ -74,6 +78,8 @@ type searchEpicParams struct {
 // @Param milestone_id query string false "作成日" format(YYYY-MM-DDThh:mm:ss±hh:mm)
 // @Param assignee_id query string false "作成日" format(YYYY-MM-DDThh:mm:ss±hh:mm)
 // @Param project_id query string false "作成日" format(YYYY-MM-DDThh:mm:ss±hh:mm)
+// @Param label_ids query []string false "ラベルID" collectionFormat(multi)
+// @Param label_match query string false "ラベルの一致条件(いずれか/全て)" Enums(any, all) default(any)
 // @Param offset query int false "開始位置" default(0) minimum(0)
 // @Param limit query int false "取得上限" default(12) minimum(1) maximum(100)
 func (h *EpicHandler) GetEpics(ctx *gin.Context) {
@@ -86,7 +92,7 @@ func (h *EpicHandler) GetEpics(ctx *gin.Context) {
 		return
 	}
 	var epics []models.Epic
-	query := createEpicQueryBuilder(params, h)
+	query := createEpicQueryBuilder(ctx, params, h)
 	if err := query.Find(&epics).Error; err != nil {
 		h.logger.Error("failed to get epics", zap.Error(err),
 			zap.String("trace_id", traceID))
@@ -116,7 +122,7 @@ func (h *EpicHandler) GetEpicDetail(ctx *gin.Context) {
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
 	var epic models.Epic
-	if err := h.Db.Where("id = ?", id).First(&epic).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).Preload("Labels").First(&epic).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to find coupon", zap.Error(err),
@@ -154,7 +160,7 @@ func (h *EpicHandler) CreateEpic(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
-	if err := h.Db.Create(&epic).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Create(&epic).Error; err != nil {
 		h.logger.Error("failed to create coupon", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create epic", err))
@@ -178,10 +184,34 @@ func (h *EpicHandler) CreateEpic(ctx *gin.Context) {
 // @Param epicRequest body epicRequest true "update epic"
 // @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
 func (h *EpicHandler) UpdateEpic(ctx *gin.Context) {
+	h.updateEpic(ctx, bindPut)
+}
+
+// PatchEpic @title epic部分編集
+// @id PatchEpic
+// @tags epics
+// @version バージョン(1.0)
+// @description epicを部分的に編集する。JSON Merge Patch(RFC 7396)で、nullを指定した項目は空にする
+// @description 指定した項目のみを検証し、id, author_id, labels, created_at, updated_atは変更できない
+// @Summary epic部分編集
+// @Produce json
+// @Success 202 {object} epicResponseItem
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /epics/:id [PATCH]
+// @Accept json
+// @Param epicRequest body epicRequest true "patch epic"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *EpicHandler) PatchEpic(ctx *gin.Context) {
+	h.updateEpic(ctx, bindMergePatch("id", "author_id", "labels", "created_at", "updated_at"))
+}
+
+// updateEpic PUTとPATCHで共通の編集処理。bindで更新内容を反映する
+func (h *EpicHandler) updateEpic(ctx *gin.Context, bind bindFunc) {
 	var epic models.Epic
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&epic).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&epic).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to update epic", zap.Error(err),
@@ -194,14 +224,23 @@ func (h *EpicHandler) UpdateEpic(ctx *gin.Context) {
 		}
 		return
 	}
-	if err := ctx.ShouldBindJSON(&epic); err != nil {
+	before := epicActivityFields(&epic)
+	if err := bind(ctx, &epic); err != nil {
 		res := createValidateErrorResponse(err)
 		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
 
-	if err := h.Db.Save(&epic).Error; err != nil {
+	epic.UpdatedAt = time.Now()
+	events := models.NewActivityEvents(models.CommentTargetEpic, strconv.FormatUint(epic.ID, 10),
+		appcontext.GetUserID(ctx), before, epicActivityFields(&epic), epic.UpdatedAt)
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&epic).Error; err != nil {
+			return err
+		}
+		return saveActivityEvents(tx, events)
+	}); err != nil {
 		h.logger.Error("failed to update epic", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update epic", err))
@@ -228,7 +267,7 @@ func (h *EpicHandler) DeleteEpic(ctx *gin.Context) {
 	var epic models.Epic
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&epic).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&epic).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to delete epic", zap.Error(err),
@@ -242,7 +281,7 @@ func (h *EpicHandler) DeleteEpic(ctx *gin.Context) {
 		return
 	}
 
-	if err := h.Db.Delete(&epic).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Delete(&epic).Error; err != nil {
 		h.logger.Error("failed to delete epic", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to delete epic", err))
@@ -252,12 +291,24 @@ func (h *EpicHandler) DeleteEpic(ctx *gin.Context) {
 	ctx.JSON(http.StatusNoContent, nil)
 }
 
-func createEpicQueryBuilder(params searchEpicParams, h *EpicHandler) *gorm.DB {
-	var products []models.Product
-	query := h.Db.Find(&products)
+// epicActivityFields 変更履歴の比較に使う項目。ステータスは開閉フラグをopen/closedで表す
+func epicActivityFields(epic *models.Epic) map[string]string {
+	status := "closed"
+	if epic.IsOpen {
+		status = "open"
+	}
+	return map[string]string{
+		models.ActivityFieldStatus:    status,
+		models.ActivityFieldAssignee:  epic.AssigneeID,
+		models.ActivityFieldMilestone: epic.MilestoneID,
+	}
+}
+
+func createEpicQueryBuilder(ctx *gin.Context, params searchEpicParams, h *EpicHandler) *gorm.DB {
+	query := tenantDB(ctx, h.Db).Preload("Labels")
 
 	if params.IsOpen != "" {
-		query = query.Where("IsOpen = ?", params.IsOpen)
+		query = query.Where("is_open = ?", params.IsOpen)
 	}
 	if params.AuthorID != "" {
 		query = query.Where("author_id = ?", params.AuthorID)
@@ -277,6 +328,7 @@ func createEpicQueryBuilder(params searchEpicParams, h *EpicHandler) *gorm.DB {
 	if params.ProjectID != "" {
 		query = query.Where("project_id = ?", params.ProjectID)
 	}
+	query = applyLabelFilter(query, "epic_labels", "epic_id", params.LabelIDs, params.LabelMatch)
 	if params.Offset != "" {
 		query = query.Offset(params.Offset)
 	}
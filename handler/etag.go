@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+// errVersionConflict 読み込んでから保存するまでの間に他の更新が行われた
+var errVersionConflict = errors.New("resource was modified by another request")
+
+// versionETag バージョンから作るETag。リソース自身の項目が更新されるたびに変わる。編集の応答に付ける
+func versionETag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// matchesETag If-Match、If-None-Matchの値にetagが含まれるか。"*"は全てに一致する
+func matchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyETag バージョンと本文のハッシュから作るETag。Preloadした関連リソースだけが変わった場合も変わる
+// If-Matchでは先頭のバージョンのみを比較する
+func bodyETag(version uint, body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + strconv.FormatUint(uint64(version), 10) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// matchesVersion If-Matchの値に、versionのversionETagかbodyETagが含まれるか。"*"は全てに一致する
+func matchesVersion(header string, version uint) bool {
+	prefix := `"` + strconv.FormatUint(uint64(version), 10) + "-"
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == versionETag(version) || strings.HasPrefix(candidate, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// respondWithETag 本文から作ったETagを付けて応答する。If-None-Matchが一致する場合は本文を返さず304にする
+func respondWithETag(ctx *gin.Context, version uint, obj interface{}) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to encode response", err))
+		return
+	}
+	etag := bodyETag(version, body)
+	ctx.Header("ETag", etag)
+	if inm := ctx.GetHeader("If-None-Match"); inm != "" && matchesETag(inm, etag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// checkIfMatch If-Matchが現在のバージョンと一致しない場合は412を返してfalseを返す
+// If-Matchがない場合も、保存の直前に読み込んだバージョンのままかをbumpVersionで確認する
+func checkIfMatch(ctx *gin.Context, version uint) bool {
+	ifMatch := ctx.GetHeader("If-Match")
+	if ifMatch == "" || matchesVersion(ifMatch, version) {
+		return true
+	}
+	ctx.Header("ETag", versionETag(version))
+	ctx.AbortWithStatusJSON(http.StatusPreconditionFailed,
+		errors.NewPreconditionFailedError("resource has been modified"))
+	return false
+}
+
+// bumpVersion 読み込んだ時点のバージョンのままであれば1つ進めて行をロックし、*versionを新しいバージョンにする
+// 他の更新が先に行われていればerrVersionConflictを返す。同じトランザクション内で続けて保存する
+func bumpVersion(tx *gorm.DB, table, id string, version *uint) error {
+	res := tx.Table(table).Where("id = ? AND version = ?", id, *version).
+		UpdateColumn("version", gorm.Expr("version + 1"))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	*version++
+	return nil
+}
+
+// respondVersionConflict 保存の直前に他の更新が行われた場合の応答。If-Matchを指定していれば412、なければ409を返す
+func respondVersionConflict(ctx *gin.Context) {
+	if ctx.GetHeader("If-Match") != "" {
+		ctx.AbortWithStatusJSON(http.StatusPreconditionFailed,
+			errors.NewPreconditionFailedError("resource has been modified"))
+		return
+	}
+	ctx.AbortWithStatusJSON(http.StatusConflict, errors.NewConflictError("resource has been modified"))
+}
+
+// deleteWithVersion 読み込んだ時点のバージョンのままであれば削除する。他の更新が先に行われていればerrVersionConflictを返す
+func deleteWithVersion(db *gorm.DB, value interface{}, version uint) error {
+	res := db.Where("version = ?", version).Delete(value)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchesETag(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{name: "一致する場合", header: `"2"`, want: true},
+		{name: "複数指定のいずれかに一致する場合", header: `"1", "2"`, want: true},
+		{name: "*を指定した場合", header: "*", want: true},
+		{name: "一致しない場合", header: `"1"`, want: false},
+		{name: "弱いETagの場合", header: `W/"2"`, want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, matchesETag(tt.header, versionETag(2)))
+		})
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		ifMatch    string
+		want       bool
+		wantStatus int
+	}{
+		{name: "If-Matchがない場合は続行すること", want: true, wantStatus: http.StatusOK},
+		{name: "If-Matchが一致する場合は続行すること", ifMatch: `"3"`, want: true, wantStatus: http.StatusOK},
+		{name: "詳細取得のETagのバージョンが一致する場合は続行すること", ifMatch: `"3-0123456789abcdef"`, want: true, wantStatus: http.StatusOK},
+		{name: "詳細取得のETagのバージョンが一致しない場合は412になること", ifMatch: `"2-0123456789abcdef"`, want: false, wantStatus: http.StatusPreconditionFailed},
+		{name: "バージョンの桁が異なる場合は412になること", ifMatch: `"33-0123456789abcdef"`, want: false, wantStatus: http.StatusPreconditionFailed},
+		{name: "If-Matchが一致しない場合は412になること", ifMatch: `"2"`, want: false, wantStatus: http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rec := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(rec)
+			ctx.Request = httptest.NewRequest(http.MethodPut, "/projects/1", nil)
+			if tt.ifMatch != "" {
+				ctx.Request.Header.Set("If-Match", tt.ifMatch)
+			}
+			assert.Equal(t, tt.want, checkIfMatch(ctx, 3))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestRespondWithETag(t *testing.T) {
+	t.Parallel()
+
+	type child struct {
+		Quantity int `json:"quantity"`
+	}
+	type parent struct {
+		Version  uint    `json:"version"`
+		Children []child `json:"children"`
+	}
+	respond := func(obj parent, ifNoneMatch string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+		if ifNoneMatch != "" {
+			ctx.Request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		respondWithETag(ctx, obj.Version, obj)
+		// ルーターを通さないため、本文のない応答はここでステータスを書き込む
+		ctx.Writer.WriteHeaderNow()
+		return rec
+	}
+
+	t.Run("If-None-Matchが一致する場合は304になること", func(t *testing.T) {
+		t.Parallel()
+		obj := parent{Version: 1, Children: []child{{Quantity: 1}}}
+		rec := respond(obj, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"version":1,"children":[{"quantity":1}]}`, rec.Body.String())
+		etag := rec.Header().Get("ETag")
+
+		rec = respond(obj, etag)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("バージョンが同じでも関連リソースが変わった場合は本文を返すこと", func(t *testing.T) {
+		t.Parallel()
+		etag := respond(parent{Version: 1, Children: []child{{Quantity: 1}}}, "").Header().Get("ETag")
+
+		rec := respond(parent{Version: 1, Children: []child{{Quantity: 2}}}, etag)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+	})
+}
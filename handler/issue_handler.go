@@ -1,44 +1,69 @@
 package handler
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/AI1411/golang-admin-api/models"
 	"github.com/AI1411/golang-admin-api/util/appcontext"
 	"github.com/AI1411/golang-admin-api/util/errors"
+	"github.com/AI1411/golang-admin-api/webhook"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/copier"
 	"github.com/jinzhu/gorm"
 	"go.uber.org/zap"
-	"net/http"
 )
 
 type IssueHandler struct {
-	Db     *gorm.DB
-	logger *zap.Logger
+	Db            *gorm.DB
+	logger        *zap.Logger
+	uuidGenerator models.UUIDGenerator
 }
 
-func NewIssueHandler(db *gorm.DB, logger *zap.Logger) *IssueHandler {
+func NewIssueHandler(db *gorm.DB, logger *zap.Logger, uuidGenerator models.UUIDGenerator) *IssueHandler {
 	return &IssueHandler{
-		Db:     db,
-		logger: logger,
+		Db:            db,
+		logger:        logger,
+		uuidGenerator: uuidGenerator,
 	}
 }
 
 type issueRequest struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description" binding:"omitempty,max=255"`
-	UserID      string `json:"user_id" binding:"omitempty"`
-	MilestoneID string `json:"milestone_id" binding:"omitempty"`
-	IssueStatus string `json:"issue_status" binding:"required"`
+	Title       string  `json:"title" binding:"required,max=64" example:"title"`
+	Description string  `json:"description" binding:"omitempty,max=255" example:"description"`
+	UserID      string  `json:"user_id" binding:"omitempty,len=36" example:"443b5f1c-8a3a-4485-b3bc-05e69b40b290"`
+	ProjectID   string  `json:"project_id" binding:"required,len=36" example:"443b5f1c-8a3a-4485-b3bc-05e69b40b290"`
+	MilestoneID string  `json:"milestone_id" binding:"omitempty,len=36" example:"443b5f1c-8a3a-4485-b3bc-05e69b40b290"`
+	EpicID      *uint64 `json:"epic_id" binding:"omitempty" example:"1"`
+	IssueStatus string  `json:"issue_status" binding:"omitempty,max=64" example:"waiting"`
+	StoryPoints *uint   `json:"story_points" binding:"omitempty,max=100" example:"3"`
+	// Version 編集時に指定すると、現在の値と一致しない場合は409を返す
+	Version *uint `json:"version" binding:"omitempty" example:"1"`
+}
+
+type assignIssueRequest struct {
+	UserID string `json:"user_id" binding:"omitempty,len=36" example:"443b5f1c-8a3a-4485-b3bc-05e69b40b290"`
+}
+
+type moveIssueRequest struct {
+	IssueStatus string `json:"issue_status" binding:"required,max=64" example:"in_progress"`
+	PrevID      string `json:"prev_id" binding:"omitempty,len=36" example:"443b5f1c-8a3a-4485-b3bc-05e69b40b290"`
+	NextID      string `json:"next_id" binding:"omitempty,len=36" example:"443b5f1c-8a3a-4485-b3bc-05e69b40b290"`
+	Version     uint   `json:"version" binding:"required" example:"1"`
 }
 
 type issueResponseItem struct {
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	UserID      string `json:"user_id"`
-	MilestoneID string `json:"milestone_id"`
-	IssueStatus string `json:"issue_status"`
-	CreatedAt   string `json:"created_at"`
+	ID          string           `json:"id"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	UserID      string           `json:"user_id"`
+	ProjectID   string           `json:"project_id"`
+	MilestoneID string           `json:"milestone_id"`
+	EpicID      *uint64          `json:"epic_id"`
+	IssueStatus string           `json:"issue_status"`
+	StoryPoints *uint            `json:"story_points"`
+	CreatedAt   string           `json:"created_at"`
+	Labels      models.LabelList `json:"labels"`
 }
 
 type issueResponse struct {
@@ -47,13 +72,18 @@ type issueResponse struct {
 }
 
 type searchIssueParams struct {
-	ID          string `form:"id" binding:"omitempty"`
-	Title       string `form:"title" binding:"omitempty,max=64"`
-	Description string `form:"description" binding:"omitempty,max=255"`
-	UserID      string `form:"assignee_id" binding:"omitempty,len=36" `
-	MilestoneID string `form:"milestone_id" binding:"omitempty,len=36" `
-	Offset      string `form:"offset,default=0" binding:"omitempty,numeric"`
-	Limit       string `form:"limit,default=10" binding:"omitempty,numeric"`
+	ID          string   `form:"id" binding:"omitempty"`
+	Title       string   `form:"title" binding:"omitempty,max=64"`
+	Description string   `form:"description" binding:"omitempty,max=255"`
+	UserID      string   `form:"assignee_id" binding:"omitempty,len=36" `
+	ProjectID   string   `form:"project_id" binding:"omitempty,len=36" `
+	MilestoneID string   `form:"milestone_id" binding:"omitempty,len=36" `
+	EpicID      string   `form:"epic_id" binding:"omitempty,numeric" `
+	IssueStatus string   `form:"issue_status" binding:"omitempty,max=64" `
+	LabelIDs    []string `form:"label_ids" binding:"omitempty,dive,len=36"`
+	LabelMatch  string   `form:"label_match" binding:"omitempty,oneof=any all"`
+	Offset      string   `form:"offset,default=0" binding:"omitempty,numeric"`
+	Limit       string   `form:"limit,default=10" binding:"omitempty,numeric"`
 }
 
 // GetIssues @title 一覧取得
@@ -68,10 +98,15 @@ type searchIssueParams struct {
 // @Failure 500 {object} errorResponse
 // @Router /issues [GET]
 // @Param id query string false "ID" minlength(36) maxlength(36) format(UUID v4)
-// @Param user_id query string false "ユーザID" minlength(36) maxlength(36) format(UUID v4)
+// @Param assignee_id query string false "担当者ID" minlength(36) maxlength(36) format(UUID v4)
 // @Param title query string false "タイトル" minlength(1) maxlength(64)
 // @Param description query string false "Issue description" minlength(1) maxlength(255)
+// @Param project_id query string false "プロジェクトID" minlength(36) maxlength(36) format(UUID v4)
 // @Param milestone_id query string false "マイルストーンID" minlength(36) maxlength(36) format(UUID v4)
+// @Param epic_id query int false "エピックID"
+// @Param issue_status query string false "Issueステータス" maxlength(64)
+// @Param label_ids query []string false "ラベルID" collectionFormat(multi)
+// @Param label_match query string false "ラベルの一致条件(いずれか/全て)" Enums(any, all) default(any)
 // @Param offset query int false "開始位置" default(0) minimum(0)
 // @Param limit query int false "取得上限" default(12) minimum(1) maximum(100)
 func (h *IssueHandler) GetIssues(ctx *gin.Context) {
@@ -84,7 +119,7 @@ func (h *IssueHandler) GetIssues(ctx *gin.Context) {
 		return
 	}
 	var issues []models.Issue
-	query := createIssueQueryBuilder(params, h)
+	query := createIssueQueryBuilder(ctx, params, h)
 	if err := query.Find(&issues).Error; err != nil {
 		h.logger.Error("failed to get issues", zap.Error(err),
 			zap.String("trace_id", traceID))
@@ -101,25 +136,580 @@ func (h *IssueHandler) GetIssues(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, response)
 }
 
-func createIssueQueryBuilder(params searchIssueParams, h *IssueHandler) *gorm.DB {
-	var products []models.Product
-	query := h.Db.Find(&products)
+// GetIssueDetail @title issue詳細
+// @id GetIssueDetail
+// @tags issues
+// @version バージョン(1.0)
+// @description issue詳細を返す
+// @Summary issue詳細取得
+// @Produce json
+// @Success 200 {object} models.Issue
+// @Success 304
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /issues/:id [GET]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *IssueHandler) GetIssueDetail(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var issue models.Issue
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).Preload("Milestone").Preload("Labels").First(&issue).Error; err != nil {
+		h.abortIssueLookup(ctx, traceID, err)
+		return
+	}
+	respondWithETag(ctx, issue.Version, issue)
+}
+
+// CreateIssue @title issue作成
+// @id CreateIssue
+// @tags issues
+// @version バージョン(1.0)
+// @description issueを作成する。ステータスはプロジェクトのワークフローの最初のステータスのみ指定できる
+// @Summary issue作成
+// @Produce json
+// @Success 201 {object} models.Issue
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /issues [POST]
+// @Accept json
+// @Param issueRequest body issueRequest true "create issue"
+func (h *IssueHandler) CreateIssue(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req issueRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if err := validateIssueRelations(tenantDB(ctx, h.Db), &req); err != nil {
+		h.abortIssueError(ctx, traceID, err)
+		return
+	}
+	workflow, err := findIssueWorkflow(tenantDB(ctx, h.Db), req.ProjectID)
+	if err != nil {
+		h.abortIssueError(ctx, traceID, err)
+		return
+	}
+	if req.IssueStatus == "" {
+		req.IssueStatus = workflow.Initial()
+	}
+	if req.IssueStatus != workflow.Initial() {
+		ctx.JSON(http.StatusBadRequest,
+			errors.NewBadRequestError("issue_status must be "+workflow.Initial()))
+		return
+	}
+
+	rank, err := bottomRank(tenantDB(ctx, h.Db), req.ProjectID, req.IssueStatus)
+	if err != nil {
+		h.abortIssueError(ctx, traceID, err)
+		return
+	}
+
+	issue := models.Issue{
+		ID:          h.uuidGenerator.GenerateUUID(),
+		Title:       req.Title,
+		Description: req.Description,
+		UserID:      req.UserID,
+		ProjectID:   req.ProjectID,
+		MilestoneID: req.MilestoneID,
+		EpicID:      req.EpicID,
+		IssueStatus: req.IssueStatus,
+		StoryPoints: req.StoryPoints,
+		BoardRank:   rank,
+		Version:     1,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := tenantDB(ctx, h.Db).Create(&issue).Error; err != nil {
+		h.logger.Error("failed to create issue", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create issue", err))
+		return
+	}
+	ctx.JSON(http.StatusCreated, issue)
+}
+
+// UpdateIssue @title issue編集
+// @id UpdateIssue
+// @tags issues
+// @version バージョン(1.0)
+// @description issueを編集する。ステータスはワークフロー上の隣り合うステータスにのみ変更できる。
+// @description versionが現在の値と一致しない場合、または読み込んでから保存するまでに他の操作で更新された場合は409を返す
+// @Summary issue編集
+// @Produce json
+// @Success 202 {object} models.Issue
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /issues/:id [PUT]
+// @Accept json
+// @Param issueRequest body issueRequest true "update issue"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param If-Match header string false "取得時のETag"
+func (h *IssueHandler) UpdateIssue(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var issue models.Issue
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&issue).Error; err != nil {
+		h.abortIssueLookup(ctx, traceID, err)
+		return
+	}
+	if !checkIfMatch(ctx, issue.Version) {
+		return
+	}
+	var req issueRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if req.Version != nil && *req.Version != issue.Version {
+		ctx.JSON(http.StatusConflict, errors.NewConflictError("issue has been modified by another request"))
+		return
+	}
+	if issue.ProjectID != "" && req.ProjectID != issue.ProjectID {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("project_id cannot be changed"))
+		return
+	}
+	if err := validateIssueRelations(tenantDB(ctx, h.Db), &req); err != nil {
+		h.abortIssueError(ctx, traceID, err)
+		return
+	}
+	before := issueActivityFields(&issue)
+	if req.IssueStatus != "" && req.IssueStatus != issue.IssueStatus {
+		workflow, err := findIssueWorkflow(tenantDB(ctx, h.Db), req.ProjectID)
+		if err != nil {
+			h.abortIssueError(ctx, traceID, err)
+			return
+		}
+		if !workflow.CanTransition(issue.IssueStatus, req.IssueStatus) {
+			ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError(
+				"cannot transition issue_status from "+issue.IssueStatus+" to "+req.IssueStatus))
+			return
+		}
+		rank, err := bottomRank(tenantDB(ctx, h.Db), issue.ProjectID, req.IssueStatus)
+		if err != nil {
+			h.abortIssueError(ctx, traceID, err)
+			return
+		}
+		issue.IssueStatus = req.IssueStatus
+		issue.BoardRank = rank
+	}
+
+	issue.Title = req.Title
+	issue.Description = req.Description
+	issue.UserID = req.UserID
+	issue.ProjectID = req.ProjectID
+	issue.MilestoneID = req.MilestoneID
+	issue.EpicID = req.EpicID
+	issue.StoryPoints = req.StoryPoints
+	issue.UpdatedAt = time.Now()
+	events := models.NewActivityEvents(models.CommentTargetIssue, issue.ID, appcontext.GetUserID(ctx),
+		before, issueActivityFields(&issue), issue.UpdatedAt)
+	if err := h.saveIssue(ctx, &issue, events); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			respondVersionConflict(ctx)
+			return
+		}
+		h.logger.Error("failed to update issue", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update issue", err))
+		return
+	}
+	ctx.Header("ETag", versionETag(issue.Version))
+	ctx.JSON(http.StatusAccepted, issue)
+}
+
+// AssignIssue @title issue担当者設定
+// @id AssignIssue
+// @tags issues
+// @version バージョン(1.0)
+// @description issueの担当者を設定する。user_idを省略すると担当者を外す。
+// @description 読み込んでから保存するまでに他の操作で更新された場合は409を返す
+// @Summary issue担当者設定
+// @Produce json
+// @Success 202 {object} models.Issue
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /issues/:id/assign [POST]
+// @Accept json
+// @Param assignIssueRequest body assignIssueRequest true "assign issue"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *IssueHandler) AssignIssue(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var issue models.Issue
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&issue).Error; err != nil {
+		h.abortIssueLookup(ctx, traceID, err)
+		return
+	}
+	var req assignIssueRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if req.UserID != "" {
+		if err := existsRecord(tenantDB(ctx, h.Db), "users", req.UserID, "user not found"); err != nil {
+			h.abortIssueError(ctx, traceID, err)
+			return
+		}
+	}
+
+	before := issueActivityFields(&issue)
+	issue.UserID = req.UserID
+	issue.UpdatedAt = time.Now()
+	events := models.NewActivityEvents(models.CommentTargetIssue, issue.ID, appcontext.GetUserID(ctx),
+		before, issueActivityFields(&issue), issue.UpdatedAt)
+	if err := h.saveIssue(ctx, &issue, events); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			respondVersionConflict(ctx)
+			return
+		}
+		h.logger.Error("failed to assign issue", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to assign issue", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, issue)
+}
+
+// MoveIssue @title issue移動
+// @id MoveIssue
+// @tags issues
+// @version バージョン(1.0)
+// @description ボード上でissueを移動する。prev_idの直後、またはnext_idの直前に並べ、どちらも省略すると列の末尾に並べる。
+// @description versionが現在の値と一致しない場合は他の操作と競合したとみなし409を返す
+// @Summary issue移動
+// @Produce json
+// @Success 202 {object} models.Issue
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /issues/:id/move [POST]
+// @Accept json
+// @Param moveIssueRequest body moveIssueRequest true "move issue"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *IssueHandler) MoveIssue(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var issue models.Issue
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&issue).Error; err != nil {
+		h.abortIssueLookup(ctx, traceID, err)
+		return
+	}
+	var req moveIssueRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if req.PrevID == issue.ID || req.NextID == issue.ID {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("prev_id and next_id must be other issues"))
+		return
+	}
+	if req.Version != issue.Version {
+		ctx.JSON(http.StatusConflict, errors.NewConflictError("issue has been modified by another request"))
+		return
+	}
+	workflow, err := findIssueWorkflow(tenantDB(ctx, h.Db), issue.ProjectID)
+	if err != nil {
+		h.abortIssueError(ctx, traceID, err)
+		return
+	}
+	if !workflow.CanTransition(issue.IssueStatus, req.IssueStatus) {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError(
+			"cannot transition issue_status from "+issue.IssueStatus+" to "+req.IssueStatus))
+		return
+	}
+
+	before := issueActivityFields(&issue)
+	err = tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		rank, err := rankForMove(tx, &issue, &req)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		// 読み込んだ時点のversionを条件に更新し、同時に行われた移動や編集を上書きしないようにする
+		result := tx.Table("issues").Where("id = ? AND version = ?", issue.ID, req.Version).Updates(map[string]interface{}{
+			"issue_status": req.IssueStatus,
+			"board_rank":   rank,
+			"version":      gorm.Expr("version + 1"),
+			"updated_at":   now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.NewConflictError("issue has been modified by another request")
+		}
+		issue.IssueStatus = req.IssueStatus
+		issue.BoardRank = rank
+		issue.Version = req.Version + 1
+		issue.UpdatedAt = now
+
+		events := models.NewActivityEvents(models.CommentTargetIssue, issue.ID, appcontext.GetUserID(ctx),
+			before, issueActivityFields(&issue), now)
+		if err := saveActivityEvents(tx, events); err != nil {
+			return err
+		}
+		return webhook.Enqueue(tx, webhook.EventIssueUpdated, issue)
+	})
+	if err != nil {
+		if restErr, ok := err.(errors.RestErr); ok {
+			ctx.JSON(restErr.Status(), restErr)
+			return
+		}
+		h.logger.Error("failed to move issue", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to move issue", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, issue)
+}
+
+// DeleteIssue @title issue削除
+// @id DeleteIssue
+// @tags issues
+// @version バージョン(1.0)
+// @description issueを削除する
+// @Summary issue削除
+// @Success 204
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /issues/:id [DELETE]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *IssueHandler) DeleteIssue(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var issue models.Issue
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&issue).Error; err != nil {
+		h.abortIssueLookup(ctx, traceID, err)
+		return
+	}
+	if err := tenantDB(ctx, h.Db).Delete(&issue).Error; err != nil {
+		h.logger.Error("failed to delete issue", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to delete issue", err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// saveIssue issueを保存し、変更履歴とissue.updatedイベントを同じトランザクションで登録する
+// 読み込んだ時点のversionを条件に更新し、他の移動や編集が先に行われていればerrVersionConflictを返す
+func (h *IssueHandler) saveIssue(ctx *gin.Context, issue *models.Issue, events []models.ActivityEvent) error {
+	return tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := bumpVersion(tx, "issues", issue.ID, &issue.Version); err != nil {
+			return err
+		}
+		if err := tx.Save(issue).Error; err != nil {
+			return err
+		}
+		if err := saveActivityEvents(tx, events); err != nil {
+			return err
+		}
+		return webhook.Enqueue(tx, webhook.EventIssueUpdated, issue)
+	})
+}
+
+// rankForMove 移動先の列でprev_id・next_idの間に並ぶ値を返す
+// 列の並び順が未設定や重複で壊れている場合は、先に列全体を振り直す
+func rankForMove(tx *gorm.DB, issue *models.Issue, req *moveIssueRequest) (string, error) {
+	var column []models.Issue
+	if err := tx.Where("project_id = ? AND issue_status = ? AND id <> ?",
+		issue.ProjectID, req.IssueStatus, issue.ID).Find(&column).Error; err != nil {
+		return "", err
+	}
+	models.SortIssuesByRank(column)
+	if !models.HasValidRanks(column) {
+		for i, rank := range models.RankSequence(len(column)) {
+			if err := tx.Table("issues").Where("id = ?", column[i].ID).
+				Updates(map[string]interface{}{"board_rank": rank}).Error; err != nil {
+				return "", err
+			}
+			column[i].BoardRank = rank
+		}
+	}
+
+	indexOf := func(id string) int {
+		for i := range column {
+			if column[i].ID == id {
+				return i
+			}
+		}
+		return -1
+	}
+	var prevRank, nextRank string
+	switch {
+	case req.PrevID != "":
+		i := indexOf(req.PrevID)
+		if i < 0 {
+			return "", errors.NewBadRequestError("prev_id is not in the column")
+		}
+		prevRank = column[i].BoardRank
+		if i+1 < len(column) {
+			nextRank = column[i+1].BoardRank
+		}
+		// 両方指定された場合は隣り合っていなければ、クライアントの表示が古いとみなす
+		if req.NextID != "" && (i+1 >= len(column) || column[i+1].ID != req.NextID) {
+			return "", errors.NewConflictError("board has been changed by another request")
+		}
+	case req.NextID != "":
+		i := indexOf(req.NextID)
+		if i < 0 {
+			return "", errors.NewBadRequestError("next_id is not in the column")
+		}
+		nextRank = column[i].BoardRank
+		if i > 0 {
+			prevRank = column[i-1].BoardRank
+		}
+	case len(column) > 0:
+		prevRank = column[len(column)-1].BoardRank
+	}
+	return models.RankBetween(prevRank, nextRank)
+}
+
+// bottomRank 列の末尾に並ぶ値を返す
+func bottomRank(db *gorm.DB, projectID, status string) (string, error) {
+	var last models.Issue
+	err := db.Where("project_id = ? AND issue_status = ?", projectID, status).
+		Order("board_rank desc").First(&last).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return "", err
+	}
+	rank, err := models.RankBetween(last.BoardRank, "")
+	if err != nil {
+		// 並び順が壊れている場合は未設定として末尾に並べ、次の移動時に振り直す
+		return "", nil
+	}
+	return rank, nil
+}
+
+func issueActivityFields(issue *models.Issue) map[string]string {
+	return map[string]string{
+		models.ActivityFieldStatus:    issue.IssueStatus,
+		models.ActivityFieldAssignee:  issue.UserID,
+		models.ActivityFieldMilestone: issue.MilestoneID,
+	}
+}
+
+func (h *IssueHandler) abortIssueLookup(ctx *gin.Context, traceID string, err error) {
+	switch err {
+	case gorm.ErrRecordNotFound:
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("issue not found"))
+	case gorm.ErrInvalidSQL:
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid sql"))
+	default:
+		h.logger.Error("failed to get issue", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get issue", err))
+	}
+}
+
+func (h *IssueHandler) abortIssueError(ctx *gin.Context, traceID string, err error) {
+	if restErr, ok := err.(errors.RestErr); ok {
+		ctx.JSON(restErr.Status(), restErr)
+		return
+	}
+	h.logger.Error("failed to validate issue", zap.Error(err),
+		zap.String("trace_id", traceID))
+	ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to validate issue", err))
+}
+
+// validateIssueRelations 参照先が存在し、milestoneとepicが同じprojectに属していることを確認する
+func validateIssueRelations(db *gorm.DB, req *issueRequest) error {
+	if err := existsRecord(db, "projects", req.ProjectID, "project not found"); err != nil {
+		return err
+	}
+	if req.UserID != "" {
+		if err := existsRecord(db, "users", req.UserID, "user not found"); err != nil {
+			return err
+		}
+	}
+	if req.MilestoneID != "" {
+		var milestone models.Milestone
+		if err := db.Where("id = ?", req.MilestoneID).First(&milestone).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return errors.NewBadRequestError("milestone not found")
+			}
+			return err
+		}
+		if milestone.ProjectID != req.ProjectID {
+			return errors.NewBadRequestError("milestone does not belong to the project")
+		}
+	}
+	if req.EpicID != nil {
+		var epic models.Epic
+		if err := db.Where("id = ?", *req.EpicID).First(&epic).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return errors.NewBadRequestError("epic not found")
+			}
+			return err
+		}
+		if epic.ProjectID != req.ProjectID {
+			return errors.NewBadRequestError("epic does not belong to the project")
+		}
+	}
+	return nil
+}
+
+// existsRecord 指定したテーブルにIDが存在しない場合はBadRequestを返す
+func existsRecord(db *gorm.DB, table, id, message string) error {
+	var count int
+	if err := db.Table(table).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.NewBadRequestError(message)
+	}
+	return nil
+}
+
+// findIssueWorkflow projectのワークフローを返す。未設定の場合は既定のワークフローを返す
+func findIssueWorkflow(db *gorm.DB, projectID string) (models.IssueWorkflow, error) {
+	var workflow models.ProjectWorkflow
+	if err := db.Where("project_id = ?", projectID).First(&workflow).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return models.DefaultIssueWorkflow, nil
+		}
+		return nil, err
+	}
+	return workflow.Workflow(), nil
+}
+
+func createIssueQueryBuilder(ctx *gin.Context, params searchIssueParams, h *IssueHandler) *gorm.DB {
+	query := tenantDB(ctx, h.Db).Preload("Labels")
 
 	if params.ID != "" {
 		query = query.Where("id = ?", params.ID)
 	}
 	if params.Title != "" {
-		query = query.Where("id LIKE ?", "%"+params.Title+"%")
+		query = query.Where("title LIKE ?", "%"+params.Title+"%")
 	}
 	if params.Description != "" {
 		query = query.Where("description LIKE ?", "%"+params.Description+"%")
 	}
 	if params.UserID != "" {
-		query = query.Where("user_id ?", params.ID)
+		query = query.Where("user_id = ?", params.UserID)
+	}
+	if params.ProjectID != "" {
+		query = query.Where("project_id = ?", params.ProjectID)
 	}
 	if params.MilestoneID != "" {
 		query = query.Where("milestone_id = ?", params.MilestoneID)
 	}
+	if params.EpicID != "" {
+		query = query.Where("epic_id = ?", params.EpicID)
+	}
+	if params.IssueStatus != "" {
+		query = query.Where("issue_status = ?", params.IssueStatus)
+	}
+	query = applyLabelFilter(query, "issue_labels", "issue_id", params.LabelIDs, params.LabelMatch)
 	if params.Offset != "" {
 		query = query.Offset(params.Offset)
 	}
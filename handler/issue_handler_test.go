@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/middleware"
+)
+
+var updateIssueTestCases = []struct {
+	tid        int
+	name       string
+	issueID    string
+	request    map[string]interface{}
+	wantStatus int
+	wantBody   string
+}{
+	{
+		tid:     1,
+		name:    "次のステータスへ変更できること",
+		issueID: "2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c01",
+		request: map[string]interface{}{
+			"title":        "issue1",
+			"project_id":   projectIDForTest,
+			"issue_status": "in_progress",
+		},
+		wantStatus: http.StatusAccepted,
+	},
+	{
+		tid:     2,
+		name:    "ステータスを飛ばして変更した場合400エラーになること",
+		issueID: "2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c02",
+		request: map[string]interface{}{
+			"title":        "issue2",
+			"project_id":   projectIDForTest,
+			"issue_status": "done",
+		},
+		wantStatus: http.StatusBadRequest,
+		wantBody:   `{"message": "cannot transition issue_status from waiting to done","status": 400,"error": "bad_request","causes": null}`,
+	},
+	{
+		tid:     3,
+		name:    "1つ前のステータスへ差し戻せること",
+		issueID: "2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c03",
+		request: map[string]interface{}{
+			"title":        "issue3",
+			"project_id":   projectIDForTest,
+			"issue_status": "in_progress",
+		},
+		wantStatus: http.StatusAccepted,
+	},
+	{
+		tid:     4,
+		name:    "存在しないIDを指定した場合404エラーになること",
+		issueID: "invalid_issue",
+		request: map[string]interface{}{
+			"title":      "issue",
+			"project_id": projectIDForTest,
+		},
+		wantStatus: http.StatusNotFound,
+		wantBody:   `{"message": "issue not found","status": 404,"error": "not_found","causes": null}`,
+	},
+}
+
+func TestUpdateIssue(t *testing.T) {
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE projects")
+	dbConn.Exec("TRUNCATE TABLE project_workflows")
+	dbConn.Exec("TRUNCATE TABLE issues")
+	dbConn.Exec("insert into projects (id, project_title, project_description, created_at, updated_at)values('090e142d-baa3-4039-9d21-cf5a1af39094','1','1','2022-06-20 22:14:22','2022-06-20 22:14:22');")
+	dbConn.Exec("insert into issues (id, title, description, user_id, project_id, milestone_id, issue_status, created_at, updated_at)values('2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c01','issue1','','','090e142d-baa3-4039-9d21-cf5a1af39094','','waiting','2022-09-19 10:00:00','2022-09-19 10:00:00'),('2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c02','issue2','','','090e142d-baa3-4039-9d21-cf5a1af39094','','waiting','2022-09-19 10:00:00','2022-09-19 10:00:00'),('2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c03','issue3','','','090e142d-baa3-4039-9d21-cf5a1af39094','','review','2022-09-19 10:00:00','2022-09-19 10:00:00');")
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(func(_ *gin.Context) { binding.EnableDecoderUseNumber = true })
+	r.Use(middleware.NewTracing())
+	r.Use(middleware.NewLogging(zapLogger))
+	issueHandler := NewIssueHandler(dbConn, zapLogger, nil)
+	r.PUT("/issues/:id", issueHandler.UpdateIssue)
+
+	for _, tt := range updateIssueTestCases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rec := httptest.NewRecorder()
+			jsonStr, _ := json.Marshal(tt.request)
+			req := httptest.NewRequest(http.MethodPut, "/issues/"+tt.issueID, bytes.NewBuffer(jsonStr))
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, rec.Body.String())
+				return
+			}
+			var got map[string]interface{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, tt.request["issue_status"], got["issue_status"])
+		})
+	}
+}
+
+var moveIssueTestCases = []struct {
+	tid        int
+	name       string
+	issueID    string
+	request    map[string]interface{}
+	wantStatus int
+	wantBody   string
+}{
+	{
+		tid:     1,
+		name:    "隣の列の末尾へ移動できること",
+		issueID: "2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c01",
+		request: map[string]interface{}{
+			"issue_status": "in_progress",
+			"version":      1,
+		},
+		wantStatus: http.StatusAccepted,
+	},
+	{
+		tid:     2,
+		name:    "移動済みのissueの直前へ移動できること",
+		issueID: "2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c02",
+		request: map[string]interface{}{
+			"issue_status": "in_progress",
+			"next_id":      "2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c01",
+			"version":      1,
+		},
+		wantStatus: http.StatusAccepted,
+	},
+	{
+		tid:     3,
+		name:    "versionが古い場合409エラーになること",
+		issueID: "2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c01",
+		request: map[string]interface{}{
+			"issue_status": "review",
+			"version":      1,
+		},
+		wantStatus: http.StatusConflict,
+		wantBody:   `{"message": "issue has been modified by another request","status": 409,"error": "conflict","causes": null}`,
+	},
+	{
+		tid:     4,
+		name:    "移動先の列に無いissueを指定した場合400エラーになること",
+		issueID: "2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c03",
+		request: map[string]interface{}{
+			"issue_status": "review",
+			"prev_id":      "2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c01",
+			"version":      1,
+		},
+		wantStatus: http.StatusBadRequest,
+		wantBody:   `{"message": "prev_id is not in the column","status": 400,"error": "bad_request","causes": null}`,
+	},
+	{
+		tid:     5,
+		name:    "ステータスを飛ばして移動した場合400エラーになること",
+		issueID: "2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c03",
+		request: map[string]interface{}{
+			"issue_status": "waiting",
+			"version":      1,
+		},
+		wantStatus: http.StatusBadRequest,
+		wantBody:   `{"message": "cannot transition issue_status from review to waiting","status": 400,"error": "bad_request","causes": null}`,
+	},
+}
+
+func TestMoveIssue(t *testing.T) {
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE projects")
+	dbConn.Exec("TRUNCATE TABLE project_workflows")
+	dbConn.Exec("TRUNCATE TABLE issues")
+	dbConn.Exec("insert into projects (id, project_title, project_description, created_at, updated_at)values('090e142d-baa3-4039-9d21-cf5a1af39094','1','1','2022-06-20 22:14:22','2022-06-20 22:14:22');")
+	dbConn.Exec("insert into issues (id, title, description, user_id, project_id, milestone_id, issue_status, board_rank, version, created_at, updated_at)values('2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c01','issue1','','','090e142d-baa3-4039-9d21-cf5a1af39094','','waiting','i',1,'2022-09-19 10:00:00','2022-09-19 10:00:00'),('2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c02','issue2','','','090e142d-baa3-4039-9d21-cf5a1af39094','','waiting','r',1,'2022-09-19 10:00:00','2022-09-19 10:00:00'),('2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c03','issue3','','','090e142d-baa3-4039-9d21-cf5a1af39094','','review','i',1,'2022-09-19 10:00:00','2022-09-19 10:00:00');")
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(func(_ *gin.Context) { binding.EnableDecoderUseNumber = true })
+	r.Use(middleware.NewTracing())
+	r.Use(middleware.NewLogging(zapLogger))
+	issueHandler := NewIssueHandler(dbConn, zapLogger, nil)
+	r.POST("/issues/:id/move", issueHandler.MoveIssue)
+
+	// 前のケースの移動結果に依存するため順番に実行する
+	for _, tt := range moveIssueTestCases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			jsonStr, _ := json.Marshal(tt.request)
+			req := httptest.NewRequest(http.MethodPost, "/issues/"+tt.issueID+"/move", bytes.NewBuffer(jsonStr))
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, rec.Body.String())
+				return
+			}
+			var got map[string]interface{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, tt.request["issue_status"], got["issue_status"])
+			assert.EqualValues(t, 2, got["version"])
+		})
+	}
+}
+
+func TestUpdateIssueAfterMove(t *testing.T) {
+	const issueID = "2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c01"
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE projects")
+	dbConn.Exec("TRUNCATE TABLE project_workflows")
+	dbConn.Exec("TRUNCATE TABLE issues")
+	dbConn.Exec("insert into projects (id, project_title, project_description, created_at, updated_at)values('090e142d-baa3-4039-9d21-cf5a1af39094','1','1','2022-06-20 22:14:22','2022-06-20 22:14:22');")
+	dbConn.Exec("insert into issues (id, title, description, user_id, project_id, milestone_id, issue_status, board_rank, version, created_at, updated_at)values('2d2c8b3e-4bd5-4b39-8c0f-1a6b1e4c0c01','issue1','','','090e142d-baa3-4039-9d21-cf5a1af39094','','waiting','i',1,'2022-09-19 10:00:00','2022-09-19 10:00:00');")
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(func(_ *gin.Context) { binding.EnableDecoderUseNumber = true })
+	r.Use(middleware.NewTracing())
+	r.Use(middleware.NewLogging(zapLogger))
+	issueHandler := NewIssueHandler(dbConn, zapLogger, nil)
+	r.PUT("/issues/:id", issueHandler.UpdateIssue)
+	r.POST("/issues/:id/move", issueHandler.MoveIssue)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/issues/"+issueID+"/move",
+		bytes.NewBufferString(`{"issue_status":"in_progress","version":1}`)))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var moved map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &moved))
+
+	assertNotOverwritten := func(t *testing.T) {
+		t.Helper()
+		var status, rank string
+		var version uint
+		row := dbConn.Raw("SELECT issue_status, board_rank, version FROM issues WHERE id = ?", issueID).Row()
+		require.NoError(t, row.Scan(&status, &rank, &version))
+		assert.Equal(t, moved["issue_status"], status)
+		assert.Equal(t, moved["board_rank"], rank)
+		assert.EqualValues(t, 2, version)
+	}
+
+	t.Run("移動前のversionで編集した場合409エラーになり、移動結果を上書きしないこと", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/issues/"+issueID,
+			bytes.NewBufferString(`{"title":"stale","project_id":"090e142d-baa3-4039-9d21-cf5a1af39094","issue_status":"waiting","version":1}`)))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assertNotOverwritten(t)
+	})
+
+	t.Run("移動前のETagをIf-Matchに指定した場合412エラーになり、移動結果を上書きしないこと", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/issues/"+issueID,
+			bytes.NewBufferString(`{"title":"stale","project_id":"090e142d-baa3-4039-9d21-cf5a1af39094","issue_status":"waiting"}`))
+		req.Header.Set("If-Match", `"1"`)
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		assertNotOverwritten(t)
+	})
+
+	t.Run("最新のversionを指定した場合は編集でき、versionが進むこと", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/issues/"+issueID,
+			bytes.NewBufferString(`{"title":"latest","project_id":"090e142d-baa3-4039-9d21-cf5a1af39094","issue_status":"in_progress","version":2}`)))
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		var got map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, "latest", got["title"])
+		assert.Equal(t, moved["board_rank"], got["board_rank"])
+		assert.EqualValues(t, 3, got["version"])
+		assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	})
+}
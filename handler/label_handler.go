@@ -0,0 +1,386 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+const (
+	labelMatchAny = "any"
+	labelMatchAll = "all"
+)
+
+type LabelHandler struct {
+	Db            *gorm.DB
+	logger        *zap.Logger
+	uuidGenerator models.UUIDGenerator
+}
+
+func NewLabelHandler(db *gorm.DB, logger *zap.Logger, uuidGenerator models.UUIDGenerator) *LabelHandler {
+	return &LabelHandler{
+		Db:            db,
+		logger:        logger,
+		uuidGenerator: uuidGenerator,
+	}
+}
+
+type labelRequest struct {
+	Name        string `json:"name" binding:"required,max=64" example:"bug"`
+	Color       string `json:"color" binding:"required,hexcolor,len=7" example:"#d73a4a"`
+	Description string `json:"description" binding:"omitempty,max=255" example:"不具合"`
+}
+
+type setLabelsRequest struct {
+	LabelIDs []string `json:"label_ids" binding:"omitempty,dive,len=36" example:"443b5f1c-8a3a-4485-b3bc-05e69b40b290"`
+}
+
+type labelsResponse struct {
+	Total  int              `json:"total"`
+	Labels models.LabelList `json:"labels"`
+}
+
+// GetLabels @title ラベル一覧
+// @id GetLabels
+// @tags labels
+// @version バージョン(1.0)
+// @description projectのラベル一覧を名前順に返す
+// @Summary ラベル一覧取得
+// @Produce json
+// @Success 200 {object} labelsResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /projects/:id/labels [GET]
+// @Param id path string true "プロジェクトID" minlength(36) maxlength(36) format(UUID v4)
+func (h *LabelHandler) GetLabels(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var project models.Project
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&project).Error; err != nil {
+		h.abortLabelLookup(ctx, traceID, err, "project not found")
+		return
+	}
+	projectID := project.ID
+	var labels models.LabelList
+	if err := tenantDB(ctx, h.Db).Where("project_id = ?", projectID).Order("name").Find(&labels).Error; err != nil {
+		h.logger.Error("failed to get labels", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get labels", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, labelsResponse{
+		Total:  len(labels),
+		Labels: labels,
+	})
+}
+
+// CreateLabel @title ラベル作成
+// @id CreateLabel
+// @tags labels
+// @version バージョン(1.0)
+// @description projectにラベルを作成する。同じproject内で名前は重複できない
+// @Summary ラベル作成
+// @Produce json
+// @Success 201 {object} models.Label
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /projects/:id/labels [POST]
+// @Accept json
+// @Param labelRequest body labelRequest true "create label"
+// @Param id path string true "プロジェクトID" minlength(36) maxlength(36) format(UUID v4)
+func (h *LabelHandler) CreateLabel(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var project models.Project
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&project).Error; err != nil {
+		h.abortLabelLookup(ctx, traceID, err, "project not found")
+		return
+	}
+	projectID := project.ID
+	var req labelRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if err := h.validateLabelName(ctx, projectID, req.Name, ""); err != nil {
+		h.abortLabelError(ctx, traceID, err)
+		return
+	}
+
+	label := models.Label{
+		ID:          h.uuidGenerator.GenerateUUID(),
+		ProjectID:   projectID,
+		Name:        req.Name,
+		Color:       strings.ToLower(req.Color),
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := tenantDB(ctx, h.Db).Create(&label).Error; err != nil {
+		h.logger.Error("failed to create label", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create label", err))
+		return
+	}
+	ctx.JSON(http.StatusCreated, label)
+}
+
+// UpdateLabel @title ラベル編集
+// @id UpdateLabel
+// @tags labels
+// @version バージョン(1.0)
+// @description ラベルの名前・色・説明を編集する。付与済みのepic・issueにも反映される
+// @Summary ラベル編集
+// @Produce json
+// @Success 202 {object} models.Label
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /labels/:id [PUT]
+// @Accept json
+// @Param labelRequest body labelRequest true "update label"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *LabelHandler) UpdateLabel(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	label, ok := h.findLabel(ctx, traceID)
+	if !ok {
+		return
+	}
+	var req labelRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if err := h.validateLabelName(ctx, label.ProjectID, req.Name, label.ID); err != nil {
+		h.abortLabelError(ctx, traceID, err)
+		return
+	}
+
+	label.Name = req.Name
+	label.Color = strings.ToLower(req.Color)
+	label.Description = req.Description
+	label.UpdatedAt = time.Now()
+	if err := tenantDB(ctx, h.Db).Save(label).Error; err != nil {
+		h.logger.Error("failed to update label", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update label", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, label)
+}
+
+// DeleteLabel @title ラベル削除
+// @id DeleteLabel
+// @tags labels
+// @version バージョン(1.0)
+// @description ラベルを削除し、epic・issueへの付与も外す
+// @Summary ラベル削除
+// @Success 204
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /labels/:id [DELETE]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *LabelHandler) DeleteLabel(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	label, ok := h.findLabel(ctx, traceID)
+	if !ok {
+		return
+	}
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM epic_labels WHERE label_id = ?", label.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM issue_labels WHERE label_id = ?", label.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(label).Error
+	}); err != nil {
+		h.logger.Error("failed to delete label", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to delete label", err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// SetEpicLabels @title epicラベル設定
+// @id SetEpicLabels
+// @tags labels
+// @version バージョン(1.0)
+// @description epicに付与するラベルを指定した一覧で置き換える。epicと同じprojectのラベルのみ指定できる
+// @Summary epicラベル設定
+// @Produce json
+// @Success 202 {object} labelsResponse
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /epics/:id/labels [PUT]
+// @Accept json
+// @Param setLabelsRequest body setLabelsRequest true "set labels"
+// @Param id path int true "epic ID"
+func (h *LabelHandler) SetEpicLabels(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var epic models.Epic
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&epic).Error; err != nil {
+		h.abortLabelLookup(ctx, traceID, err, "epic not found")
+		return
+	}
+	h.setLabels(ctx, traceID, &epic, epic.ProjectID)
+}
+
+// SetIssueLabels @title issueラベル設定
+// @id SetIssueLabels
+// @tags labels
+// @version バージョン(1.0)
+// @description issueに付与するラベルを指定した一覧で置き換える。issueと同じprojectのラベルのみ指定できる
+// @Summary issueラベル設定
+// @Produce json
+// @Success 202 {object} labelsResponse
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /issues/:id/labels [PUT]
+// @Accept json
+// @Param setLabelsRequest body setLabelsRequest true "set labels"
+// @Param id path string true "issue ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *LabelHandler) SetIssueLabels(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var issue models.Issue
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&issue).Error; err != nil {
+		h.abortLabelLookup(ctx, traceID, err, "issue not found")
+		return
+	}
+	h.setLabels(ctx, traceID, &issue, issue.ProjectID)
+}
+
+// setLabels ownerのLabelsを指定したラベルで置き換える
+func (h *LabelHandler) setLabels(ctx *gin.Context, traceID string, owner interface{}, projectID string) {
+	var req setLabelsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	labels := models.LabelList{}
+	if len(req.LabelIDs) > 0 {
+		if err := tenantDB(ctx, h.Db).Where("id IN (?)", req.LabelIDs).Order("name").Find(&labels).Error; err != nil {
+			h.logger.Error("failed to get labels", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to set labels", err))
+			return
+		}
+		if len(labels) != len(uniqueStrings(req.LabelIDs)) {
+			ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("label not found"))
+			return
+		}
+		if !labels.BelongTo(projectID) {
+			ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("label does not belong to the project"))
+			return
+		}
+	}
+
+	association := tenantDB(ctx, h.Db).Model(owner).Association("Labels")
+	if len(labels) == 0 {
+		association = association.Clear()
+	} else {
+		association = association.Replace(labels)
+	}
+	if err := association.Error; err != nil {
+		h.logger.Error("failed to set labels", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to set labels", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, labelsResponse{
+		Total:  len(labels),
+		Labels: labels,
+	})
+}
+
+func (h *LabelHandler) findLabel(ctx *gin.Context, traceID string) (*models.Label, bool) {
+	var label models.Label
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&label).Error; err != nil {
+		h.abortLabelLookup(ctx, traceID, err, "label not found")
+		return nil, false
+	}
+	return &label, true
+}
+
+// validateLabelName 同じproject内に同名のラベルが無いことを確認する
+func (h *LabelHandler) validateLabelName(ctx *gin.Context, projectID, name, exceptID string) error {
+	var count int
+	query := tenantDB(ctx, h.Db).Model(&models.Label{}).Where("project_id = ? AND name = ?", projectID, name)
+	if exceptID != "" {
+		query = query.Where("id <> ?", exceptID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.NewBadRequestError("label name already exists")
+	}
+	return nil
+}
+
+func (h *LabelHandler) abortLabelLookup(ctx *gin.Context, traceID string, err error, message string) {
+	switch err {
+	case gorm.ErrRecordNotFound:
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError(message))
+	case gorm.ErrInvalidSQL:
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid sql"))
+	default:
+		h.logger.Error("failed to find record", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to find record", err))
+	}
+}
+
+func (h *LabelHandler) abortLabelError(ctx *gin.Context, traceID string, err error) {
+	if restErr, ok := err.(errors.RestErr); ok {
+		ctx.JSON(restErr.Status(), restErr)
+		return
+	}
+	h.logger.Error("failed to validate label", zap.Error(err),
+		zap.String("trace_id", traceID))
+	ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to validate label", err))
+}
+
+// applyLabelFilter label_idsに一致するレコードに絞り込む
+// matchがallの場合は全てのラベルを持つもの、それ以外はいずれかのラベルを持つものを返す
+func applyLabelFilter(query *gorm.DB, joinTable, ownerColumn string, labelIDs []string, match string) *gorm.DB {
+	if len(labelIDs) == 0 {
+		return query
+	}
+	labelIDs = uniqueStrings(labelIDs)
+	sub := "SELECT " + ownerColumn + " FROM " + joinTable + " WHERE label_id IN (?)"
+	if match == labelMatchAll {
+		return query.Where("id IN ("+sub+" GROUP BY "+ownerColumn+" HAVING COUNT(DISTINCT label_id) = ?)",
+			labelIDs, len(labelIDs))
+	}
+	return query.Where("id IN ("+sub+")", labelIDs)
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		unique = append(unique, v)
+	}
+	return unique
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+// bindFunc 更新内容を読み込んだレコードに反映する。PUTとPATCHで同じ更新処理を使う
+type bindFunc func(ctx *gin.Context, obj interface{}) error
+
+// bindPut PUTの本文で全ての項目を置き換え、全ての項目を検証する
+func bindPut(ctx *gin.Context, obj interface{}) error {
+	return ctx.ShouldBindJSON(obj)
+}
+
+// bindMergePatch RFC 7396のJSON Merge PatchをPATCHの本文から読み込んで反映する
+// 本文に含まれる項目のみを検証し、immutableの項目を現在と異なる値にしようとした場合はエラーにする
+func bindMergePatch(immutable ...string) bindFunc {
+	return func(ctx *gin.Context, obj interface{}) error {
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			return err
+		}
+		var patch interface{}
+		if err := decodeJSON(body, &patch); err != nil {
+			return err
+		}
+		patchFields, ok := patch.(map[string]interface{})
+		if !ok {
+			return errors.New("merge patch must be a JSON object")
+		}
+
+		current, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		var target map[string]interface{}
+		if err := decodeJSON(current, &target); err != nil {
+			return err
+		}
+		for _, field := range immutable {
+			if value, ok := patchFields[field]; ok && !reflect.DeepEqual(value, target[field]) {
+				return fmt.Errorf("%s cannot be changed", field)
+			}
+		}
+		merged, err := json.Marshal(applyMergePatch(target, patch))
+		if err != nil {
+			return err
+		}
+		if err := replaceJSONFields(obj, merged); err != nil {
+			return err
+		}
+		return validatePatchedFields(obj, patchFields)
+	}
+}
+
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// applyMergePatch RFC 7396のアルゴリズム。nullの項目は削除し、オブジェクトは再帰的に反映し、それ以外は置き換える
+func applyMergePatch(target, patch interface{}) interface{} {
+	patchFields, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetFields, ok := target.(map[string]interface{})
+	if !ok {
+		targetFields = map[string]interface{}{}
+	}
+	for name, value := range patchFields {
+		if value == nil {
+			delete(targetFields, name)
+			continue
+		}
+		targetFields[name] = applyMergePatch(targetFields[name], value)
+	}
+	return targetFields
+}
+
+// replaceJSONFields 反映後のJSONで項目を置き換える。削除した項目はゼロ値にし、JSONに含まれない項目(json:"-")は元の値を残す
+func replaceJSONFields(obj interface{}, merged []byte) error {
+	dst := reflect.ValueOf(obj).Elem()
+	replaced := reflect.New(dst.Type())
+	if err := json.Unmarshal(merged, replaced.Interface()); err != nil {
+		return err
+	}
+	for i := 0; i < dst.NumField(); i++ {
+		if dst.Type().Field(i).Tag.Get("json") == "-" {
+			replaced.Elem().Field(i).Set(dst.Field(i))
+		}
+	}
+	dst.Set(replaced.Elem())
+	return nil
+}
+
+// validatePatchedFields 本文に含まれる項目のみを検証する。既存のレコードが満たしていない規則で更新できなくならないようにする
+func validatePatchedFields(obj interface{}, patchFields map[string]interface{}) error {
+	if validate == nil {
+		return binding.Validator.ValidateStruct(obj)
+	}
+	var fields []string
+	t := reflect.TypeOf(obj).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if _, ok := patchFields[name]; ok {
+			fields = append(fields, t.Field(i).Name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return validate.StructPartial(obj, fields...)
+}
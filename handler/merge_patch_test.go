@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func newPatchContext(body string) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	return ctx
+}
+
+func currentProduct() models.Product {
+	return models.Product{
+		ID:             "090e142d-baa3-4039-9d21-cf5a1af39094",
+		OrganizationID: models.DefaultOrganizationID,
+		ProductName:    "test",
+		Price:          1000,
+		Remarks:        "remarks",
+		Quantity:       10,
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		target string
+		patch  string
+		want   string
+	}{
+		{name: "値を置き換えること", target: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "項目を追加すること", target: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{name: "nullの項目を削除すること", target: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{name: "配列は置き換えること", target: `{"a":["b"]}`, patch: `{"a":["c","d"]}`, want: `{"a":["c","d"]}`},
+		{name: "オブジェクトは再帰的に反映すること", target: `{"a":{"b":"c","d":"e"}}`, patch: `{"a":{"b":"d","d":null}}`, want: `{"a":{"b":"d"}}`},
+		{name: "オブジェクト以外をオブジェクトで置き換えること", target: `{"a":"b"}`, patch: `{"a":{"c":null,"d":"e"}}`, want: `{"a":{"d":"e"}}`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var target, patch, want interface{}
+			require.NoError(t, decodeJSON([]byte(tt.target), &target))
+			require.NoError(t, decodeJSON([]byte(tt.patch), &patch))
+			require.NoError(t, decodeJSON([]byte(tt.want), &want))
+			assert.Equal(t, want, applyMergePatch(target, patch))
+		})
+	}
+}
+
+func TestBindMergePatch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("指定した項目のみを更新し、JSONに含まれない項目は残すこと", func(t *testing.T) {
+		t.Parallel()
+		product := currentProduct()
+		err := bindMergePatch("id")(newPatchContext(`{"price":2000,"remarks":null}`), &product)
+		require.NoError(t, err)
+		want := currentProduct()
+		want.Price = 2000
+		want.Remarks = ""
+		assert.Equal(t, want, product)
+	})
+
+	t.Run("指定しない必須項目は検証しないこと", func(t *testing.T) {
+		t.Parallel()
+		product := currentProduct()
+		product.ProductName = ""
+		err := bindMergePatch("id")(newPatchContext(`{"quantity":5}`), &product)
+		require.NoError(t, err)
+		assert.Equal(t, 5, product.Quantity)
+	})
+
+	t.Run("指定した項目は検証すること", func(t *testing.T) {
+		t.Parallel()
+		product := currentProduct()
+		err := bindMergePatch("id")(newPatchContext(`{"product_name":"`+strings.Repeat("a", 65)+`"}`), &product)
+		require.Error(t, err)
+		res := createValidateErrorResponse(err)
+		require.Len(t, res.Details, 1)
+		assert.Equal(t, "ProductName", res.Details[0].Attribute)
+	})
+
+	t.Run("変更できない項目を変更する場合はエラーになること", func(t *testing.T) {
+		t.Parallel()
+		product := currentProduct()
+		err := bindMergePatch("id")(newPatchContext(`{"id":"a8c2f5f4-0b8e-4a3c-9f63-0b8e4a3c9f63"}`), &product)
+		assert.EqualError(t, err, "id cannot be changed")
+		assert.Equal(t, currentProduct(), product)
+	})
+
+	t.Run("変更できない項目も現在と同じ値であれば許可すること", func(t *testing.T) {
+		t.Parallel()
+		product := currentProduct()
+		err := bindMergePatch("id")(newPatchContext(`{"id":"090e142d-baa3-4039-9d21-cf5a1af39094","quantity":1}`), &product)
+		require.NoError(t, err)
+		assert.Equal(t, 1, product.Quantity)
+	})
+
+	t.Run("オブジェクト以外の本文はエラーになること", func(t *testing.T) {
+		t.Parallel()
+		product := currentProduct()
+		err := bindMergePatch("id")(newPatchContext(`[{"price":1}]`), &product)
+		assert.EqualError(t, err, "merge patch must be a JSON object")
+	})
+
+	t.Run("型が異なる値はエラーになること", func(t *testing.T) {
+		t.Parallel()
+		product := currentProduct()
+		err := bindMergePatch("id")(newPatchContext(`{"price":"free"}`), &product)
+		assert.Error(t, err)
+		assert.Equal(t, currentProduct(), product)
+	})
+}
@@ -36,6 +36,15 @@ type milestoneRequest struct {
 	MilestoneTitle       string `json:"milestone_title" example:"milestone title" binding:"required,max=64"`
 	MilestoneDescription string `json:"milestone_description" example:"milestone description" binding:"omitempty,max=255"`
 	ProjectId            string `json:"project_id" example:"443b5f1c-8a3a-4485-b3bc-05e69b40b290" format:"/^[0-9A-F]{8}-[0-9A-F]{4}-4[0-9A-F]{3}-[89AB][0-9A-F]{3}-[0-9A-F]{12}$/i" binding:"required,uuid4"`
+	StartDate            string `json:"start_date" example:"2022-10-01T00:00:00+09:00" binding:"omitempty"`
+	DueDate              string `json:"due_date" example:"2022-10-14T00:00:00+09:00" binding:"omitempty"`
+}
+
+type burndownResponse struct {
+	MilestoneID string                 `json:"milestone_id"`
+	StartDate   *time.Time             `json:"start_date"`
+	DueDate     *time.Time             `json:"due_date"`
+	Points      []models.BurndownPoint `json:"points"`
 }
 
 type milestoneResponseItem struct {
@@ -78,7 +87,7 @@ func (h *MilestoneHandler) GetMilestones(ctx *gin.Context) {
 		return
 	}
 	var milestones []models.Milestone
-	query := createMilestoneQueryBuilder(params, h)
+	query := createMilestoneQueryBuilder(ctx, params, h)
 	if err := query.Find(&milestones).Error; err != nil {
 		h.logger.Error("failed to get milestone", zap.Error(err),
 			zap.String("trace_id", traceID))
@@ -108,7 +117,7 @@ func (h *MilestoneHandler) GetMilestoneDetail(ctx *gin.Context) {
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
 	var milestone models.Milestone
-	if err := h.Db.Where("id = ?", id).First(&milestone).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&milestone).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to find milestone", zap.Error(err),
@@ -124,6 +133,58 @@ func (h *MilestoneHandler) GetMilestoneDetail(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, milestone)
 }
 
+// GetMilestoneBurndown @title milestoneバーンダウン
+// @id GetMilestoneBurndown
+// @tags milestones
+// @version バージョン(1.0)
+// @description 開始日から期日までの日ごとの残issue数・残ストーリーポイントと理想線を返す。当日の値は現在のissueから集計する
+// @Summary milestoneバーンダウン取得
+// @Produce json
+// @Success 200 {object} burndownResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /milestones/:id/burndown [GET]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *MilestoneHandler) GetMilestoneBurndown(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var milestone models.Milestone
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&milestone).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("milestone not found"))
+		default:
+			h.logger.Error("failed to find milestone", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get burndown", err))
+		}
+		return
+	}
+
+	var snapshots []models.MilestoneSnapshot
+	if err := tenantDB(ctx, h.Db).Where("milestone_id = ?", milestone.ID).Order("snapshot_date").Find(&snapshots).Error; err != nil {
+		h.logger.Error("failed to get milestone snapshots", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get burndown", err))
+		return
+	}
+	var issues []models.Issue
+	if err := tenantDB(ctx, h.Db).Where("milestone_id = ?", milestone.ID).Find(&issues).Error; err != nil {
+		h.logger.Error("failed to get issues", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get burndown", err))
+		return
+	}
+
+	now := time.Now()
+	today := models.NewMilestoneSnapshot(milestone.ID, now, issues)
+	ctx.JSON(http.StatusOK, burndownResponse{
+		MilestoneID: milestone.ID,
+		StartDate:   milestone.StartDate,
+		DueDate:     milestone.DueDate,
+		Points:      models.BuildBurndown(&milestone, append(snapshots, today), now),
+	})
+}
+
 // CreateMilestone @title milestone作成
 // @id CreateMilestone
 // @tags milestones
@@ -146,9 +207,13 @@ func (h *MilestoneHandler) CreateMilestone(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
+	if !milestone.HasValidPeriod() {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("due_date must be on or after start_date"))
+		return
+	}
 	milestone.CreateUUID()
 
-	if err := h.Db.Create(&milestone).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Create(&milestone).Error; err != nil {
 		h.logger.Error("failed to create milestone", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create milestone", err))
@@ -173,10 +238,34 @@ func (h *MilestoneHandler) CreateMilestone(ctx *gin.Context) {
 // @Param milestoneRequest body milestoneRequest true "update milestone"
 // @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
 func (h *MilestoneHandler) UpdateMileStone(ctx *gin.Context) {
+	h.updateMileStone(ctx, bindPut)
+}
+
+// PatchMileStone @title milestone部分編集
+// @id PatchMileStone
+// @tags milestones
+// @version バージョン(1.0)
+// @description milestoneを部分的に編集する。JSON Merge Patch(RFC 7396)で、nullを指定した項目は空にする
+// @description 指定した項目のみを検証し、id, created_at, updated_atは変更できない
+// @Summary milestone部分編集
+// @Produce json
+// @Success 202 {object} milestoneResponseItem
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /milestones/:id [PATCH]
+// @Accept json
+// @Param milestoneRequest body milestoneRequest true "patch milestone"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *MilestoneHandler) PatchMileStone(ctx *gin.Context) {
+	h.updateMileStone(ctx, bindMergePatch("id", "created_at", "updated_at"))
+}
+
+// updateMileStone PUTとPATCHで共通の編集処理。bindで更新内容を反映する
+func (h *MilestoneHandler) updateMileStone(ctx *gin.Context, bind bindFunc) {
 	var milestone models.Milestone
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&milestone).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&milestone).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to update milestone", zap.Error(err),
@@ -189,14 +278,18 @@ func (h *MilestoneHandler) UpdateMileStone(ctx *gin.Context) {
 		}
 		return
 	}
-	if err := ctx.ShouldBindJSON(&milestone); err != nil {
+	if err := bind(ctx, &milestone); err != nil {
 		res := createValidateErrorResponse(err)
 		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
+	if !milestone.HasValidPeriod() {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("due_date must be on or after start_date"))
+		return
+	}
 
-	if err := h.Db.Save(&milestone).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Save(&milestone).Error; err != nil {
 		h.logger.Error("failed to update milestone", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update milestone", err))
@@ -223,7 +316,7 @@ func (h *MilestoneHandler) DeleteMilestone(ctx *gin.Context) {
 	var milestone models.Milestone
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&milestone).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&milestone).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to delete milestone", zap.Error(err),
@@ -237,7 +330,7 @@ func (h *MilestoneHandler) DeleteMilestone(ctx *gin.Context) {
 		return
 	}
 
-	if err := h.Db.Delete(&milestone).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Delete(&milestone).Error; err != nil {
 		h.logger.Error("failed to delete milestone", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to delete milestone", err))
@@ -247,9 +340,9 @@ func (h *MilestoneHandler) DeleteMilestone(ctx *gin.Context) {
 	ctx.JSON(http.StatusNoContent, nil)
 }
 
-func createMilestoneQueryBuilder(params searchMilestoneParams, h *MilestoneHandler) *gorm.DB {
+func createMilestoneQueryBuilder(ctx *gin.Context, params searchMilestoneParams, h *MilestoneHandler) *gorm.DB {
 	var products []models.Product
-	query := h.Db.Find(&products)
+	query := tenantDB(ctx, h.Db).Find(&products)
 
 	if params.MilestoneTitle != "" {
 		query = query.Where("milestone_title LIKE ?", "%"+params.MilestoneTitle+"%")
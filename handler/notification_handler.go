@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+type NotificationHandler struct {
+	Db     *gorm.DB
+	logger *zap.Logger
+}
+
+func NewNotificationHandler(db *gorm.DB, logger *zap.Logger) *NotificationHandler {
+	return &NotificationHandler{
+		Db:     db,
+		logger: logger,
+	}
+}
+
+type searchNotificationParams struct {
+	Unread string `form:"unread" binding:"omitempty,oneof=true false"`
+	Offset string `form:"offset,default=0" binding:"omitempty,numeric"`
+	Limit  string `form:"limit,default=20" binding:"omitempty,numeric"`
+}
+
+type notificationsResponse struct {
+	Total         int                   `json:"total"`
+	Notifications []models.Notification `json:"notifications"`
+}
+
+// GetNotifications @title 通知一覧
+// @id GetNotifications
+// @tags notifications
+// @version バージョン(1.0)
+// @description ログインユーザーの通知を新しい順に返す
+// @Summary 通知一覧取得
+// @Produce json
+// @Success 200 {object} notificationsResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /notifications [GET]
+// @Param unread query bool false "未読のみ"
+// @Param offset query int false "開始位置" default(0) minimum(0)
+// @Param limit query int false "取得上限" default(20) minimum(1) maximum(100)
+func (h *NotificationHandler) GetNotifications(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var params searchNotificationParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind query params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	query := tenantDB(ctx, h.Db).Where("user_id = ?", appcontext.GetUserID(ctx))
+	switch params.Unread {
+	case "true":
+		query = query.Where("read_at IS NULL")
+	case "false":
+		query = query.Where("read_at IS NOT NULL")
+	}
+	notifications := []models.Notification{}
+	if err := query.Order("created_at desc, id desc").
+		Offset(params.Offset).Limit(params.Limit).Find(&notifications).Error; err != nil {
+		h.logger.Error("failed to get notifications", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get notifications", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, notificationsResponse{
+		Total:         len(notifications),
+		Notifications: notifications,
+	})
+}
+
+// ReadNotification @title 通知既読
+// @id ReadNotification
+// @tags notifications
+// @version バージョン(1.0)
+// @description ログインユーザーの通知を既読にする
+// @Summary 通知既読
+// @Produce json
+// @Success 202 {object} models.Notification
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /notifications/:id/read [POST]
+// @Param id path int true "ID"
+func (h *NotificationHandler) ReadNotification(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var notification models.Notification
+	if err := tenantDB(ctx, h.Db).Where("id = ? AND user_id = ?", ctx.Param("id"), appcontext.GetUserID(ctx)).
+		First(&notification).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("notification not found"))
+		default:
+			h.logger.Error("failed to get notification", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to read notification", err))
+		}
+		return
+	}
+	if notification.ReadAt == nil {
+		now := time.Now()
+		if err := tenantDB(ctx, h.Db).Model(&notification).Update("read_at", now).Error; err != nil {
+			h.logger.Error("failed to read notification", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to read notification", err))
+			return
+		}
+		notification.ReadAt = &now
+	}
+	ctx.JSON(http.StatusAccepted, notification)
+}
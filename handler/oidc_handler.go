@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/loginguard"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/oidc"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+	"github.com/AI1411/golang-admin-api/webhook"
+)
+
+var (
+	errOIDCEmailNotVerified = errors.New("email is not verified by the identity provider")
+	errOIDCUserNotFound     = errors.New("no user is associated with the identity")
+)
+
+// OIDCHandler 外部のIDプロバイダーによるシングルサインオン
+// 認証後のJWTの発行や2段階認証はパスワードによるログインと同じくAuthHandlerが行う
+type OIDCHandler struct {
+	Db     *gorm.DB
+	logger *zap.Logger
+	flow   *oidc.Flow
+	auth   *AuthHandler
+}
+
+func NewOIDCHandler(db *gorm.DB, logger *zap.Logger, flow *oidc.Flow, auth *AuthHandler) *OIDCHandler {
+	return &OIDCHandler{
+		Db:     db,
+		logger: logger,
+		flow:   flow,
+		auth:   auth,
+	}
+}
+
+type oidcProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+type oidcCallbackParams struct {
+	Code             string `form:"code"`
+	State            string `form:"state"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
+
+// GetOIDCProviders @title シングルサインオンのプロバイダー一覧
+// @id GetOIDCProviders
+// @tags auth
+// @version バージョン(1.0)
+// @description ログインに使えるIDプロバイダーの名前を返す
+// @Summary シングルサインオンのプロバイダー一覧
+// @Produce json
+// @Success 200 {object} oidcProvidersResponse
+// @Router /auth/oidc/providers [GET]
+func (h *OIDCHandler) GetOIDCProviders(ctx *gin.Context) {
+	providers := h.flow.ProviderNames()
+	if providers == nil {
+		providers = []string{}
+	}
+	ctx.JSON(http.StatusOK, oidcProvidersResponse{Providers: providers})
+}
+
+// BeginOIDCLogin @title シングルサインオン開始
+// @id BeginOIDCLogin
+// @tags auth
+// @version バージョン(1.0)
+// @description IDプロバイダーの認可画面へリダイレクトする。認可コードフローとPKCEを使う
+// @description ログインCSRFを防ぐため、stateに署名した値をCookieに保存し、コールバックで照合する
+// @Summary シングルサインオン開始
+// @Success 302
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /auth/oidc/:provider/login [GET]
+// @Param provider path string true "IDプロバイダー名"
+func (h *OIDCHandler) BeginOIDCLogin(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	authorization, err := h.flow.Begin(ctx, ctx.Param("provider"))
+	switch {
+	case errors.Is(err, oidc.ErrUnknownProvider):
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
+		return
+	case err != nil:
+		h.logger.Error("failed to begin oidc login", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to begin oidc login", err))
+		return
+	}
+	h.setStateCookie(ctx, authorization.StateCookie, int(oidc.StateTTL.Seconds()))
+	ctx.Redirect(http.StatusFound, authorization.URL)
+}
+
+// OIDCCallback @title シングルサインオンのコールバック
+// @id OIDCCallback
+// @tags auth
+// @version バージョン(1.0)
+// @description 認可コードを交換してIDトークンを検証し、ログインする
+// @description IDプロバイダーのアカウントに紐付いたユーザー、無ければ確認済みのメールアドレスが一致するユーザーとしてログインする
+// @description どちらも無い場合は、プロバイダーの設定で許可されていればユーザーを作成する
+// @Summary シングルサインオンのコールバック
+// @Produce json
+// @Success 200
+// @Failure 400 {object} errorResponse
+// @Failure 401 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /auth/oidc/:provider/callback [GET]
+// @Param provider path string true "IDプロバイダー名"
+// @Param code query string true "認可コード"
+// @Param state query string true "ログイン開始時に発行したstate"
+func (h *OIDCHandler) OIDCCallback(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var params oidcCallbackParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind query params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if params.Error != "" {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError(
+			strings.TrimSpace("authorization failed: "+params.Error+" "+params.ErrorDescription)))
+		return
+	}
+
+	name := ctx.Param("provider")
+	// stateのCookieは一度だけ使う
+	stateCookie, _ := ctx.Cookie(oidc.StateCookieName)
+	h.setStateCookie(ctx, "", -1)
+	claims, err := h.flow.Complete(ctx, name, params.State, stateCookie, params.Code)
+	switch {
+	case errors.Is(err, oidc.ErrUnknownProvider):
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError(err.Error()))
+		return
+	case errors.Is(err, oidc.ErrInvalidState):
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError(err.Error()))
+		return
+	case errors.Is(err, oidc.ErrExchangeFailed), errors.Is(err, oidc.ErrInvalidIDToken):
+		h.logger.Warn("failed to complete oidc login", zap.Error(err),
+			zap.String("provider", name), zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusUnauthorized, errors.NewUnauthorizedError("failed to authenticate with identity provider"))
+		return
+	case err != nil:
+		h.logger.Error("failed to complete oidc login", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to complete oidc login", err))
+		return
+	}
+
+	provider, _ := h.flow.Provider(name)
+	user, err := h.resolveUser(provider.Config(), claims)
+	switch {
+	case errors.Is(err, errOIDCEmailNotVerified), errors.Is(err, errOIDCUserNotFound):
+		ctx.JSON(http.StatusForbidden, errors.NewForbiddenError(err.Error()))
+		return
+	case err != nil:
+		h.logger.Error("failed to resolve oidc user", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to resolve oidc user", err))
+		return
+	}
+
+	attempt := newLoginAttempt(ctx, loginguard.NormalizeEmail(user.Email))
+	attempt.UserID = &user.ID
+	h.auth.respondLogin(ctx, traceID, &user, &attempt)
+}
+
+// setStateCookie ログインを開始したブラウザにstateを紐付ける
+// IDプロバイダーからのリダイレクトでも送られるよう、SameSite=Laxにする
+func (h *OIDCHandler) setStateCookie(ctx *gin.Context, value string, maxAge int) {
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     oidc.StateCookieName,
+		Value:    value,
+		Path:     "/auth/oidc",
+		MaxAge:   maxAge,
+		Secure:   h.auth.sessions.Cookie().Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// resolveUser IDトークンの情報に対応するユーザーを返す。必要に応じて紐付けやユーザーの作成を行う
+// 照合するのはプロバイダーに設定した組織のユーザーのみで、メールアドレスでの照合はプロバイダーが確認済みとしたメールアドレスに限る
+func (h *OIDCHandler) resolveUser(config oidc.Config, claims *oidc.Claims) (models.User, error) {
+	var user models.User
+	err := h.Db.Transaction(func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", config.Name, claims.Subject).First(&identity).Error
+		switch {
+		case err == nil:
+			err := tx.Where("id = ? AND organization_id = ?", identity.UserID, config.OrganizationID).First(&user).Error
+			if gorm.IsRecordNotFoundError(err) {
+				return errOIDCUserNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if claims.Email != "" && claims.Email != identity.Email {
+				return tx.Model(&identity).Updates(map[string]interface{}{
+					"email":      claims.Email,
+					"updated_at": time.Now(),
+				}).Error
+			}
+			return nil
+		case !gorm.IsRecordNotFoundError(err):
+			return err
+		}
+
+		if claims.Email == "" || !claims.EmailVerified {
+			return errOIDCEmailNotVerified
+		}
+		// プロバイダーに設定した組織のユーザーのみと照合し、別の組織のユーザーとしてログインできないようにする
+		err = tx.Where("email = ? AND organization_id = ?", loginguard.NormalizeEmail(claims.Email), config.OrganizationID).
+			First(&user).Error
+		switch {
+		case gorm.IsRecordNotFoundError(err):
+			if !config.AutoProvision {
+				return errOIDCUserNotFound
+			}
+			if err := provisionUser(tx, config, claims, &user); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case !user.IsEmailVerified():
+			// プロバイダーがメールアドレスを確認しているため、確認済みとして扱う
+			now := time.Now()
+			if err := tx.Model(&user).Updates(map[string]interface{}{
+				"email_verified_at": now,
+				"version":           gorm.Expr("version + 1"),
+				"updated_at":        now,
+			}).Error; err != nil {
+				return err
+			}
+			user.EmailVerifiedAt = &now
+		}
+		return tx.Create(&models.UserIdentity{
+			UserID:    user.ID,
+			Provider:  config.Name,
+			Subject:   claims.Subject,
+			Email:     claims.Email,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}).Error
+	})
+	return user, err
+}
+
+// provisionUser 初回のシングルサインオンでユーザーを作成する
+// パスワードは設定せず、必要になればパスワード再設定で設定する
+func provisionUser(tx *gorm.DB, config oidc.Config, claims *oidc.Claims, user *models.User) error {
+	now := time.Now()
+	*user = models.User{
+		OrganizationID:  config.OrganizationID,
+		Role:            models.UserRoleMember,
+		FirstName:       claims.GivenName,
+		LastName:        claims.FamilyName,
+		Email:           loginguard.NormalizeEmail(claims.Email),
+		Password:        []byte{},
+		EmailVerifiedAt: &now,
+		Version:         1,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if user.FirstName == "" && user.LastName == "" {
+		user.FirstName = claims.Name
+	}
+	if user.FirstName == "" {
+		user.FirstName = strings.SplitN(user.Email, "@", 2)[0]
+	}
+	user.CreateUUID()
+	if err := tx.Create(user).Error; err != nil {
+		return err
+	}
+	return webhook.Enqueue(tx.Scopes(models.OrganizationScope(user.OrganizationID)), webhook.EventUserRegistered, webhook.UserRegistered{
+		UserID:    user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Email:     user.Email,
+	})
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/authtoken"
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/loginguard"
+	"github.com/AI1411/golang-admin-api/mailer"
+	"github.com/AI1411/golang-admin-api/middleware"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/oidc"
+	"github.com/AI1411/golang-admin-api/oidc/oidctest"
+	"github.com/AI1411/golang-admin-api/session"
+	util "github.com/AI1411/golang-admin-api/util/jwt"
+)
+
+const (
+	existingUserIDForOIDCTest      = "8c2f5d3e-4b6a-4f7c-9d0e-1f2a3b4c5d01"
+	otherOrgUserIDForOIDCTest      = "8c2f5d3e-4b6a-4f7c-9d0e-1f2a3b4c5d02"
+	otherOrganizationIDForOIDCTest = "00000000-0000-4000-8000-000000000002"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE users")
+	dbConn.Exec("TRUNCATE TABLE user_identities")
+	dbConn.Exec("insert into users (id, organization_id, first_name, last_name, age, email, password, role, created_at, updated_at)values('8c2f5d3e-4b6a-4f7c-9d0e-1f2a3b4c5d01','00000000-0000-4000-8000-000000000001','existing','existing',30,'existing@example.com','','member','2022-09-27 10:00:00','2022-09-27 10:00:00');")
+	dbConn.Exec("insert into users (id, organization_id, first_name, last_name, age, email, password, role, created_at, updated_at)values('8c2f5d3e-4b6a-4f7c-9d0e-1f2a3b4c5d02','00000000-0000-4000-8000-000000000002','other','other',30,'other@example.com','','admin','2022-09-27 10:00:00','2022-09-27 10:00:00');")
+
+	provider, err := oidctest.NewServer("client", "secret")
+	require.NoError(t, err)
+	defer provider.Close()
+	flow := oidc.NewFlow(authtoken.NewMemoryStore(), "secret", oidc.NewProvider(oidc.Config{
+		Name:           "corp",
+		Issuer:         provider.Issuer(),
+		ClientID:       "client",
+		ClientSecret:   "secret",
+		RedirectURL:    "http://localhost:8080/auth/oidc/corp/callback",
+		Scopes:         []string{"openid", "email", "profile"},
+		OrganizationID: models.DefaultOrganizationID,
+		AutoProvision:  true,
+	}, provider.Client()))
+
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(middleware.NewTracing())
+	authHandler := NewAuthHandler(dbConn, zapLogger, mailer.NewMemoryMailer(),
+		authtoken.NewIssuer(authtoken.NewMemoryStore(), "secret"),
+		loginguard.New(loginguard.NewMemoryStore(), loginguard.DefaultPolicy),
+		session.NewManager(session.NewMemoryStore(), session.DefaultCookieConfig, util.TokenLifetime), "http://localhost:3000")
+	oidcHandler := NewOIDCHandler(dbConn, zapLogger, flow, authHandler)
+	r.GET("/auth/oidc/providers", oidcHandler.GetOIDCProviders)
+	r.GET("/auth/oidc/:provider/login", oidcHandler.BeginOIDCLogin)
+	r.GET("/auth/oidc/:provider/callback", oidcHandler.OIDCCallback)
+
+	// ログインを開始し、プロバイダーで同意した後のコールバックの結果を返す
+	login := func(t *testing.T, identity oidctest.Identity) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/corp/login", nil))
+		require.Equal(t, http.StatusFound, rec.Code)
+		callback, err := provider.Authorize(rec.Header().Get("Location"), identity)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+		for _, cookie := range rec.Result().Cookies() {
+			req.AddCookie(cookie)
+		}
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	loggedInUserID := func(t *testing.T, rec *httptest.ResponseRecorder) string {
+		t.Helper()
+		var res struct {
+			User models.User `json:"user"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res.User.ID
+	}
+
+	t.Run("設定されているプロバイダーの一覧を取得できること", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/providers", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"providers": ["corp"]}`, rec.Body.String())
+	})
+
+	t.Run("PKCEのcode_challengeを付けて認可画面へリダイレクトすること", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/corp/login", nil))
+		require.Equal(t, http.StatusFound, rec.Code)
+		location, err := url.Parse(rec.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+		assert.NotEmpty(t, location.Query().Get("code_challenge"))
+		assert.NotEmpty(t, location.Query().Get("state"))
+		assert.NotEmpty(t, location.Query().Get("nonce"))
+		cookies := rec.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, oidc.StateCookieName, cookies[0].Name)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	})
+
+	t.Run("設定されていないプロバイダーは404になること", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/unknown/login", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("確認済みのメールアドレスが一致する既存のユーザーとしてログインできること", func(t *testing.T) {
+		rec := login(t, oidctest.Identity{Subject: "existing", Email: "existing@example.com", EmailVerified: true})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, existingUserIDForOIDCTest, loggedInUserID(t, rec))
+	})
+
+	t.Run("メールアドレスが確認されていない場合は既存のユーザーに紐付けないこと", func(t *testing.T) {
+		rec := login(t, oidctest.Identity{Subject: "unverified", Email: "existing@example.com", EmailVerified: false})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	var provisionedUserID string
+	t.Run("初回ログインでユーザーを作成すること", func(t *testing.T) {
+		rec := login(t, oidctest.Identity{
+			Subject:       "new",
+			Email:         "new@example.com",
+			EmailVerified: true,
+			GivenName:     "Hanako",
+			FamilyName:    "Suzuki",
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+		provisionedUserID = loggedInUserID(t, rec)
+		var user models.User
+		require.NoError(t, dbConn.Where("id = ?", provisionedUserID).First(&user).Error)
+		assert.Equal(t, "new@example.com", user.Email)
+		assert.Equal(t, "Hanako", user.FirstName)
+		assert.Equal(t, models.UserRoleMember, user.Role)
+		assert.Equal(t, models.DefaultOrganizationID, user.OrganizationID)
+		assert.True(t, user.IsEmailVerified())
+	})
+
+	t.Run("メールアドレスが変わっても同じユーザーとしてログインできること", func(t *testing.T) {
+		rec := login(t, oidctest.Identity{Subject: "new", Email: "renamed@example.com", EmailVerified: true})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, provisionedUserID, loggedInUserID(t, rec))
+	})
+
+	t.Run("別の組織のユーザーとはメールアドレスが一致しても紐付けないこと", func(t *testing.T) {
+		rec := login(t, oidctest.Identity{Subject: "other", Email: "other@example.com", EmailVerified: true})
+		require.Equal(t, http.StatusOK, rec.Code)
+		userID := loggedInUserID(t, rec)
+		assert.NotEqual(t, otherOrgUserIDForOIDCTest, userID)
+		var user models.User
+		require.NoError(t, dbConn.Where("id = ?", userID).First(&user).Error)
+		assert.Equal(t, models.DefaultOrganizationID, user.OrganizationID)
+		assert.NotEqual(t, otherOrganizationIDForOIDCTest, user.OrganizationID)
+	})
+
+	t.Run("ログインを開始したブラウザのCookieが無い場合は400になること", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/corp/login", nil))
+		require.Equal(t, http.StatusFound, rec.Code)
+		callback, err := provider.Authorize(rec.Header().Get("Location"), oidctest.Identity{Subject: "existing", Email: "existing@example.com", EmailVerified: true})
+		require.NoError(t, err)
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("stateが不正な場合は400になること", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/corp/callback?code=x&state=invalid", nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
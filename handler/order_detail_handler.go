@@ -57,7 +57,7 @@ func (h *OrderDetailHandler) GetOrderDetail(ctx *gin.Context) {
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
 	var orderDetail models.OrderDetail
-	if err := h.Db.Where("id = ?", id).First(&orderDetail).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&orderDetail).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to get order detail", zap.Error(err),
@@ -96,7 +96,7 @@ func (h *OrderDetailHandler) CreateOrderDetail(ctx *gin.Context) {
 		return
 	}
 	orderDetail.CreateUUID()
-	if err := h.Db.Create(&orderDetail).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Create(&orderDetail).Error; err != nil {
 		h.logger.Error("failed to create order detail", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError,
@@ -124,7 +124,7 @@ func (h *OrderDetailHandler) UpdateOrderDetail(ctx *gin.Context) {
 	var orderDetail models.OrderDetail
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&orderDetail).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&orderDetail).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to update order detail", zap.Error(err),
@@ -144,7 +144,7 @@ func (h *OrderDetailHandler) UpdateOrderDetail(ctx *gin.Context) {
 		return
 	}
 
-	if err := h.Db.Save(&orderDetail).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Save(&orderDetail).Error; err != nil {
 		h.logger.Error("failed to update order detail", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError,
@@ -172,7 +172,7 @@ func (h *OrderDetailHandler) DeleteOrderDetail(ctx *gin.Context) {
 	orderDetail := models.OrderDetail{}
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&orderDetail).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&orderDetail).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to delete order detail", zap.Error(err),
@@ -185,7 +185,7 @@ func (h *OrderDetailHandler) DeleteOrderDetail(ctx *gin.Context) {
 		}
 		return
 	}
-	if err := h.Db.Delete(&orderDetail).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Delete(&orderDetail).Error; err != nil {
 		h.logger.Error("failed to delete order detail", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError,
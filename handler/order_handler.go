@@ -16,7 +16,9 @@ import (
 	"github.com/signintech/gopdf"
 
 	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/outbox"
 	"github.com/AI1411/golang-admin-api/util/errors"
+	"github.com/AI1411/golang-admin-api/webhook"
 )
 
 type OrderHandler struct {
@@ -55,7 +57,7 @@ func (h *OrderHandler) GetOrders(ctx *gin.Context) {
 	}
 
 	var orders []models.Order
-	query := createOrderQueryBuilder(params, h)
+	query := createOrderQueryBuilder(ctx, params, h)
 	query.Preload("OrderDetails").Find(&orders)
 
 	ctx.JSON(http.StatusOK, gin.H{
@@ -68,7 +70,7 @@ func (h *OrderHandler) GetOrder(ctx *gin.Context) {
 	id := ctx.Param("id")
 	var order models.Order
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Preload("OrderDetails").Where("id = ?", id).First(&order).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Preload("OrderDetails").Where("id = ?", id).First(&order).Error; err != nil {
 		if gorm.IsRecordNotFoundError(err) {
 			h.logger.Error("failed to get order", zap.Error(err),
 				zap.String("trace_id", traceID))
@@ -81,7 +83,7 @@ func (h *OrderHandler) GetOrder(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, order)
+	respondWithETag(ctx, order.Version, order)
 }
 
 func (h *OrderHandler) CreateOrder(ctx *gin.Context) {
@@ -93,6 +95,11 @@ func (h *OrderHandler) CreateOrder(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
+	if order.OrderStatus.IsPaymentDrivenStatus() {
+		ctx.JSON(http.StatusBadRequest,
+			errors.NewBadRequestError("order_status can only be changed by payment results"))
+		return
+	}
 	orderData := models.Order{
 		ID:          order.CreateUUID(),
 		UserID:      order.UserID,
@@ -100,10 +107,11 @@ func (h *OrderHandler) CreateOrder(ctx *gin.Context) {
 		TotalPrice:  order.OrderDetails.TotalPrice(),
 		OrderStatus: order.OrderStatus,
 		Remarks:     order.Remarks,
+		Version:     1,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	if err := h.Db.Transaction(func(tx *gorm.DB) error {
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Table("orders").Create(&orderData).Error; err != nil {
 			h.logger.Error("failed to create order", zap.Error(err),
 				zap.String("trace_id", traceID))
@@ -121,7 +129,11 @@ func (h *OrderHandler) CreateOrder(ctx *gin.Context) {
 				return err
 			}
 		}
-		return nil
+		if err := outbox.Record(tx, outbox.TopicOrderCreated, orderData.ID,
+			outbox.DedupKey(outbox.TopicOrderCreated, orderData.ID), orderData); err != nil {
+			return err
+		}
+		return webhook.Enqueue(tx, webhook.EventOrderCreated, orderData)
 	}); err != nil {
 		h.logger.Error("failed to create order", zap.Error(err),
 			zap.String("trace_id", traceID))
@@ -133,10 +145,20 @@ func (h *OrderHandler) CreateOrder(ctx *gin.Context) {
 }
 
 func (h *OrderHandler) UpdateOrder(ctx *gin.Context) {
+	h.updateOrder(ctx, bindPut)
+}
+
+// PatchOrder 本文をJSON Merge Patchとして扱い、指定した項目のみを編集する
+func (h *OrderHandler) PatchOrder(ctx *gin.Context) {
+	h.updateOrder(ctx, bindMergePatch("id", "order_details", "quantity", "total_price", "version", "created_at", "updated_at"))
+}
+
+// updateOrder PUTとPATCHで共通の編集処理。bindで更新内容を反映する
+func (h *OrderHandler) updateOrder(ctx *gin.Context, bind bindFunc) {
 	order := models.Order{}
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&order).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&order).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to update milestone", zap.Error(err),
@@ -149,25 +171,55 @@ func (h *OrderHandler) UpdateOrder(ctx *gin.Context) {
 		}
 		return
 	}
-	if err := ctx.ShouldBindJSON(&order); err != nil {
+	if !checkIfMatch(ctx, order.Version) {
+		return
+	}
+	currentStatus, version := order.OrderStatus, order.Version
+	if err := bind(ctx, &order); err != nil {
 		res := createValidateErrorResponse(err)
 		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
-	if err := h.Db.Save(&order).Error; err != nil {
+	if order.OrderStatus != currentStatus && order.OrderStatus.IsPaymentDrivenStatus() {
+		ctx.JSON(http.StatusBadRequest,
+			errors.NewBadRequestError("order_status can only be changed by payment results"))
+		return
+	}
+	order.Version = version
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := bumpVersion(tx, "orders", order.ID, &order.Version); err != nil {
+			return err
+		}
+		if err := tx.Save(&order).Error; err != nil {
+			return err
+		}
+		if order.OrderStatus == currentStatus {
+			return nil
+		}
+		return webhook.Enqueue(tx, webhook.EventOrderStatusChanged, webhook.OrderStatusChanged{
+			OrderID:    order.ID,
+			FromStatus: currentStatus,
+			ToStatus:   order.OrderStatus,
+		})
+	}); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			respondVersionConflict(ctx)
+			return
+		}
 		h.logger.Error("failed to update milestone", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update order", err))
 		return
 	}
+	ctx.Header("ETag", versionETag(order.Version))
 	ctx.JSON(http.StatusAccepted, order)
 }
 
 func (h *OrderHandler) DeleteOrder(ctx *gin.Context) {
 	var order models.Order
 	id := ctx.Param("id")
-	if err := h.Db.Where("id = ?", id).First(&order).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&order).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("order not found"))
@@ -176,8 +228,15 @@ func (h *OrderHandler) DeleteOrder(ctx *gin.Context) {
 		}
 		return
 	}
+	if !checkIfMatch(ctx, order.Version) {
+		return
+	}
 
-	if err := h.Db.Delete(&order).Error; err != nil {
+	if err := deleteWithVersion(tenantDB(ctx, h.Db), &order, order.Version); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			respondVersionConflict(ctx)
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to delete order", err))
 		return
 	}
@@ -210,7 +269,7 @@ func (h *OrderHandler) ExportPDF(ctx *gin.Context) {
 	pdf.SetFont("ipaexg", "", 28)
 
 	var order models.Order
-	if err := h.Db.Where("id = ?", req.OrderID).First(&order).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", req.OrderID).First(&order).Error; err != nil {
 		if gorm.IsRecordNotFoundError(err) {
 			h.logger.Error("failed to get order", zap.Error(err),
 				zap.String("trace_id", traceID))
@@ -224,7 +283,7 @@ func (h *OrderHandler) ExportPDF(ctx *gin.Context) {
 	}
 
 	var user models.User
-	if err := h.Db.Where("id = ?", order.UserID).First(&user).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", order.UserID).First(&user).Error; err != nil {
 		if gorm.IsRecordNotFoundError(err) {
 			h.logger.Error("failed to get user", zap.Error(err),
 				zap.String("trace_id", traceID))
@@ -297,9 +356,9 @@ func convertKoyomi(date time.Time) (year, month, day string) {
 	return year, strconv.Itoa(int(te.Month())), strconv.Itoa(te.Day())
 }
 
-func createOrderQueryBuilder(params searchOrderParams, h *OrderHandler) *gorm.DB {
+func createOrderQueryBuilder(ctx *gin.Context, params searchOrderParams, h *OrderHandler) *gorm.DB {
 	var orders []models.Order
-	query := h.Db.Order("created_at desc").Find(&orders)
+	query := tenantDB(ctx, h.Db).Order("created_at desc").Find(&orders)
 	if params.UserID != "" {
 		query = query.Where("user_id = ?", params.UserID)
 	}
@@ -47,6 +47,7 @@ var getOrdersTestCases = []struct {
 					"total_price": 200,
 					"order_status": "waiting",
 					"remarks": "remarks",
+					"version": 1,
 					"order_details": [
 						{
 							"id": "27f1c6ce-7588-4300-9014-e6649af06319",
@@ -75,6 +76,7 @@ var getOrdersTestCases = []struct {
 					"total_price": 100,
 					"order_status": "new",
 					"remarks": "test",
+					"version": 1,
 					"order_details": [
 						{
 							"id": "218c51c0-904e-4743-a2ae-94f0e34a0d6f",
@@ -171,6 +173,7 @@ var getOrdersTestCases = []struct {
 					"total_price": 100,
 					"order_status": "new",
 					"remarks": "test",
+					"version": 1,
 					"order_details": [
 						{
 							"id": "218c51c0-904e-4743-a2ae-94f0e34a0d6f",
@@ -212,6 +215,7 @@ var getOrdersTestCases = []struct {
 					"total_price": 100,
 					"order_status": "new",
 					"remarks": "test",
+					"version": 1,
 					"order_details": [
 						{
 							"id": "218c51c0-904e-4743-a2ae-94f0e34a0d6f",
@@ -253,6 +257,7 @@ var getOrdersTestCases = []struct {
 					"total_price": 100,
 					"order_status": "new",
 					"remarks": "test",
+					"version": 1,
 					"order_details": [
 						{
 							"id": "218c51c0-904e-4743-a2ae-94f0e34a0d6f",
@@ -294,6 +299,7 @@ var getOrdersTestCases = []struct {
 					"total_price": 100,
 					"order_status": "new",
 					"remarks": "test",
+					"version": 1,
 					"order_details": [
 						{
 							"id": "218c51c0-904e-4743-a2ae-94f0e34a0d6f",
@@ -335,6 +341,7 @@ var getOrdersTestCases = []struct {
 					"total_price": 100,
 					"order_status": "new",
 					"remarks": "test",
+					"version": 1,
 					"order_details": [
 						{
 							"id": "218c51c0-904e-4743-a2ae-94f0e34a0d6f",
@@ -376,6 +383,7 @@ var getOrdersTestCases = []struct {
 					"total_price": 200,
 					"order_status": "waiting",
 					"remarks": "remarks",
+					"version": 1,
 					"order_details": [
 						{
 							"id": "27f1c6ce-7588-4300-9014-e6649af06319",
@@ -458,6 +466,7 @@ var getOrderDetailTestCases = []struct {
 			"total_price": 100,
 			"order_status": "new",
 			"remarks": "test",
+			"version": 1,
 			"order_details": [
 				{
 					"id": "218c51c0-904e-4743-a2ae-94f0e34a0d6f",
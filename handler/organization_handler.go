@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+type OrganizationHandler struct {
+	Db     *gorm.DB
+	logger *zap.Logger
+}
+
+func NewOrganizationHandler(db *gorm.DB, logger *zap.Logger) *OrganizationHandler {
+	return &OrganizationHandler{
+		Db:     db,
+		logger: logger,
+	}
+}
+
+type updateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=64" example:"株式会社サンプル"`
+}
+
+// tenantDB リクエストの組織に絞り込んだDBを返す
+// 組織はResolveOrganizationミドルウェアが設定する。認証を経ないリクエストでは絞り込まない
+func tenantDB(ctx *gin.Context, db *gorm.DB) *gorm.DB {
+	return db.Scopes(models.OrganizationScope(appcontext.GetOrganizationID(ctx)))
+}
+
+// GetOrganization @title 組織詳細
+// @id GetOrganization
+// @tags organization
+// @version バージョン(1.0)
+// @description リクエストの対象となっている組織を返す
+// @Summary 組織詳細取得
+// @Produce json
+// @Success 200 {object} models.Organization
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /organization [GET]
+func (h *OrganizationHandler) GetOrganization(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var organization models.Organization
+	if err := h.Db.Where("id = ?", appcontext.GetOrganizationID(ctx)).First(&organization).Error; err != nil {
+		h.abortOrganizationLookup(ctx, traceID, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, organization)
+}
+
+// UpdateOrganization @title 組織編集
+// @id UpdateOrganization
+// @tags organization
+// @version バージョン(1.0)
+// @description リクエストの対象となっている組織の名前を変更する
+// @Summary 組織編集
+// @Produce json
+// @Success 202 {object} models.Organization
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /organization [PUT]
+// @Accept json
+// @Param updateOrganizationRequest body updateOrganizationRequest true "update organization"
+func (h *OrganizationHandler) UpdateOrganization(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var organization models.Organization
+	if err := h.Db.Where("id = ?", appcontext.GetOrganizationID(ctx)).First(&organization).Error; err != nil {
+		h.abortOrganizationLookup(ctx, traceID, err)
+		return
+	}
+	var req updateOrganizationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	organization.Name = req.Name
+	organization.UpdatedAt = time.Now()
+	if err := h.Db.Save(&organization).Error; err != nil {
+		h.logger.Error("failed to update organization", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update organization", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, organization)
+}
+
+func (h *OrganizationHandler) abortOrganizationLookup(ctx *gin.Context, traceID string, err error) {
+	switch err {
+	case gorm.ErrRecordNotFound:
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("organization not found"))
+	default:
+		h.logger.Error("failed to get organization", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get organization", err))
+	}
+}
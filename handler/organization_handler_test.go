@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/middleware"
+	"github.com/AI1411/golang-admin-api/models/mock_model"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+)
+
+const (
+	organizationAIDForTest = "0a6c2b8e-1f3d-4c2a-9b7e-5d4f3a2b1c01"
+	organizationBIDForTest = "0a6c2b8e-1f3d-4c2a-9b7e-5d4f3a2b1c02"
+	userAIDForTest         = "7b1e4c2d-3a5f-4e6b-8c9d-0e1f2a3b4c01"
+	userBIDForTest         = "7b1e4c2d-3a5f-4e6b-8c9d-0e1f2a3b4c02"
+	productAIDForTest      = "3c2d1e0f-9a8b-4c7d-6e5f-4a3b2c1d0e01"
+	productBIDForTest      = "3c2d1e0f-9a8b-4c7d-6e5f-4a3b2c1d0e02"
+	projectBIDForTest      = "5e4f3a2b-1c0d-4e9f-8a7b-6c5d4e3f2a02"
+	createdProductIDToTest = "3c2d1e0f-9a8b-4c7d-6e5f-4a3b2c1d0e03"
+)
+
+var tenantIsolationTestCases = []struct {
+	tid          int
+	name         string
+	method       string
+	path         string
+	organization string
+	request      map[string]interface{}
+	wantStatus   int
+	wantBody     string
+}{
+	{
+		tid:        1,
+		name:       "一覧には自分の組織の商品だけが含まれること",
+		method:     http.MethodGet,
+		path:       "/products",
+		wantStatus: http.StatusOK,
+		wantBody: `{
+			"total": 1,
+			"products": [
+				{
+					"id": "3c2d1e0f-9a8b-4c7d-6e5f-4a3b2c1d0e01",
+					"product_name": "A",
+					"price": 100,
+					"remarks": "",
+					"quantity": 1
+				}
+			]
+		}`,
+	},
+	{
+		tid:        2,
+		name:       "他の組織の商品は取得できないこと",
+		method:     http.MethodGet,
+		path:       "/products/" + productBIDForTest,
+		wantStatus: http.StatusNotFound,
+		wantBody: `{
+			"message": "product not found",
+			"status": 404,
+			"error": "not_found",
+			"causes": null
+		}`,
+	},
+	{
+		tid:    3,
+		name:   "他の組織の商品は更新できないこと",
+		method: http.MethodPut,
+		path:   "/products/" + productBIDForTest,
+		request: map[string]interface{}{
+			"product_name": "updated",
+			"price":        1,
+			"quantity":     1,
+		},
+		wantStatus: http.StatusNotFound,
+		wantBody: `{
+			"message": "product not found",
+			"status": 404,
+			"error": "not_found",
+			"causes": null
+		}`,
+	},
+	{
+		tid:        4,
+		name:       "他の組織のプロジェクトは削除できないこと",
+		method:     http.MethodDelete,
+		path:       "/projects/" + projectBIDForTest,
+		wantStatus: http.StatusNotFound,
+		wantBody: `{
+			"message": "project not found",
+			"status": 404,
+			"error": "not_found",
+			"causes": null
+		}`,
+	},
+	{
+		tid:        5,
+		name:       "ユーザー一覧には自分の組織のユーザーだけが含まれること",
+		method:     http.MethodGet,
+		path:       "/users?email=b@example.com",
+		wantStatus: http.StatusOK,
+		wantBody: `{
+			"total": 0,
+			"users": []
+		}`,
+	},
+	{
+		tid:          6,
+		name:         "所属していない組織を指定した場合は403になること",
+		method:       http.MethodGet,
+		path:         "/products",
+		organization: organizationBIDForTest,
+		wantStatus:   http.StatusForbidden,
+		wantBody: `{
+			"message": "user does not belong to the organization",
+			"status": 403,
+			"error": "forbidden",
+			"causes": null
+		}`,
+	},
+	{
+		tid:          7,
+		name:         "所属している組織をヘッダーで指定できること",
+		method:       http.MethodGet,
+		path:         "/organization",
+		organization: organizationAIDForTest,
+		wantStatus:   http.StatusOK,
+		wantBody: `{
+			"id": "0a6c2b8e-1f3d-4c2a-9b7e-5d4f3a2b1c01",
+			"name": "A",
+			"created_at": "2022-09-25T10:00:00+09:00",
+			"updated_at": "2022-09-25T10:00:00+09:00"
+		}`,
+	},
+}
+
+func TestTenantIsolation(t *testing.T) {
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE organizations")
+	dbConn.Exec("TRUNCATE TABLE users")
+	dbConn.Exec("TRUNCATE TABLE products")
+	dbConn.Exec("TRUNCATE TABLE projects")
+	dbConn.Exec("insert into organizations (id, name, created_at, updated_at)values('0a6c2b8e-1f3d-4c2a-9b7e-5d4f3a2b1c01','A','2022-09-25 10:00:00','2022-09-25 10:00:00'),('0a6c2b8e-1f3d-4c2a-9b7e-5d4f3a2b1c02','B','2022-09-25 10:00:00','2022-09-25 10:00:00');")
+	dbConn.Exec("insert into users (id, organization_id, first_name, last_name, age, email, password, created_at, updated_at)values('7b1e4c2d-3a5f-4e6b-8c9d-0e1f2a3b4c01','0a6c2b8e-1f3d-4c2a-9b7e-5d4f3a2b1c01','a','a',20,'a@example.com','','2022-09-25 10:00:00','2022-09-25 10:00:00'),('7b1e4c2d-3a5f-4e6b-8c9d-0e1f2a3b4c02','0a6c2b8e-1f3d-4c2a-9b7e-5d4f3a2b1c02','b','b',20,'b@example.com','','2022-09-25 10:00:00','2022-09-25 10:00:00');")
+	dbConn.Exec("insert into products (id, organization_id, product_name, price, remarks, quantity, created_at, updated_at)values('3c2d1e0f-9a8b-4c7d-6e5f-4a3b2c1d0e01','0a6c2b8e-1f3d-4c2a-9b7e-5d4f3a2b1c01','A',100,'',1,'2022-09-25 10:00:00','2022-09-25 10:00:00'),('3c2d1e0f-9a8b-4c7d-6e5f-4a3b2c1d0e02','0a6c2b8e-1f3d-4c2a-9b7e-5d4f3a2b1c02','B',200,'',2,'2022-09-25 10:00:00','2022-09-25 10:00:00');")
+	dbConn.Exec("insert into projects (id, organization_id, project_title, project_description, created_at, updated_at)values('5e4f3a2b-1c0d-4e9f-8a7b-6c5d4e3f2a02','0a6c2b8e-1f3d-4c2a-9b7e-5d4f3a2b1c02','B','B','2022-09-25 10:00:00','2022-09-25 10:00:00');")
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(func(_ *gin.Context) { binding.EnableDecoderUseNumber = true })
+	r.Use(middleware.NewTracing())
+	r.Use(middleware.NewLogging(zapLogger))
+	// 組織Aのユーザーとして認証済みにする
+	r.Use(func(ctx *gin.Context) { appcontext.SetUserIDIntoContext(ctx, userAIDForTest) })
+	r.Use(middleware.ResolveOrganization(dbConn))
+	mockCtrl := gomock.NewController(t)
+	uuidGen := mock_models.NewMockUUIDGenerator(mockCtrl)
+	uuidGen.EXPECT().GenerateUUID().Return(createdProductIDToTest).AnyTimes()
+	productHandler := NewProductHandler(dbConn, uuidGen, zapLogger)
+	projectHandler := NewProjectHandler(dbConn, uuidGen, zapLogger)
+	userHandler := NewUserHandler(dbConn, zapLogger)
+	organizationHandler := NewOrganizationHandler(dbConn, zapLogger)
+	r.GET("/products", productHandler.GetAllProduct)
+	r.GET("/products/:id", productHandler.GetProductDetail)
+	r.POST("/products", productHandler.CreateProduct)
+	r.PUT("/products/:id", productHandler.UpdateProduct)
+	r.DELETE("/projects/:id", projectHandler.DeleteProject)
+	r.GET("/users", userHandler.GetAllUser)
+	r.GET("/organization", organizationHandler.GetOrganization)
+
+	for _, tt := range tenantIsolationTestCases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var body *bytes.Buffer
+			if tt.request != nil {
+				jsonStr, _ := json.Marshal(tt.request)
+				body = bytes.NewBuffer(jsonStr)
+			} else {
+				body = bytes.NewBuffer(nil)
+			}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, body)
+			if tt.organization != "" {
+				req.Header.Set(middleware.OrganizationHeader, tt.organization)
+			}
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+		})
+	}
+
+	t.Run("他の組織のデータが変更されていないこと", func(t *testing.T) {
+		var productName string
+		require.NoError(t, dbConn.Table("products").Where("id = ?", productBIDForTest).
+			Select("product_name").Row().Scan(&productName))
+		assert.Equal(t, "B", productName)
+		var count int
+		require.NoError(t, dbConn.Table("projects").Where("id = ?", projectBIDForTest).Count(&count).Error)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("作成したレコードが自分の組織に所属すること", func(t *testing.T) {
+		jsonStr, _ := json.Marshal(map[string]interface{}{
+			"product_name": "created",
+			"price":        1,
+			"quantity":     1,
+		})
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/products", bytes.NewBuffer(jsonStr))
+		r.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var organizationID string
+		require.NoError(t, dbConn.Table("products").Where("id = ?", createdProductIDToTest).
+			Select("organization_id").Row().Scan(&organizationID))
+		assert.Equal(t, organizationAIDForTest, organizationID)
+	})
+}
@@ -0,0 +1,361 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/payment"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+	"github.com/AI1411/golang-admin-api/webhook"
+)
+
+const paymentSignatureHeader = "X-Payment-Signature"
+
+type PaymentHandler struct {
+	Db            *gorm.DB
+	logger        *zap.Logger
+	uuidGenerator models.UUIDGenerator
+	provider      payment.Provider
+}
+
+func NewPaymentHandler(db *gorm.DB, logger *zap.Logger, uuidGenerator models.UUIDGenerator,
+	provider payment.Provider,
+) *PaymentHandler {
+	return &PaymentHandler{
+		Db:            db,
+		logger:        logger,
+		uuidGenerator: uuidGenerator,
+		provider:      provider,
+	}
+}
+
+type createPaymentRequest struct {
+	Amount int64  `json:"amount" binding:"omitempty,min=1" example:"1000"`
+	Token  string `json:"token" binding:"required,max=255" example:"tok_visa"`
+}
+
+type paymentAmountRequest struct {
+	Amount int64 `json:"amount" binding:"omitempty,min=1" example:"1000"`
+}
+
+type paymentsResponse struct {
+	Total    int              `json:"total"`
+	Payments []models.Payment `json:"payments"`
+}
+
+// GetOrderPayments @title 注文の決済一覧
+// @id GetOrderPayments
+// @tags payments
+// @version バージョン(1.0)
+// @description 注文に紐づく決済一覧を返す
+// @Summary 決済一覧取得
+// @Produce json
+// @Success 200 {object} paymentsResponse
+// @Failure 500 {object} errorResponse
+// @Router /orders/:id/payments [GET]
+// @Param id path string true "注文ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *PaymentHandler) GetOrderPayments(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var payments []models.Payment
+	if err := tenantDB(ctx, h.Db).Where("order_id = ?", ctx.Param("id")).Order("created_at").Find(&payments).Error; err != nil {
+		h.logger.Error("failed to get payments", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get payments", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, paymentsResponse{
+		Total:    len(payments),
+		Payments: payments,
+	})
+}
+
+// CreatePayment @title 決済オーソリ
+// @id CreatePayment
+// @tags payments
+// @version バージョン(1.0)
+// @description 注文に対して決済のオーソリを行う。amountを省略した場合は未入金かつ未オーソリの額をオーソリする
+// @Summary 決済オーソリ
+// @Produce json
+// @Success 201 {object} models.Payment
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /orders/:id/payments [POST]
+// @Accept json
+// @Param createPaymentRequest body createPaymentRequest true "authorize payment"
+// @Param id path string true "注文ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *PaymentHandler) CreatePayment(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req createPaymentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+
+	var p models.Payment
+	var authorizeErr error
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		// 同じ注文へのオーソリが並行しても合計金額を超えないよう、注文を行ロックしてから未入金額を求める
+		var order models.Order
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", ctx.Param("id")).First(&order).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return errors.NewNotFoundError("order not found")
+			}
+			return err
+		}
+		var payments models.PaymentList
+		if err := tx.Where("order_id = ?", order.ID).Find(&payments).Error; err != nil {
+			return err
+		}
+		remaining := order.TotalPrice - payments.NetCapturedAmount() - payments.AuthorizedAmount()
+		amount := req.Amount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount <= 0 || amount > remaining {
+			return errors.NewBadRequestError("invalid payment amount")
+		}
+
+		p = models.Payment{
+			ID:        h.uuidGenerator.GenerateUUID(),
+			OrderID:   order.ID,
+			Provider:  h.provider.Name(),
+			Amount:    amount,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		// オーソリは売上確定まで入金されないため、コミットに失敗してもプロバイダ側で期限切れになる
+		result, err := h.provider.Authorize(ctx, payment.AuthorizeRequest{
+			OrderID: order.ID,
+			Amount:  amount,
+			Token:   req.Token,
+		})
+		if err != nil {
+			authorizeErr = err
+			p.PaymentStatus = models.PaymentStatusFailed
+			p.FailureReason = err.Error()
+			return tx.Create(&p).Error
+		}
+		applyPaymentResult(&p, result)
+		return tx.Create(&p).Error
+	}); err != nil {
+		if restErr, ok := err.(errors.RestErr); ok {
+			ctx.JSON(restErr.Status(), restErr)
+			return
+		}
+		h.logger.Error("failed to create payment", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create payment", err))
+		return
+	}
+	if authorizeErr != nil {
+		h.logger.Info("payment authorization failed", zap.Error(authorizeErr),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("payment authorization failed"))
+		return
+	}
+	ctx.JSON(http.StatusCreated, p)
+}
+
+// CapturePayment @title 売上確定
+// @id CapturePayment
+// @tags payments
+// @version バージョン(1.0)
+// @description オーソリ済みの決済を売上確定し、注文ステータスを更新する。amountを省略した場合はオーソリ全額を確定する
+// @Summary 売上確定
+// @Produce json
+// @Success 202 {object} models.Payment
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /payments/:id/capture [POST]
+// @Accept json
+// @Param paymentAmountRequest body paymentAmountRequest false "capture amount"
+// @Param id path string true "決済ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *PaymentHandler) CapturePayment(ctx *gin.Context) {
+	h.updatePayment(ctx, func(p *models.Payment, amount int64) (*payment.Result, error) {
+		if amount == 0 {
+			amount = p.Amount
+		}
+		return h.provider.Capture(ctx, p.TransactionID, amount)
+	})
+}
+
+// RefundPayment @title 返金
+// @id RefundPayment
+// @tags payments
+// @version バージョン(1.0)
+// @description 売上確定済みの決済を返金し、注文ステータスを更新する。amountを省略した場合は返金可能な全額を返金する
+// @Summary 返金
+// @Produce json
+// @Success 202 {object} models.Payment
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /payments/:id/refund [POST]
+// @Accept json
+// @Param paymentAmountRequest body paymentAmountRequest false "refund amount"
+// @Param id path string true "決済ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *PaymentHandler) RefundPayment(ctx *gin.Context) {
+	h.updatePayment(ctx, func(p *models.Payment, amount int64) (*payment.Result, error) {
+		if amount == 0 {
+			amount = p.CapturedAmount - p.RefundedAmount
+		}
+		return h.provider.Refund(ctx, p.TransactionID, amount)
+	})
+}
+
+// HandleWebhook @title 決済Webhook
+// @id HandlePaymentWebhook
+// @tags payments
+// @version バージョン(1.0)
+// @description 決済プロバイダからの通知を署名検証した上で取り込み、注文ステータスを更新する
+// @Summary 決済Webhook受信
+// @Success 204
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /payments/webhook [POST]
+// @Accept json
+func (h *PaymentHandler) HandleWebhook(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	payload, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("failed to read body"))
+		return
+	}
+	event, err := h.provider.VerifyWebhook(payload, ctx.GetHeader(paymentSignatureHeader))
+	if err != nil {
+		h.logger.Error("failed to verify payment webhook", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid webhook"))
+		return
+	}
+
+	var p models.Payment
+	if err := tenantDB(ctx, h.Db).Where("provider = ? AND transaction_id = ?", h.provider.Name(), event.TransactionID).
+		First(&p).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("payment not found"))
+			return
+		}
+		h.logger.Error("failed to get payment", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get payment", err))
+		return
+	}
+	applyPaymentResult(&p, &payment.Result{
+		TransactionID:  event.TransactionID,
+		Status:         event.Status,
+		Amount:         p.Amount,
+		CapturedAmount: event.CapturedAmount,
+		RefundedAmount: event.RefundedAmount,
+	})
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&p).Error; err != nil {
+			return err
+		}
+		return syncOrderPaymentStatus(tx, p.OrderID)
+	}); err != nil {
+		h.logger.Error("failed to apply payment webhook", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to apply payment webhook", err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *PaymentHandler) updatePayment(ctx *gin.Context,
+	call func(p *models.Payment, amount int64) (*payment.Result, error),
+) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req paymentAmountRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			res := createValidateErrorResponse(err)
+			res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+			ctx.AbortWithStatusJSON(res.Code, res)
+			return
+		}
+	}
+
+	var p models.Payment
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&p).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("payment not found"))
+			return
+		}
+		h.logger.Error("failed to get payment", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get payment", err))
+		return
+	}
+
+	result, err := call(&p, req.Amount)
+	if err != nil {
+		h.logger.Info("payment operation failed", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError(err.Error()))
+		return
+	}
+	applyPaymentResult(&p, result)
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&p).Error; err != nil {
+			return err
+		}
+		return syncOrderPaymentStatus(tx, p.OrderID)
+	}); err != nil {
+		h.logger.Error("failed to update payment", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update payment", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, p)
+}
+
+func applyPaymentResult(p *models.Payment, result *payment.Result) {
+	p.TransactionID = result.TransactionID
+	p.PaymentStatus = models.PaymentStatus(result.Status)
+	p.CapturedAmount = result.CapturedAmount
+	p.RefundedAmount = result.RefundedAmount
+	p.UpdatedAt = time.Now()
+}
+
+// syncOrderPaymentStatus 注文に紐づく決済の状態から注文ステータスを再計算する
+func syncOrderPaymentStatus(tx *gorm.DB, orderID string) error {
+	var order models.Order
+	if err := tx.Where("id = ?", orderID).First(&order).Error; err != nil {
+		return err
+	}
+	// 決済Webhookのようにリクエストに組織が無い場合でも、注文の組織の購読にだけ通知する
+	tx = tx.Scopes(models.OrganizationScope(order.OrganizationID))
+	var payments models.PaymentList
+	if err := tx.Where("order_id = ?", orderID).Find(&payments).Error; err != nil {
+		return err
+	}
+	status := payments.OrderStatus(order.TotalPrice, order.OrderStatus)
+	if status == order.OrderStatus {
+		return nil
+	}
+	if err := tx.Table("orders").Where("id = ?", order.ID).Updates(map[string]interface{}{
+		"order_status": status,
+		"updated_at":   time.Now(),
+		"version":      gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+	return webhook.Enqueue(tx, webhook.EventOrderStatusChanged, webhook.OrderStatusChanged{
+		OrderID:    order.ID,
+		FromStatus: order.OrderStatus,
+		ToStatus:   status,
+	})
+}
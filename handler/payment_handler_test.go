@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/middleware"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/payment"
+)
+
+func TestHandlePaymentWebhook(t *testing.T) {
+	const orderID = "090e142d-baa3-4039-9d21-cf5a1af39094"
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE orders")
+	dbConn.Exec("TRUNCATE TABLE payments")
+	dbConn.Exec("TRUNCATE TABLE webhook_subscriptions")
+	dbConn.Exec("TRUNCATE TABLE webhook_deliveries")
+	require.NoError(t, dbConn.Exec("INSERT INTO orders (id, organization_id, user_id, quantity, total_price, order_status, remarks, created_at, updated_at)VALUES (?, ?, '7dc41179-824e-4b8a-b894-2082ca5eac5b', 3, 300, 'new', 'test','2022-06-11 10:36:43', '2022-06-11 10:36:43');",
+		orderID, organizationAIDForTest).Error)
+	require.NoError(t, dbConn.Exec("INSERT INTO webhook_subscriptions (id, organization_id, url, event_types, secret, is_active)VALUES"+
+		" ('4a1e5b2c-7d3f-4e8a-9b0c-1d2e3f4a5b01', ?, 'http://127.0.0.1:1', 'order.status_changed', 'secret', true),"+
+		" ('4a1e5b2c-7d3f-4e8a-9b0c-1d2e3f4a5b02', ?, 'http://127.0.0.1:1', 'order.status_changed', 'secret', true);",
+		organizationAIDForTest, organizationBIDForTest).Error)
+
+	provider := payment.NewFakeProvider("secret")
+	authorized, err := provider.Authorize(context.Background(), payment.AuthorizeRequest{OrderID: orderID, Amount: 300, Token: "tok_visa"})
+	require.NoError(t, err)
+	require.NoError(t, dbConn.Exec("INSERT INTO payments (id, organization_id, order_id, provider, transaction_id, amount, captured_amount, refunded_amount, payment_status, failure_reason)VALUES ('5c3325c1-d539-42d6-b405-2af2f6b99ed9', ?, ?, 'fake', ?, 300, 0, 0, 'authorized', '');",
+		organizationAIDForTest, orderID, authorized.TransactionID).Error)
+
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(middleware.NewTracing())
+	paymentHandler := NewPaymentHandler(dbConn, zapLogger, &models.RandomUUIDGenerator{}, provider)
+	r.POST("/payments/webhook", paymentHandler.HandleWebhook)
+
+	t.Run("注文の組織の購読にだけ注文ステータスの変更を通知すること", func(t *testing.T) {
+		payload := `{"transaction_id":"` + authorized.TransactionID + `","status":"captured","captured_amount":300,"refunded_amount":0}`
+		req := httptest.NewRequest(http.MethodPost, "/payments/webhook", strings.NewReader(payload))
+		req.Header.Set(paymentSignatureHeader, provider.SignWebhook([]byte(payload)))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		var order models.Order
+		require.NoError(t, dbConn.Where("id = ?", orderID).First(&order).Error)
+		assert.Equal(t, models.OrderStatusPaid, order.OrderStatus)
+
+		var deliveries []models.WebhookDelivery
+		require.NoError(t, dbConn.Find(&deliveries).Error)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, "4a1e5b2c-7d3f-4e8a-9b0c-1d2e3f4a5b01", deliveries[0].SubscriptionID)
+		assert.Equal(t, organizationAIDForTest, deliveries[0].OrganizationID)
+	})
+}
+
+func TestCreatePayment(t *testing.T) {
+	const orderID = "090e142d-baa3-4039-9d21-cf5a1af39094"
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE orders")
+	dbConn.Exec("TRUNCATE TABLE payments")
+	require.NoError(t, dbConn.Exec("INSERT INTO orders (id, user_id, quantity, total_price, order_status, remarks, created_at, updated_at)VALUES (?, '7dc41179-824e-4b8a-b894-2082ca5eac5b', 3, 300, 'new', 'test','2022-06-11 10:36:43', '2022-06-11 10:36:43');",
+		orderID).Error)
+
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(middleware.NewTracing())
+	paymentHandler := NewPaymentHandler(dbConn, zapLogger, &models.RandomUUIDGenerator{}, payment.NewFakeProvider("secret"))
+	r.POST("/orders/:id/payments", paymentHandler.CreatePayment)
+	authorize := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders/"+orderID+"/payments", strings.NewReader(body)))
+		return rec
+	}
+
+	t.Run("未入金額の範囲でオーソリできること", func(t *testing.T) {
+		rec := authorize(`{"amount":200,"token":"tok_visa"}`)
+		require.Equal(t, http.StatusCreated, rec.Code)
+	})
+
+	t.Run("既存のオーソリと合わせて合計金額を超える場合は400を返すこと", func(t *testing.T) {
+		rec := authorize(`{"amount":200,"token":"tok_visa"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message": "invalid payment amount","status": 400,"error": "bad_request","causes": null}`, rec.Body.String())
+	})
+
+	t.Run("amountを省略した場合は未オーソリの額だけをオーソリすること", func(t *testing.T) {
+		rec := authorize(`{"token":"tok_visa"}`)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var p models.Payment
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+		assert.Equal(t, int64(100), p.Amount)
+		assert.Equal(t, models.PaymentStatusAuthorized, p.PaymentStatus)
+	})
+
+	t.Run("オーソリ済みの額が合計金額に達した後は400を返すこと", func(t *testing.T) {
+		rec := authorize(`{"token":"tok_visa"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var count int
+		require.NoError(t, dbConn.Table("payments").Where("order_id = ?", orderID).Count(&count).Error)
+		assert.Equal(t, 2, count)
+	})
+}
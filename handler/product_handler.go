@@ -81,7 +81,7 @@ func (h *ProductHandler) GetAllProduct(ctx *gin.Context) {
 		return
 	}
 	var products []models.Product
-	query := createProductQueryBuilder(params, h)
+	query := createProductQueryBuilder(ctx, params, h)
 	if err := query.Find(&products).Error; err != nil {
 		h.logger.Error("failed to get products", zap.Error(err),
 			zap.String("trace_id", traceID))
@@ -111,7 +111,7 @@ func (h *ProductHandler) GetProductDetail(ctx *gin.Context) {
 	var product models.Product
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&product).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&product).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to get product", zap.Error(err),
@@ -149,7 +149,7 @@ func (h *ProductHandler) CreateProduct(ctx *gin.Context) {
 	}
 	product.ID = h.uuidGenerator.GenerateUUID()
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Create(&product).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Create(&product).Error; err != nil {
 		h.logger.Error("failed to create product", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create product", err))
@@ -173,10 +173,34 @@ func (h *ProductHandler) CreateProduct(ctx *gin.Context) {
 // @Param productRequest body productRequest true "update product"
 // @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
 func (h *ProductHandler) UpdateProduct(ctx *gin.Context) {
+	h.updateProduct(ctx, bindPut)
+}
+
+// PatchProduct @title product部分編集
+// @id PatchProduct
+// @tags products
+// @version バージョン(1.0)
+// @description productを部分的に編集する。JSON Merge Patch(RFC 7396)で、nullを指定した項目は空にする
+// @description 指定した項目のみを検証し、idは変更できない
+// @Summary product部分編集
+// @Produce json
+// @Success 202 {object} productResponseItem
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /products/:id [PATCH]
+// @Accept json
+// @Param productRequest body productRequest true "patch product"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *ProductHandler) PatchProduct(ctx *gin.Context) {
+	h.updateProduct(ctx, bindMergePatch("id"))
+}
+
+// updateProduct PUTとPATCHで共通の編集処理。bindで更新内容を反映する
+func (h *ProductHandler) updateProduct(ctx *gin.Context, bind bindFunc) {
 	product := models.Product{}
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&product).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&product).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to update product", zap.Error(err),
@@ -189,13 +213,13 @@ func (h *ProductHandler) UpdateProduct(ctx *gin.Context) {
 		}
 		return
 	}
-	if err := ctx.ShouldBindJSON(&product); err != nil {
+	if err := bind(ctx, &product); err != nil {
 		res := createValidateErrorResponse(err)
 		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
-	if err := h.Db.Save(&product).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Save(&product).Error; err != nil {
 		h.logger.Error("failed to update product", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update product", err))
@@ -221,7 +245,7 @@ func (h *ProductHandler) DeleteProduct(ctx *gin.Context) {
 	product := models.Product{}
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&product).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&product).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			h.logger.Error("failed to delete product", zap.Error(err),
@@ -234,7 +258,7 @@ func (h *ProductHandler) DeleteProduct(ctx *gin.Context) {
 		}
 		return
 	}
-	if err := h.Db.Delete(&product).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Delete(&product).Error; err != nil {
 		h.logger.Error("failed to delete product", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to delete product", err))
@@ -243,9 +267,9 @@ func (h *ProductHandler) DeleteProduct(ctx *gin.Context) {
 	ctx.JSON(http.StatusNoContent, nil)
 }
 
-func createProductQueryBuilder(params searchProductParams, h *ProductHandler) *gorm.DB {
+func createProductQueryBuilder(ctx *gin.Context, params searchProductParams, h *ProductHandler) *gorm.DB {
 	var products []models.Product
-	query := h.Db.Find(&products)
+	query := tenantDB(ctx, h.Db).Find(&products)
 	if params.ProductName != "" {
 		query = query.Where("product_name LIKE ?", "%"+params.ProductName+"%")
 	}
@@ -3,6 +3,7 @@ package handler
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/AI1411/golang-admin-api/util/appcontext"
 	"go.uber.org/zap"
@@ -74,7 +75,7 @@ func (h *ProjectHandler) GetProjects(ctx *gin.Context) {
 		return
 	}
 	var projects []models.Project
-	query := createProjectQueryBuilder(params, h)
+	query := createProjectQueryBuilder(ctx, params, h)
 	if err := query.Find(&projects).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get epics", err))
 		return
@@ -89,19 +90,21 @@ func (h *ProjectHandler) GetProjects(ctx *gin.Context) {
 // @id GetProjectDetail
 // @tags projects
 // @version バージョン(1.0)
-// @description project詳細を返す
+// @description project詳細を返す。ETagを返し、If-None-Matchが一致する場合は304を返す
 // @Summary project詳細取得
 // @Produce json
 // @Success 200 {object} projectResponseItem
+// @Success 304
 // @Failure 400 {object} errorResponse
 // @Failure 404 {object} errorResponse
 // @Failure 500 {object} errorResponse
 // @Router /projects/:id [GET]
 // @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param If-None-Match header string false "取得済みのETag"
 func (h *ProjectHandler) GetProjectDetail(ctx *gin.Context) {
 	id := ctx.Param("id")
 	var project models.Project
-	if err := h.Db.Where("id = ?", id).Preload("Epics").First(&project).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).Preload("Epics").First(&project).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("project not found"))
@@ -110,7 +113,342 @@ func (h *ProjectHandler) GetProjectDetail(ctx *gin.Context) {
 		}
 		return
 	}
-	ctx.JSON(http.StatusOK, project)
+	respondWithETag(ctx, project.Version, project)
+}
+
+type searchProjectTreeParams struct {
+	AssigneeID string `form:"assignee_id" binding:"omitempty,len=36"`
+	Label      string `form:"label" binding:"omitempty,max=64"`
+}
+
+// GetProjectTree @title projectツリー
+// @id GetProjectTree
+// @tags projects
+// @version バージョン(1.0)
+// @description projectに属するmilestone・epic・issueを階層で返し、完了率を集計する
+// @Summary projectツリー取得
+// @Produce json
+// @Success 200 {object} models.ProjectTree
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /projects/:id/tree [GET]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param assignee_id query string false "担当者ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param label query string false "ラベル名" maxlength(64)
+func (h *ProjectHandler) GetProjectTree(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var params searchProjectTreeParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind query params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+
+	var project models.Project
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&project).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("project not found"))
+		case gorm.ErrInvalidSQL:
+			ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid sql"))
+		default:
+			h.logger.Error("failed to get project", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get project", err))
+		}
+		return
+	}
+
+	var milestones []models.Milestone
+	if err := tenantDB(ctx, h.Db).Where("project_id = ?", project.ID).Order("created_at").Find(&milestones).Error; err != nil {
+		h.logger.Error("failed to get milestones", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get project tree", err))
+		return
+	}
+
+	var epics models.EpicList
+	if err := createProjectTreeEpicQueryBuilder(ctx, project.ID, params, h).Find(&epics).Error; err != nil {
+		h.logger.Error("failed to get epics", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get project tree", err))
+		return
+	}
+
+	milestoneIDs := make([]string, len(milestones))
+	for i, m := range milestones {
+		milestoneIDs[i] = m.ID
+	}
+	var issues []models.Issue
+	if err := createProjectTreeIssueQueryBuilder(ctx, project.ID, milestoneIDs, params, h).Find(&issues).Error; err != nil {
+		h.logger.Error("failed to get issues", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get project tree", err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.BuildProjectTree(&project, milestones, epics, issues))
+}
+
+type searchProjectBoardParams struct {
+	AssigneeID  string `form:"assignee_id" binding:"omitempty,len=36"`
+	MilestoneID string `form:"milestone_id" binding:"omitempty,len=36"`
+	EpicID      string `form:"epic_id" binding:"omitempty,numeric"`
+}
+
+// GetProjectBoard @title projectボード
+// @id GetProjectBoard
+// @tags projects
+// @version バージョン(1.0)
+// @description projectのワークフローのステータスごとの列に、issueを並び順で返す
+// @Summary projectボード取得
+// @Produce json
+// @Success 200 {object} models.Board
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /projects/:id/board [GET]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param assignee_id query string false "担当者ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param milestone_id query string false "マイルストーンID" minlength(36) maxlength(36) format(UUID v4)
+// @Param epic_id query int false "エピックID"
+func (h *ProjectHandler) GetProjectBoard(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var params searchProjectBoardParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind query params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	var project models.Project
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&project).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("project not found"))
+		default:
+			h.logger.Error("failed to get project", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get board", err))
+		}
+		return
+	}
+	workflow, err := findIssueWorkflow(tenantDB(ctx, h.Db), project.ID)
+	if err != nil {
+		h.logger.Error("failed to get workflow", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get board", err))
+		return
+	}
+
+	query := tenantDB(ctx, h.Db).Preload("Labels").Where("project_id = ?", project.ID)
+	if params.AssigneeID != "" {
+		query = query.Where("user_id = ?", params.AssigneeID)
+	}
+	if params.MilestoneID != "" {
+		query = query.Where("milestone_id = ?", params.MilestoneID)
+	}
+	if params.EpicID != "" {
+		query = query.Where("epic_id = ?", params.EpicID)
+	}
+	var issues []models.Issue
+	if err := query.Find(&issues).Error; err != nil {
+		h.logger.Error("failed to get issues", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get board", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, models.BuildBoard(project.ID, workflow, issues))
+}
+
+type searchProjectVelocityParams struct {
+	Limit string `form:"limit,default=6" binding:"omitempty,numeric"`
+}
+
+// GetProjectVelocity @title projectベロシティ
+// @id GetProjectVelocity
+// @tags projects
+// @version バージョン(1.0)
+// @description 期日が設定されたmilestoneごとの計画量と完了量を期日順に返す。平均は期日を過ぎたmilestoneのみで計算する
+// @Summary projectベロシティ取得
+// @Produce json
+// @Success 200 {object} models.Velocity
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /projects/:id/velocity [GET]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param limit query int false "直近のmilestoneの件数" default(6) minimum(1) maximum(100)
+func (h *ProjectHandler) GetProjectVelocity(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var params searchProjectVelocityParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind query params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	var project models.Project
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&project).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("project not found"))
+		default:
+			h.logger.Error("failed to get project", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get velocity", err))
+		}
+		return
+	}
+
+	var milestones []models.Milestone
+	if err := tenantDB(ctx, h.Db).Where("project_id = ? AND due_date IS NOT NULL", project.ID).
+		Order("due_date desc").Limit(params.Limit).Find(&milestones).Error; err != nil {
+		h.logger.Error("failed to get milestones", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get velocity", err))
+		return
+	}
+	for i, j := 0, len(milestones)-1; i < j; i, j = i+1, j-1 {
+		milestones[i], milestones[j] = milestones[j], milestones[i]
+	}
+
+	var issues []models.Issue
+	if len(milestones) > 0 {
+		milestoneIDs := make([]string, len(milestones))
+		for i, m := range milestones {
+			milestoneIDs[i] = m.ID
+		}
+		if err := tenantDB(ctx, h.Db).Where("milestone_id IN (?)", milestoneIDs).Find(&issues).Error; err != nil {
+			h.logger.Error("failed to get issues", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get velocity", err))
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, models.BuildVelocity(milestones, issues, time.Now()))
+}
+
+type projectWorkflowRequest struct {
+	Statuses []string `json:"statuses" binding:"required,min=2,dive,required,max=64" example:"waiting,in_progress,review,done"`
+}
+
+type projectWorkflowResponse struct {
+	ProjectID string   `json:"project_id"`
+	Statuses  []string `json:"statuses"`
+}
+
+// GetProjectWorkflow @title projectワークフロー
+// @id GetProjectWorkflow
+// @tags projects
+// @version バージョン(1.0)
+// @description projectのissueステータスの並びを返す。未設定の場合は既定のワークフローを返す
+// @Summary projectワークフロー取得
+// @Produce json
+// @Success 200 {object} projectWorkflowResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /projects/:id/workflow [GET]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *ProjectHandler) GetProjectWorkflow(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var project models.Project
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&project).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("project not found"))
+		case gorm.ErrInvalidSQL:
+			ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid sql"))
+		}
+		return
+	}
+	workflow, err := findIssueWorkflow(tenantDB(ctx, h.Db), project.ID)
+	if err != nil {
+		h.logger.Error("failed to get project workflow", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get project workflow", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, projectWorkflowResponse{
+		ProjectID: project.ID,
+		Statuses:  workflow,
+	})
+}
+
+// UpdateProjectWorkflow @title projectワークフロー編集
+// @id UpdateProjectWorkflow
+// @tags projects
+// @version バージョン(1.0)
+// @description projectのissueステータスの並びを設定する。末尾はdoneとし、既存issueのステータスを全て含める必要がある
+// @Summary projectワークフロー編集
+// @Produce json
+// @Success 202 {object} projectWorkflowResponse
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /projects/:id/workflow [PUT]
+// @Accept json
+// @Param projectWorkflowRequest body projectWorkflowRequest true "update project workflow"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *ProjectHandler) UpdateProjectWorkflow(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var project models.Project
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&project).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("project not found"))
+		case gorm.ErrInvalidSQL:
+			ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid sql"))
+		}
+		return
+	}
+	var req projectWorkflowRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	workflow := models.IssueWorkflow(req.Statuses)
+	if !workflow.Validate() {
+		ctx.JSON(http.StatusBadRequest,
+			errors.NewBadRequestError("statuses must be unique and end with "+models.IssueStatusDone))
+		return
+	}
+
+	var statuses []string
+	if err := tenantDB(ctx, h.Db).Table("issues").Where("project_id = ?", project.ID).
+		Pluck("DISTINCT issue_status", &statuses).Error; err != nil {
+		h.logger.Error("failed to get issue statuses", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update project workflow", err))
+		return
+	}
+	for _, status := range statuses {
+		if !workflow.Contains(status) {
+			ctx.JSON(http.StatusBadRequest,
+				errors.NewBadRequestError("statuses must include "+status+" used by existing issues"))
+			return
+		}
+	}
+
+	if err := tenantDB(ctx, h.Db).Save(&models.ProjectWorkflow{
+		ProjectID: project.ID,
+		Statuses:  workflow.String(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}).Error; err != nil {
+		h.logger.Error("failed to update project workflow", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update project workflow", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, projectWorkflowResponse{
+		ProjectID: project.ID,
+		Statuses:  workflow,
+	})
 }
 
 // CreateProject @title project作成
@@ -136,7 +474,8 @@ func (h *ProjectHandler) CreateProject(ctx *gin.Context) {
 		return
 	}
 	project.ID = h.uuidGenerator.GenerateUUID()
-	if err := h.Db.Create(&project).Error; err != nil {
+	project.Version = 1
+	if err := tenantDB(ctx, h.Db).Create(&project).Error; err != nil {
 		ctx.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
@@ -147,20 +486,50 @@ func (h *ProjectHandler) CreateProject(ctx *gin.Context) {
 // @id UpdateProject
 // @tags projects
 // @version バージョン(1.0)
-// @description projectを編集する
+// @description projectを編集する。If-Matchが現在のETagと一致しない場合は412を返す
 // @Summary project編集
 // @Produce json
 // @Success 202 {object} projectResponseItem
 // @Failure 400 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 412 {object} errorResponse
 // @Failure 500 {object} errorResponse
 // @Router /projects/:id [PUT]
 // @Accept json
 // @Param projectRequest body projectRequest true "update project"
 // @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param If-Match header string false "取得時のETag"
 func (h *ProjectHandler) UpdateProject(ctx *gin.Context) {
+	h.updateProject(ctx, bindPut)
+}
+
+// PatchProject @title project部分編集
+// @id PatchProject
+// @tags projects
+// @version バージョン(1.0)
+// @description projectを部分的に編集する。JSON Merge Patch(RFC 7396)で、nullを指定した項目は空にする
+// @description 指定した項目のみを検証し、id, epics, versionは変更できない。If-Matchが現在のETagと一致しない場合は412を返す
+// @Summary project部分編集
+// @Produce json
+// @Success 202 {object} projectResponseItem
+// @Failure 400 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /projects/:id [PATCH]
+// @Accept json
+// @Param projectRequest body projectRequest true "patch project"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param If-Match header string false "取得時のETag"
+func (h *ProjectHandler) PatchProject(ctx *gin.Context) {
+	h.updateProject(ctx, bindMergePatch("id", "epics", "version"))
+}
+
+// updateProject PUTとPATCHで共通の編集処理。bindで更新内容を反映する
+func (h *ProjectHandler) updateProject(ctx *gin.Context, bind bindFunc) {
 	var project models.Project
 	id := ctx.Param("id")
-	if err := h.Db.Where("id = ?", id).First(&project).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&project).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("project not found"))
@@ -169,19 +538,34 @@ func (h *ProjectHandler) UpdateProject(ctx *gin.Context) {
 		}
 		return
 	}
+	if !checkIfMatch(ctx, project.Version) {
+		return
+	}
 	traceID := appcontext.GetTraceID(ctx)
-	if err := ctx.ShouldBindJSON(&project); err != nil {
+	version := project.Version
+	if err := bind(ctx, &project); err != nil {
 		res := createValidateErrorResponse(err)
 		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
+	project.Version = version
 
-	if err := h.Db.Save(&project).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := bumpVersion(tx, "projects", project.ID, &project.Version); err != nil {
+			return err
+		}
+		return tx.Save(&project).Error
+	}); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			respondVersionConflict(ctx)
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update project", err))
 		return
 	}
 
+	ctx.Header("ETag", versionETag(project.Version))
 	ctx.JSON(http.StatusAccepted, project)
 }
 
@@ -189,19 +573,22 @@ func (h *ProjectHandler) UpdateProject(ctx *gin.Context) {
 // @id DeleteProject
 // @tags projects
 // @version バージョン(1.0)
-// @description projectを削除する
+// @description projectを削除する。If-Matchが現在のETagと一致しない場合は412を返す
 // @Summary project削除
 // @Produce json
 // @Success 204
 // @Failure 400 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 412 {object} errorResponse
 // @Failure 500 {object} errorResponse
 // @Router /projects/:id [DELETE]
 // @Accept json
 // @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param If-Match header string false "取得時のETag"
 func (h *ProjectHandler) DeleteProject(ctx *gin.Context) {
 	project := models.Project{}
 	id := ctx.Param("id")
-	if err := h.Db.Where("id = ?", id).First(&project).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&project).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("project not found"))
@@ -210,13 +597,23 @@ func (h *ProjectHandler) DeleteProject(ctx *gin.Context) {
 		}
 		return
 	}
-	h.Db.Delete(&project)
+	if !checkIfMatch(ctx, project.Version) {
+		return
+	}
+	if err := deleteWithVersion(tenantDB(ctx, h.Db), &project, project.Version); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			respondVersionConflict(ctx)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to delete project", err))
+		return
+	}
 	ctx.Status(http.StatusNoContent)
 }
 
-func createProjectQueryBuilder(param searchProjectParams, h *ProjectHandler) *gorm.DB {
+func createProjectQueryBuilder(ctx *gin.Context, param searchProjectParams, h *ProjectHandler) *gorm.DB {
 	var projects []models.Project
-	query := h.Db.Find(&projects)
+	query := tenantDB(ctx, h.Db).Find(&projects)
 	log.Printf("p=%+v", param)
 	if param.ProjectTitle != "" {
 		query = query.Where("project_title LIKE ?", "%"+param.ProjectTitle+"%")
@@ -229,3 +626,34 @@ func createProjectQueryBuilder(param searchProjectParams, h *ProjectHandler) *go
 	}
 	return query
 }
+
+func createProjectTreeEpicQueryBuilder(ctx *gin.Context, projectID string, params searchProjectTreeParams, h *ProjectHandler) *gorm.DB {
+	query := tenantDB(ctx, h.Db).Where("project_id = ?", projectID).Preload("Labels").Order("id")
+	if params.AssigneeID != "" {
+		query = query.Where("assignee_id = ?", params.AssigneeID)
+	}
+	if params.Label != "" {
+		query = query.Where("id IN (SELECT el.epic_id FROM epic_labels el INNER JOIN labels l ON l.id = el.label_id"+
+			" WHERE l.project_id = ? AND l.name = ?)", projectID, params.Label)
+	}
+	return query
+}
+
+func createProjectTreeIssueQueryBuilder(ctx *gin.Context, projectID string, milestoneIDs []string, params searchProjectTreeParams,
+	h *ProjectHandler,
+) *gorm.DB {
+	query := tenantDB(ctx, h.Db).Preload("Labels").Order("created_at")
+	if len(milestoneIDs) > 0 {
+		query = query.Where("project_id = ? OR milestone_id IN (?)", projectID, milestoneIDs)
+	} else {
+		query = query.Where("project_id = ?", projectID)
+	}
+	if params.AssigneeID != "" {
+		query = query.Where("user_id = ?", params.AssigneeID)
+	}
+	if params.Label != "" {
+		query = query.Where("id IN (SELECT il.issue_id FROM issue_labels il INNER JOIN labels l ON l.id = il.label_id"+
+			" WHERE l.project_id = ? AND l.name = ?)", projectID, params.Label)
+	}
+	return query
+}
@@ -43,12 +43,14 @@ var getProjectsTestCases = []struct {
 					"id": "090e142d-baa3-4039-9d21-cf5a1af39094",
 					"project_title": "1",
 					"project_description": "1",
+					"version": 1,
 					"epics": null
 				},
 				{
 					"id": "5c3325c1-d539-42d6-b405-2af2f6b99ed9",
 					"project_title": "2",
 					"project_description": "2",
+					"version": 1,
 					"epics": null
 				}
 			],
@@ -108,6 +110,7 @@ var getProjectsTestCases = []struct {
 					"id": "090e142d-baa3-4039-9d21-cf5a1af39094",
 					"project_title": "1",
 					"project_description": "1",
+					"version": 1,
 					"epics": null
 				}
 			],
@@ -127,6 +130,7 @@ var getProjectsTestCases = []struct {
 					"id": "5c3325c1-d539-42d6-b405-2af2f6b99ed9",
 					"project_title": "2",
 					"project_description": "2",
+					"version": 1,
 					"epics": null
 				}
 			],
@@ -146,6 +150,7 @@ var getProjectsTestCases = []struct {
 					"id": "090e142d-baa3-4039-9d21-cf5a1af39094",
 					"project_title": "1",
 					"project_description": "1",
+					"version": 1,
 					"epics": null
 				}
 			],
@@ -204,6 +209,7 @@ var getProjectDetailTestCases = []struct {
 			"id": "090e142d-baa3-4039-9d21-cf5a1af39094",
 			"project_title": "1",
 			"project_description": "1",
+			"version": 1,
 			"epics": []
 		}`,
 	},
@@ -262,6 +268,7 @@ var createProjectTestCases = []struct {
 			"id": "090e142d-baa3-4039-9d21-cf5a1af39094",
 			"project_title": "project title",
 			"project_description": "test",
+			"version": 1,
 			"epics": null
 		}`,
 	},
@@ -372,3 +379,112 @@ func TestDeleteProject(t *testing.T) {
 		})
 	}
 }
+
+func TestProjectConditionalRequests(t *testing.T) {
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE projects")
+	dbConn.Exec("insert into projects (id, project_title, project_description, created_at, updated_at)values('090e142d-baa3-4039-9d21-cf5a1af39094','1','1','2022-06-20 22:14:22','2022-06-20 22:14:22');")
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(middleware.NewTracing())
+	projectHandler := NewProjectHandler(dbConn, nil, zapLogger)
+	r.GET("/projects/:id", projectHandler.GetProjectDetail)
+	r.PUT("/projects/:id", projectHandler.UpdateProject)
+	r.DELETE("/projects/:id", projectHandler.DeleteProject)
+
+	request := func(method string, header map[string]string, body map[string]interface{}) *httptest.ResponseRecorder {
+		jsonStr, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, "/projects/090e142d-baa3-4039-9d21-cf5a1af39094", bytes.NewBuffer(jsonStr))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var etag string
+	t.Run("詳細取得でETagを返し、If-None-Matchが一致する場合は304になること", func(t *testing.T) {
+		rec := request(http.MethodGet, nil, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		etag = rec.Header().Get("ETag")
+		assert.True(t, strings.HasPrefix(etag, `"1-`))
+
+		rec = request(http.MethodGet, map[string]string{"If-None-Match": etag}, nil)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("詳細取得のETagをIf-Matchに指定した場合は更新でき、バージョンが進むこと", func(t *testing.T) {
+		rec := request(http.MethodPut, map[string]string{"If-Match": etag},
+			map[string]interface{}{"project_title": "updated", "project_description": "updated", "version": 100})
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+		var project map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &project))
+		assert.Equal(t, float64(2), project["version"])
+	})
+
+	t.Run("If-Matchが古い場合は412になり、更新・削除されないこと", func(t *testing.T) {
+		rec := request(http.MethodPut, map[string]string{"If-Match": `"1"`},
+			map[string]interface{}{"project_title": "stale", "project_description": "stale"})
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		rec = request(http.MethodDelete, map[string]string{"If-Match": `"1"`}, nil)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+		rec = request(http.MethodGet, nil, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var project map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &project))
+		assert.Equal(t, "updated", project["project_title"])
+	})
+
+	t.Run("If-Matchが一致する場合は削除できること", func(t *testing.T) {
+		rec := request(http.MethodDelete, map[string]string{"If-Match": `"2"`}, nil)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+}
+
+func TestPatchProject(t *testing.T) {
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE projects")
+	dbConn.Exec("insert into projects (id, project_title, project_description, created_at, updated_at)values('090e142d-baa3-4039-9d21-cf5a1af39094','1','1','2022-06-20 22:14:22','2022-06-20 22:14:22');")
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(middleware.NewTracing())
+	projectHandler := NewProjectHandler(dbConn, nil, zapLogger)
+	r.PATCH("/projects/:id", projectHandler.PatchProject)
+
+	request := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/projects/090e142d-baa3-4039-9d21-cf5a1af39094", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("指定した項目のみを更新し、nullの項目は空にすること", func(t *testing.T) {
+		rec := request(`{"project_title":"patched","project_description":null}`)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.JSONEq(t, `{
+			"id": "090e142d-baa3-4039-9d21-cf5a1af39094",
+			"project_title": "patched",
+			"project_description": "",
+			"version": 2,
+			"epics": null
+		}`, rec.Body.String())
+	})
+
+	t.Run("指定した項目が不正な場合は400になること", func(t *testing.T) {
+		rec := request(`{"project_title":null}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("IDを変更しようとした場合は400になること", func(t *testing.T) {
+		rec := request(`{"id":"a8c2f5f4-0b8e-4a3c-9f63-0b8e4a3c9f63"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "id cannot be changed")
+	})
+}
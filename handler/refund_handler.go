@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/payment"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+	"github.com/AI1411/golang-admin-api/webhook"
+)
+
+type RefundHandler struct {
+	Db            *gorm.DB
+	logger        *zap.Logger
+	uuidGenerator models.UUIDGenerator
+	provider      payment.Provider
+}
+
+func NewRefundHandler(db *gorm.DB, logger *zap.Logger, uuidGenerator models.UUIDGenerator,
+	provider payment.Provider,
+) *RefundHandler {
+	return &RefundHandler{
+		Db:            db,
+		logger:        logger,
+		uuidGenerator: uuidGenerator,
+		provider:      provider,
+	}
+}
+
+type refundItemRequest struct {
+	OrderDetailID string `json:"order_detail_id" binding:"required,len=36" example:"218c51c0-904e-4743-a2ae-94f0e34a0d6f"`
+	Quantity      int64  `json:"quantity" binding:"required,gte=1" example:"1"`
+}
+
+type refundRequest struct {
+	Reason string              `json:"reason" binding:"omitempty,max=255" example:"商品破損のため"`
+	Items  []refundItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+type refundResponse struct {
+	Refund models.Refund `json:"refund"`
+	Order  models.Order  `json:"order"`
+}
+
+// CreateRefund @title 注文明細の返金
+// @id CreateRefund
+// @tags orders
+// @version バージョン(1.0)
+// @description 指定した注文明細を数量単位で返金する。一部の明細のみが対象の場合は注文ステータスをpartiallyにする。返金は決済プロバイダで行う
+// @Summary 注文明細返金
+// @Produce json
+// @Success 201 {object} refundResponse
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /orders/:id/refunds [POST]
+// @Accept json
+// @Param refundRequest body refundRequest true "refund items"
+// @Param id path string true "注文ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *RefundHandler) CreateRefund(ctx *gin.Context) {
+	h.createRefund(ctx, models.RefundTypeRefund)
+}
+
+// CreateReturn @title 注文明細の返品
+// @id CreateReturn
+// @tags orders
+// @version バージョン(1.0)
+// @description 指定した注文明細を数量単位で返品し、商品在庫を戻す。一部の明細のみが対象の場合は注文ステータスをpartiallyにする。返金は決済プロバイダで行う
+// @Summary 注文明細返品
+// @Produce json
+// @Success 201 {object} refundResponse
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /orders/:id/returns [POST]
+// @Accept json
+// @Param refundRequest body refundRequest true "return items"
+// @Param id path string true "注文ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *RefundHandler) CreateReturn(ctx *gin.Context) {
+	h.createRefund(ctx, models.RefundTypeReturn)
+}
+
+func (h *RefundHandler) createRefund(ctx *gin.Context, refundType models.RefundType) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req refundRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+
+	var order models.Order
+	refund := models.Refund{
+		ID:           h.uuidGenerator.GenerateUUID(),
+		OrderID:      ctx.Param("id"),
+		RefundType:   refundType,
+		RefundStatus: models.RefundStatusPending,
+		Reason:       req.Reason,
+		CreatedAt:    time.Now(),
+	}
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		// 同じ注文への返金が並行しても返金可能額を超えないよう、注文を行ロックする
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Preload("OrderDetails").
+			Where("id = ?", refund.OrderID).First(&order).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return errors.NewNotFoundError("order not found")
+			}
+			return err
+		}
+		details := map[string]*models.OrderDetail{}
+		for i := range order.OrderDetails {
+			details[order.OrderDetails[i].ID] = &order.OrderDetails[i]
+		}
+
+		var splits models.OrderDetailList
+		for _, item := range req.Items {
+			detail, ok := details[item.OrderDetailID]
+			if !ok {
+				return errors.NewNotFoundError("order detail not found: " + item.OrderDetailID)
+			}
+			if detail.OrderDetailStatus.IsClosed() || item.Quantity > detail.Quantity {
+				return errors.NewBadRequestError("order detail cannot be refunded: " + item.OrderDetailID)
+			}
+			target, err := h.closeOrderDetail(tx, detail, item.Quantity, refundType.DetailStatus())
+			if err != nil {
+				return err
+			}
+			if target != detail {
+				splits = append(splits, *target)
+			}
+			if refundType == models.RefundTypeReturn {
+				if err := tx.Table("products").Where("id = ?", target.ProductID).
+					UpdateColumn("quantity", gorm.Expr("quantity + ?", target.Quantity)).Error; err != nil {
+					return err
+				}
+			}
+			refund.RefundItems = append(refund.RefundItems, models.RefundItem{
+				ID:            h.uuidGenerator.GenerateUUID(),
+				RefundID:      refund.ID,
+				OrderDetailID: target.ID,
+				ProductID:     target.ProductID,
+				Quantity:      target.Quantity,
+				Amount:        target.Quantity * target.Price,
+			})
+		}
+
+		order.OrderDetails = append(order.OrderDetails, splits...)
+		refund.Amount = refund.RefundItems.TotalAmount()
+		if err := checkRefundable(tx, order.ID, refund.Amount); err != nil {
+			return err
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return err
+		}
+
+		fromStatus := order.OrderStatus
+		active := order.OrderDetails.Active()
+		order.Quantity = active.TotalQuantity()
+		order.TotalPrice = active.TotalPrice()
+		order.OrderStatus = models.OrderStatusPartially
+		if len(active) == 0 {
+			order.OrderStatus = refundType.OrderStatus()
+		}
+		order.Version++
+		order.UpdatedAt = time.Now()
+		if err := tx.Table("orders").Where("id = ?", order.ID).Updates(map[string]interface{}{
+			"quantity":     order.Quantity,
+			"total_price":  order.TotalPrice,
+			"order_status": order.OrderStatus,
+			"updated_at":   order.UpdatedAt,
+			"version":      gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		if order.OrderStatus == fromStatus {
+			return nil
+		}
+		return webhook.Enqueue(tx, webhook.EventOrderStatusChanged, webhook.OrderStatusChanged{
+			OrderID:    order.ID,
+			FromStatus: fromStatus,
+			ToStatus:   order.OrderStatus,
+		})
+	}); err != nil {
+		if restErr, ok := err.(errors.RestErr); ok {
+			ctx.JSON(restErr.Status(), restErr)
+			return
+		}
+		h.logger.Error("failed to create refund", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create refund", err))
+		return
+	}
+
+	// 決済プロバイダの呼び出しはロールバックできないため、返金を記録してコミットした後に行う
+	if err := h.refundPayments(ctx, tenantDB(ctx, h.Db), &refund); err != nil {
+		h.logger.Error("failed to refund payments", zap.Error(err),
+			zap.String("refund_id", refund.ID),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to refund payments", err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, refundResponse{
+		Refund: refund,
+		Order:  order,
+	})
+}
+
+// checkRefundable 売上確定額から返金済みの額と決済プロバイダでの返金待ちの額を差し引いた額で返金できるか確認する
+func checkRefundable(tx *gorm.DB, orderID string, amount int64) error {
+	var payments models.PaymentList
+	if err := tx.Where("order_id = ?", orderID).Find(&payments).Error; err != nil {
+		return err
+	}
+	var pending int64
+	if err := tx.Table("refunds").Select("COALESCE(SUM(amount), 0)").
+		Where("order_id = ? AND refund_status = ?", orderID, models.RefundStatusPending).
+		Row().Scan(&pending); err != nil {
+		return err
+	}
+	if amount > payments.NetCapturedAmount()-pending {
+		return errors.NewBadRequestError("refund amount exceeds captured amount")
+	}
+	return nil
+}
+
+// refundPayments 売上確定済みの決済から作成順に決済プロバイダで返金し、結果を決済と返金に反映する
+// 決済プロバイダでの返金に失敗した場合は返金をfailedにし、成功した分の決済の結果は残す
+func (h *RefundHandler) refundPayments(ctx context.Context, db *gorm.DB, refund *models.Refund) error {
+	var payments []models.Payment
+	if err := db.Where("order_id = ? AND payment_status IN (?)", refund.OrderID, []models.PaymentStatus{
+		models.PaymentStatusCaptured, models.PaymentStatusPartiallyRefunded,
+	}).Order("created_at").Find(&payments).Error; err != nil {
+		return err
+	}
+
+	amount := refund.Amount
+	var refundErr error
+	for i := range payments {
+		if amount == 0 {
+			break
+		}
+		p := &payments[i]
+		refundAmount := p.CapturedAmount - p.RefundedAmount
+		if refundAmount > amount {
+			refundAmount = amount
+		}
+		if refundAmount <= 0 {
+			continue
+		}
+		result, err := h.provider.Refund(ctx, p.TransactionID, refundAmount)
+		if err != nil {
+			refundErr = err
+			break
+		}
+		applyPaymentResult(p, result)
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(p).Error; err != nil {
+				return err
+			}
+			return syncOrderPaymentStatus(tx, p.OrderID)
+		}); err != nil {
+			// プロバイダでは返金済みのため、決済の状態はプロバイダからのWebhookで同期される
+			refundErr = err
+			break
+		}
+		amount -= refundAmount
+	}
+
+	refund.RefundStatus = models.RefundStatusCompleted
+	if refundErr != nil {
+		refund.RefundStatus = models.RefundStatusFailed
+	}
+	if err := db.Model(refund).Update("refund_status", refund.RefundStatus).Error; err != nil {
+		return err
+	}
+	return refundErr
+}
+
+// closeOrderDetail 明細を指定数量分だけ返金・返品済みにする
+// 一部数量のみの場合は元の明細を減算し、対象数量分の明細を新たに作成して返す
+func (h *RefundHandler) closeOrderDetail(tx *gorm.DB, detail *models.OrderDetail,
+	quantity int64, status models.OrderDetailStatus,
+) (*models.OrderDetail, error) {
+	if quantity == detail.Quantity {
+		detail.OrderDetailStatus = status
+		if err := tx.Model(detail).Update("order_detail_status", status).Error; err != nil {
+			return nil, err
+		}
+		return detail, nil
+	}
+
+	detail.Quantity -= quantity
+	if err := tx.Model(detail).Update("quantity", detail.Quantity).Error; err != nil {
+		return nil, err
+	}
+	split := models.OrderDetail{
+		ID:                h.uuidGenerator.GenerateUUID(),
+		OrderID:           detail.OrderID,
+		ProductID:         detail.ProductID,
+		Quantity:          quantity,
+		OrderDetailStatus: status,
+		Price:             detail.Price,
+	}
+	if err := tx.Create(&split).Error; err != nil {
+		return nil, err
+	}
+	return &split, nil
+}
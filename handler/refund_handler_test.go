@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/middleware"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/payment"
+)
+
+func TestCreateRefund(t *testing.T) {
+	const orderID = "090e142d-baa3-4039-9d21-cf5a1af39094"
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE orders")
+	dbConn.Exec("TRUNCATE TABLE order_details")
+	dbConn.Exec("TRUNCATE TABLE payments")
+	dbConn.Exec("TRUNCATE TABLE refunds")
+	dbConn.Exec("TRUNCATE TABLE refund_items")
+	require.NoError(t, dbConn.Exec("INSERT INTO orders (id, user_id, quantity, total_price, order_status, remarks, created_at, updated_at)VALUES ('090e142d-baa3-4039-9d21-cf5a1af39094', '7dc41179-824e-4b8a-b894-2082ca5eac5b', 3, 300, 'paid', 'test','2022-06-11 10:36:43', '2022-06-11 10:36:43');").Error)
+	require.NoError(t, dbConn.Exec("INSERT INTO order_details(id,order_id,product_id,quantity,price,order_detail_status,created_at,updated_at)VALUES('218c51c0-904e-4743-a2ae-94f0e34a0d6f','090e142d-baa3-4039-9d21-cf5a1af39094','66925ce2-47ee-4dfb-b974-f0ba3cd5c178',1,100,'new','2022-06-01 16:10:15','2022-06-01 16:10:15'),('23c66d26-4432-4f7f-9a0d-2642731a28cc','090e142d-baa3-4039-9d21-cf5a1af39094','1583cc19-bbfa-405a-affb-9f01953f5b6d',2,100,'new','2022-06-11 14:50:51','2022-06-11 14:50:51');").Error)
+
+	provider := payment.NewFakeProvider("secret")
+	authorized, err := provider.Authorize(context.Background(), payment.AuthorizeRequest{OrderID: orderID, Amount: 300, Token: "tok_visa"})
+	require.NoError(t, err)
+	_, err = provider.Capture(context.Background(), authorized.TransactionID, 300)
+	require.NoError(t, err)
+	require.NoError(t, dbConn.Exec("INSERT INTO payments (id, order_id, provider, transaction_id, amount, captured_amount, refunded_amount, payment_status, failure_reason)VALUES ('5c3325c1-d539-42d6-b405-2af2f6b99ed9', ?, 'fake', ?, 300, 300, 0, 'captured', '');",
+		orderID, authorized.TransactionID).Error)
+
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(middleware.NewTracing())
+	refundHandler := NewRefundHandler(dbConn, zapLogger, &models.RandomUUIDGenerator{}, provider)
+	r.POST("/orders/:id/refunds", refundHandler.CreateRefund)
+
+	refund := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders/"+orderID+"/refunds", strings.NewReader(body)))
+		return rec
+	}
+	findPayment := func(t *testing.T) models.Payment {
+		t.Helper()
+		var p models.Payment
+		require.NoError(t, dbConn.Where("order_id = ?", orderID).First(&p).Error)
+		return p
+	}
+
+	t.Run("一部の明細を決済プロバイダで返金し、注文ステータスをpartiallyにすること", func(t *testing.T) {
+		rec := refund(`{"items":[{"order_detail_id":"23c66d26-4432-4f7f-9a0d-2642731a28cc","quantity":1}]}`)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var res refundResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, int64(100), res.Refund.Amount)
+		assert.Equal(t, models.RefundStatusCompleted, res.Refund.RefundStatus)
+		assert.Equal(t, models.OrderStatusPartially, res.Order.OrderStatus)
+		assert.Equal(t, int64(2), res.Order.Quantity)
+		assert.Equal(t, int64(200), res.Order.TotalPrice)
+
+		var order models.Order
+		require.NoError(t, dbConn.Where("id = ?", orderID).First(&order).Error)
+		assert.Equal(t, models.OrderStatusPartially, order.OrderStatus)
+		assert.Equal(t, int64(200), order.TotalPrice)
+
+		p := findPayment(t)
+		assert.Equal(t, models.PaymentStatusPartiallyRefunded, p.PaymentStatus)
+		assert.Equal(t, int64(100), p.RefundedAmount)
+	})
+
+	t.Run("全ての明細を返金すると注文はrefundedになること", func(t *testing.T) {
+		rec := refund(`{"items":[{"order_detail_id":"218c51c0-904e-4743-a2ae-94f0e34a0d6f","quantity":1},{"order_detail_id":"23c66d26-4432-4f7f-9a0d-2642731a28cc","quantity":1}]}`)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var res refundResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, models.OrderStatusRefunded, res.Order.OrderStatus)
+
+		p := findPayment(t)
+		assert.Equal(t, models.PaymentStatusRefunded, p.PaymentStatus)
+		assert.Equal(t, int64(300), p.RefundedAmount)
+	})
+}
+
+func TestCreateRefundWithoutPayment(t *testing.T) {
+	const orderID = "5c3325c1-d539-42d6-b405-2af2f6b99ed9"
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE orders")
+	dbConn.Exec("TRUNCATE TABLE order_details")
+	dbConn.Exec("TRUNCATE TABLE payments")
+	dbConn.Exec("TRUNCATE TABLE refunds")
+	require.NoError(t, dbConn.Exec("INSERT INTO orders (id, user_id, quantity, total_price, order_status, remarks, created_at, updated_at)VALUES ('5c3325c1-d539-42d6-b405-2af2f6b99ed9', '7dc41179-824e-4b8a-b894-2082ca5eac5b', 1, 100, 'new', 'test','2022-06-11 10:36:43', '2022-06-11 10:36:43');").Error)
+	require.NoError(t, dbConn.Exec("INSERT INTO order_details(id,order_id,product_id,quantity,price,order_detail_status,created_at,updated_at)VALUES('218c51c0-904e-4743-a2ae-94f0e34a0d6f','5c3325c1-d539-42d6-b405-2af2f6b99ed9','66925ce2-47ee-4dfb-b974-f0ba3cd5c178',1,100,'new','2022-06-01 16:10:15','2022-06-01 16:10:15');").Error)
+
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(middleware.NewTracing())
+	refundHandler := NewRefundHandler(dbConn, zapLogger, &models.RandomUUIDGenerator{}, payment.NewFakeProvider("secret"))
+	r.POST("/orders/:id/refunds", refundHandler.CreateRefund)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders/"+orderID+"/refunds",
+		strings.NewReader(`{"items":[{"order_detail_id":"218c51c0-904e-4743-a2ae-94f0e34a0d6f","quantity":1}]}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var order models.Order
+	require.NoError(t, dbConn.Preload("OrderDetails").Where("id = ?", orderID).First(&order).Error)
+	assert.Equal(t, models.OrderStatusNew, order.OrderStatus)
+	assert.Equal(t, models.OrderDetailStatusNew, order.OrderDetails[0].OrderDetailStatus)
+}
+
+func TestCreateRefundProviderFailure(t *testing.T) {
+	const orderID = "5c3325c1-d539-42d6-b405-2af2f6b99ed9"
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE orders")
+	dbConn.Exec("TRUNCATE TABLE order_details")
+	dbConn.Exec("TRUNCATE TABLE payments")
+	dbConn.Exec("TRUNCATE TABLE refunds")
+	dbConn.Exec("TRUNCATE TABLE refund_items")
+	require.NoError(t, dbConn.Exec("INSERT INTO orders (id, user_id, quantity, total_price, order_status, remarks, created_at, updated_at)VALUES ('5c3325c1-d539-42d6-b405-2af2f6b99ed9', '7dc41179-824e-4b8a-b894-2082ca5eac5b', 1, 100, 'paid', 'test','2022-06-11 10:36:43', '2022-06-11 10:36:43');").Error)
+	require.NoError(t, dbConn.Exec("INSERT INTO order_details(id,order_id,product_id,quantity,price,order_detail_status,created_at,updated_at)VALUES('218c51c0-904e-4743-a2ae-94f0e34a0d6f','5c3325c1-d539-42d6-b405-2af2f6b99ed9','66925ce2-47ee-4dfb-b974-f0ba3cd5c178',1,100,'new','2022-06-01 16:10:15','2022-06-01 16:10:15');").Error)
+	// プロバイダに存在しない取引のため、返金はプロバイダで失敗する
+	require.NoError(t, dbConn.Exec("INSERT INTO payments (id, order_id, provider, transaction_id, amount, captured_amount, refunded_amount, payment_status, failure_reason)VALUES ('090e142d-baa3-4039-9d21-cf5a1af39094', ?, 'fake', 'fake_missing', 100, 100, 0, 'captured', '');",
+		orderID).Error)
+
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(middleware.NewTracing())
+	refundHandler := NewRefundHandler(dbConn, zapLogger, &models.RandomUUIDGenerator{}, payment.NewFakeProvider("secret"))
+	r.POST("/orders/:id/refunds", refundHandler.CreateRefund)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders/"+orderID+"/refunds",
+		strings.NewReader(`{"items":[{"order_detail_id":"218c51c0-904e-4743-a2ae-94f0e34a0d6f","quantity":1}]}`)))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	var refund models.Refund
+	require.NoError(t, dbConn.Where("order_id = ?", orderID).First(&refund).Error)
+	assert.Equal(t, models.RefundStatusFailed, refund.RefundStatus)
+	var p models.Payment
+	require.NoError(t, dbConn.Where("order_id = ?", orderID).First(&p).Error)
+	assert.Equal(t, int64(0), p.RefundedAmount)
+}
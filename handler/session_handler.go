@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/session"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+type SessionHandler struct {
+	logger   *zap.Logger
+	sessions *session.Manager
+}
+
+func NewSessionHandler(logger *zap.Logger, sessions *session.Manager) *SessionHandler {
+	return &SessionHandler{
+		logger:   logger,
+		sessions: sessions,
+	}
+}
+
+type sessionResponse struct {
+	session.Session
+	// Current このリクエストの認証に使ったセッションか
+	Current bool `json:"current"`
+}
+
+type sessionsResponse struct {
+	Total    int               `json:"total"`
+	Sessions []sessionResponse `json:"sessions"`
+}
+
+// GetSessions @title セッション一覧
+// @id GetSessions
+// @tags sessions
+// @version バージョン(1.0)
+// @description ログイン中の自分のセッションを新しい順に取得する。端末、IPアドレス、User-Agentを含む
+// @Summary セッション一覧取得
+// @Produce json
+// @Success 200 {object} sessionsResponse
+// @Failure 500 {object} errorResponse
+// @Router /sessions [GET]
+func (h *SessionHandler) GetSessions(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	sessions, err := h.sessions.List(ctx, appcontext.GetUserID(ctx))
+	if err != nil {
+		h.logger.Error("failed to get sessions", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get sessions", err))
+		return
+	}
+	current := appcontext.GetSessionID(ctx)
+	res := sessionsResponse{
+		Total:    len(sessions),
+		Sessions: make([]sessionResponse, len(sessions)),
+	}
+	for i, sess := range sessions {
+		res.Sessions[i] = sessionResponse{
+			Session: sess,
+			Current: current != "" && sess.ID == current,
+		}
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// RevokeSession @title セッション削除
+// @id RevokeSession
+// @tags sessions
+// @version バージョン(1.0)
+// @description 自分のセッションを削除する。削除したセッションのトークンでは認証できない
+// @Summary セッション削除
+// @Produce json
+// @Success 200
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /sessions/:id [DELETE]
+// @Param id path string true "セッションID"
+func (h *SessionHandler) RevokeSession(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	revoked, err := h.sessions.Revoke(ctx, appcontext.GetUserID(ctx), ctx.Param("id"))
+	if err != nil {
+		h.logger.Error("failed to revoke session", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to revoke session", err))
+		return
+	}
+	if !revoked {
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("session not found"))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "セッションを削除しました",
+	})
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/middleware"
+	"github.com/AI1411/golang-admin-api/session"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	util "github.com/AI1411/golang-admin-api/util/jwt"
+)
+
+func TestSessionHandler(t *testing.T) {
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	manager := session.NewManager(session.NewMemoryStore(), session.DefaultCookieConfig, util.TokenLifetime)
+	sessionHandler := NewSessionHandler(zapLogger, manager)
+
+	start := func(t *testing.T, userID string) *session.Session {
+		t.Helper()
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+		sess, err := manager.Start(ctx, userID)
+		require.NoError(t, err)
+		return sess
+	}
+	current := start(t, userAIDForTest)
+	other := start(t, userAIDForTest)
+	otherUsers := start(t, "other-user")
+
+	r := gin.New()
+	r.Use(middleware.NewTracing())
+	r.Use(func(ctx *gin.Context) {
+		appcontext.SetUserIDIntoContext(ctx, userAIDForTest)
+		appcontext.SetSessionIDIntoContext(ctx, current.ID)
+	})
+	r.GET("/sessions", sessionHandler.GetSessions)
+	r.DELETE("/sessions/:id", sessionHandler.RevokeSession)
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	t.Run("自分のセッションの一覧を取得できること", func(t *testing.T) {
+		rec := request(http.MethodGet, "/sessions")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var res sessionsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		require.Equal(t, 2, res.Total)
+		currents := map[string]bool{}
+		for _, sess := range res.Sessions {
+			currents[sess.ID] = sess.Current
+		}
+		assert.Equal(t, map[string]bool{current.ID: true, other.ID: false}, currents)
+	})
+
+	t.Run("他のユーザーのセッションは削除できないこと", func(t *testing.T) {
+		rec := request(http.MethodDelete, "/sessions/"+otherUsers.ID)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("自分のセッションを削除できること", func(t *testing.T) {
+		rec := request(http.MethodDelete, "/sessions/"+other.ID)
+		require.Equal(t, http.StatusOK, rec.Code)
+		rec = request(http.MethodGet, "/sessions")
+		var res sessionsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		require.Equal(t, 1, res.Total)
+		assert.Equal(t, current.ID, res.Sessions[0].ID)
+	})
+}
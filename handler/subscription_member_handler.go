@@ -31,7 +31,8 @@ func NewSubscriptionMemberHandler(db *gorm.DB, logger *zap.Logger, uuidGenerator
 
 type searchSubscriptionMemberParams struct {
 	UserID              string `form:"user_id" binding:"omitempty,uuid4"`
-	MemberStatus        string `form:"member_status" binding:"omitempty,oneof=active inactive"`
+	PlanID              string `form:"plan_id" binding:"omitempty,uuid4"`
+	MemberStatus        string `form:"member_status" binding:"omitempty,oneof=premium basic inactive stopped"`
 	MemberStartDateFrom string `form:"member_start_date_from" binding:"omitempty,datetime"`
 	MemberEndDateTo     string `form:"member_end_date_to" binding:"omitempty,datetime"`
 	Offset              string `form:"offset,default=0" binding:"omitempty,numeric"`
@@ -40,17 +41,34 @@ type searchSubscriptionMemberParams struct {
 
 type subscriptionMemberRequest struct {
 	UserID          string `json:"user_id" binding:"omitempty,uuid4" example:"015cd44f-5f66-4303-a269-68e75ec6fcc7"`
+	PlanID          string `json:"plan_id" binding:"omitempty,uuid4" example:"8f2d7c4e-6e0a-4d8e-9d1e-3c1b0c1f6a21"`
 	MemberStatus    string `json:"member_status" binding:"omitempty,oneof=premium basic" example:"premium"`
 	MemberStartDate string `json:"member_start_date" binding:"omitempty,datetime" format:"YYYY-MM-DDThh:mm:ss±hh:mm" example:"2020-01-01T00:00:00+09:00"`
 	MemberEndDate   string `json:"member_end_date" binding:"omitempty,datetime" format:"YYYY-MM-DDThh:mm:ss±hh:mm" example:"2020-01-01T00:00:00+09:00"`
 }
 
 type subscriptionMemberResponseItem struct {
-	ID              string    `json:"id" example:"218c51c0-904e-4743-a2ae-94f0e34a0d6f"`
-	UserID          string    `json:"user_id" example:"218c51c0-904e-4743-a2ae-94f0e34a0d6f"`
-	MemberStatus    string    `json:"member_status" example:"basic"`
-	MemberStartDate time.Time `json:"member_start_date" example:"2020-01-01T00:00:00+09:00"`
-	MemberEndDate   time.Time `json:"member_end_date" example:"2020-01-01T00:00:00+09:00"`
+	ID                string    `json:"id" example:"218c51c0-904e-4743-a2ae-94f0e34a0d6f"`
+	UserID            string    `json:"user_id" example:"218c51c0-904e-4743-a2ae-94f0e34a0d6f"`
+	PlanID            string    `json:"plan_id" example:"8f2d7c4e-6e0a-4d8e-9d1e-3c1b0c1f6a21"`
+	MemberStatus      string    `json:"member_status" example:"basic"`
+	MemberStartDate   time.Time `json:"member_start_date" example:"2020-01-01T00:00:00+09:00"`
+	MemberEndDate     time.Time `json:"member_end_date" example:"2020-01-01T00:00:00+09:00"`
+	CancelAtPeriodEnd bool      `json:"cancel_at_period_end" example:"false"`
+}
+
+type changeSubscriptionPlanRequest struct {
+	PlanID string `json:"plan_id" binding:"required,uuid4" example:"8f2d7c4e-6e0a-4d8e-9d1e-3c1b0c1f6a21"`
+}
+
+type changeSubscriptionPlanResponse struct {
+	SubscriptionMember subscriptionMemberResponseItem `json:"subscription_member"`
+	ProratedAmount     int64                          `json:"prorated_amount" example:"490"`
+}
+
+type subscriptionMemberHistoriesResponse struct {
+	Total     int                                `json:"total"`
+	Histories []models.SubscriptionMemberHistory `json:"histories"`
 }
 
 type subscriptionMembersResponse struct {
@@ -88,7 +106,7 @@ func (h *SubscriptionMemberHandler) GetSubscriptionMember(ctx *gin.Context) {
 	}
 
 	var subscriptionMembers []models.SubscriptionMember
-	query := createSubscriptionMemberQueryBuilder(params, h)
+	query := createSubscriptionMemberQueryBuilder(ctx, params, h)
 	query.Find(&subscriptionMembers)
 
 	res := subscriptionMembersResponse{
@@ -120,7 +138,7 @@ func (h *SubscriptionMemberHandler) GetSubscriptionMember(ctx *gin.Context) {
 func (h *SubscriptionMemberHandler) GetSubscriptionMemberDetail(ctx *gin.Context) {
 	var subscriptionMember models.SubscriptionMember
 	id := ctx.Param("id")
-	if err := h.Db.Where("id = ?", id).Find(&subscriptionMember).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).Find(&subscriptionMember).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("subscription_member not found"))
@@ -172,7 +190,31 @@ func (h *SubscriptionMemberHandler) CreateSubscriptionMember(ctx *gin.Context) {
 	subscriptionMember.MemberStartDate = time.Now()
 	subscriptionMember.MemberEndDate = time.Now().Add(time.Hour * 24 * 30)
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Create(&subscriptionMember).Error; err != nil {
+	if req.PlanID != "" {
+		var plan models.SubscriptionPlan
+		if err := tenantDB(ctx, h.Db).Where("id = ?", req.PlanID).First(&plan).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("subscription plan not found"))
+				return
+			}
+			h.logger.Error("failed to get subscription plan", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError,
+				errors.NewInternalServerError("failed to get subscription plan", err))
+			return
+		}
+		subscriptionMember.PlanID = plan.ID
+		subscriptionMember.MemberStatus = plan.MemberStatus
+		subscriptionMember.MemberEndDate = subscriptionMember.MemberStartDate.Add(plan.Period())
+	}
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&subscriptionMember).Error; err != nil {
+			return err
+		}
+		history := models.NewSubscriptionMemberHistory(&subscriptionMember, models.Inactive, "",
+			models.HistoryReasonCreated)
+		return tx.Create(history).Error
+	}); err != nil {
 		h.logger.Error("failed to create subscriptionMember", zap.Error(err),
 			zap.String("trace_id", traceID))
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create subscriptionMember", err))
@@ -181,30 +223,31 @@ func (h *SubscriptionMemberHandler) CreateSubscriptionMember(ctx *gin.Context) {
 	ctx.JSON(http.StatusCreated, subscriptionMember)
 }
 
-// UpdateSubscriptionMember @title product編集
+// UpdateSubscriptionMember @title subscriptionMember編集
 // @id UpdateSubscriptionMember
-// @tags products
+// @tags subscription_members
 // @version バージョン(1.0)
-// @description productを編集する
-// @Summary product編集
+// @description subscriptionMemberを編集する。ステータスが変わった場合は履歴を残す。プランはプラン変更APIで変更する
+// @Summary subscriptionMember編集
 // @Produce json
-// @Success 202 {object} productResponseItem
+// @Success 202 {object} subscriptionMemberResponseItem
 // @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
 // @Failure 500 {object} errorResponse
-// @Router /products/:id [PUT]
+// @Router /subscriptionMembers/:id [PUT]
 // @Accept json
-// @Param productRequest body productRequest true "update product"
+// @Param subscriptionMemberRequest body subscriptionMemberRequest true "update subscriptionMember"
 // @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
 func (h *SubscriptionMemberHandler) UpdateSubscriptionMember(ctx *gin.Context) {
-	product := models.SubscriptionMember{}
+	subscriptionMember := models.SubscriptionMember{}
 	id := ctx.Param("id")
 	traceID := appcontext.GetTraceID(ctx)
-	if err := h.Db.Where("id = ?", id).First(&product).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&subscriptionMember).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
-			h.logger.Error("failed to update product", zap.Error(err),
+			h.logger.Error("failed to update subscriptionMember", zap.Error(err),
 				zap.String("trace_id", traceID))
-			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("product not found"))
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("subscription_member not found"))
 		case gorm.ErrInvalidSQL:
 			h.logger.Error("invalid sql", zap.Error(err),
 				zap.String("trace_id", traceID))
@@ -212,27 +255,269 @@ func (h *SubscriptionMemberHandler) UpdateSubscriptionMember(ctx *gin.Context) {
 		}
 		return
 	}
-	if err := ctx.ShouldBindJSON(&product); err != nil {
+	var req subscriptionMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+
+	// プランの変更は日割りの差額計算が必要なため、プラン変更APIでのみ受け付ける
+	if req.PlanID != "" && req.PlanID != subscriptionMember.PlanID {
+		ctx.JSON(http.StatusBadRequest,
+			errors.NewBadRequestError("plan_id cannot be changed, use /subscriptionMembers/:id/changePlan"))
+		return
+	}
+
+	var p parser
+	fromStatus := subscriptionMember.MemberStatus
+	fromPlanID := subscriptionMember.PlanID
+	if req.MemberStatus != "" {
+		subscriptionMember.MemberStatus = models.MemberStatus(req.MemberStatus)
+	}
+	if req.MemberStartDate != "" {
+		subscriptionMember.MemberStartDate = p.parseTime(req.MemberStartDate)
+	}
+	if req.MemberEndDate != "" {
+		subscriptionMember.MemberEndDate = p.parseTime(req.MemberEndDate)
+	}
+	if p.err != nil {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError(p.err.Error()))
+		return
+	}
+
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&subscriptionMember).Error; err != nil {
+			return err
+		}
+		if fromStatus == subscriptionMember.MemberStatus {
+			return nil
+		}
+		history := models.NewSubscriptionMemberHistory(&subscriptionMember, fromStatus, fromPlanID,
+			models.HistoryReasonUpdated)
+		return tx.Create(history).Error
+	}); err != nil {
+		h.logger.Error("failed to update subscriptionMember", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to update subscriptionMember", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, subscriptionMember)
+}
+
+// ChangeSubscriptionPlan @title subscriptionMemberプラン変更
+// @id ChangeSubscriptionPlan
+// @tags subscription_members
+// @version バージョン(1.0)
+// @description プランを即時にアップグレード/ダウングレードし、残り期間分の日割り差額を返す。
+// @description 差額が正なら追加請求、負なら返金(クレジット)となり、差額がなければ履歴の理由はplan_changedになる
+// @Summary subscriptionMemberプラン変更
+// @Produce json
+// @Success 202 {object} changeSubscriptionPlanResponse
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /subscriptionMembers/:id/changePlan [POST]
+// @Accept json
+// @Param changeSubscriptionPlanRequest body changeSubscriptionPlanRequest true "change plan"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *SubscriptionMemberHandler) ChangeSubscriptionPlan(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req changeSubscriptionPlanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
 		res := createValidateErrorResponse(err)
 		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
-	if err := h.Db.Save(&product).Error; err != nil {
-		h.logger.Error("failed to update product", zap.Error(err),
+
+	var subscriptionMember models.SubscriptionMember
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&subscriptionMember).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("subscription_member not found"))
+			return
+		}
+		h.logger.Error("failed to get subscriptionMember", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get subscriptionMember", err))
+		return
+	}
+	if !subscriptionMember.IsActive() {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("subscription_member is not active"))
+		return
+	}
+	if subscriptionMember.PlanID == req.PlanID {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("plan is not changed"))
+		return
+	}
+
+	var next models.SubscriptionPlan
+	if err := tenantDB(ctx, h.Db).Where("id = ?", req.PlanID).First(&next).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("subscription plan not found"))
+			return
+		}
+		h.logger.Error("failed to get subscription plan", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get subscription plan", err))
+		return
+	}
+	var current *models.SubscriptionPlan
+	if subscriptionMember.PlanID != "" {
+		var plan models.SubscriptionPlan
+		if err := tenantDB(ctx, h.Db).Where("id = ?", subscriptionMember.PlanID).First(&plan).Error; err != nil &&
+			!gorm.IsRecordNotFoundError(err) {
+			h.logger.Error("failed to get subscription plan", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError,
+				errors.NewInternalServerError("failed to get subscription plan", err))
+			return
+		}
+		if plan.ID != "" {
+			current = &plan
+		}
+	}
+
+	fromStatus := subscriptionMember.MemberStatus
+	fromPlanID := subscriptionMember.PlanID
+	amount := subscriptionMember.ChangePlan(current, &next, time.Now())
+	reason := models.HistoryReasonPlanChanged
+	switch {
+	case amount > 0:
+		reason = models.HistoryReasonUpgraded
+	case amount < 0:
+		reason = models.HistoryReasonDowngraded
+	}
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&subscriptionMember).Error; err != nil {
+			return err
+		}
+		history := models.NewSubscriptionMemberHistory(&subscriptionMember, fromStatus, fromPlanID, reason)
+		return tx.Create(history).Error
+	}); err != nil {
+		h.logger.Error("failed to change subscription plan", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to change subscription plan", err))
+		return
+	}
+
+	res := changeSubscriptionPlanResponse{ProratedAmount: amount}
+	if err := copier.Copy(&res.SubscriptionMember, &subscriptionMember); err != nil {
+		h.logger.Error("failed to copy subscriptionMember", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to copy subscriptionMember", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, res)
+}
+
+// CancelSubscriptionMember @title subscriptionMember解約
+// @id CancelSubscriptionMember
+// @tags subscription_members
+// @version バージョン(1.0)
+// @description 現在の契約期間の終了時に解約されるよう予約する。期間終了までは現在のステータスのまま利用できる
+// @Summary subscriptionMember解約予約
+// @Produce json
+// @Success 202 {object} subscriptionMemberResponseItem
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /subscriptionMembers/:id/cancel [POST]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *SubscriptionMemberHandler) CancelSubscriptionMember(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var subscriptionMember models.SubscriptionMember
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&subscriptionMember).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("subscription_member not found"))
+			return
+		}
+		h.logger.Error("failed to get subscriptionMember", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get subscriptionMember", err))
+		return
+	}
+	if !subscriptionMember.IsActive() {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("subscription_member is not active"))
+		return
+	}
+	if subscriptionMember.CancelAtPeriodEnd {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("subscription_member is already canceled"))
+		return
+	}
+
+	subscriptionMember.CancelAtPeriodEnd = true
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&subscriptionMember).Error; err != nil {
+			return err
+		}
+		history := models.NewSubscriptionMemberHistory(&subscriptionMember, subscriptionMember.MemberStatus,
+			subscriptionMember.PlanID, models.HistoryReasonCancelReserve)
+		return tx.Create(history).Error
+	}); err != nil {
+		h.logger.Error("failed to cancel subscriptionMember", zap.Error(err),
 			zap.String("trace_id", traceID))
-		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update product", err))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to cancel subscriptionMember", err))
 		return
 	}
-	ctx.JSON(http.StatusAccepted, product)
+
+	var res subscriptionMemberResponseItem
+	if err := copier.Copy(&res, &subscriptionMember); err != nil {
+		h.logger.Error("failed to copy subscriptionMember", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to copy subscriptionMember", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, res)
+}
+
+// GetSubscriptionMemberHistories @title subscriptionMemberステータス変更履歴
+// @id GetSubscriptionMemberHistories
+// @tags subscription_members
+// @version バージョン(1.0)
+// @description subscriptionMemberのステータス変更履歴を新しい順に返す
+// @Summary subscriptionMember履歴取得
+// @Produce json
+// @Success 200 {object} subscriptionMemberHistoriesResponse
+// @Failure 500 {object} errorResponse
+// @Router /subscriptionMembers/:id/histories [GET]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *SubscriptionMemberHandler) GetSubscriptionMemberHistories(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var histories []models.SubscriptionMemberHistory
+	if err := tenantDB(ctx, h.Db).Where("subscription_member_id = ?", ctx.Param("id")).
+		Order("created_at desc, id desc").
+		Find(&histories).Error; err != nil {
+		h.logger.Error("failed to get subscriptionMember histories", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get subscriptionMember histories", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, subscriptionMemberHistoriesResponse{
+		Total:     len(histories),
+		Histories: histories,
+	})
 }
 
-func createSubscriptionMemberQueryBuilder(param searchSubscriptionMemberParams, h *SubscriptionMemberHandler) *gorm.DB {
+func createSubscriptionMemberQueryBuilder(ctx *gin.Context, param searchSubscriptionMemberParams, h *SubscriptionMemberHandler) *gorm.DB {
 	var subscriptionMember []models.SubscriptionMember
-	query := h.Db.Find(&subscriptionMember)
+	query := tenantDB(ctx, h.Db).Find(&subscriptionMember)
 	if param.UserID != "" {
 		query = query.Where("user_id = ?", param.UserID)
 	}
+	if param.PlanID != "" {
+		query = query.Where("plan_id = ?", param.PlanID)
+	}
 	if param.MemberStatus != "" {
 		query = query.Where("member_status = ?", param.MemberStatus)
 	}
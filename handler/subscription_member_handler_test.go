@@ -1 +1,75 @@
 package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/middleware"
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func TestChangeSubscriptionPlan(t *testing.T) {
+	const (
+		memberID        = "218c51c0-904e-4743-a2ae-94f0e34a0d6f"
+		basicPlanID     = "8f2d7c4e-6e0a-4d8e-9d1e-3c1b0c1f6a21"
+		lateralPlanID   = "8f2d7c4e-6e0a-4d8e-9d1e-3c1b0c1f6a22"
+		expensivePlanID = "8f2d7c4e-6e0a-4d8e-9d1e-3c1b0c1f6a23"
+	)
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE subscription_members")
+	dbConn.Exec("TRUNCATE TABLE subscription_plans")
+	dbConn.Exec("TRUNCATE TABLE subscription_member_histories")
+	require.NoError(t, dbConn.Exec("INSERT INTO subscription_plans (id, plan_name, member_status, price, period_days)VALUES (?, 'basic', 'basic', 1000, 30), (?, 'basic2', 'basic', 1000, 30), (?, 'premium', 'premium', 3000, 30);",
+		basicPlanID, lateralPlanID, expensivePlanID).Error)
+	require.NoError(t, dbConn.Exec("INSERT INTO subscription_members (id, user_id, plan_id, member_status, member_start_date, member_end_date)VALUES (?, '7dc41179-824e-4b8a-b894-2082ca5eac5b', ?, 'basic', DATE_SUB(NOW(), INTERVAL 10 DAY), DATE_ADD(NOW(), INTERVAL 20 DAY));",
+		memberID, basicPlanID).Error)
+
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(middleware.NewTracing())
+	subscriptionMemberHandler := NewSubscriptionMemberHandler(dbConn, zapLogger, &models.RandomUUIDGenerator{})
+	r.PUT("/subscriptionMembers/:id", subscriptionMemberHandler.UpdateSubscriptionMember)
+	r.POST("/subscriptionMembers/:id/changePlan", subscriptionMemberHandler.ChangeSubscriptionPlan)
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+	lastReason := func(t *testing.T) string {
+		t.Helper()
+		var history models.SubscriptionMemberHistory
+		require.NoError(t, dbConn.Where("subscription_member_id = ?", memberID).Order("id DESC").First(&history).Error)
+		return history.Reason
+	}
+
+	t.Run("編集APIでプランを変更しようとした場合400エラーになること", func(t *testing.T) {
+		rec := request(http.MethodPut, "/subscriptionMembers/"+memberID, `{"plan_id":"`+expensivePlanID+`"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message": "plan_id cannot be changed, use /subscriptionMembers/:id/changePlan","status": 400,"error": "bad_request","causes": null}`, rec.Body.String())
+
+		var member models.SubscriptionMember
+		require.NoError(t, dbConn.Where("id = ?", memberID).First(&member).Error)
+		assert.Equal(t, basicPlanID, member.PlanID)
+	})
+
+	t.Run("差額のないプランへの変更はアップグレードとして記録しないこと", func(t *testing.T) {
+		rec := request(http.MethodPost, "/subscriptionMembers/"+memberID+"/changePlan", `{"plan_id":"`+lateralPlanID+`"}`)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, models.HistoryReasonPlanChanged, lastReason(t))
+	})
+
+	t.Run("料金の高いプランへの変更はアップグレードとして記録すること", func(t *testing.T) {
+		rec := request(http.MethodPost, "/subscriptionMembers/"+memberID+"/changePlan", `{"plan_id":"`+expensivePlanID+`"}`)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, models.HistoryReasonUpgraded, lastReason(t))
+	})
+}
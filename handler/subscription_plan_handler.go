@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+type SubscriptionPlanHandler struct {
+	Db            *gorm.DB
+	logger        *zap.Logger
+	uuidGenerator models.UUIDGenerator
+}
+
+func NewSubscriptionPlanHandler(db *gorm.DB, logger *zap.Logger, uuidGenerator models.UUIDGenerator,
+) *SubscriptionPlanHandler {
+	return &SubscriptionPlanHandler{
+		Db:            db,
+		logger:        logger,
+		uuidGenerator: uuidGenerator,
+	}
+}
+
+type subscriptionPlanRequest struct {
+	PlanName     string `json:"plan_name" binding:"required,max=64" example:"プレミアム月額"`
+	MemberStatus string `json:"member_status" binding:"required,oneof=premium basic" example:"premium"`
+	Price        uint   `json:"price" binding:"gte=0" example:"980"`
+	PeriodDays   uint   `json:"period_days" binding:"required,min=1" example:"30"`
+}
+
+type subscriptionPlansResponse struct {
+	Total             int                       `json:"total"`
+	SubscriptionPlans []models.SubscriptionPlan `json:"subscription_plans"`
+}
+
+// GetSubscriptionPlans @title 一覧取得
+// @id GetSubscriptionPlans
+// @tags subscription_plans
+// @version バージョン(1.0)
+// @description subscription_plan一覧情報を取得する
+// @Summary subscription_plan一覧取得
+// @Produce json
+// @Success 200 {object} subscriptionPlansResponse
+// @Failure 500 {object} errorResponse
+// @Router /subscriptionPlans [GET]
+func (h *SubscriptionPlanHandler) GetSubscriptionPlans(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var plans []models.SubscriptionPlan
+	if err := tenantDB(ctx, h.Db).Order("price").Find(&plans).Error; err != nil {
+		h.logger.Error("failed to get subscription plans", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get subscription plans", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, subscriptionPlansResponse{
+		Total:             len(plans),
+		SubscriptionPlans: plans,
+	})
+}
+
+// CreateSubscriptionPlan @title subscription_plan作成
+// @id CreateSubscriptionPlan
+// @tags subscription_plans
+// @version バージョン(1.0)
+// @description subscription_planを作成する
+// @Summary subscription_plan作成
+// @Produce json
+// @Success 201 {object} models.SubscriptionPlan
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /subscriptionPlans [POST]
+// @Accept json
+// @Param subscriptionPlanRequest body subscriptionPlanRequest true "create subscription plan"
+func (h *SubscriptionPlanHandler) CreateSubscriptionPlan(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req subscriptionPlanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+
+	plan := models.SubscriptionPlan{
+		ID:           h.uuidGenerator.GenerateUUID(),
+		PlanName:     req.PlanName,
+		MemberStatus: models.MemberStatus(req.MemberStatus),
+		Price:        req.Price,
+		PeriodDays:   req.PeriodDays,
+	}
+	if err := tenantDB(ctx, h.Db).Create(&plan).Error; err != nil {
+		h.logger.Error("failed to create subscription plan", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to create subscription plan", err))
+		return
+	}
+	ctx.JSON(http.StatusCreated, plan)
+}
+
+// UpdateSubscriptionPlan @title subscription_plan編集
+// @id UpdateSubscriptionPlan
+// @tags subscription_plans
+// @version バージョン(1.0)
+// @description subscription_planを編集する。既存会員には次回更新時から反映される
+// @Summary subscription_plan編集
+// @Produce json
+// @Success 202 {object} models.SubscriptionPlan
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /subscriptionPlans/:id [PUT]
+// @Accept json
+// @Param subscriptionPlanRequest body subscriptionPlanRequest true "update subscription plan"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *SubscriptionPlanHandler) UpdateSubscriptionPlan(ctx *gin.Context) {
+	var plan models.SubscriptionPlan
+	id := ctx.Param("id")
+	traceID := appcontext.GetTraceID(ctx)
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&plan).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			h.logger.Error("failed to update subscription plan", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("subscription plan not found"))
+		default:
+			h.logger.Error("failed to update subscription plan", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.JSON(http.StatusInternalServerError,
+				errors.NewInternalServerError("failed to update subscription plan", err))
+		}
+		return
+	}
+	var req subscriptionPlanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+
+	plan.PlanName = req.PlanName
+	plan.MemberStatus = models.MemberStatus(req.MemberStatus)
+	plan.Price = req.Price
+	plan.PeriodDays = req.PeriodDays
+	if err := tenantDB(ctx, h.Db).Save(&plan).Error; err != nil {
+		h.logger.Error("failed to update subscription plan", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to update subscription plan", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, plan)
+}
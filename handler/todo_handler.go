@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/AI1411/golang-admin-api/util/appcontext"
 
@@ -33,6 +34,8 @@ type searchTodoPrams struct {
 	Status    string `form:"status" binding:"omitempty,oneof=new processing done closed"`
 	UserID    string `form:"user_id" binding:"omitempty,max=64"`
 	CreatedAt string `form:"created_at" binding:"omitempty,datetime"`
+	DueBefore string `form:"due_before" binding:"omitempty,datetime=2006-01-02"`
+	Overdue   string `form:"overdue" binding:"omitempty,oneof=true false"`
 	Offset    string `form:"offset" binding:"omitempty,numeric"`
 	Limit     string `form:"limit" binding:"omitempty,numeric"`
 }
@@ -42,6 +45,17 @@ type todoRequest struct {
 	Body   string `json:"body" binding:"required,max=64" example:"test body"`
 	Status string `json:"status" binding:"required,oneof=new processing done closed" example:"new"`
 	UserId string `json:"user_id" binding:"required,uuid4" format:"/^[0-9A-F]{8}-[0-9A-F]{4}-4[0-9A-F]{3}-[89AB][0-9A-F]{3}-[0-9A-F]{12}$/i" example:"443b5f1c-8a3a-4485-b3bc-05e69b40b290"`
+	// 期限。繰り返しを指定する場合は必須
+	DueAt string `json:"due_at" binding:"omitempty" example:"2022-10-01T18:00:00+09:00"`
+	// リマインド日時。期限より前を指定する
+	RemindAt       string `json:"remind_at" binding:"omitempty" example:"2022-10-01T09:00:00+09:00"`
+	RecurrenceRule string `json:"recurrence_rule" binding:"omitempty,oneof=daily weekly monthly" example:"weekly"`
+}
+
+type todoChecklistItemRequest struct {
+	Title    string `json:"title" binding:"required,max=64" example:"下書きを作成する"`
+	IsDone   bool   `json:"is_done" example:"false"`
+	Position int    `json:"position" binding:"omitempty,min=0" example:"1"`
 }
 
 type todoItem struct {
@@ -69,6 +83,8 @@ type todosResponse struct {
 // @Param status query string false "ステータス <br><table><tr><th>項目</th><th>説明</th></tr><tr><td>new</td><td>新規</td></tr><tr><td>processing</td><td>進行中</td></tr><tr><td>done</td><td>完了</td></tr><tr><td>closed</td><td>終了</td></tr></table>" Enums(new, processing, done, closed)
 // @Param user_id query string false "ユーザID" minlength(36) maxlength(36) format(UUID v4)
 // @Param created_at query string false "作成日" format(YYYY-MM-DDThh:mm:ss±hh:mm)
+// @Param due_before query string false "この日付より前が期限のtodo" format(YYYY-MM-DD)
+// @Param overdue query bool false "期限を過ぎた未完了のtodoのみ"
 // @Param offset query int false "開始位置" default(0) minimum(0)
 // @Param limit query int false "取得上限" default(12) minimum(1) maximum(100)
 func (h *TodoHandler) GetAll(ctx *gin.Context) {
@@ -81,7 +97,7 @@ func (h *TodoHandler) GetAll(ctx *gin.Context) {
 		return
 	}
 	var todos []models.Todo
-	query := createBaseQueryBuilder(params, h)
+	query := createBaseQueryBuilder(ctx, params, h)
 	query.Find(&todos)
 
 	res := todosResponse{
@@ -113,7 +129,9 @@ func (h *TodoHandler) GetAll(ctx *gin.Context) {
 func (h *TodoHandler) GetDetail(ctx *gin.Context) {
 	var todo models.Todo
 	id := ctx.Param("id")
-	if err := h.Db.Where("id = ?", id).Find(&todo).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).Preload("ChecklistItems", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Find(&todo).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("todo not found"))
@@ -146,7 +164,14 @@ func (h *TodoHandler) CreateTodo(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
-	h.Db.Create(&todo)
+	if !todo.HasValidSchedule() {
+		ctx.JSON(http.StatusBadRequest,
+			errors.NewBadRequestError("recurrence_rule requires due_at and remind_at must not be after due_at"))
+		return
+	}
+	todo.RemindedAt = nil
+	todo.RecurredAt = nil
+	tenantDB(ctx, h.Db).Create(&todo)
 	ctx.JSON(http.StatusCreated, todo)
 }
 
@@ -167,13 +192,24 @@ func (h *TodoHandler) CreateTodo(ctx *gin.Context) {
 func (h *TodoHandler) UpdateTodo(ctx *gin.Context) {
 	todo := models.Todo{}
 	id := ctx.Param("id")
-	h.Db.First(&todo, id)
+	tenantDB(ctx, h.Db).First(&todo, id)
+	remindAt, remindedAt, recurredAt := todo.RemindAt, todo.RemindedAt, todo.RecurredAt
 	if err := ctx.ShouldBindJSON(&todo); err != nil {
 		res := createValidateErrorResponse(err)
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
-	h.Db.Save(&todo)
+	if !todo.HasValidSchedule() {
+		ctx.JSON(http.StatusBadRequest,
+			errors.NewBadRequestError("recurrence_rule requires due_at and remind_at must not be after due_at"))
+		return
+	}
+	// 通知・繰り返しの実行状況はバッチだけが更新する。リマインド日時が変わった場合は再度通知する
+	todo.RemindedAt, todo.RecurredAt = remindedAt, recurredAt
+	if !equalTimePtr(remindAt, todo.RemindAt) {
+		todo.RemindedAt = nil
+	}
+	tenantDB(ctx, h.Db).Save(&todo)
 	ctx.JSON(http.StatusAccepted, todo)
 }
 
@@ -193,7 +229,7 @@ func (h *TodoHandler) UpdateTodo(ctx *gin.Context) {
 func (h TodoHandler) DeleteTodo(ctx *gin.Context) {
 	todo := models.Todo{}
 	id := ctx.Param("id")
-	if err := h.Db.Where("id = ?", id).First(&todo).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&todo).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("todo not found"))
@@ -202,13 +238,152 @@ func (h TodoHandler) DeleteTodo(ctx *gin.Context) {
 		}
 		return
 	}
-	h.Db.Delete(&todo)
+	tenantDB(ctx, h.Db).Delete(&todo)
+	ctx.Status(http.StatusNoContent)
+}
+
+// CreateChecklistItem @title todoチェックリスト追加
+// @id CreateChecklistItem
+// @tags todos
+// @version バージョン(1.0)
+// @description todoにチェックリストの項目を追加する
+// @Summary todoチェックリスト追加
+// @Produce json
+// @Success 201 {object} models.TodoChecklistItem
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /todos/:id/checklist [POST]
+// @Accept json
+// @Param todoChecklistItemRequest body todoChecklistItemRequest true "create checklist item"
+// @Param id path int true "ID"
+func (h *TodoHandler) CreateChecklistItem(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var todo models.Todo
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&todo).Error; err != nil {
+		h.abortTodoLookup(ctx, traceID, err, "todo not found")
+		return
+	}
+	var req todoChecklistItemRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	item := models.TodoChecklistItem{
+		TodoID:    todo.ID,
+		Title:     req.Title,
+		IsDone:    req.IsDone,
+		Position:  req.Position,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := tenantDB(ctx, h.Db).Create(&item).Error; err != nil {
+		h.logger.Error("failed to create checklist item", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to create checklist item", err))
+		return
+	}
+	ctx.JSON(http.StatusCreated, item)
+}
+
+// UpdateChecklistItem @title todoチェックリスト編集
+// @id UpdateChecklistItem
+// @tags todos
+// @version バージョン(1.0)
+// @description todoのチェックリストの項目を編集する
+// @Summary todoチェックリスト編集
+// @Produce json
+// @Success 202 {object} models.TodoChecklistItem
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /todos/:id/checklist/:item_id [PUT]
+// @Accept json
+// @Param todoChecklistItemRequest body todoChecklistItemRequest true "update checklist item"
+// @Param id path int true "ID"
+// @Param item_id path int true "チェックリスト項目ID"
+func (h *TodoHandler) UpdateChecklistItem(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var item models.TodoChecklistItem
+	if err := tenantDB(ctx, h.Db).Where("id = ? AND todo_id = ?", ctx.Param("item_id"), ctx.Param("id")).
+		First(&item).Error; err != nil {
+		h.abortTodoLookup(ctx, traceID, err, "checklist item not found")
+		return
+	}
+	var req todoChecklistItemRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	item.Title = req.Title
+	item.IsDone = req.IsDone
+	item.Position = req.Position
+	item.UpdatedAt = time.Now()
+	if err := tenantDB(ctx, h.Db).Save(&item).Error; err != nil {
+		h.logger.Error("failed to update checklist item", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update checklist item", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, item)
+}
+
+// DeleteChecklistItem @title todoチェックリスト削除
+// @id DeleteChecklistItem
+// @tags todos
+// @version バージョン(1.0)
+// @description todoのチェックリストの項目を削除する
+// @Summary todoチェックリスト削除
+// @Success 204
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /todos/:id/checklist/:item_id [DELETE]
+// @Param id path int true "ID"
+// @Param item_id path int true "チェックリスト項目ID"
+func (h *TodoHandler) DeleteChecklistItem(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var item models.TodoChecklistItem
+	if err := tenantDB(ctx, h.Db).Where("id = ? AND todo_id = ?", ctx.Param("item_id"), ctx.Param("id")).
+		First(&item).Error; err != nil {
+		h.abortTodoLookup(ctx, traceID, err, "checklist item not found")
+		return
+	}
+	if err := tenantDB(ctx, h.Db).Delete(&item).Error; err != nil {
+		h.logger.Error("failed to delete checklist item", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to delete checklist item", err))
+		return
+	}
 	ctx.Status(http.StatusNoContent)
 }
 
-func createBaseQueryBuilder(param searchTodoPrams, h *TodoHandler) *gorm.DB {
+func (h *TodoHandler) abortTodoLookup(ctx *gin.Context, traceID string, err error, message string) {
+	switch err {
+	case gorm.ErrRecordNotFound:
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError(message))
+	case gorm.ErrInvalidSQL:
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid sql"))
+	default:
+		h.logger.Error("failed to get todo", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get todo", err))
+	}
+}
+
+func equalTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func createBaseQueryBuilder(ctx *gin.Context, param searchTodoPrams, h *TodoHandler) *gorm.DB {
 	var todos []models.Todo
-	query := h.Db.Find(&todos)
+	query := tenantDB(ctx, h.Db).Find(&todos)
 	if param.Title != "" {
 		query = query.Where("title LIKE ?", "%"+param.Title+"%")
 	}
@@ -224,6 +399,16 @@ func createBaseQueryBuilder(param searchTodoPrams, h *TodoHandler) *gorm.DB {
 	if param.CreatedAt != "" {
 		query = query.Where("created_at = ?", param.CreatedAt)
 	}
+	if param.DueBefore != "" {
+		query = query.Where("due_at < ?", param.DueBefore)
+	}
+	finished := []models.TodoStatus{models.TodoStatusDone, models.TodoStatusClosed}
+	switch param.Overdue {
+	case "true":
+		query = query.Where("due_at < ? AND status NOT IN (?)", time.Now(), finished)
+	case "false":
+		query = query.Where("due_at IS NULL OR due_at >= ? OR status IN (?)", time.Now(), finished)
+	}
 	if param.Offset != "" {
 		query = query.Offset(param.Offset)
 	}
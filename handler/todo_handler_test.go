@@ -216,7 +216,7 @@ var getTodosTestCases = []struct {
 func TestGetTodos(t *testing.T) {
 	dbConn := db.Init()
 	dbConn.Exec("TRUNCATE TABLE todos")
-	dbConn.Exec("insert into todos values (1, 'test1', 'body1', 'new', 'e29aa01f-8df4-422e-8341-ec976be91f8d', '2022-03-26 21:34:52', '2022-03-26 21:34:52'),(2, 'test2', 'body2', 'done', 'e29aa01f-8df4-422e-8341-ec976be91f8c', '2022-03-26 21:34:52', '2022-03-26 21:34:52');")
+	dbConn.Exec("insert into todos (id, title, body, status, user_id, created_at, updated_at) values (1, 'test1', 'body1', 'new', 'e29aa01f-8df4-422e-8341-ec976be91f8d', '2022-03-26 21:34:52', '2022-03-26 21:34:52'),(2, 'test2', 'body2', 'done', 'e29aa01f-8df4-422e-8341-ec976be91f8c', '2022-03-26 21:34:52', '2022-03-26 21:34:52');")
 
 	r := gin.New()
 	zapLogger, err := logger.NewLoggerForTest(true)
@@ -283,7 +283,7 @@ var getTodoDetailTestCases = []struct {
 func TestTodoDetail(t *testing.T) {
 	dbConn := db.Init()
 	dbConn.Exec("TRUNCATE TABLE todos")
-	dbConn.Exec("insert into todos values (1, 'test1', 'body1', 'new', 'e29aa01f-8df4-422e-8341-ec976be91f8d', '2022-03-26 21:34:52', '2022-03-26 21:34:52'),(2, 'test2', 'body2', 'new', 2, '2022-03-26 21:34:52', '2022-03-26 21:34:52');")
+	dbConn.Exec("insert into todos (id, title, body, status, user_id, created_at, updated_at) values (1, 'test1', 'body1', 'new', 'e29aa01f-8df4-422e-8341-ec976be91f8d', '2022-03-26 21:34:52', '2022-03-26 21:34:52'),(2, 'test2', 'body2', 'new', 2, '2022-03-26 21:34:52', '2022-03-26 21:34:52');")
 
 	r := gin.New()
 	zapLogger, err := logger.NewLoggerForTest(true)
@@ -370,6 +370,19 @@ var createTodoTestCases = []struct {
 			]
 		}`,
 	},
+	{
+		tid:  3,
+		name: "期限の無い繰り返しは400エラーになること",
+		request: map[string]interface{}{
+			"title":           "test",
+			"body":            "test",
+			"status":          "new",
+			"user_id":         "e29aa01f-8df4-422e-8341-ec976be91f8d",
+			"recurrence_rule": "weekly",
+		},
+		wantStatus: http.StatusBadRequest,
+		wantBody:   `{"message": "recurrence_rule requires due_at and remind_at must not be after due_at","status": 400,"error": "bad_request","causes": null}`,
+	},
 }
 
 func TestCreateTodo(t *testing.T) {
@@ -462,7 +475,7 @@ var updateTodoTestCases = []struct {
 func TestUpdateTodo(t *testing.T) {
 	dbConn := db.Init()
 	dbConn.Exec("TRUNCATE TABLE todos")
-	dbConn.Exec("insert into todos values ('e29aa01f-8df4-422e-8341-ec976be91f8q', 'test1', 'body1', 'new', 'e29aa01f-8df4-422e-8341-ec976be91f81', '2022-03-26 21:34:52', '2022-03-26 21:34:52'),(2, 'test2', 'body2', 'new', 'e29aa01f-8df4-422e-8341-ec976be91f82', '2022-03-26 21:34:52', '2022-03-26 21:34:52');")
+	dbConn.Exec("insert into todos (id, title, body, status, user_id, created_at, updated_at) values ('e29aa01f-8df4-422e-8341-ec976be91f8q', 'test1', 'body1', 'new', 'e29aa01f-8df4-422e-8341-ec976be91f81', '2022-03-26 21:34:52', '2022-03-26 21:34:52'),(2, 'test2', 'body2', 'new', 'e29aa01f-8df4-422e-8341-ec976be91f82', '2022-03-26 21:34:52', '2022-03-26 21:34:52');")
 
 	r := gin.New()
 	zapLogger, err := logger.NewLoggerForTest(true)
@@ -524,7 +537,7 @@ var deleteTodoTestCases = []struct {
 func TestDeleteTodo(t *testing.T) {
 	dbConn := db.Init()
 	dbConn.Exec("TRUNCATE TABLE todos")
-	dbConn.Exec("insert into todos values (1, 'test1', 'body1', 'new', 'e29aa01f-8df4-422e-8341-ec976be91f8d', '2022-03-26 21:34:52', '2022-03-26 21:34:52'),(2, 'test2', 'body2', 'new', 'e29aa01f-8df4-422e-8341-ec976be91f8c', '2022-03-26 21:34:52', '2022-03-26 21:34:52');")
+	dbConn.Exec("insert into todos (id, title, body, status, user_id, created_at, updated_at) values (1, 'test1', 'body1', 'new', 'e29aa01f-8df4-422e-8341-ec976be91f8d', '2022-03-26 21:34:52', '2022-03-26 21:34:52'),(2, 'test2', 'body2', 'new', 'e29aa01f-8df4-422e-8341-ec976be91f8c', '2022-03-26 21:34:52', '2022-03-26 21:34:52');")
 
 	r := gin.New()
 	zapLogger, err := logger.NewLoggerForTest(true)
@@ -0,0 +1,418 @@
+package handler
+
+import (
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/skip2/go-qrcode"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/authtoken"
+	"github.com/AI1411/golang-admin-api/loginguard"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/totp"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+// twoFactorIssuer 認証アプリに表示するサービス名
+const twoFactorIssuer = "golang-admin-api"
+
+type twoFactorCodeRequest struct {
+	// Code 認証アプリのコードまたはリカバリーコード
+	Code string `json:"code" binding:"required,max=16" example:"123456"`
+}
+
+type disableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required,max=255"`
+	Code     string `json:"code" binding:"required,max=16" example:"123456"`
+}
+
+type verifyTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required,max=16" example:"123456"`
+}
+
+type twoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	// QRCode ProvisioningURIのQRコード(PNG)のdata URI
+	QRCode string `json:"qr_code"`
+}
+
+type recoveryCodesResponse struct {
+	// RecoveryCodes 一度だけ表示する。再表示はできないため、再発行する必要がある
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// SetupTwoFactor @title 2段階認証の登録開始
+// @id SetupTwoFactor
+// @tags auth
+// @version バージョン(1.0)
+// @description TOTPの秘密鍵を発行し、認証アプリで読み取るQRコードを返す。/auth/2fa/enableで認証コードを確認するまで有効にはならない
+// @Summary 2段階認証の登録開始
+// @Produce json
+// @Success 200 {object} twoFactorSetupResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /auth/2fa/setup [POST]
+func (h *AuthHandler) SetupTwoFactor(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	user, ok := h.currentUser(ctx, traceID)
+	if !ok {
+		return
+	}
+	if user.IsTwoFactorEnabled() {
+		ctx.JSON(http.StatusConflict, errors.NewConflictError("two factor authentication is already enabled"))
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		h.logger.Error("failed to generate totp secret", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to generate totp secret", err))
+		return
+	}
+	uri := totp.ProvisioningURI(twoFactorIssuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		h.logger.Error("failed to generate qrcode", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to generate qrcode", err))
+		return
+	}
+	// 有効にするまでは以前の秘密鍵を上書きして登録をやり直せる
+	if err := h.Db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"two_factor_secret":    secret,
+		"two_factor_last_step": 0,
+		"version":              gorm.Expr("version + 1"),
+		"updated_at":           time.Now(),
+	}).Error; err != nil {
+		h.logger.Error("failed to save totp secret", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to save totp secret", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, twoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: uri,
+		QRCode:          "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// EnableTwoFactor @title 2段階認証の有効化
+// @id EnableTwoFactor
+// @tags auth
+// @version バージョン(1.0)
+// @description 登録を開始した認証アプリのコードを確認して2段階認証を有効にし、リカバリーコードを発行する
+// @Summary 2段階認証の有効化
+// @Produce json
+// @Success 200 {object} recoveryCodesResponse
+// @Failure 400 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /auth/2fa/enable [POST]
+// @Accept json
+// @Param twoFactorCodeRequest body twoFactorCodeRequest true "enable two factor"
+func (h *AuthHandler) EnableTwoFactor(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req twoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	user, ok := h.currentUser(ctx, traceID)
+	if !ok {
+		return
+	}
+	if user.IsTwoFactorEnabled() {
+		ctx.JSON(http.StatusConflict, errors.NewConflictError("two factor authentication is already enabled"))
+		return
+	}
+	if user.TwoFactorSecret == "" {
+		ctx.JSON(http.StatusConflict, errors.NewConflictError("two factor authentication setup has not been started"))
+		return
+	}
+	step, valid := totp.Validate(user.TwoFactorSecret, req.Code, time.Now(), user.TwoFactorLastStep)
+	if !valid {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid two factor code"))
+		return
+	}
+	var codes []string
+	if err := h.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"two_factor_last_step":  step,
+			"two_factor_enabled_at": time.Now(),
+			"version":               gorm.Expr("version + 1"),
+			"updated_at":            time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	}); err != nil {
+		h.logger.Error("failed to enable two factor", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to enable two factor", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor @title 2段階認証の無効化
+// @id DisableTwoFactor
+// @tags auth
+// @version バージョン(1.0)
+// @description パスワードと認証コードを確認して2段階認証を無効にする。2段階認証が必須の管理者は無効にできない
+// @description 操作したセッション以外のセッションは無効になる
+// @Summary 2段階認証の無効化
+// @Produce json
+// @Success 200
+// @Failure 400 {object} errorResponse
+// @Failure 401 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /auth/2fa/disable [POST]
+// @Accept json
+// @Param disableTwoFactorRequest body disableTwoFactorRequest true "disable two factor"
+func (h *AuthHandler) DisableTwoFactor(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req disableTwoFactorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	user, ok := h.currentUser(ctx, traceID)
+	if !ok {
+		return
+	}
+	if !user.IsTwoFactorEnabled() {
+		ctx.JSON(http.StatusConflict, errors.NewConflictError("two factor authentication is not enabled"))
+		return
+	}
+	if user.RequiresTwoFactor() {
+		ctx.JSON(http.StatusConflict, errors.NewConflictError("two factor authentication is required for admin"))
+		return
+	}
+	if user.ComparePassword(req.Password) != nil {
+		ctx.JSON(http.StatusUnauthorized, errors.NewUnauthorizedError("invalid password"))
+		return
+	}
+	if !h.checkSecondFactor(ctx, traceID, &user, req.Code) {
+		return
+	}
+	if err := h.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"two_factor_secret":     "",
+			"two_factor_last_step":  0,
+			"two_factor_enabled_at": nil,
+			"version":               gorm.Expr("version + 1"),
+			"updated_at":            time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	}); err != nil {
+		h.logger.Error("failed to disable two factor", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to disable two factor", err))
+		return
+	}
+	// 2段階認証で守られていた他のログインは無効にし、操作したセッションのみ残す
+	if err := h.sessions.RevokeAll(ctx, user.ID, appcontext.GetSessionID(ctx)); err != nil {
+		h.logger.Error("failed to revoke sessions", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to revoke sessions", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "2段階認証を無効にしました",
+	})
+}
+
+// RegenerateRecoveryCodes @title リカバリーコードの再発行
+// @id RegenerateRecoveryCodes
+// @tags auth
+// @version バージョン(1.0)
+// @description 認証コードを確認してリカバリーコードを発行し直す。以前のコードは使えなくなる
+// @Summary リカバリーコードの再発行
+// @Produce json
+// @Success 200 {object} recoveryCodesResponse
+// @Failure 400 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /auth/2fa/recoveryCodes [POST]
+// @Accept json
+// @Param twoFactorCodeRequest body twoFactorCodeRequest true "regenerate recovery codes"
+func (h *AuthHandler) RegenerateRecoveryCodes(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req twoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	user, ok := h.currentUser(ctx, traceID)
+	if !ok {
+		return
+	}
+	if !user.IsTwoFactorEnabled() {
+		ctx.JSON(http.StatusConflict, errors.NewConflictError("two factor authentication is not enabled"))
+		return
+	}
+	if !h.checkSecondFactor(ctx, traceID, &user, req.Code) {
+		return
+	}
+	var codes []string
+	if err := h.Db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	}); err != nil {
+		h.logger.Error("failed to regenerate recovery codes", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to regenerate recovery codes", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// VerifyTwoFactor @title 2段階認証
+// @id VerifyTwoFactor
+// @tags auth
+// @version バージョン(1.0)
+// @description ログインで返されたchallenge_tokenと認証コードまたはリカバリーコードを検証し、JWTを発行する
+// @description challenge_tokenは一度だけ使える。コードが間違っていた場合はパスワードの入力からやり直す
+// @Summary 2段階認証
+// @Produce json
+// @Success 200
+// @Failure 400 {object} errorResponse
+// @Failure 401 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /auth/2fa/verify [POST]
+// @Accept json
+// @Param verifyTwoFactorRequest body verifyTwoFactorRequest true "verify two factor"
+func (h *AuthHandler) VerifyTwoFactor(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req verifyTwoFactorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	userID, ok := h.consumeToken(ctx, traceID, authtoken.PurposeTwoFactorLogin, req.ChallengeToken)
+	if !ok {
+		return
+	}
+	var user models.User
+	if err := h.Db.Where("id = ?", userID).First(&user).Error; err != nil {
+		h.abortUserLookup(ctx, traceID, err)
+		return
+	}
+	email := loginguard.NormalizeEmail(user.Email)
+	attempt := newLoginAttempt(ctx, email)
+	attempt.UserID = &user.ID
+	valid, err := h.verifySecondFactor(&user, req.Code)
+	if err != nil {
+		h.logger.Error("failed to verify two factor code", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to verify two factor code", err))
+		return
+	}
+	if !valid {
+		if _, err := h.guard.Fail(ctx, email, attempt.IPAddress); err != nil {
+			h.logger.Error("failed to record login failure", zap.Error(err),
+				zap.String("trace_id", traceID))
+		}
+		attempt.FailureReason = models.LoginFailureInvalidTwoFactorCode
+		h.recordLoginAttempt(traceID, &attempt)
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"message": "認証コードが間違っています。再度ログインしてください",
+		})
+		return
+	}
+	h.completeLogin(ctx, traceID, &user, &attempt)
+}
+
+// currentUser 認証済みのユーザーを取得する。取得できない場合は応答を返してfalseを返す
+func (h *AuthHandler) currentUser(ctx *gin.Context, traceID string) (models.User, bool) {
+	var user models.User
+	if err := h.Db.Where("id = ?", appcontext.GetUserID(ctx)).First(&user).Error; err != nil {
+		h.abortUserLookup(ctx, traceID, err)
+		return user, false
+	}
+	return user, true
+}
+
+// checkSecondFactor 認証コードを検証する。間違っている場合は応答を返してfalseを返す
+func (h *AuthHandler) checkSecondFactor(ctx *gin.Context, traceID string, user *models.User, code string) bool {
+	valid, err := h.verifySecondFactor(user, code)
+	if err != nil {
+		h.logger.Error("failed to verify two factor code", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to verify two factor code", err))
+		return false
+	}
+	if !valid {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid two factor code"))
+		return false
+	}
+	return true
+}
+
+// verifySecondFactor 認証アプリのコードまたは未使用のリカバリーコードを検証し、使用済みにする
+// 同時に同じコードが送られた場合も、条件付きの更新でどちらか一方だけを成功させる
+func (h *AuthHandler) verifySecondFactor(user *models.User, code string) (bool, error) {
+	if step, ok := totp.Validate(user.TwoFactorSecret, code, time.Now(), user.TwoFactorLastStep); ok {
+		result := h.Db.Model(&models.User{}).
+			Where("id = ? AND two_factor_last_step < ?", user.ID, step).
+			Updates(map[string]interface{}{
+				"two_factor_last_step": step,
+				"version":              gorm.Expr("version + 1"),
+				"updated_at":           time.Now(),
+			})
+		if result.Error != nil {
+			return false, result.Error
+		}
+		user.TwoFactorLastStep = step
+		return result.RowsAffected == 1, nil
+	}
+	result := h.Db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, totp.HashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// replaceRecoveryCodes 以前のリカバリーコードを消して新しいコードを発行する
+func replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	codes, err := totp.GenerateRecoveryCodes(totp.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		if err := tx.Create(&models.RecoveryCode{
+			UserID:    userID,
+			CodeHash:  totp.HashRecoveryCode(code),
+			CreatedAt: time.Now(),
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
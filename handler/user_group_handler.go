@@ -2,166 +2,751 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
 
 	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
 	"github.com/AI1411/golang-admin-api/util/errors"
 )
 
 type UserGroupHandler struct {
-	Db *gorm.DB
+	Db     *gorm.DB
+	logger *zap.Logger
 }
 
-func NewUserGroupHandler(db *gorm.DB) *UserGroupHandler {
-	return &UserGroupHandler{Db: db}
+func NewUserGroupHandler(db *gorm.DB, logger *zap.Logger) *UserGroupHandler {
+	return &UserGroupHandler{
+		Db:     db,
+		logger: logger,
+	}
 }
 
 type searchUserGroupParams struct {
 	GroupName string `form:"group_name" binding:"omitempty,max=64"`
+	ParentID  string `form:"parent_id" binding:"omitempty,len=36"`
 }
 
 type createUserGroupParams struct {
-	GroupName string   `json:"group_name" binding:"required,max=64"`
-	UserIDs   []string `json:"user_ids" binding:"required"`
+	GroupName string   `json:"group_name" binding:"required,max=64" example:"開発部"`
+	ParentID  string   `json:"parent_id" binding:"omitempty,len=36" example:"443b5f1c-8a3a-4485-b3bc-05e69b40b290"`
+	UserIDs   []string `json:"user_ids" binding:"required,dive,len=36"`
+}
+
+type updateUserGroupParams struct {
+	GroupName string `json:"group_name" binding:"required,max=64" example:"開発部"`
+	ParentID  string `json:"parent_id" binding:"omitempty,len=36" example:"443b5f1c-8a3a-4485-b3bc-05e69b40b290"`
+}
+
+type groupMemberRequest struct {
+	UserID string `json:"user_id" binding:"required,len=36" example:"443b5f1c-8a3a-4485-b3bc-05e69b40b290"`
+	Role   string `json:"role" binding:"omitempty,oneof=owner member" example:"member"`
+}
+
+type groupMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner member" example:"owner"`
+}
+
+type searchGroupMemberParams struct {
+	Inherited string `form:"inherited,default=true" binding:"omitempty,oneof=true false"`
+}
+
+type groupMemberResponseItem struct {
+	UserID        string `json:"user_id"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	Email         string `json:"email"`
+	Role          string `json:"role"`
+	InheritedFrom string `json:"inherited_from,omitempty"`
+}
+
+type groupMembersResponse struct {
+	Total   int                       `json:"total"`
+	Members []groupMemberResponseItem `json:"members"`
 }
 
+type userGroupMembershipItem struct {
+	GroupID       string `json:"group_id"`
+	GroupName     string `json:"group_name"`
+	Role          string `json:"role"`
+	InheritedFrom string `json:"inherited_from,omitempty"`
+}
+
+type userGroupMembershipsResponse struct {
+	Total  int                       `json:"total"`
+	Groups []userGroupMembershipItem `json:"groups"`
+}
+
+// GetAllUserGroups @title 一覧取得
+// @id GetAllUserGroups
+// @tags userGroups
+// @version バージョン(1.0)
+// @description 指定された条件に一致するグループ一覧を、直接所属するユーザーとともに返す
+// @Summary グループ一覧取得
+// @Produce json
+// @Success 200 {array} models.UserGroup
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /userGroups [GET]
+// @Param group_name query string false "グループ名" maxlength(64)
+// @Param parent_id query string false "親グループID" minlength(36) maxlength(36) format(UUID v4)
 func (h *UserGroupHandler) GetAllUserGroups(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
 	var params searchUserGroupParams
 	if err := ctx.ShouldBindQuery(&params); err != nil {
 		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind query params", traceID, err)
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
 
 	var userGroups []models.UserGroup
-	query := createUserGroupQueryBuilder(params, h)
-	if err := h.Db.Transaction(func(tx *gorm.DB) error {
-		if err := query.Find(&userGroups).Error; err != nil {
-			ctx.JSON(http.StatusInternalServerError,
-				errors.NewInternalServerError("failed to get user groups", err))
+	query := createUserGroupQueryBuilder(ctx, params, h)
+	if err := query.Preload("Users").Find(&userGroups).Error; err != nil {
+		h.logger.Error("failed to get user groups", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get user groups", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, userGroups)
+}
+
+// GetUserGroupsDetail @title グループ詳細
+// @id GetUserGroupsDetail
+// @tags userGroups
+// @version バージョン(1.0)
+// @description グループと直接所属するユーザーを返す
+// @Summary グループ詳細取得
+// @Produce json
+// @Success 200 {object} models.UserGroup
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /userGroups/:id [GET]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *UserGroupHandler) GetUserGroupsDetail(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var userGroup models.UserGroup
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).Preload("Users").First(&userGroup).Error; err != nil {
+		h.abortUserGroupLookup(ctx, traceID, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, userGroup)
+}
+
+// CreateUserGroup @title グループ作成
+// @id CreateUserGroup
+// @tags userGroups
+// @version バージョン(1.0)
+// @description グループを作成する。作成したユーザーはオーナー、user_idsのユーザーはメンバーになる。
+// @description parent_idを指定する場合は親グループまたはその祖先グループのオーナーである必要がある
+// @Summary グループ作成
+// @Produce json
+// @Success 201 {object} models.UserGroup
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /userGroups [POST]
+// @Accept json
+// @Param createUserGroupParams body createUserGroupParams true "create user group"
+func (h *UserGroupHandler) CreateUserGroup(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	params := createUserGroupParams{}
+	if err := ctx.ShouldBindJSON(&params); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if params.ParentID != "" {
+		hierarchy, _, err := h.loadGroupHierarchy(ctx)
+		if err != nil {
+			h.abortUserGroupError(ctx, traceID, "failed to create user group", err)
+			return
+		}
+		if err := h.authorizeGroupOwner(ctx, hierarchy, params.ParentID); err != nil {
+			h.abortUserGroupError(ctx, traceID, "failed to create user group", err)
+			return
+		}
+	}
+
+	now := time.Now()
+	userGroup := models.UserGroup{
+		GroupName: params.GroupName,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	userGroup.CreateUUID()
+	if params.ParentID != "" {
+		userGroup.ParentID = &params.ParentID
+	}
+
+	requesterID := appcontext.GetUserID(ctx)
+	memberships := make([]models.GroupUser, 0, len(params.UserIDs)+1)
+	if requesterID != "" {
+		memberships = append(memberships, models.GroupUser{UserID: requesterID, Role: models.GroupRoleOwner})
+	}
+	for _, userID := range uniqueStrings(params.UserIDs) {
+		if userID != requesterID {
+			memberships = append(memberships, models.GroupUser{UserID: userID, Role: models.GroupRoleMember})
+		}
+	}
+
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if params.ParentID != "" {
+			if err := existsRecord(tx, "user_groups", params.ParentID, "parent group not found"); err != nil {
+				return err
+			}
+		}
+		if err := h.existUsers(tx, params.UserIDs); err != nil {
 			return err
 		}
-		var groupUser []models.GroupUser
-		if err := tx.Table("group_user").Find(&groupUser).Error; err != nil {
-			ctx.JSON(http.StatusInternalServerError,
-				errors.NewInternalServerError("failed to get user groups", err))
+		if err := tx.Create(&userGroup).Error; err != nil {
 			return err
 		}
-		for i, group := range userGroups {
-			var userIDs []string
-			for _, user := range groupUser {
-				if group.ID == user.GroupID {
-					userIDs = append(userIDs, user.UserID)
-				}
-			}
-			var users []models.User
-			if err := tx.Table("users").Where("id in (?)", userIDs).Find(&users).Error; err != nil {
-				ctx.JSON(http.StatusInternalServerError,
-					errors.NewInternalServerError("failed to get user groups", err))
+		for i := range memberships {
+			memberships[i].GroupID = userGroup.ID
+			memberships[i].CreatedAt = now
+			memberships[i].UpdatedAt = now
+			if err := tx.Create(&memberships[i]).Error; err != nil {
 				return err
 			}
-			if len(users) > 0 {
-				group.Users = users
-			}
-			userGroups[i] = group
 		}
-		return nil
+		return tx.Where("id = ?", userGroup.ID).Preload("Users").First(&userGroup).Error
 	}); err != nil {
-		ctx.JSON(http.StatusInternalServerError,
-			errors.NewInternalServerError("failed to get user groups or group user", err))
+		h.abortUserGroupError(ctx, traceID, "failed to create user group", err)
 		return
 	}
-	ctx.JSON(http.StatusOK, userGroups)
+
+	ctx.JSON(http.StatusCreated, userGroup)
 }
 
-func (h *UserGroupHandler) GetUserGroupsDetail(ctx *gin.Context) {
+// UpdateUserGroup @title グループ編集
+// @id UpdateUserGroup
+// @tags userGroups
+// @version バージョン(1.0)
+// @description グループ名と親グループを変更する。グループまたは祖先グループのオーナーのみ実行でき、
+// @description 親グループを変更する場合は変更先のグループまたはその祖先グループのオーナーである必要がある
+// @Summary グループ編集
+// @Produce json
+// @Success 202 {object} models.UserGroup
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /userGroups/:id [PUT]
+// @Accept json
+// @Param updateUserGroupParams body updateUserGroupParams true "update user group"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *UserGroupHandler) UpdateUserGroup(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
 	var userGroup models.UserGroup
-	if err := h.Db.Transaction(func(tx *gorm.DB) error {
-		id := ctx.Param("id")
-		if err := tx.Where("id = ?", id).First(&userGroup).Error; err != nil {
-			ctx.JSON(http.StatusInternalServerError,
-				errors.NewInternalServerError("failed to get user groups", err))
-			return err
-		}
-		var groupUser []models.GroupUser
-		if err := tx.Table("group_user").Find(&groupUser).Error; err != nil {
-			ctx.JSON(http.StatusInternalServerError,
-				errors.NewInternalServerError("failed to get user groups", err))
-			return err
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&userGroup).Error; err != nil {
+		h.abortUserGroupLookup(ctx, traceID, err)
+		return
+	}
+	var params updateUserGroupParams
+	if err := ctx.ShouldBindJSON(&params); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	hierarchy, _, err := h.loadGroupHierarchy(ctx)
+	if err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to update user group", err)
+		return
+	}
+	if err := h.authorizeGroupOwner(ctx, hierarchy, userGroup.ID); err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to update user group", err)
+		return
+	}
+	if params.ParentID != "" {
+		if err := existsRecord(tenantDB(ctx, h.Db), "user_groups", params.ParentID, "parent group not found"); err != nil {
+			h.abortUserGroupError(ctx, traceID, "failed to update user group", err)
+			return
 		}
-		var userIDs []string
-		for _, user := range groupUser {
-			if userGroup.ID == user.GroupID {
-				userIDs = append(userIDs, user.UserID)
+		// 移動先のグループを管理できない場合は、そのグループのサブグループにできない
+		if userGroup.ParentID == nil || *userGroup.ParentID != params.ParentID {
+			if err := h.authorizeGroupOwner(ctx, hierarchy, params.ParentID); err != nil {
+				h.abortUserGroupError(ctx, traceID, "failed to update user group", err)
+				return
 			}
 		}
-		var users []models.User
-		if err := tx.Table("users").Where("id in (?)", userIDs).Find(&users).Error; err != nil {
-			ctx.JSON(http.StatusInternalServerError,
-				errors.NewInternalServerError("failed to get user groups", err))
+	}
+	if !hierarchy.CanSetParent(userGroup.ID, params.ParentID) {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("parent_id must not be the group itself or its subgroup"))
+		return
+	}
+
+	userGroup.GroupName = params.GroupName
+	userGroup.ParentID = nil
+	if params.ParentID != "" {
+		userGroup.ParentID = &params.ParentID
+	}
+	userGroup.UpdatedAt = time.Now()
+	if err := tenantDB(ctx, h.Db).Table("user_groups").Where("id = ?", userGroup.ID).Updates(map[string]interface{}{
+		"group_name": userGroup.GroupName,
+		"parent_id":  userGroup.ParentID,
+		"updated_at": userGroup.UpdatedAt,
+	}).Error; err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to update user group", err)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, userGroup)
+}
+
+// DeleteUserGroup @title グループ削除
+// @id DeleteUserGroup
+// @tags userGroups
+// @version バージョン(1.0)
+// @description グループと所属を削除する。サブグループは削除したグループの親グループへ移す。グループまたは祖先グループのオーナーのみ実行できる
+// @Summary グループ削除
+// @Success 204
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /userGroups/:id [DELETE]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *UserGroupHandler) DeleteUserGroup(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var userGroup models.UserGroup
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&userGroup).Error; err != nil {
+		h.abortUserGroupLookup(ctx, traceID, err)
+		return
+	}
+	hierarchy, _, err := h.loadGroupHierarchy(ctx)
+	if err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to delete user group", err)
+		return
+	}
+	if err := h.authorizeGroupOwner(ctx, hierarchy, userGroup.ID); err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to delete user group", err)
+		return
+	}
+
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("user_groups").Where("parent_id = ?", userGroup.ID).
+			Updates(map[string]interface{}{"parent_id": userGroup.ParentID, "updated_at": time.Now()}).Error; err != nil {
 			return err
 		}
-		if len(users) > 0 {
-			userGroup.Users = users
+		if err := tx.Where("group_id = ?", userGroup.ID).Delete(&models.GroupUser{}).Error; err != nil {
+			return err
 		}
-		return nil
+		return tx.Where("id = ?", userGroup.ID).Delete(&models.UserGroup{}).Error
 	}); err != nil {
-		ctx.JSON(http.StatusInternalServerError,
-			errors.NewInternalServerError("failed to get user groups or group user", err))
+		h.abortUserGroupError(ctx, traceID, "failed to delete user group", err)
 		return
 	}
-	ctx.JSON(http.StatusOK, userGroup)
+	ctx.Status(http.StatusNoContent)
 }
 
-func (h *UserGroupHandler) CreateUserGroup(ctx *gin.Context) {
-	createUserGroupParams := createUserGroupParams{}
-	if err := ctx.ShouldBindJSON(&createUserGroupParams); err != nil {
+// GetGroupMembers @title グループメンバー一覧
+// @id GetGroupMembers
+// @tags userGroups
+// @version バージョン(1.0)
+// @description グループのメンバーを返す。inheritedがtrueの場合はサブグループのメンバーも含める
+// @Summary グループメンバー一覧取得
+// @Produce json
+// @Success 200 {object} groupMembersResponse
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /userGroups/:id/members [GET]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param inherited query bool false "サブグループのメンバーを含めるか" default(true)
+func (h *UserGroupHandler) GetGroupMembers(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var params searchGroupMemberParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
 		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind query params", traceID, err)
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
+	var userGroup models.UserGroup
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&userGroup).Error; err != nil {
+		h.abortUserGroupLookup(ctx, traceID, err)
+		return
+	}
+	hierarchy, _, err := h.loadGroupHierarchy(ctx)
+	if err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to get group members", err)
+		return
+	}
+	groupIDs := []string{userGroup.ID}
+	if params.Inherited == "true" {
+		groupIDs = append(groupIDs, hierarchy.Descendants(userGroup.ID)...)
+	}
+	var memberships []models.GroupUser
+	if err := tenantDB(ctx, h.Db).Where("group_id IN (?)", groupIDs).Find(&memberships).Error; err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to get group members", err)
+		return
+	}
+	members := hierarchy.Members(userGroup.ID, memberships)
 
-	userGroup := models.UserGroup{
-		GroupName: createUserGroupParams.GroupName,
+	userIDs := make([]string, len(members))
+	for i, m := range members {
+		userIDs[i] = m.UserID
+	}
+	var users []models.User
+	if err := tenantDB(ctx, h.Db).Where("id IN (?)", userIDs).Find(&users).Error; err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to get group members", err)
+		return
+	}
+	usersByID := make(map[string]models.User, len(users))
+	for _, u := range users {
+		usersByID[u.ID] = u
 	}
 
-	userGroup.CreateUUID()
-	if err := h.Db.Transaction(func(tx *gorm.DB) error {
-		if err := h.Db.Create(&userGroup).Error; err != nil {
-			ctx.JSON(http.StatusInternalServerError,
-				errors.NewInternalServerError("Failed to create user group", err))
-			return err
+	res := groupMembersResponse{Members: make([]groupMemberResponseItem, 0, len(members))}
+	for _, m := range members {
+		u, ok := usersByID[m.UserID]
+		if !ok {
+			continue
 		}
+		res.Members = append(res.Members, groupMemberResponseItem{
+			UserID:        u.ID,
+			FirstName:     u.FirstName,
+			LastName:      u.LastName,
+			Email:         u.Email,
+			Role:          m.Role,
+			InheritedFrom: m.InheritedFrom,
+		})
+	}
+	res.Total = len(res.Members)
+	ctx.JSON(http.StatusOK, res)
+}
 
-		for _, u := range createUserGroupParams.UserIDs {
-			groupUser := models.GroupUser{
-				UserID:  u,
-				GroupID: userGroup.ID,
-			}
-			if err := h.Db.Table("group_user").Create(&groupUser).Error; err != nil {
-				ctx.JSON(http.StatusInternalServerError,
-					errors.NewInternalServerError("Failed to create user group user", err))
-				return err
-			}
+// AddGroupMember @title グループメンバー追加
+// @id AddGroupMember
+// @tags userGroups
+// @version バージョン(1.0)
+// @description グループにユーザーを追加する。グループまたは祖先グループのオーナーのみ実行できる
+// @Summary グループメンバー追加
+// @Produce json
+// @Success 201 {object} models.GroupUser
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /userGroups/:id/members [POST]
+// @Accept json
+// @Param groupMemberRequest body groupMemberRequest true "add group member"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *UserGroupHandler) AddGroupMember(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var userGroup models.UserGroup
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&userGroup).Error; err != nil {
+		h.abortUserGroupLookup(ctx, traceID, err)
+		return
+	}
+	var req groupMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.GroupRoleMember
+	}
+	hierarchy, _, err := h.loadGroupHierarchy(ctx)
+	if err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to add group member", err)
+		return
+	}
+	if err := h.authorizeGroupOwner(ctx, hierarchy, userGroup.ID); err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to add group member", err)
+		return
+	}
+	if err := existsRecord(tenantDB(ctx, h.Db), "users", req.UserID, "user not found"); err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to add group member", err)
+		return
+	}
+	var count int
+	if err := tenantDB(ctx, h.Db).Model(&models.GroupUser{}).Where("group_id = ? AND user_id = ?", userGroup.ID, req.UserID).
+		Count(&count).Error; err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to add group member", err)
+		return
+	}
+	if count > 0 {
+		ctx.JSON(http.StatusConflict, errors.NewConflictError("user is already a member of the group"))
+		return
+	}
+
+	now := time.Now()
+	membership := models.GroupUser{
+		GroupID:   userGroup.ID,
+		UserID:    req.UserID,
+		Role:      req.Role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := tenantDB(ctx, h.Db).Create(&membership).Error; err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to add group member", err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, membership)
+}
+
+// UpdateGroupMember @title グループメンバー役割変更
+// @id UpdateGroupMember
+// @tags userGroups
+// @version バージョン(1.0)
+// @description グループ内の役割を変更する。グループまたは祖先グループのオーナーのみ実行でき、最後のオーナーは降格できない
+// @Summary グループメンバー役割変更
+// @Produce json
+// @Success 202 {object} models.GroupUser
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /userGroups/:id/members/:user_id [PUT]
+// @Accept json
+// @Param groupMemberRoleRequest body groupMemberRoleRequest true "update group member"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param user_id path string true "ユーザーID" minlength(36) maxlength(36) format(UUID v4)
+func (h *UserGroupHandler) UpdateGroupMember(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	membership, ok := h.findGroupMember(ctx, traceID)
+	if !ok {
+		return
+	}
+	var req groupMemberRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	hierarchy, _, err := h.loadGroupHierarchy(ctx)
+	if err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to update group member", err)
+		return
+	}
+	if err := h.authorizeGroupOwner(ctx, hierarchy, membership.GroupID); err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to update group member", err)
+		return
+	}
+	if membership.IsOwner() && req.Role != models.GroupRoleOwner {
+		if err := h.ensureAnotherOwner(ctx, membership); err != nil {
+			h.abortUserGroupError(ctx, traceID, "failed to update group member", err)
+			return
 		}
+	}
 
-		return nil
-	}); err != nil {
-		ctx.JSON(http.StatusInternalServerError,
-			errors.NewInternalServerError("Failed to create user group or group user", err))
+	membership.Role = req.Role
+	membership.UpdatedAt = time.Now()
+	if err := tenantDB(ctx, h.Db).Save(&membership).Error; err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to update group member", err)
 		return
 	}
+	ctx.JSON(http.StatusAccepted, membership)
+}
 
-	ctx.JSON(http.StatusCreated, userGroup)
+// RemoveGroupMember @title グループメンバー削除
+// @id RemoveGroupMember
+// @tags userGroups
+// @version バージョン(1.0)
+// @description グループからユーザーを外す。グループまたは祖先グループのオーナーか、本人のみ実行でき、最後のオーナーは外せない
+// @Summary グループメンバー削除
+// @Success 204
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /userGroups/:id/members/:user_id [DELETE]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param user_id path string true "ユーザーID" minlength(36) maxlength(36) format(UUID v4)
+func (h *UserGroupHandler) RemoveGroupMember(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	membership, ok := h.findGroupMember(ctx, traceID)
+	if !ok {
+		return
+	}
+	if membership.UserID != appcontext.GetUserID(ctx) {
+		hierarchy, _, err := h.loadGroupHierarchy(ctx)
+		if err != nil {
+			h.abortUserGroupError(ctx, traceID, "failed to remove group member", err)
+			return
+		}
+		if err := h.authorizeGroupOwner(ctx, hierarchy, membership.GroupID); err != nil {
+			h.abortUserGroupError(ctx, traceID, "failed to remove group member", err)
+			return
+		}
+	}
+	if membership.IsOwner() {
+		if err := h.ensureAnotherOwner(ctx, membership); err != nil {
+			h.abortUserGroupError(ctx, traceID, "failed to remove group member", err)
+			return
+		}
+	}
+	if err := tenantDB(ctx, h.Db).Delete(&membership).Error; err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to remove group member", err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
 }
 
-func createUserGroupQueryBuilder(param searchUserGroupParams, h *UserGroupHandler) *gorm.DB {
-	var userGroups []models.UserGroup
-	query := h.Db.Find(&userGroups)
+// GetUserGroupMemberships @title ユーザーの所属グループ一覧
+// @id GetUserGroupMemberships
+// @tags users
+// @version バージョン(1.0)
+// @description ユーザーが直接または継承で所属するグループと役割を返す
+// @Summary ユーザーの所属グループ一覧取得
+// @Produce json
+// @Success 200 {object} userGroupMembershipsResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /users/:id/groups [GET]
+// @Param id path string true "ユーザーID" minlength(36) maxlength(36) format(UUID v4)
+func (h *UserGroupHandler) GetUserGroupMemberships(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	userID := ctx.Param("id")
+	var count int
+	if err := tenantDB(ctx, h.Db).Table("users").Where("id = ?", userID).Count(&count).Error; err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to get user groups", err)
+		return
+	}
+	if count == 0 {
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("user not found"))
+		return
+	}
+	hierarchy, groups, err := h.loadGroupHierarchy(ctx)
+	if err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to get user groups", err)
+		return
+	}
+	var memberships []models.GroupUser
+	if err := tenantDB(ctx, h.Db).Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+		h.abortUserGroupError(ctx, traceID, "failed to get user groups", err)
+		return
+	}
+	names := make(map[string]string, len(groups))
+	for _, g := range groups {
+		names[g.ID] = g.GroupName
+	}
+
+	res := userGroupMembershipsResponse{Groups: []userGroupMembershipItem{}}
+	for _, m := range hierarchy.GroupsOf(userID, memberships) {
+		res.Groups = append(res.Groups, userGroupMembershipItem{
+			GroupID:       m.GroupID,
+			GroupName:     names[m.GroupID],
+			Role:          m.Role,
+			InheritedFrom: m.InheritedFrom,
+		})
+	}
+	res.Total = len(res.Groups)
+	ctx.JSON(http.StatusOK, res)
+}
+
+// loadGroupHierarchy 全グループの親子関係を読み込む。所属は必要な範囲だけ呼び出し側で読み込む
+func (h *UserGroupHandler) loadGroupHierarchy(ctx *gin.Context) (*models.GroupHierarchy, []models.UserGroup, error) {
+	var groups []models.UserGroup
+	if err := tenantDB(ctx, h.Db).Select("id, group_name, parent_id").Find(&groups).Error; err != nil {
+		return nil, nil, err
+	}
+	return models.NewGroupHierarchy(groups), groups, nil
+}
+
+// authorizeGroupOwner リクエストしたユーザーがグループまたは祖先グループのオーナーであることを確認する
+// オーナーが1人もいない既存のグループは、誰でも管理できるようにする
+func (h *UserGroupHandler) authorizeGroupOwner(ctx *gin.Context, hierarchy *models.GroupHierarchy, groupID string) error {
+	groupIDs := append([]string{groupID}, hierarchy.Ancestors(groupID)...)
+	var owners []models.GroupUser
+	if err := tenantDB(ctx, h.Db).Where("group_id IN (?) AND role = ?", groupIDs, models.GroupRoleOwner).
+		Find(&owners).Error; err != nil {
+		return err
+	}
+	if !hierarchy.HasOwner(groupID, owners) {
+		return nil
+	}
+	if !hierarchy.IsOwner(groupID, appcontext.GetUserID(ctx), owners) {
+		return errors.NewForbiddenError("only group owners can manage the group")
+	}
+	return nil
+}
+
+// ensureAnotherOwner グループに他のオーナーがいることを確認する
+func (h *UserGroupHandler) ensureAnotherOwner(ctx *gin.Context, membership models.GroupUser) error {
+	var count int
+	if err := tenantDB(ctx, h.Db).Model(&models.GroupUser{}).
+		Where("group_id = ? AND role = ? AND id <> ?", membership.GroupID, models.GroupRoleOwner, membership.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.NewBadRequestError("group must have at least one owner")
+	}
+	return nil
+}
+
+func (h *UserGroupHandler) findGroupMember(ctx *gin.Context, traceID string) (models.GroupUser, bool) {
+	var membership models.GroupUser
+	if err := tenantDB(ctx, h.Db).Where("group_id = ? AND user_id = ?", ctx.Param("id"), ctx.Param("user_id")).
+		First(&membership).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("group member not found"))
+		default:
+			h.abortUserGroupError(ctx, traceID, "failed to get group member", err)
+		}
+		return membership, false
+	}
+	return membership, true
+}
+
+// existUsers 指定したユーザーが全て存在することを確認する
+func (h *UserGroupHandler) existUsers(tx *gorm.DB, userIDs []string) error {
+	userIDs = uniqueStrings(userIDs)
+	if len(userIDs) == 0 {
+		return nil
+	}
+	var count int
+	if err := tx.Table("users").Where("id IN (?)", userIDs).Count(&count).Error; err != nil {
+		return err
+	}
+	if count != len(userIDs) {
+		return errors.NewBadRequestError("user not found")
+	}
+	return nil
+}
+
+func (h *UserGroupHandler) abortUserGroupLookup(ctx *gin.Context, traceID string, err error) {
+	switch err {
+	case gorm.ErrRecordNotFound:
+		ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("user group not found"))
+	case gorm.ErrInvalidSQL:
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid sql"))
+	default:
+		h.logger.Error("failed to get user group", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get user group", err))
+	}
+}
+
+func (h *UserGroupHandler) abortUserGroupError(ctx *gin.Context, traceID, message string, err error) {
+	if restErr, ok := err.(errors.RestErr); ok {
+		ctx.JSON(restErr.Status(), restErr)
+		return
+	}
+	h.logger.Error(message, zap.Error(err),
+		zap.String("trace_id", traceID))
+	ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError(message, err))
+}
+
+func createUserGroupQueryBuilder(ctx *gin.Context, param searchUserGroupParams, h *UserGroupHandler) *gorm.DB {
+	query := tenantDB(ctx, h.Db)
 	if param.GroupName != "" {
 		query = query.Where("group_name LIKE ?", "%"+param.GroupName+"%")
 	}
+	if param.ParentID != "" {
+		query = query.Where("parent_id = ?", param.ParentID)
+	}
 	return query
 }
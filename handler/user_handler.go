@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/csv"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -50,7 +52,7 @@ func (h *UserHandler) GetAllUser(ctx *gin.Context) {
 	}
 
 	var users []models.User
-	query := createUserQueryBuilder(params, h)
+	query := createUserQueryBuilder(ctx, params, h)
 	if err := query.Preload("Todos").Find(&users).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to get users", err))
 		return
@@ -65,7 +67,7 @@ func (h *UserHandler) GetAllUser(ctx *gin.Context) {
 func (h *UserHandler) GetUserDetail(ctx *gin.Context) {
 	var user models.User
 	id := ctx.Param("id")
-	if err := h.Db.Preload("Todos").Where("id = ?", id).First(&user).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Preload("Todos").Where("id = ?", id).First(&user).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("user not found"))
@@ -74,13 +76,23 @@ func (h *UserHandler) GetUserDetail(ctx *gin.Context) {
 		}
 		return
 	}
-	ctx.JSON(http.StatusOK, user)
+	respondWithETag(ctx, user.Version, user)
 }
 
 func (h *UserHandler) UpdateUser(ctx *gin.Context) {
+	h.updateUser(ctx, bindPut)
+}
+
+// PatchUser 本文をJSON Merge Patchとして扱い、指定した項目のみを編集する
+func (h *UserHandler) PatchUser(ctx *gin.Context) {
+	h.updateUser(ctx, bindMergePatch("id", "role", "email", "email_verified_at", "two_factor_enabled_at", "password", "todos", "version", "created_at", "updated_at"))
+}
+
+// updateUser PUTとPATCHで共通の編集処理。bindで更新内容を反映する
+func (h *UserHandler) updateUser(ctx *gin.Context, bind bindFunc) {
 	user := models.User{}
 	id := ctx.Param("id")
-	if err := h.Db.Where("id = ?", id).First(&user).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&user).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("user not found"))
@@ -89,23 +101,59 @@ func (h *UserHandler) UpdateUser(ctx *gin.Context) {
 		}
 		return
 	}
-	if err := ctx.ShouldBindJSON(&user); err != nil {
+	if !checkIfMatch(ctx, user.Version) {
+		return
+	}
+	// 権限、メールアドレスの確認状態、2段階認証は専用の操作でのみ変更できる
+	role, emailVerifiedAt, twoFactorEnabledAt, version := user.Role, user.EmailVerifiedAt, user.TwoFactorEnabledAt, user.Version
+	email, password := user.Email, user.Password
+	if err := bind(ctx, &user); err != nil {
 		res := createValidateErrorResponse(err)
 		ctx.AbortWithStatusJSON(res.Code, res)
 		return
 	}
+	// メールアドレスは確認のやり直し、パスワードはハッシュ化とセッションの失効が必要なため、
+	// PUTで本文全体を受け取る場合も変更は認めず、パスワードの再設定などの専用の操作で変更させる
+	if user.Email != email {
+		res := createValidateErrorResponse(fmt.Errorf("email cannot be changed"))
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if !bytes.Equal(user.Password, password) {
+		res := createValidateErrorResponse(fmt.Errorf("password cannot be changed, use the password reset"))
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	user.Role, user.EmailVerifiedAt, user.TwoFactorEnabledAt, user.Version = role, emailVerifiedAt, twoFactorEnabledAt, version
+	user.UpdatedAt = time.Now()
 
-	if err := h.Db.Save(&user).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := bumpVersion(tx, "users", user.ID, &user.Version); err != nil {
+			return err
+		}
+		// 編集できる項目のみを書き込み、専用の操作で変更された項目を読み込んだ時点の値で上書きしない
+		return tx.Table("users").Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"first_name": user.FirstName,
+			"last_name":  user.LastName,
+			"age":        user.Age,
+			"updated_at": user.UpdatedAt,
+		}).Error
+	}); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			respondVersionConflict(ctx)
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to update user", err))
 		return
 	}
+	ctx.Header("ETag", versionETag(user.Version))
 	ctx.JSON(http.StatusAccepted, user)
 }
 
 func (h *UserHandler) DeleteUser(ctx *gin.Context) {
 	user := models.User{}
 	id := ctx.Param("id")
-	if err := h.Db.Where("id = ?", id).First(&user).Error; err != nil {
+	if err := tenantDB(ctx, h.Db).Where("id = ?", id).First(&user).Error; err != nil {
 		switch err {
 		case gorm.ErrRecordNotFound:
 			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("product not found"))
@@ -114,7 +162,14 @@ func (h *UserHandler) DeleteUser(ctx *gin.Context) {
 		}
 		return
 	}
-	if err := h.Db.Delete(&user).Error; err != nil {
+	if !checkIfMatch(ctx, user.Version) {
+		return
+	}
+	if err := deleteWithVersion(tenantDB(ctx, h.Db), &user, user.Version); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			respondVersionConflict(ctx)
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errors.NewInternalServerError("failed to delete user", err))
 		return
 	}
@@ -127,7 +182,7 @@ func (h *UserHandler) ExportCSV(ctx *gin.Context) {
 	fileName := time.Now().Format("202101011111") + "_users.csv"
 	filePath := "assets/csv/users/" + fileName
 
-	if err := h.CreateFile(filePath); err != nil {
+	if err := h.CreateFile(ctx, filePath); err != nil {
 		log.Printf("test %+v", err)
 		return
 	}
@@ -137,7 +192,7 @@ func (h *UserHandler) ExportCSV(ctx *gin.Context) {
 	})
 }
 
-func (h *UserHandler) CreateFile(filepath string) error {
+func (h *UserHandler) CreateFile(ctx *gin.Context, filepath string) error {
 	file, err := os.Create(filepath)
 	if err != nil {
 		return err
@@ -150,7 +205,7 @@ func (h *UserHandler) CreateFile(filepath string) error {
 
 	var users []models.User
 
-	h.Db.Find(&users)
+	tenantDB(ctx, h.Db).Find(&users)
 
 	if err := writer.Write([]string{
 		"ID", "LastName", "FirstName", "Email", "Age",
@@ -174,9 +229,9 @@ func (h *UserHandler) CreateFile(filepath string) error {
 	return nil
 }
 
-func createUserQueryBuilder(params searchUserParams, h *UserHandler) *gorm.DB {
+func createUserQueryBuilder(ctx *gin.Context, params searchUserParams, h *UserHandler) *gorm.DB {
 	var users []models.User
-	query := h.Db.Find(&users)
+	query := tenantDB(ctx, h.Db).Find(&users)
 
 	if params.FirstName != "" {
 		query = query.Where("first_name LIKE ?", "%"+params.FirstName+"%")
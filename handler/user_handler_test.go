@@ -44,6 +44,8 @@ var getUsersTestCases = []struct {
 					"last_name": "1",
 					"age": 22,
 					"email": "test@gmail.com",
+					"role": "member",
+					"version": 1,
 					"password": "JDJhJDE0JFJDRHc1NGNHY0hNd1cySGJZdFZiOHV0ZUZ1d05jSU5xalBDYmFHM3hMNUszNGhrbmMzdGE2",
 					"created_at": "2022-06-20T22:14:22+09:00",
 					"updated_at": "2022-06-20T22:14:22+09:00",
@@ -55,6 +57,8 @@ var getUsersTestCases = []struct {
 					"last_name": "2",
 					"age": 37,
 					"email": "ishii@gmail.com",
+					"role": "member",
+					"version": 1,
 					"password": "JDJhJDE0JFJDRHc1NGNHY0hNd1cySGJZdFZiOHV0ZUZ1d05jSU5xalBDYmFHM3hMNUszNGhrbmMzdGE2",
 					"created_at": "2022-06-20T22:14:23+09:00",
 					"updated_at": "2022-06-20T22:14:23+09:00",
@@ -134,6 +138,8 @@ var getUsersTestCases = []struct {
 					"last_name": "1",
 					"age": 22,
 					"email": "test@gmail.com",
+					"role": "member",
+					"version": 1,
 					"password": "JDJhJDE0JFJDRHc1NGNHY0hNd1cySGJZdFZiOHV0ZUZ1d05jSU5xalBDYmFHM3hMNUszNGhrbmMzdGE2",
 					"created_at": "2022-06-20T22:14:22+09:00",
 					"updated_at": "2022-06-20T22:14:22+09:00",
@@ -158,6 +164,8 @@ var getUsersTestCases = []struct {
 					"last_name": "1",
 					"age": 22,
 					"email": "test@gmail.com",
+					"role": "member",
+					"version": 1,
 					"password": "JDJhJDE0JFJDRHc1NGNHY0hNd1cySGJZdFZiOHV0ZUZ1d05jSU5xalBDYmFHM3hMNUszNGhrbmMzdGE2",
 					"created_at": "2022-06-20T22:14:22+09:00",
 					"updated_at": "2022-06-20T22:14:22+09:00",
@@ -182,6 +190,8 @@ var getUsersTestCases = []struct {
 					"last_name": "1",
 					"age": 22,
 					"email": "test@gmail.com",
+					"role": "member",
+					"version": 1,
 					"password": "JDJhJDE0JFJDRHc1NGNHY0hNd1cySGJZdFZiOHV0ZUZ1d05jSU5xalBDYmFHM3hMNUszNGhrbmMzdGE2",
 					"created_at": "2022-06-20T22:14:22+09:00",
 					"updated_at": "2022-06-20T22:14:22+09:00",
@@ -206,6 +216,8 @@ var getUsersTestCases = []struct {
 					"last_name": "1",
 					"age": 22,
 					"email": "test@gmail.com",
+					"role": "member",
+					"version": 1,
 					"password": "JDJhJDE0JFJDRHc1NGNHY0hNd1cySGJZdFZiOHV0ZUZ1d05jSU5xalBDYmFHM3hMNUszNGhrbmMzdGE2",
 					"created_at": "2022-06-20T22:14:22+09:00",
 					"updated_at": "2022-06-20T22:14:22+09:00",
@@ -230,6 +242,8 @@ var getUsersTestCases = []struct {
 					"last_name": "2",
 					"age": 37,
 					"email": "ishii@gmail.com",
+					"role": "member",
+					"version": 1,
 					"password": "JDJhJDE0JFJDRHc1NGNHY0hNd1cySGJZdFZiOHV0ZUZ1d05jSU5xalBDYmFHM3hMNUszNGhrbmMzdGE2",
 					"created_at": "2022-06-20T22:14:23+09:00",
 					"updated_at": "2022-06-20T22:14:23+09:00",
@@ -254,6 +268,8 @@ var getUsersTestCases = []struct {
 					"last_name": "1",
 					"age": 22,
 					"email": "test@gmail.com",
+					"role": "member",
+					"version": 1,
 					"password": "JDJhJDE0JFJDRHc1NGNHY0hNd1cySGJZdFZiOHV0ZUZ1d05jSU5xalBDYmFHM3hMNUszNGhrbmMzdGE2",
 					"created_at": "2022-06-20T22:14:22+09:00",
 					"updated_at": "2022-06-20T22:14:22+09:00",
@@ -316,6 +332,8 @@ var getUserDetailTestCases = []struct {
 			"last_name": "1",
 			"age": 22,
 			"email": "test@gmail.com",
+			"role": "member",
+			"version": 1,
 			"password": "JDJhJDE0JFJDRHc1NGNHY0hNd1cySGJZdFZiOHV0ZUZ1d05jSU5xalBDYmFHM3hMNUszNGhrbmMzdGE2",
 			"created_at": "2022-06-20T22:14:22+09:00",
 			"updated_at": "2022-06-20T22:14:22+09:00",
@@ -409,3 +427,119 @@ func TestDeleteUser(t *testing.T) {
 		})
 	}
 }
+
+func TestPatchUser(t *testing.T) {
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE users")
+	require.NoError(t, dbConn.Exec("insert into users (id, first_name, last_name, age, email, password, role, email_verified_at, two_factor_secret, two_factor_enabled_at, created_at, updated_at)values('090e142d-baa3-4039-9d21-cf5a1af39094','1','1',22,'test@gmail.com','$2a$14$RCDw54cGcHMwW2HbYtVb8uteFuwNcINqjPCbaG3xL5K34hknc3ta6','admin','2022-06-20 22:14:22','secret','2022-06-20 22:14:22','2022-06-20 22:14:22','2022-06-20 22:14:22');").Error)
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(func(_ *gin.Context) { binding.EnableDecoderUseNumber = true })
+	r.Use(middleware.NewTracing())
+	r.Use(middleware.NewLogging(zapLogger))
+	userHandler := NewUserHandler(dbConn, zapLogger)
+	r.PATCH("/users/:id", userHandler.PatchUser)
+
+	patch := func(body, ifMatch string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPatch, "/users/"+userIDForTest, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("編集できる項目のみを更新し、権限や2段階認証の設定は変更しないこと", func(t *testing.T) {
+		rec := patch(`{"first_name":"patched"}`, `"1"`)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+		var role, secret, firstName string
+		row := dbConn.Raw("SELECT role, two_factor_secret, first_name FROM users WHERE id = ?", userIDForTest).Row()
+		require.NoError(t, row.Scan(&role, &secret, &firstName))
+		assert.Equal(t, "admin", role)
+		assert.Equal(t, "secret", secret)
+		assert.Equal(t, "patched", firstName)
+	})
+
+	t.Run("他の操作でバージョンが進んだ後に古いETagで編集した場合412エラーになること", func(t *testing.T) {
+		// 権限の変更などの専用の操作もバージョンを進める
+		require.NoError(t, dbConn.Exec("UPDATE users SET role = 'member', version = version + 1 WHERE id = ?", userIDForTest).Error)
+		rec := patch(`{"first_name":"stale"}`, `"2"`)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+		var role, firstName string
+		row := dbConn.Raw("SELECT role, first_name FROM users WHERE id = ?", userIDForTest).Row()
+		require.NoError(t, row.Scan(&role, &firstName))
+		assert.Equal(t, "member", role)
+		assert.Equal(t, "patched", firstName)
+	})
+
+	t.Run("メールアドレスやパスワードを変更しようとした場合400エラーになること", func(t *testing.T) {
+		for _, body := range []string{`{"email":"changed@gmail.com"}`, `{"password":"cGxhaW50ZXh0"}`} {
+			rec := patch(body, "")
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		}
+
+		var email, password string
+		row := dbConn.Raw("SELECT email, password FROM users WHERE id = ?", userIDForTest).Row()
+		require.NoError(t, row.Scan(&email, &password))
+		assert.Equal(t, "test@gmail.com", email)
+		assert.Equal(t, "$2a$14$RCDw54cGcHMwW2HbYtVb8uteFuwNcINqjPCbaG3xL5K34hknc3ta6", password)
+	})
+}
+
+func TestUpdateUser(t *testing.T) {
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE users")
+	require.NoError(t, dbConn.Exec("insert into users (id, first_name, last_name, age, email, password, email_verified_at, created_at, updated_at)values('090e142d-baa3-4039-9d21-cf5a1af39094','1','1',22,'test@gmail.com','$2a$14$RCDw54cGcHMwW2HbYtVb8uteFuwNcINqjPCbaG3xL5K34hknc3ta6','2022-06-20 22:14:22','2022-06-20 22:14:22','2022-06-20 22:14:22');").Error)
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(func(_ *gin.Context) { binding.EnableDecoderUseNumber = true })
+	r.Use(middleware.NewTracing())
+	r.Use(middleware.NewLogging(zapLogger))
+	userHandler := NewUserHandler(dbConn, zapLogger)
+	r.PUT("/users/:id", userHandler.UpdateUser)
+
+	put := func(email, password string) *httptest.ResponseRecorder {
+		jsonStr, _ := json.Marshal(map[string]interface{}{
+			"first_name": "updated",
+			"last_name":  "1",
+			"age":        22,
+			"email":      email,
+			"password":   password,
+		})
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/users/"+userIDForTest, bytes.NewBuffer(jsonStr)))
+		return rec
+	}
+	// 詳細APIで返されるパスワードのハッシュ値(base64)
+	const currentPassword = "JDJhJDE0JFJDRHc1NGNHY0hNd1cySGJZdFZiOHV0ZUZ1d05jSU5xalBDYmFHM3hMNUszNGhrbmMzdGE2"
+
+	t.Run("パスワードを変更しようとした場合400エラーになり、平文のパスワードが保存されないこと", func(t *testing.T) {
+		rec := put("test@gmail.com", "cGxhaW50ZXh0")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"code":400,"message":"password cannot be changed, use the password reset","details":null}`, rec.Body.String())
+	})
+
+	t.Run("メールアドレスを変更しようとした場合400エラーになること", func(t *testing.T) {
+		rec := put("changed@gmail.com", currentPassword)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"code":400,"message":"email cannot be changed","details":null}`, rec.Body.String())
+	})
+
+	t.Run("メールアドレスとパスワードが現在と同じ場合は他の項目を更新できること", func(t *testing.T) {
+		rec := put("test@gmail.com", currentPassword)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		var firstName, password string
+		row := dbConn.Raw("SELECT first_name, password FROM users WHERE id = ?", userIDForTest).Row()
+		require.NoError(t, row.Scan(&firstName, &password))
+		assert.Equal(t, "updated", firstName)
+		assert.Equal(t, "$2a$14$RCDw54cGcHMwW2HbYtVb8uteFuwNcINqjPCbaG3xL5K34hknc3ta6", password)
+	})
+}
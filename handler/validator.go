@@ -173,6 +173,18 @@ func getAttribute(attribute string) string {
 		return "プロジェクトID"
 	case "ProjectTitle":
 		return "プロジェクト名"
+	case "MilestoneID":
+		return "マイルストーンID"
+	case "EpicID":
+		return "エピックID"
+	case "IssueStatus":
+		return "Issueステータス"
+	case "DueBefore":
+		return "期限"
+	case "Overdue":
+		return "期限切れ"
+	case "RecurrenceRule":
+		return "繰り返し"
 	case "CreatedAt":
 		return "作成日時"
 	case "UpdatedAt":
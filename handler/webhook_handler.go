@@ -0,0 +1,421 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+	"github.com/AI1411/golang-admin-api/webhook"
+)
+
+type WebhookHandler struct {
+	Db            *gorm.DB
+	logger        *zap.Logger
+	uuidGenerator models.UUIDGenerator
+	dispatcher    *webhook.Dispatcher
+}
+
+func NewWebhookHandler(db *gorm.DB, logger *zap.Logger, uuidGenerator models.UUIDGenerator,
+	dispatcher *webhook.Dispatcher,
+) *WebhookHandler {
+	return &WebhookHandler{
+		Db:            db,
+		logger:        logger,
+		uuidGenerator: uuidGenerator,
+		dispatcher:    dispatcher,
+	}
+}
+
+type webhookSubscriptionRequest struct {
+	URL        string   `json:"url" binding:"required,url,max=255" example:"https://example.com/webhooks"`
+	EventTypes []string `json:"event_types" binding:"required,min=1" example:"order.created"`
+	IsActive   *bool    `json:"is_active" example:"true"`
+}
+
+type webhookSubscriptionCreatedResponse struct {
+	models.WebhookSubscription
+	// Secret 作成時のみ返却される署名シークレット
+	Secret string `json:"secret" example:"whsec_xxxx"`
+}
+
+type webhookSubscriptionsResponse struct {
+	Total                int                          `json:"total"`
+	WebhookSubscriptions []models.WebhookSubscription `json:"webhook_subscriptions"`
+}
+
+type webhookDeliveriesResponse struct {
+	Total             int                      `json:"total"`
+	WebhookDeliveries []models.WebhookDelivery `json:"webhook_deliveries"`
+}
+
+type webhookDeliveryLogsResponse struct {
+	Total int                         `json:"total"`
+	Logs  []models.WebhookDeliveryLog `json:"logs"`
+}
+
+type searchWebhookDeliveryParams struct {
+	DeliveryStatus string `form:"delivery_status" binding:"omitempty,oneof=pending succeeded failed"`
+	EventType      string `form:"event_type" binding:"omitempty,max=64"`
+	Offset         string `form:"offset,default=0" binding:"omitempty,numeric"`
+	Limit          string `form:"limit,default=10" binding:"omitempty,numeric"`
+}
+
+// GetWebhookSubscriptions @title 一覧取得
+// @id GetWebhookSubscriptions
+// @tags webhooks
+// @version バージョン(1.0)
+// @description Webhook購読一覧を取得する
+// @Summary webhook購読一覧取得
+// @Produce json
+// @Success 200 {object} webhookSubscriptionsResponse
+// @Failure 500 {object} errorResponse
+// @Router /webhooks [GET]
+func (h *WebhookHandler) GetWebhookSubscriptions(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var subscriptions []models.WebhookSubscription
+	if err := tenantDB(ctx, h.Db).Order("created_at").Find(&subscriptions).Error; err != nil {
+		h.logger.Error("failed to get webhook subscriptions", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get webhook subscriptions", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, webhookSubscriptionsResponse{
+		Total:                len(subscriptions),
+		WebhookSubscriptions: subscriptions,
+	})
+}
+
+// CreateWebhookSubscription @title webhook購読作成
+// @id CreateWebhookSubscription
+// @tags webhooks
+// @version バージョン(1.0)
+// @description Webhookの送信先を登録する。署名シークレットは作成時のレスポンスでのみ返却される
+// @Summary webhook購読作成
+// @Produce json
+// @Success 201 {object} webhookSubscriptionCreatedResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /webhooks [POST]
+// @Accept json
+// @Param webhookSubscriptionRequest body webhookSubscriptionRequest true "create webhook subscription"
+func (h *WebhookHandler) CreateWebhookSubscription(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var req webhookSubscriptionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if !validEventTypes(req.EventTypes) {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid event_types"))
+		return
+	}
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		h.logger.Error("failed to generate webhook secret", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to create webhook subscription", err))
+		return
+	}
+
+	subscription := models.WebhookSubscription{
+		ID:         h.uuidGenerator.GenerateUUID(),
+		URL:        req.URL,
+		EventTypes: strings.Join(req.EventTypes, ","),
+		Secret:     secret,
+		IsActive:   req.IsActive == nil || *req.IsActive,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	if err := tenantDB(ctx, h.Db).Create(&subscription).Error; err != nil {
+		h.logger.Error("failed to create webhook subscription", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to create webhook subscription", err))
+		return
+	}
+	ctx.JSON(http.StatusCreated, webhookSubscriptionCreatedResponse{
+		WebhookSubscription: subscription,
+		Secret:              secret,
+	})
+}
+
+// UpdateWebhookSubscription @title webhook購読編集
+// @id UpdateWebhookSubscription
+// @tags webhooks
+// @version バージョン(1.0)
+// @description Webhookの送信先・購読イベント・有効フラグを編集する
+// @Summary webhook購読編集
+// @Produce json
+// @Success 202 {object} models.WebhookSubscription
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /webhooks/:id [PUT]
+// @Accept json
+// @Param webhookSubscriptionRequest body webhookSubscriptionRequest true "update webhook subscription"
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *WebhookHandler) UpdateWebhookSubscription(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var subscription models.WebhookSubscription
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&subscription).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("webhook subscription not found"))
+			return
+		}
+		h.logger.Error("failed to get webhook subscription", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get webhook subscription", err))
+		return
+	}
+	var req webhookSubscriptionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind json params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+	if !validEventTypes(req.EventTypes) {
+		ctx.JSON(http.StatusBadRequest, errors.NewBadRequestError("invalid event_types"))
+		return
+	}
+
+	subscription.URL = req.URL
+	subscription.EventTypes = strings.Join(req.EventTypes, ",")
+	if req.IsActive != nil {
+		subscription.IsActive = *req.IsActive
+	}
+	subscription.UpdatedAt = time.Now()
+	if err := tenantDB(ctx, h.Db).Save(&subscription).Error; err != nil {
+		h.logger.Error("failed to update webhook subscription", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to update webhook subscription", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, subscription)
+}
+
+// DeleteWebhookSubscription @title webhook購読削除
+// @id DeleteWebhookSubscription
+// @tags webhooks
+// @version バージョン(1.0)
+// @description Webhook購読を削除する。未配信の配信は送信せずに失敗とする
+// @Summary webhook購読削除
+// @Success 204
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /webhooks/:id [DELETE]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *WebhookHandler) DeleteWebhookSubscription(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var subscription models.WebhookSubscription
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&subscription).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("webhook subscription not found"))
+			return
+		}
+		h.logger.Error("failed to get webhook subscription", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get webhook subscription", err))
+		return
+	}
+	if err := tenantDB(ctx, h.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("webhook_deliveries").
+			Where("subscription_id = ? AND delivery_status = ?", subscription.ID, models.WebhookDeliveryStatusPending).
+			Updates(map[string]interface{}{
+				"delivery_status": models.WebhookDeliveryStatusFailed,
+				"next_attempt_at": nil,
+				"last_error":      "webhook subscription deleted",
+				"claim_token":     nil,
+				"claimed_until":   nil,
+				"updated_at":      time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&subscription).Error
+	}); err != nil {
+		h.logger.Error("failed to delete webhook subscription", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to delete webhook subscription", err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries @title webhook配信一覧
+// @id GetWebhookDeliveries
+// @tags webhooks
+// @version バージョン(1.0)
+// @description Webhook購読ごとの配信キューを新しい順に返す
+// @Summary webhook配信一覧取得
+// @Produce json
+// @Success 200 {object} webhookDeliveriesResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /webhooks/:id/deliveries [GET]
+// @Param id path string true "ID" minlength(36) maxlength(36) format(UUID v4)
+// @Param delivery_status query string false "配信ステータス" Enums(pending, succeeded, failed)
+// @Param event_type query string false "イベント種別"
+// @Param offset query int false "開始位置" default(0) minimum(0)
+// @Param limit query int false "取得上限" default(10) minimum(1) maximum(100)
+func (h *WebhookHandler) GetWebhookDeliveries(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var params searchWebhookDeliveryParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		res := createValidateErrorResponse(err)
+		res.outputErrorLog(h.logger, "failed to bind query params", traceID, err)
+		ctx.AbortWithStatusJSON(res.Code, res)
+		return
+	}
+
+	query := tenantDB(ctx, h.Db).Where("subscription_id = ?", ctx.Param("id")).Order("created_at desc")
+	if params.DeliveryStatus != "" {
+		query = query.Where("delivery_status = ?", params.DeliveryStatus)
+	}
+	if params.EventType != "" {
+		query = query.Where("event_type = ?", params.EventType)
+	}
+	if params.Offset != "" {
+		query = query.Offset(params.Offset)
+	}
+	if params.Limit != "" {
+		query = query.Limit(params.Limit)
+	}
+	var deliveries []models.WebhookDelivery
+	if err := query.Find(&deliveries).Error; err != nil {
+		h.logger.Error("failed to get webhook deliveries", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get webhook deliveries", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, webhookDeliveriesResponse{
+		Total:             len(deliveries),
+		WebhookDeliveries: deliveries,
+	})
+}
+
+// GetWebhookDeliveryLogs @title webhook配信ログ
+// @id GetWebhookDeliveryLogs
+// @tags webhooks
+// @version バージョン(1.0)
+// @description 配信ごとの送信試行ログを返す
+// @Summary webhook配信ログ取得
+// @Produce json
+// @Success 200 {object} webhookDeliveryLogsResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /webhookDeliveries/:id/logs [GET]
+// @Param id path string true "配信ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *WebhookHandler) GetWebhookDeliveryLogs(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	// ログのテーブルは組織で絞り込まれないため、先に配信が自組織のものか確認する
+	var delivery models.WebhookDelivery
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&delivery).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("webhook delivery not found"))
+			return
+		}
+		h.logger.Error("failed to get webhook delivery", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get webhook delivery", err))
+		return
+	}
+	var logs []models.WebhookDeliveryLog
+	if err := tenantDB(ctx, h.Db).Where("delivery_id = ?", delivery.ID).Order("attempt").Find(&logs).Error; err != nil {
+		h.logger.Error("failed to get webhook delivery logs", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get webhook delivery logs", err))
+		return
+	}
+	ctx.JSON(http.StatusOK, webhookDeliveryLogsResponse{
+		Total: len(logs),
+		Logs:  logs,
+	})
+}
+
+// ReplayWebhookDelivery @title webhook再送
+// @id ReplayWebhookDelivery
+// @tags webhooks
+// @version バージョン(1.0)
+// @description 過去の配信と同じペイロードで新しい配信を作成し、即時に送信する
+// @Summary webhook再送
+// @Produce json
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /webhookDeliveries/:id/replay [POST]
+// @Param id path string true "配信ID" minlength(36) maxlength(36) format(UUID v4)
+func (h *WebhookHandler) ReplayWebhookDelivery(ctx *gin.Context) {
+	traceID := appcontext.GetTraceID(ctx)
+	var original models.WebhookDelivery
+	if err := tenantDB(ctx, h.Db).Where("id = ?", ctx.Param("id")).First(&original).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			ctx.JSON(http.StatusNotFound, errors.NewNotFoundError("webhook delivery not found"))
+			return
+		}
+		h.logger.Error("failed to get webhook delivery", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get webhook delivery", err))
+		return
+	}
+
+	now := time.Now()
+	// すぐに送信するため、作成時に取得して配信のバッチから重複して送信されないようにする
+	claimToken := h.uuidGenerator.GenerateUUID()
+	claimedUntil := now.Add(webhook.ClaimTTL)
+	replay := models.WebhookDelivery{
+		ID:             h.uuidGenerator.GenerateUUID(),
+		SubscriptionID: original.SubscriptionID,
+		EventID:        original.EventID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		DeliveryStatus: models.WebhookDeliveryStatusPending,
+		NextAttemptAt:  &now,
+		ClaimToken:     &claimToken,
+		ClaimedUntil:   &claimedUntil,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := tenantDB(ctx, h.Db).Create(&replay).Error; err != nil {
+		h.logger.Error("failed to create webhook delivery", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to replay webhook delivery", err))
+		return
+	}
+	if err := h.dispatcher.Deliver(ctx, &replay); err != nil {
+		h.logger.Error("failed to deliver webhook", zap.Error(err),
+			zap.String("trace_id", traceID))
+		ctx.JSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to replay webhook delivery", err))
+		return
+	}
+	ctx.JSON(http.StatusAccepted, replay)
+}
+
+func validEventTypes(eventTypes []string) bool {
+	for _, t := range eventTypes {
+		if !webhook.IsValidEventType(t) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/middleware"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/webhook"
+)
+
+func TestDeleteWebhookSubscription(t *testing.T) {
+	const subscriptionID = "7dc41179-824e-4b8a-b894-2082ca5eac5b"
+	const deliveryID = "218c51c0-904e-4743-a2ae-94f0e34a0d6f"
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE webhook_subscriptions")
+	dbConn.Exec("TRUNCATE TABLE webhook_deliveries")
+	dbConn.Exec("TRUNCATE TABLE webhook_delivery_logs")
+	require.NoError(t, dbConn.Exec("INSERT INTO webhook_subscriptions (id, url, event_types, secret, is_active) VALUES (?, 'http://127.0.0.1:1', 'order.created', 'secret', true)",
+		subscriptionID).Error)
+	require.NoError(t, dbConn.Exec("INSERT INTO webhook_deliveries (id, subscription_id, event_id, event_type, payload, delivery_status, attempts, next_attempt_at)"+
+		" VALUES (?, ?, 'event', 'order.created', '{}', 'pending', 0, ?)", deliveryID, subscriptionID, time.Now().Add(-time.Minute)).Error)
+
+	r := gin.New()
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	r.Use(middleware.NewTracing())
+	dispatcher := webhook.NewDispatcher(dbConn, http.DefaultClient, zapLogger)
+	webhookHandler := NewWebhookHandler(dbConn, zapLogger, &models.RandomUUIDGenerator{}, dispatcher)
+	r.DELETE("/webhooks/:id", webhookHandler.DeleteWebhookSubscription)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/webhooks/"+subscriptionID, nil))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	var delivery models.WebhookDelivery
+	require.NoError(t, dbConn.Where("id = ?", deliveryID).First(&delivery).Error)
+	assert.Equal(t, models.WebhookDeliveryStatusFailed, delivery.DeliveryStatus)
+	assert.Equal(t, "webhook subscription deleted", delivery.LastError)
+	assert.Nil(t, delivery.NextAttemptAt)
+
+	// 未配信の配信が残らないため、配信のバッチは何も送信せずに終わる
+	count, err := dispatcher.DeliverDue(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestGetWebhookDeliveryLogs(t *testing.T) {
+	const deliveryID = "218c51c0-904e-4743-a2ae-94f0e34a0d6f"
+	dbConn := db.Init()
+	dbConn.Exec("TRUNCATE TABLE webhook_deliveries")
+	dbConn.Exec("TRUNCATE TABLE webhook_delivery_logs")
+	require.NoError(t, dbConn.Exec("INSERT INTO webhook_deliveries (id, organization_id, subscription_id, event_id, event_type, payload, delivery_status, attempts)"+
+		" VALUES (?, ?, '7dc41179-824e-4b8a-b894-2082ca5eac5b', 'event', 'order.created', '{}', 'succeeded', 1)", deliveryID, organizationAIDForTest).Error)
+	require.NoError(t, dbConn.Exec("INSERT INTO webhook_delivery_logs (delivery_id, attempt, status_code, response_body, duration_ms) VALUES (?, 1, 200, 'ok', 10)",
+		deliveryID).Error)
+
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	dispatcher := webhook.NewDispatcher(dbConn, http.DefaultClient, zapLogger)
+	webhookHandler := NewWebhookHandler(dbConn, zapLogger, &models.RandomUUIDGenerator{}, dispatcher)
+	getLogs := func(organizationID string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.Use(middleware.NewTracing())
+		r.Use(func(ctx *gin.Context) { appcontext.SetOrganizationIDIntoContext(ctx, organizationID) })
+		r.GET("/webhookDeliveries/:id/logs", webhookHandler.GetWebhookDeliveryLogs)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/webhookDeliveries/"+deliveryID+"/logs", nil))
+		return rec
+	}
+
+	t.Run("自組織の配信のログを返すこと", func(t *testing.T) {
+		rec := getLogs(organizationAIDForTest)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var res webhookDeliveryLogsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, 1, res.Total)
+	})
+
+	t.Run("他の組織の配信のログは404を返すこと", func(t *testing.T) {
+		rec := getLogs(organizationBIDForTest)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"message": "webhook delivery not found","status": 404,"error": "not_found","causes": null}`, rec.Body.String())
+	})
+}
@@ -0,0 +1,153 @@
+// Package ical RFC 5545 形式のカレンダーの出力と取り込みを行う
+// 出力するのはVEVENTとVTODOの基本的なプロパティのみで、繰り返しやタイムゾーン定義は扱わない
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405"
+	// 1行の最大オクテット数。超える場合は折り返す
+	maxLineOctets = 75
+)
+
+const (
+	TodoStatusNeedsAction = "NEEDS-ACTION"
+	TodoStatusInProcess   = "IN-PROCESS"
+	TodoStatusCompleted   = "COMPLETED"
+	TodoStatusCancelled   = "CANCELLED"
+)
+
+// Event VEVENT。AllDayの場合は日付のみで出力し、Endは翌日とする
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	LastModified time.Time
+}
+
+// Todo VTODO
+type Todo struct {
+	UID          string
+	Summary      string
+	Description  string
+	Due          *time.Time
+	Status       string
+	LastModified time.Time
+}
+
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+	Todos  []Todo
+}
+
+// Encode カレンダーをCRLF区切りで書き出す。DTSTAMPにはnowを使う
+func (c *Calendar) Encode(w io.Writer, now time.Time) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", c.ProdID)
+	e.line("CALSCALE", "GREGORIAN")
+	e.line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		e.line("X-WR-CALNAME", escapeText(c.Name))
+	}
+	for _, ev := range c.Events {
+		e.line("BEGIN", "VEVENT")
+		e.line("UID", ev.UID)
+		e.line("DTSTAMP", formatUTC(now))
+		if ev.AllDay {
+			end := ev.End
+			if !end.After(ev.Start) {
+				end = ev.Start.AddDate(0, 0, 1)
+			}
+			e.line("DTSTART;VALUE=DATE", ev.Start.Format(dateFormat))
+			e.line("DTEND;VALUE=DATE", end.Format(dateFormat))
+		} else {
+			e.line("DTSTART", formatUTC(ev.Start))
+			if !ev.End.IsZero() {
+				e.line("DTEND", formatUTC(ev.End))
+			}
+		}
+		e.line("SUMMARY", escapeText(ev.Summary))
+		if ev.Description != "" {
+			e.line("DESCRIPTION", escapeText(ev.Description))
+		}
+		if !ev.LastModified.IsZero() {
+			e.line("LAST-MODIFIED", formatUTC(ev.LastModified))
+		}
+		e.line("END", "VEVENT")
+	}
+	for _, td := range c.Todos {
+		e.line("BEGIN", "VTODO")
+		e.line("UID", td.UID)
+		e.line("DTSTAMP", formatUTC(now))
+		if td.Due != nil {
+			e.line("DUE", formatUTC(*td.Due))
+		}
+		e.line("SUMMARY", escapeText(td.Summary))
+		if td.Description != "" {
+			e.line("DESCRIPTION", escapeText(td.Description))
+		}
+		if td.Status != "" {
+			e.line("STATUS", td.Status)
+		}
+		if !td.LastModified.IsZero() {
+			e.line("LAST-MODIFIED", formatUTC(td.LastModified))
+		}
+		e.line("END", "VTODO")
+	}
+	e.line("END", "VCALENDAR")
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line 75オクテットを超える行は、マルチバイト文字の途中で切らないように折り返す
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+	s := name + ":" + value
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if _, e.err = e.w.WriteString(s[:cut] + "\r\n "); e.err != nil {
+			return
+		}
+		s = s[cut:]
+		// 継続行は先頭の空白も1オクテットとして数える
+		limit = maxLineOctets - 1
+	}
+	_, e.err = e.w.WriteString(s + "\r\n")
+}
+
+func formatUTC(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat) + "Z"
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
@@ -0,0 +1,142 @@
+package ical_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/ical"
+)
+
+func TestCalendar_Encode(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 9, 23, 10, 0, 0, 0, time.UTC)
+	due := time.Date(2022, 9, 30, 18, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+	cal := &ical.Calendar{
+		ProdID: "-//golang-admin-api//calendar//JA",
+		Name:   "テスト",
+		Events: []ical.Event{
+			{
+				UID:     "milestone-1@golang-admin-api",
+				Summary: "リリース; v1.0, 正式版",
+				Start:   time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC),
+				AllDay:  true,
+			},
+		},
+		Todos: []ical.Todo{
+			{
+				UID:         "todo-1@golang-admin-api",
+				Summary:     "週報",
+				Description: strings.Repeat("あ", 40) + "\n2行目",
+				Due:         &due,
+				Status:      ical.TodoStatusNeedsAction,
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, cal.Encode(&buf, now))
+	out := buf.String()
+
+	t.Run("必須のプロパティが出力されること", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+		assert.True(t, strings.HasSuffix(out, "END:VCALENDAR\r\n"))
+		assert.Contains(t, out, "DTSTAMP:20220923T100000Z\r\n")
+	})
+
+	t.Run("終日の予定は日付のみで翌日が終了日になること", func(t *testing.T) {
+		t.Parallel()
+		assert.Contains(t, out, "DTSTART;VALUE=DATE:20221001\r\nDTEND;VALUE=DATE:20221002\r\n")
+	})
+
+	t.Run("日時はUTCで出力されること", func(t *testing.T) {
+		t.Parallel()
+		assert.Contains(t, out, "DUE:20220930T090000Z\r\n")
+	})
+
+	t.Run("テキストがエスケープされること", func(t *testing.T) {
+		t.Parallel()
+		assert.Contains(t, out, `SUMMARY:リリース\; v1.0\, 正式版`)
+	})
+
+	t.Run("75オクテットを超える行が折り返されること", func(t *testing.T) {
+		t.Parallel()
+		for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+			assert.LessOrEqual(t, len(line), 75)
+		}
+		assert.Contains(t, out, "\r\n ")
+	})
+
+	t.Run("出力した内容を読み込めること", func(t *testing.T) {
+		t.Parallel()
+		parsed, err := ical.Parse(strings.NewReader(out), time.UTC)
+		require.NoError(t, err)
+		require.Len(t, parsed.Todos, 1)
+		assert.Equal(t, "週報", parsed.Todos[0].Summary)
+		assert.Equal(t, cal.Todos[0].Description, parsed.Todos[0].Description)
+		assert.True(t, due.Equal(*parsed.Todos[0].Due))
+		require.Len(t, parsed.Events, 1)
+		assert.Equal(t, "リリース; v1.0, 正式版", parsed.Events[0].Summary)
+		assert.True(t, parsed.Events[0].AllDay)
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	jst, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	t.Run("TZID付きの日時と入れ子のコンポーネントを読み込めること", func(t *testing.T) {
+		t.Parallel()
+		src := "BEGIN:VCALENDAR\n" +
+			"VERSION:2.0\n" +
+			"BEGIN:VEVENT\n" +
+			"UID:1\n" +
+			"DTSTART;TZID=\"Asia/Tokyo\":20221001T100000\n" +
+			"SUMMARY:定例\n" +
+			" ミーティング\n" +
+			"BEGIN:VALARM\n" +
+			"TRIGGER:-PT15M\n" +
+			"END:VALARM\n" +
+			"END:VEVENT\n" +
+			"BEGIN:VTODO\n" +
+			"SUMMARY:期限なし\n" +
+			"STATUS:completed\n" +
+			"END:VTODO\n" +
+			"END:VCALENDAR\n"
+		cal, err := ical.Parse(strings.NewReader(src), time.UTC)
+		require.NoError(t, err)
+		require.Len(t, cal.Events, 1)
+		assert.Equal(t, "定例ミーティング", cal.Events[0].Summary)
+		assert.True(t, time.Date(2022, 10, 1, 10, 0, 0, 0, jst).Equal(cal.Events[0].Start))
+		require.Len(t, cal.Todos, 1)
+		assert.Nil(t, cal.Todos[0].Due)
+		assert.Equal(t, ical.TodoStatusCompleted, cal.Todos[0].Status)
+	})
+
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{name: "空の場合はエラーになること", src: ""},
+		{name: "ENDが対応していない場合はエラーになること", src: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR\n"},
+		{name: "閉じられていない場合はエラーになること", src: "BEGIN:VCALENDAR\n"},
+		{name: "不正な日時の場合はエラーになること", src: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:2022-10-01\nEND:VEVENT\nEND:VCALENDAR\n"},
+		{name: "プロパティの形式が不正な場合はエラーになること", src: "BEGIN:VCALENDAR\nSUMMARY\nEND:VCALENDAR\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ical.Parse(strings.NewReader(tt.src), time.UTC)
+			assert.ErrorIs(t, err, ical.ErrInvalidCalendar)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCalendar VCALENDARとして読み込めない
+var ErrInvalidCalendar = errors.New("invalid icalendar")
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse VCALENDARに含まれるVEVENTとVTODOを読み込む
+// TZIDの無い日時はlocのタイムゾーンとして扱う
+func Parse(r io.Reader, loc *time.Location) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cal := &Calendar{}
+	// コンポーネントの入れ子ごとにプロパティを保持する
+	var stack []string
+	var props [][]property
+	for i, line := range lines {
+		p, ok := parseProperty(line)
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d", ErrInvalidCalendar, i+1)
+		}
+		switch p.name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(p.value))
+			props = append(props, nil)
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(p.value) {
+				return nil, fmt.Errorf("%w: unexpected END:%s", ErrInvalidCalendar, p.value)
+			}
+			if err := cal.addComponent(stack, props[len(props)-1], loc); err != nil {
+				return nil, err
+			}
+			stack = stack[:len(stack)-1]
+			props = props[:len(props)-1]
+			continue
+		}
+		if len(stack) == 0 {
+			return nil, fmt.Errorf("%w: property outside of VCALENDAR", ErrInvalidCalendar)
+		}
+		props[len(props)-1] = append(props[len(props)-1], p)
+	}
+	if len(stack) != 0 || len(lines) == 0 {
+		return nil, ErrInvalidCalendar
+	}
+	return cal, nil
+}
+
+// addComponent VCALENDAR直下のVEVENT・VTODOのみ取り込み、VALARMなどの入れ子は無視する
+func (c *Calendar) addComponent(stack []string, props []property, loc *time.Location) error {
+	if len(stack) != 2 || stack[0] != "VCALENDAR" {
+		return nil
+	}
+	switch stack[1] {
+	case "VEVENT":
+		var ev Event
+		for _, p := range props {
+			var err error
+			switch p.name {
+			case "UID":
+				ev.UID = p.value
+			case "SUMMARY":
+				ev.Summary = unescapeText(p.value)
+			case "DESCRIPTION":
+				ev.Description = unescapeText(p.value)
+			case "DTSTART":
+				ev.Start, ev.AllDay, err = parseTime(p, loc)
+			case "DTEND":
+				ev.End, _, err = parseTime(p, loc)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if ev.Start.IsZero() {
+			return fmt.Errorf("%w: VEVENT without DTSTART", ErrInvalidCalendar)
+		}
+		c.Events = append(c.Events, ev)
+	case "VTODO":
+		var td Todo
+		for _, p := range props {
+			switch p.name {
+			case "UID":
+				td.UID = p.value
+			case "SUMMARY":
+				td.Summary = unescapeText(p.value)
+			case "DESCRIPTION":
+				td.Description = unescapeText(p.value)
+			case "STATUS":
+				td.Status = strings.ToUpper(p.value)
+			case "DUE":
+				due, _, err := parseTime(p, loc)
+				if err != nil {
+					return err
+				}
+				td.Due = &due
+			}
+		}
+		c.Todos = append(c.Todos, td)
+	}
+	return nil
+}
+
+// unfold 空白またはタブで始まる継続行を前の行に連結する
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// parseProperty NAME;PARAM=VALUE:VALUE の形式を分解する
+// パラメータの値はダブルクォートで囲まれている場合があり、その中の:と;は区切りとして扱わない
+func parseProperty(line string) (property, bool) {
+	p := property{params: map[string]string{}}
+	inQuote := false
+	start := 0
+	var key string
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case c == ';' || c == ':':
+			token := line[start:i]
+			if p.name == "" {
+				p.name = strings.ToUpper(token)
+			} else if key != "" {
+				p.params[key] = strings.Trim(token, `"`)
+			}
+			key = ""
+			start = i + 1
+			if c == ':' {
+				p.value = line[i+1:]
+				return p, p.name != ""
+			}
+		case c == '=' && p.name != "" && key == "":
+			key = strings.ToUpper(line[start:i])
+			start = i + 1
+		}
+	}
+	return p, false
+}
+
+// parseTime UTC(末尾Z)、TZID指定、日付のみ(VALUE=DATE)の形式を読み込む
+func parseTime(p property, loc *time.Location) (time.Time, bool, error) {
+	if p.params["VALUE"] == "DATE" || len(p.value) == len(dateFormat) {
+		t, err := time.ParseInLocation(dateFormat, p.value, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: %s", ErrInvalidCalendar, p.name)
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		t, err := time.Parse(dateTimeFormat, strings.TrimSuffix(p.value, "Z"))
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: %s", ErrInvalidCalendar, p.name)
+		}
+		return t, false, nil
+	}
+	if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(dateTimeFormat, p.value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: %s", ErrInvalidCalendar, p.name)
+	}
+	return t, false, nil
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
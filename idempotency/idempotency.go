@@ -0,0 +1,296 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+)
+
+const keyPrefix = "idempotency:"
+
+var (
+	// ErrConflict 同じキーで異なる内容のリクエストが送られた
+	ErrConflict = errors.New("idempotency key reused with a different request")
+	// ErrInProgress 同じキーのリクエストを処理中
+	ErrInProgress = errors.New("request with the same idempotency key is in progress")
+	// ErrLockLost 処理中のロックが期限切れなどで失われ、他のリクエストが同じキーを処理している可能性がある
+	ErrLockLost = errors.New("idempotency lock was lost")
+)
+
+// Record キーごとに保存するリクエストの指紋と処理結果
+type Record struct {
+	Fingerprint string `json:"fingerprint"`
+	// Completed falseの間は処理中
+	Completed bool `json:"completed"`
+	// LockToken 処理中のロックを持つリクエストの識別子。処理結果を保存すると空になる
+	LockToken  string      `json:"lock_token,omitempty"`
+	StatusCode int         `json:"status_code,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+// Fingerprint 同じキーで送られたリクエストが同じ内容かを比べるための値
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Store キーと処理結果の保存先
+// Extend・Complete・Releaseは、キーがtokenのロックで処理中のままの場合のみ行い、そうでなければErrLockLostを返す
+type Store interface {
+	// Begin キーが無ければtokenのロックで処理中として保存してnilを返す。キーがあれば保存済みの記録を返す
+	Begin(ctx context.Context, key, fingerprint, token string, lockTTL time.Duration) (*Record, error)
+	// Extend 処理中の期限をlockTTL後に延ばす
+	Extend(ctx context.Context, key, token string, lockTTL time.Duration) error
+	// Complete 処理結果を保存し、ttlの間は同じキーのリクエストに返す
+	Complete(ctx context.Context, key, token string, record *Record, ttl time.Duration) error
+	// Release 処理に失敗したキーを削除し、再試行できるようにする
+	Release(ctx context.Context, key, token string) error
+}
+
+// Lock Beginで取得した処理中のロック
+type Lock struct {
+	key   string
+	token string
+}
+
+// Keeper 処理中のロックと処理結果の有効期限を管理する
+type Keeper struct {
+	store Store
+	// lockTTL 処理中のまま止まったサーバーがキーを塞ぎ続けないよう、処理中の記録はこの時間で消える
+	lockTTL time.Duration
+	ttl     time.Duration
+}
+
+func NewKeeper(store Store, lockTTL, ttl time.Duration) *Keeper {
+	return &Keeper{store: store, lockTTL: lockTTL, ttl: ttl}
+}
+
+// Begin キーの処理を始め、処理中のロックを返す。処理済みの場合は保存した記録を返す
+// 異なる内容のリクエストの場合はErrConflictを、処理中の場合はErrInProgressを返す
+func (k *Keeper) Begin(ctx context.Context, key, fingerprint string) (*Record, *Lock, error) {
+	token, err := uuid.NewRandom()
+	if err != nil {
+		return nil, nil, err
+	}
+	lock := &Lock{key: keyPrefix + key, token: token.String()}
+	record, err := k.store.Begin(ctx, lock.key, fingerprint, lock.token, k.lockTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if record == nil {
+		return nil, lock, nil
+	}
+	if record.Fingerprint != fingerprint {
+		return nil, nil, ErrConflict
+	}
+	if !record.Completed {
+		return nil, nil, ErrInProgress
+	}
+	return record, nil, nil
+}
+
+// Hold 処理中のロックが期限切れにならないよう、stopを呼ぶまで一定の間隔で期限を延ばす
+// 処理がlockTTLより長くかかっても、同じキーの再試行を処理中として扱い続ける
+func (k *Keeper) Hold(ctx context.Context, lock *Lock) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(k.lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// ロックを失った場合はCompleteでErrLockLostになるため、ここでは延長をやめるだけにする
+				if err := k.store.Extend(ctx, lock.key, lock.token, k.lockTTL); errors.Is(err, ErrLockLost) {
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// Complete 処理結果を保存する。ロックを失っていた場合は他のリクエストの結果を上書きせずErrLockLostを返す
+func (k *Keeper) Complete(ctx context.Context, lock *Lock, record *Record) error {
+	record.Completed = true
+	record.LockToken = ""
+	return k.store.Complete(ctx, lock.key, lock.token, record, k.ttl)
+}
+
+// Release 処理に失敗したキーを削除する。ロックを失っていた場合はErrLockLostを返す
+func (k *Keeper) Release(ctx context.Context, lock *Lock) error {
+	return k.store.Release(ctx, lock.key, lock.token)
+}
+
+// RedisStore 複数のサーバーで処理中のキーと処理結果を共有する
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// lockedScript 処理中の記録がARGV[1]のロックのままであれば、続く処理を行う
+const lockedScript = `
+local value = redis.call('GET', KEYS[1])
+if not value then
+  return 0
+end
+local record = cjson.decode(value)
+if record.completed or record.lock_token ~= ARGV[1] then
+  return 0
+end
+`
+
+var (
+	extendScript   = redis.NewScript(lockedScript + `redis.call('PEXPIRE', KEYS[1], ARGV[2]) return 1`)
+	completeScript = redis.NewScript(lockedScript + `redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3]) return 1`)
+	releaseScript  = redis.NewScript(lockedScript + `redis.call('DEL', KEYS[1]) return 1`)
+)
+
+func (s *RedisStore) Begin(ctx context.Context, key, fingerprint, token string, lockTTL time.Duration) (*Record, error) {
+	value, err := json.Marshal(Record{Fingerprint: fingerprint, LockToken: token})
+	if err != nil {
+		return nil, err
+	}
+	// SETNXに失敗した直後にキーが期限切れになる場合があるため、取得できなければやり直す
+	for i := 0; i < 3; i++ {
+		ok, err := s.client.SetNX(ctx, key, value, lockTTL).Result()
+		if err != nil || ok {
+			return nil, err
+		}
+		stored, err := s.client.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var record Record
+		if err := json.Unmarshal(stored, &record); err != nil {
+			return nil, err
+		}
+		return &record, nil
+	}
+	return &Record{Fingerprint: fingerprint}, nil
+}
+
+func (s *RedisStore) Extend(ctx context.Context, key, token string, lockTTL time.Duration) error {
+	return runLocked(ctx, s.client, extendScript, key, token, lockTTL.Milliseconds())
+}
+
+func (s *RedisStore) Complete(ctx context.Context, key, token string, record *Record, ttl time.Duration) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return runLocked(ctx, s.client, completeScript, key, token, value, ttl.Milliseconds())
+}
+
+func (s *RedisStore) Release(ctx context.Context, key, token string) error {
+	return runLocked(ctx, s.client, releaseScript, key, token)
+}
+
+func runLocked(ctx context.Context, client *redis.Client, script *redis.Script, key, token string, args ...interface{}) error {
+	ok, err := script.Run(ctx, client, []string{key}, append([]interface{}{token}, args...)...).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// MemoryStore テストやローカル実行用のインメモリの保存先
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]memoryRecord
+	now     func() time.Time
+}
+
+type memoryRecord struct {
+	record    Record
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithClock(time.Now)
+}
+
+func NewMemoryStoreWithClock(now func() time.Time) *MemoryStore {
+	return &MemoryStore{records: map[string]memoryRecord{}, now: now}
+}
+
+func (s *MemoryStore) Begin(_ context.Context, key, fingerprint, token string, lockTTL time.Duration) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.records[key]; ok && s.now().Before(stored.expiresAt) {
+		record := stored.record
+		return &record, nil
+	}
+	s.records[key] = memoryRecord{
+		record:    Record{Fingerprint: fingerprint, LockToken: token},
+		expiresAt: s.now().Add(lockTTL),
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) Extend(_ context.Context, key, token string, lockTTL time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.locked(key, token)
+	if !ok {
+		return ErrLockLost
+	}
+	stored.expiresAt = s.now().Add(lockTTL)
+	s.records[key] = stored
+	return nil
+}
+
+func (s *MemoryStore) Complete(_ context.Context, key, token string, record *Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.locked(key, token); !ok {
+		return ErrLockLost
+	}
+	s.records[key] = memoryRecord{record: *record, expiresAt: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.locked(key, token); !ok {
+		return ErrLockLost
+	}
+	delete(s.records, key)
+	return nil
+}
+
+// locked キーが期限内でtokenのロックで処理中のままであれば、その記録を返す
+func (s *MemoryStore) locked(key, token string) (memoryRecord, bool) {
+	stored, ok := s.records[key]
+	if !ok || !s.now().Before(stored.expiresAt) || stored.record.Completed || stored.record.LockToken != token {
+		return memoryRecord{}, false
+	}
+	return stored, true
+}
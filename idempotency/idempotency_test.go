@@ -0,0 +1,194 @@
+package idempotency_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/idempotency"
+)
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newKeeper(c *clock) *idempotency.Keeper {
+	return idempotency.NewKeeper(idempotency.NewMemoryStoreWithClock(c.Now), time.Minute, 24*time.Hour)
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	t.Run("同じリクエストは同じ値になること", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t,
+			idempotency.Fingerprint(http.MethodPost, "/orders", []byte(`{"quantity":1}`)),
+			idempotency.Fingerprint(http.MethodPost, "/orders", []byte(`{"quantity":1}`)))
+	})
+
+	t.Run("パスまたは本文が異なる場合は異なる値になること", func(t *testing.T) {
+		t.Parallel()
+		base := idempotency.Fingerprint(http.MethodPost, "/orders", []byte(`{"quantity":1}`))
+		assert.NotEqual(t, base, idempotency.Fingerprint(http.MethodPost, "/coupons/acquire", []byte(`{"quantity":1}`)))
+		assert.NotEqual(t, base, idempotency.Fingerprint(http.MethodPost, "/orders", []byte(`{"quantity":2}`)))
+	})
+}
+
+func TestKeeper(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("処理結果を保存し、再試行に返すこと", func(t *testing.T) {
+		t.Parallel()
+		keeper := newKeeper(&clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)})
+
+		record, lock, err := keeper.Begin(ctx, "user:a:key", "fp")
+		require.NoError(t, err)
+		require.Nil(t, record)
+		require.NotNil(t, lock)
+		require.NoError(t, keeper.Complete(ctx, lock, &idempotency.Record{
+			Fingerprint: "fp",
+			StatusCode:  http.StatusCreated,
+			Header:      http.Header{"Content-Type": []string{"application/json"}},
+			Body:        []byte(`{"id":"1"}`),
+		}))
+
+		record, lock, err = keeper.Begin(ctx, "user:a:key", "fp")
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Nil(t, lock)
+		assert.True(t, record.Completed)
+		assert.Equal(t, http.StatusCreated, record.StatusCode)
+		assert.Equal(t, `{"id":"1"}`, string(record.Body))
+	})
+
+	t.Run("同じキーで異なる内容の場合はErrConflictを返すこと", func(t *testing.T) {
+		t.Parallel()
+		keeper := newKeeper(&clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)})
+		_, lock, err := keeper.Begin(ctx, "user:a:key", "fp")
+		require.NoError(t, err)
+		require.NoError(t, keeper.Complete(ctx, lock, &idempotency.Record{Fingerprint: "fp", StatusCode: http.StatusCreated}))
+
+		_, _, err = keeper.Begin(ctx, "user:a:key", "other")
+		assert.ErrorIs(t, err, idempotency.ErrConflict)
+	})
+
+	t.Run("同時に送られた同じキーのリクエストは1つだけ処理されること", func(t *testing.T) {
+		t.Parallel()
+		keeper := newKeeper(&clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)})
+
+		var wg sync.WaitGroup
+		results := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, err := keeper.Begin(ctx, "user:a:key", "fp")
+				results <- err
+			}()
+		}
+		wg.Wait()
+		close(results)
+		var started, inProgress int
+		for err := range results {
+			switch err {
+			case nil:
+				started++
+			case idempotency.ErrInProgress:
+				inProgress++
+			}
+		}
+		assert.Equal(t, 1, started)
+		assert.Equal(t, 9, inProgress)
+	})
+
+	t.Run("解放したキーや処理中のまま期限が切れたキーは再試行できること", func(t *testing.T) {
+		t.Parallel()
+		c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+		keeper := newKeeper(c)
+
+		_, lock, err := keeper.Begin(ctx, "user:a:released", "fp")
+		require.NoError(t, err)
+		require.NoError(t, keeper.Release(ctx, lock))
+		record, _, err := keeper.Begin(ctx, "user:a:released", "fp")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+
+		_, _, err = keeper.Begin(ctx, "user:a:stuck", "fp")
+		require.NoError(t, err)
+		c.Add(time.Minute)
+		record, _, err = keeper.Begin(ctx, "user:a:stuck", "fp")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("保存期間を過ぎた処理結果は返さないこと", func(t *testing.T) {
+		t.Parallel()
+		c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+		keeper := newKeeper(c)
+		_, lock, err := keeper.Begin(ctx, "user:a:key", "fp")
+		require.NoError(t, err)
+		require.NoError(t, keeper.Complete(ctx, lock, &idempotency.Record{Fingerprint: "fp", StatusCode: http.StatusCreated}))
+
+		c.Add(24 * time.Hour)
+		record, _, err := keeper.Begin(ctx, "user:a:key", "other")
+		require.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("ロックの期限が切れて他のリクエストが処理を始めた場合は、処理結果を上書きしないこと", func(t *testing.T) {
+		t.Parallel()
+		c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+		keeper := newKeeper(c)
+		_, stale, err := keeper.Begin(ctx, "user:a:key", "fp")
+		require.NoError(t, err)
+		c.Add(time.Minute)
+		_, current, err := keeper.Begin(ctx, "user:a:key", "fp")
+		require.NoError(t, err)
+		require.NotNil(t, current)
+
+		err = keeper.Complete(ctx, stale, &idempotency.Record{Fingerprint: "fp", StatusCode: http.StatusCreated})
+		assert.ErrorIs(t, err, idempotency.ErrLockLost)
+		assert.ErrorIs(t, keeper.Release(ctx, stale), idempotency.ErrLockLost)
+		_, _, err = keeper.Begin(ctx, "user:a:key", "fp")
+		assert.ErrorIs(t, err, idempotency.ErrInProgress)
+
+		require.NoError(t, keeper.Complete(ctx, current, &idempotency.Record{Fingerprint: "fp", StatusCode: http.StatusAccepted}))
+		record, _, err := keeper.Begin(ctx, "user:a:key", "fp")
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, http.StatusAccepted, record.StatusCode)
+	})
+
+	t.Run("Holdしている間はロックの期限を過ぎても処理中として扱うこと", func(t *testing.T) {
+		t.Parallel()
+		keeper := idempotency.NewKeeper(idempotency.NewMemoryStore(), 60*time.Millisecond, time.Minute)
+		_, lock, err := keeper.Begin(ctx, "user:a:key", "fp")
+		require.NoError(t, err)
+		stop := keeper.Hold(ctx, lock)
+		time.Sleep(200 * time.Millisecond)
+
+		_, _, err = keeper.Begin(ctx, "user:a:key", "fp")
+		assert.ErrorIs(t, err, idempotency.ErrInProgress)
+		stop()
+		require.NoError(t, keeper.Complete(ctx, lock, &idempotency.Record{Fingerprint: "fp", StatusCode: http.StatusCreated}))
+	})
+}
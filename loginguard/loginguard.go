@@ -0,0 +1,160 @@
+package loginguard
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const keyPrefix = "loginguard:"
+
+// Policy 失敗回数の上限とロック・遅延の設定
+type Policy struct {
+	// MaxAccountFailures Window内にこの回数失敗したアカウントをロックする
+	MaxAccountFailures int64
+	// MaxIPFailures Window内にこの回数失敗したIPアドレスをロックする。複数のアカウントを狙う攻撃に備える
+	MaxIPFailures   int64
+	Window          time.Duration
+	LockoutDuration time.Duration
+	// BaseDelay 1回目の失敗後の遅延。失敗するごとに倍になり、MaxDelayで頭打ちになる
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+var DefaultPolicy = Policy{
+	MaxAccountFailures: 5,
+	MaxIPFailures:      20,
+	Window:             15 * time.Minute,
+	LockoutDuration:    15 * time.Minute,
+	BaseDelay:          250 * time.Millisecond,
+	MaxDelay:           4 * time.Second,
+}
+
+// Decision ログインを試行してよいかの判定結果
+type Decision struct {
+	// Locked アカウントまたはIPアドレスがロックされている
+	Locked bool
+	// RetryAfter ロックが解除されるまでの時間
+	RetryAfter time.Duration
+	// Delay パスワードを検証する前に待つ時間
+	Delay time.Duration
+}
+
+// Guard アカウントとIPアドレスごとにログインの失敗を数え、遅延とロックを判定する
+// アカウントは存在しないメールアドレスも同じように数え、応答からアカウントの有無が分からないようにする
+type Guard struct {
+	store  Store
+	policy Policy
+}
+
+func New(store Store, policy Policy) *Guard {
+	return &Guard{store: store, policy: policy}
+}
+
+// Check ログインを試行する前に呼び出す
+func (g *Guard) Check(ctx context.Context, email, ip string) (Decision, error) {
+	retryAfter := time.Duration(0)
+	for _, key := range []string{lockKey("account", email), lockKey("ip", ip)} {
+		ttl, err := g.store.TTL(ctx, key)
+		if err != nil {
+			return Decision{}, err
+		}
+		if ttl > retryAfter {
+			retryAfter = ttl
+		}
+	}
+	if retryAfter > 0 {
+		return Decision{Locked: true, RetryAfter: retryAfter}, nil
+	}
+
+	failures, err := g.store.Get(ctx, failureKey("account", email))
+	if err != nil {
+		return Decision{}, err
+	}
+	return Decision{Delay: ProgressiveDelay(failures, g.policy.BaseDelay, g.policy.MaxDelay)}, nil
+}
+
+// Fail 失敗を記録し、上限に達した場合はロックしてtrueを返す
+func (g *Guard) Fail(ctx context.Context, email, ip string) (bool, error) {
+	accountLocked, err := g.fail(ctx, "account", email, g.policy.MaxAccountFailures)
+	if err != nil {
+		return false, err
+	}
+	ipLocked, err := g.fail(ctx, "ip", ip, g.policy.MaxIPFailures)
+	if err != nil {
+		return false, err
+	}
+	return accountLocked || ipLocked, nil
+}
+
+// Succeed ログインに成功したアカウントの失敗回数を消す
+// IPアドレスの失敗回数は、攻撃者が自分のアカウントでログインして消せないよう残す
+func (g *Guard) Succeed(ctx context.Context, email string) error {
+	return g.store.Del(ctx, failureKey("account", email))
+}
+
+// Unlock 管理者がアカウントのロックと失敗回数を消す
+func (g *Guard) Unlock(ctx context.Context, email string) error {
+	return g.store.Del(ctx, lockKey("account", email), failureKey("account", email))
+}
+
+func (g *Guard) fail(ctx context.Context, kind, value string, max int64) (bool, error) {
+	if value == "" || max <= 0 {
+		return false, nil
+	}
+	failures, err := g.store.Incr(ctx, failureKey(kind, value), g.policy.Window)
+	if err != nil {
+		return false, err
+	}
+	if failures < max {
+		return false, nil
+	}
+	if err := g.store.Lock(ctx, lockKey(kind, value), g.policy.LockoutDuration); err != nil {
+		return false, err
+	}
+	// ロックが解除された後は、また上限まで試行できる
+	return true, g.store.Del(ctx, failureKey(kind, value))
+}
+
+// ProgressiveDelay 失敗回数に応じた遅延を返す。失敗していない場合は遅延しない
+func ProgressiveDelay(failures int64, base, max time.Duration) time.Duration {
+	if failures <= 0 || base <= 0 {
+		return 0
+	}
+	delay := base
+	for i := int64(1); i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// Sleep delayだけ待つ。リクエストが中断された場合はすぐに戻る
+func Sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NormalizeEmail 大文字小文字や前後の空白の違いで別のアカウントとして数えないようにする
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func failureKey(kind, value string) string {
+	return keyPrefix + "failures:" + kind + ":" + value
+}
+
+func lockKey(kind, value string) string {
+	return keyPrefix + "lock:" + kind + ":" + value
+}
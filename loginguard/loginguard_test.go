@@ -0,0 +1,135 @@
+package loginguard_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/loginguard"
+)
+
+var testPolicy = loginguard.Policy{
+	MaxAccountFailures: 3,
+	MaxIPFailures:      5,
+	Window:             15 * time.Minute,
+	LockoutDuration:    10 * time.Minute,
+	BaseDelay:          time.Second,
+	MaxDelay:           4 * time.Second,
+}
+
+func TestProgressiveDelay(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		failures int64
+		want     time.Duration
+	}{
+		{name: "失敗していない場合は遅延しないこと", failures: 0, want: 0},
+		{name: "1回目の失敗後は基準の遅延になること", failures: 1, want: time.Second},
+		{name: "失敗するごとに倍になること", failures: 3, want: 4 * time.Second},
+		{name: "上限を超えないこと", failures: 10, want: 4 * time.Second},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, loginguard.ProgressiveDelay(tt.failures, time.Second, 4*time.Second))
+		})
+	}
+}
+
+func TestGuard(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("失敗するごとに遅延が長くなること", func(t *testing.T) {
+		t.Parallel()
+		g := loginguard.New(loginguard.NewMemoryStore(), testPolicy)
+		_, err := g.Fail(ctx, "a@example.com", "192.0.2.1")
+		require.NoError(t, err)
+		_, err = g.Fail(ctx, "a@example.com", "192.0.2.1")
+		require.NoError(t, err)
+
+		d, err := g.Check(ctx, "a@example.com", "192.0.2.1")
+		require.NoError(t, err)
+		assert.False(t, d.Locked)
+		assert.Equal(t, 2*time.Second, d.Delay)
+	})
+
+	t.Run("アカウントの失敗が上限に達するとロックされること", func(t *testing.T) {
+		t.Parallel()
+		now := time.Date(2022, 9, 25, 10, 0, 0, 0, time.UTC)
+		g := loginguard.New(loginguard.NewMemoryStoreWithClock(func() time.Time { return now }), testPolicy)
+		for i := 0; i < 2; i++ {
+			locked, err := g.Fail(ctx, "a@example.com", "192.0.2.1")
+			require.NoError(t, err)
+			assert.False(t, locked)
+		}
+		locked, err := g.Fail(ctx, "a@example.com", "192.0.2.2")
+		require.NoError(t, err)
+		assert.True(t, locked)
+
+		d, err := g.Check(ctx, "a@example.com", "192.0.2.3")
+		require.NoError(t, err)
+		assert.True(t, d.Locked)
+		assert.Equal(t, 10*time.Minute, d.RetryAfter)
+
+		now = now.Add(10 * time.Minute)
+		d, err = g.Check(ctx, "a@example.com", "192.0.2.3")
+		require.NoError(t, err)
+		assert.False(t, d.Locked)
+		assert.Zero(t, d.Delay)
+	})
+
+	t.Run("IPアドレスの失敗が上限に達すると別のアカウントもロックされること", func(t *testing.T) {
+		t.Parallel()
+		g := loginguard.New(loginguard.NewMemoryStore(), testPolicy)
+		for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+			_, err := g.Fail(ctx, email, "192.0.2.1")
+			require.NoError(t, err)
+		}
+
+		d, err := g.Check(ctx, "f@example.com", "192.0.2.1")
+		require.NoError(t, err)
+		assert.True(t, d.Locked)
+		d, err = g.Check(ctx, "f@example.com", "192.0.2.2")
+		require.NoError(t, err)
+		assert.False(t, d.Locked)
+	})
+
+	t.Run("ログインに成功するとアカウントの失敗回数が消えること", func(t *testing.T) {
+		t.Parallel()
+		g := loginguard.New(loginguard.NewMemoryStore(), testPolicy)
+		_, err := g.Fail(ctx, "a@example.com", "192.0.2.1")
+		require.NoError(t, err)
+		require.NoError(t, g.Succeed(ctx, "a@example.com"))
+
+		d, err := g.Check(ctx, "a@example.com", "192.0.2.1")
+		require.NoError(t, err)
+		assert.Zero(t, d.Delay)
+	})
+
+	t.Run("管理者がロックを解除できること", func(t *testing.T) {
+		t.Parallel()
+		g := loginguard.New(loginguard.NewMemoryStore(), testPolicy)
+		for i := 0; i < 3; i++ {
+			_, err := g.Fail(ctx, "a@example.com", "")
+			require.NoError(t, err)
+		}
+		require.NoError(t, g.Unlock(ctx, "a@example.com"))
+
+		d, err := g.Check(ctx, "a@example.com", "")
+		require.NoError(t, err)
+		assert.False(t, d.Locked)
+	})
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "taro@example.com", loginguard.NormalizeEmail(" Taro@Example.com "))
+}
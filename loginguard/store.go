@@ -0,0 +1,144 @@
+package loginguard
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+// Store 失敗回数とロックの保存先
+type Store interface {
+	// Incr キーの値を1増やして返す。キーが無かった場合はwindowの有効期限を設定する
+	Incr(ctx context.Context, key string, window time.Duration) (int64, error)
+	// Get キーの値を返す。キーが無い場合は0を返す
+	Get(ctx context.Context, key string) (int64, error)
+	// Lock ttlの間キーを保持する
+	Lock(ctx context.Context, key string, ttl time.Duration) error
+	// TTL キーの残りの有効期限を返す。キーが無い場合は0を返す
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Del(ctx context.Context, keys ...string) error
+}
+
+// incrScript 初回の加算時だけ有効期限を設定し、失敗が続いても期間が延びないようにする
+var incrScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// RedisStore 複数のサーバーで失敗回数を共有する
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return incrScript.Run(ctx, s.client, []string{key}, window.Milliseconds()).Int64()
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (int64, error) {
+	n, err := s.client.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (s *RedisStore) Lock(ctx context.Context, key string, ttl time.Duration) error {
+	return s.client.Set(ctx, key, 1, ttl).Err()
+}
+
+func (s *RedisStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(ctx, key).Result()
+	if err != nil || ttl < 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+func (s *RedisStore) Del(ctx context.Context, keys ...string) error {
+	return s.client.Del(ctx, keys...).Err()
+}
+
+// MemoryStore テストやローカル実行用のインメモリの保存先
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value     int64
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithClock(time.Now)
+}
+
+// NewMemoryStoreWithClock 有効期限の判定に使う現在日時を差し替える
+func NewMemoryStoreWithClock(now func() time.Time) *MemoryStore {
+	return &MemoryStore{entries: map[string]memoryEntry{}, now: now}
+}
+
+func (s *MemoryStore) Incr(_ context.Context, key string, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.get(key)
+	if !ok {
+		entry = memoryEntry{expiresAt: s.now().Add(window)}
+	}
+	entry.value++
+	s.entries[key] = entry
+	return entry.value, nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, _ := s.get(key)
+	return entry.value, nil
+}
+
+func (s *MemoryStore) Lock(_ context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = memoryEntry{value: 1, expiresAt: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) TTL(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.get(key)
+	if !ok {
+		return 0, nil
+	}
+	return entry.expiresAt.Sub(s.now()), nil
+}
+
+func (s *MemoryStore) Del(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// get 期限切れのキーは削除して無いものとして扱う
+func (s *MemoryStore) get(key string) (memoryEntry, bool) {
+	entry, ok := s.entries[key]
+	if ok && !s.now().Before(entry.expiresAt) {
+		delete(s.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, ok
+}
@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultMailDir = "assets/mails"
+
+// FileMailer ローカル実行用に送信したメールを1通ずつ.emlファイルへ書き出す
+type FileMailer struct {
+	Dir  string
+	From string
+	seq  uint64
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{Dir: dir, From: from}
+}
+
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%s_%06d.eml", now.Format("20060102150405"), atomic.AddUint64(&m.seq, 1))
+	return os.WriteFile(filepath.Join(m.Dir, name), BuildMessage(m.From, msg, now), 0o644)
+}
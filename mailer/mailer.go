@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrMailerMissing 送信先を指定しないとメールが送られないまま登録やパスワード再設定が成功するため、起動時に検出する
+var ErrMailerMissing = errors.New("MAILER is not set")
+
+// Message 送信する1通のメール。本文はプレーンテキスト
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer メールの送信先
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromEnv 環境変数MAILERに応じた送信先を返す
+// smtpはSMTP_ADDR・SMTP_USERNAME・SMTP_PASSWORD・MAIL_FROM、fileはMAIL_DIRを使い、memoryはメモリに保持する
+// 未指定の場合はErrMailerMissingを返す
+func NewFromEnv() (Mailer, error) {
+	switch name := os.Getenv("MAILER"); name {
+	case "smtp":
+		if os.Getenv("SMTP_ADDR") == "" {
+			return nil, errors.New("SMTP_ADDR is not set")
+		}
+		return NewSMTPMailer(os.Getenv("SMTP_ADDR"), os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"), os.Getenv("MAIL_FROM")), nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = DefaultMailDir
+		}
+		return NewFileMailer(dir, os.Getenv("MAIL_FROM")), nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	case "":
+		return nil, ErrMailerMissing
+	default:
+		return nil, fmt.Errorf("unknown mailer: %s", name)
+	}
+}
@@ -0,0 +1,149 @@
+package mailer_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/mailer"
+)
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+	data := mailer.LinkData{Name: "山田 太郎", URL: "https://example.com/verify-email?token=abc", ExpiresInHours: 24}
+
+	tests := []struct {
+		name        string
+		template    string
+		lang        string
+		wantSubject string
+		wantBody    string
+	}{
+		{name: "日本語のテンプレートで作成できること", template: mailer.TemplateVerifyEmail, lang: mailer.LangJa, wantSubject: "メールアドレスの確認", wantBody: "有効期限は24時間です"},
+		{name: "英語のテンプレートで作成できること", template: mailer.TemplatePasswordReset, lang: mailer.LangEn, wantSubject: "Reset your password", wantBody: "expires in 24 hour(s)"},
+		{name: "対応していない言語は日本語になること", template: mailer.TemplatePasswordReset, lang: "fr", wantSubject: "パスワードの再設定", wantBody: "一度だけ使用できます"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			msg, err := mailer.Render(tt.template, tt.lang, "taro@example.com", data)
+			require.NoError(t, err)
+			assert.Equal(t, "taro@example.com", msg.To)
+			assert.Equal(t, tt.wantSubject, msg.Subject)
+			assert.Contains(t, msg.Body, tt.wantBody)
+			assert.Contains(t, msg.Body, data.URL)
+		})
+	}
+
+	t.Run("存在しないテンプレートはエラーになること", func(t *testing.T) {
+		t.Parallel()
+		_, err := mailer.Render("unknown", mailer.LangJa, "taro@example.com", data)
+		assert.Error(t, err)
+	})
+}
+
+func TestLangFromAcceptLanguage(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, mailer.LangEn, mailer.LangFromAcceptLanguage("en-US,en;q=0.9,ja;q=0.8"))
+	assert.Equal(t, mailer.LangJa, mailer.LangFromAcceptLanguage("ja,en-US;q=0.9"))
+	assert.Equal(t, mailer.LangJa, mailer.LangFromAcceptLanguage(""))
+}
+
+func TestBuildMessage(t *testing.T) {
+	t.Parallel()
+	msg := mailer.Message{To: "taro@example.com", Subject: "メールアドレスの確認", Body: strings.Repeat("本文", 30)}
+
+	got := string(mailer.BuildMessage("noreply@example.com", msg, time.Date(2022, 9, 25, 10, 0, 0, 0, time.UTC)))
+
+	header, body := splitMessage(t, got)
+	assert.Contains(t, header, "To: taro@example.com\r\n")
+	assert.Contains(t, header, "Subject: =?UTF-8?b?")
+	assert.Contains(t, header, "Content-Type: text/plain; charset=UTF-8\r\n")
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 76)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(body, "\r\n", ""))
+	require.NoError(t, err)
+	assert.Equal(t, msg.Body, string(decoded))
+}
+
+func TestMemoryMailer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("送信したメールを保持すること", func(t *testing.T) {
+		t.Parallel()
+		m := mailer.NewMemoryMailer()
+		msg := mailer.Message{To: "taro@example.com", Subject: "件名", Body: "本文"}
+		require.NoError(t, m.Send(context.Background(), msg))
+		assert.Equal(t, []mailer.Message{msg}, m.Messages())
+	})
+
+	t.Run("エラーを設定した場合は保持しないこと", func(t *testing.T) {
+		t.Parallel()
+		m := mailer.NewMemoryMailer()
+		m.Err = errors.New("unavailable")
+		assert.EqualError(t, m.Send(context.Background(), mailer.Message{}), "unavailable")
+		assert.Empty(t, m.Messages())
+	})
+}
+
+func TestFileMailer(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	m := mailer.NewFileMailer(filepath.Join(dir, "mails"), "noreply@example.com")
+
+	require.NoError(t, m.Send(context.Background(), mailer.Message{To: "a@example.com", Subject: "1", Body: "1"}))
+	require.NoError(t, m.Send(context.Background(), mailer.Message{To: "b@example.com", Subject: "2", Body: "2"}))
+
+	files, err := os.ReadDir(filepath.Join(dir, "mails"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	content, err := os.ReadFile(filepath.Join(dir, "mails", files[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "From: noreply@example.com\r\n")
+}
+
+func splitMessage(t *testing.T, msg string) (string, string) {
+	t.Helper()
+	i := strings.Index(msg, "\r\n\r\n")
+	require.GreaterOrEqual(t, i, 0)
+	return msg[:i+2], msg[i+4:]
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Run("未設定の場合はエラーになること", func(t *testing.T) {
+		t.Setenv("MAILER", "")
+		_, err := mailer.NewFromEnv()
+		assert.ErrorIs(t, err, mailer.ErrMailerMissing)
+	})
+
+	t.Run("memoryを指定した場合はメモリに保持すること", func(t *testing.T) {
+		t.Setenv("MAILER", "memory")
+		m, err := mailer.NewFromEnv()
+		require.NoError(t, err)
+		assert.IsType(t, &mailer.MemoryMailer{}, m)
+	})
+
+	t.Run("smtpでSMTP_ADDRが未設定の場合はエラーになること", func(t *testing.T) {
+		t.Setenv("MAILER", "smtp")
+		t.Setenv("SMTP_ADDR", "")
+		_, err := mailer.NewFromEnv()
+		assert.EqualError(t, err, "SMTP_ADDR is not set")
+	})
+
+	t.Run("不明な送信先はエラーになること", func(t *testing.T) {
+		t.Setenv("MAILER", "unknown")
+		_, err := mailer.NewFromEnv()
+		assert.EqualError(t, err, "unknown mailer: unknown")
+	})
+}
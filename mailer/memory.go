@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryMailer テストやローカル実行用に送信したメールを保持する
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+	// Err nil以外を設定するとSendがそのエラーを返す
+	Err error
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(_ context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages 送信したメールを送信順に返す
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	messages := make([]Message, len(m.messages))
+	copy(messages, m.messages)
+	return messages
+}
@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer SMTPサーバー経由で送信する
+type SMTPMailer struct {
+	Addr string
+	From string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer usernameが空の場合は認証せずに送信する
+func NewSMTPMailer(addr, username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{Addr: addr, From: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(_ context.Context, msg Message) error {
+	return smtp.SendMail(m.Addr, m.auth, m.From, []string{msg.To}, BuildMessage(m.From, msg, time.Now()))
+}
+
+// BuildMessage ヘッダーと本文を組み立てる
+// 日本語の件名と本文を扱えるよう、件名はMIMEエンコードし、本文はUTF-8のbase64で送る
+func BuildMessage(from string, msg Message, now time.Time) []byte {
+	var b bytes.Buffer
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n")
+	b.WriteString("\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+	return b.Bytes()
+}
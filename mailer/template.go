@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+
+	LangJa = "ja"
+	LangEn = "en"
+)
+
+// LinkData 確認・再設定用のURLを案内するテンプレートに渡す値
+type LinkData struct {
+	Name           string
+	URL            string
+	ExpiresInHours int
+}
+
+//go:embed templates/*.txt
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.txt"))
+
+// Render テンプレートからメールを作成する
+// テンプレートの1行目は "Subject: 件名"、空行の後が本文。対応していない言語は日本語にする
+func Render(name, lang, to string, data interface{}) (Message, error) {
+	if lang != LangEn {
+		lang = LangJa
+	}
+	var b bytes.Buffer
+	if err := templates.ExecuteTemplate(&b, name+"."+lang+".txt", data); err != nil {
+		return Message{}, err
+	}
+	rendered := b.String()
+	i := strings.Index(rendered, "\n\n")
+	if i < 0 || !strings.HasPrefix(rendered, "Subject: ") {
+		return Message{}, fmt.Errorf("template %s.%s has no subject", name, lang)
+	}
+	return Message{
+		To:      to,
+		Subject: strings.TrimPrefix(rendered[:i], "Subject: "),
+		Body:    rendered[i+2:],
+	}, nil
+}
+
+// LangFromAcceptLanguage Accept-Languageヘッダーの先頭の言語がenの場合は英語、それ以外は日本語にする
+func LangFromAcceptLanguage(header string) string {
+	first := strings.TrimSpace(strings.SplitN(header, ",", 2)[0])
+	if strings.HasPrefix(strings.ToLower(first), LangEn) {
+		return LangEn
+	}
+	return LangJa
+}
@@ -3,42 +3,67 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 
-	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/apikey"
 	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/session"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
 	"github.com/AI1411/golang-admin-api/util/errors"
 	"github.com/AI1411/golang-admin-api/util/jwt"
 )
 
-func AuthenticateBearer() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		token := ctx.GetHeader("Authorization")
-		bearerToken := strings.Split(token, " ")[1]
-
-		id, err := util.ParseJwt(bearerToken)
-		if err != nil {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"message": "unauthorized!",
-			})
-			ctx.AbortWithStatus(http.StatusUnauthorized)
-			return
-		}
+// APIKeyScheme APIキーで認証する場合のAuthorizationヘッダーのスキーム
+const APIKeyScheme = "ApiKey"
 
-		if id == "" {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"message": "unauthorized!",
-			})
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+// lastUsedInterval APIキーの最終利用日時を更新する間隔。リクエストごとに書き込まないようにする
+const lastUsedInterval = time.Minute
+
+// AuthenticateBearer Authorizationヘッダーの "Bearer <JWT>" または "ApiKey <APIキー>" で認証する
+// JWTはjtiのセッションが削除されていれば拒否する
+// APIキーの場合は発行したユーザーとして扱い、スコープで許可されたHTTPメソッドのみ受け付ける
+func AuthenticateBearer(dbConn *gorm.DB, sessions *session.Manager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		scheme, credential := authorizationHeader(ctx)
+		var userID string
+		switch {
+		case strings.EqualFold(scheme, "Bearer"):
+			claims, err := util.ParseJwtClaims(credential)
+			if err != nil || claims == nil || claims.Issuer == "" {
+				abortUnauthorized(ctx)
+				return
+			}
+			// セッションの導入前に発行されたトークンはjtiがないため、有効期限まで受け付ける
+			if claims.Id != "" {
+				valid, err := sessions.Validate(ctx, claims.Issuer, claims.Id)
+				if err != nil {
+					ctx.AbortWithStatusJSON(http.StatusInternalServerError,
+						errors.NewInternalServerError("failed to get session", err))
+					return
+				}
+				if !valid {
+					abortUnauthorized(ctx)
+					return
+				}
+				appcontext.SetSessionIDIntoContext(ctx, claims.Id)
+			}
+			userID = claims.Issuer
+		case strings.EqualFold(scheme, APIKeyScheme):
+			key, ok := authenticateAPIKey(ctx, dbConn, credential)
+			if !ok {
+				return
+			}
+			userID = key.UserID
+		default:
+			abortUnauthorized(ctx)
 			return
 		}
 
 		var user models.User
-		dbConn := db.Init()
-
-		if err := dbConn.Where("id = ?", id).First(&user).Error; err != nil {
+		if err := dbConn.Where("id = ?", userID).First(&user).Error; err != nil {
 			if gorm.IsRecordNotFoundError(err) {
 				ctx.AbortWithStatusJSON(http.StatusNotFound, errors.NewNotFoundError("user not found"))
 				return
@@ -47,6 +72,59 @@ func AuthenticateBearer() gin.HandlerFunc {
 				errors.NewInternalServerError("failed to get user", err))
 			return
 		}
+		appcontext.SetUserIDIntoContext(ctx, user.ID)
 		ctx.Next()
 	}
 }
+
+// authenticateAPIKey APIキーを検証し、スコープと組織をContextに設定する
+// 検証できない場合は応答を返してfalseを返す
+func authenticateAPIKey(ctx *gin.Context, dbConn *gorm.DB, credential string) (models.APIKey, bool) {
+	var key models.APIKey
+	prefix, ok := apikey.Parse(credential)
+	if !ok {
+		abortUnauthorized(ctx)
+		return key, false
+	}
+	if err := dbConn.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			abortUnauthorized(ctx)
+			return key, false
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError,
+			errors.NewInternalServerError("failed to get api key", err))
+		return key, false
+	}
+	now := time.Now()
+	if !apikey.Verify(credential, key.KeyHash) || !key.IsActive(now) {
+		abortUnauthorized(ctx)
+		return key, false
+	}
+	scopes := key.ScopeList()
+	if !apikey.AllowsMethod(scopes, ctx.Request.Method) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, errors.NewForbiddenError("api key scope does not allow this request"))
+		return key, false
+	}
+	// 最終利用日時の記録に失敗しても認証は成功とする
+	dbConn.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", key.ID, now.Add(-lastUsedInterval)).
+		UpdateColumn("last_used_at", now)
+	appcontext.SetAPIKeyIntoContext(ctx, key.ID, scopes)
+	// APIキーは発行した組織に固定する。ResolveOrganizationはこの組織をJWTの組織と同じく扱う
+	appcontext.SetOrganizationIDIntoContext(ctx, key.OrganizationID)
+	return key, true
+}
+
+func authorizationHeader(ctx *gin.Context) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(ctx.GetHeader("Authorization")), " ", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], strings.TrimSpace(parts[1])
+}
+
+func abortUnauthorized(ctx *gin.Context) {
+	ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"message": "unauthorized!",
+	})
+}
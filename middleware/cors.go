@@ -18,6 +18,7 @@ func Cors() gin.HandlerFunc {
 			"POST",
 			"GET",
 			"PUT",
+			"PATCH",
 			"OPTIONS",
 			"DELETE",
 		},
@@ -29,6 +30,13 @@ func Cors() gin.HandlerFunc {
 			"Content-Length",
 			"Accept-Encoding",
 			"Authorization",
+			IdempotencyKeyHeader,
+			"If-Match",
+			"If-None-Match",
+		},
+		// JavaScriptから参照できるレスポンスヘッダ
+		ExposeHeaders: []string{
+			"ETag",
 		},
 		// cookieなどの情報を必要とするかどうか
 		AllowCredentials: true,
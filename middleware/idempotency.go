@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/idempotency"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+const (
+	// IdempotencyKeyHeader POSTの再試行で同じ処理を繰り返さないよう、クライアントが付けるキーのヘッダー
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader 保存済みの応答を返した場合に付けるヘッダー
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// replayExcludedHeaders 応答を返すたびに変わるため保存しないヘッダー
+var replayExcludedHeaders = []string{"Set-Cookie", "Retry-After", "X-Ratelimit-"}
+
+// Idempotency Idempotency-Keyヘッダーの付いたPOSTの応答を保存し、同じキーの再試行には保存した応答を返す
+// キーは認証したユーザーまたはAPIキーごとに区別するため、認証のミドルウェアの後に置く
+// 同じキーで内容の異なるリクエストや、処理中のリクエストと同じキーのリクエストは409を返す
+// 5xxの応答は保存せず、同じキーで再試行できるようにする
+// 処理中のロックは処理が終わるまで延長し、ロックを失った場合は他のリクエストの応答を上書きしない
+func Idempotency(keeper *idempotency.Keeper, logger *zap.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := strings.TrimSpace(ctx.GetHeader(IdempotencyKeyHeader))
+		if ctx.Request.Method != http.MethodPost || key == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, errors.NewBadRequestError("idempotency key is too long"))
+			return
+		}
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, errors.NewBadRequestError("failed to read request body"))
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+		traceID := appcontext.GetTraceID(ctx)
+		key = principalKey(ctx) + ":" + key
+		fingerprint := idempotency.Fingerprint(ctx.Request.Method, ctx.Request.URL.Path, body)
+		record, lock, err := keeper.Begin(ctx, key, fingerprint)
+		switch {
+		case errors.Is(err, idempotency.ErrConflict):
+			ctx.AbortWithStatusJSON(http.StatusConflict, errors.NewConflictError("idempotency key was used for a different request"))
+			return
+		case errors.Is(err, idempotency.ErrInProgress):
+			ctx.Header("Retry-After", "1")
+			ctx.AbortWithStatusJSON(http.StatusConflict, errors.NewConflictError("a request with the same idempotency key is in progress"))
+			return
+		case err != nil:
+			// 重複して処理するおそれがあるため、キーを確認できない場合は受け付けない
+			logger.Error("failed to begin idempotent request", zap.Error(err),
+				zap.String("trace_id", traceID))
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError,
+				errors.NewInternalServerError("failed to check idempotency key", err))
+			return
+		case record != nil:
+			replay(ctx, record)
+			return
+		}
+
+		writer := &responseWriter{
+			ResponseWriter: ctx.Writer,
+			body:           bytes.NewBufferString(""),
+		}
+		ctx.Writer = writer
+		stop := keeper.Hold(ctx.Request.Context(), lock)
+		ctx.Next()
+		stop()
+
+		if writer.Status() >= http.StatusInternalServerError {
+			if err := keeper.Release(ctx, lock); err != nil {
+				logger.Error("failed to release idempotency key", zap.Error(err),
+					zap.String("trace_id", traceID))
+			}
+			return
+		}
+		if err := keeper.Complete(ctx, lock, &idempotency.Record{
+			Fingerprint: fingerprint,
+			StatusCode:  writer.Status(),
+			Header:      replayableHeader(writer.Header()),
+			Body:        writer.body.Bytes(),
+		}); err != nil {
+			logger.Error("failed to save idempotent response", zap.Error(err),
+				zap.String("trace_id", traceID))
+		}
+	}
+}
+
+func replay(ctx *gin.Context, record *idempotency.Record) {
+	for name, values := range record.Header {
+		ctx.Writer.Header()[name] = values
+	}
+	ctx.Header(IdempotentReplayedHeader, "true")
+	ctx.Status(record.StatusCode)
+	_, _ = ctx.Writer.Write(record.Body)
+	ctx.Abort()
+}
+
+func replayableHeader(header http.Header) http.Header {
+	res := http.Header{}
+	for name, values := range header {
+		if isReplayExcluded(name) {
+			continue
+		}
+		res[name] = append([]string(nil), values...)
+	}
+	return res
+}
+
+func isReplayExcluded(name string) bool {
+	for _, excluded := range replayExcludedHeaders {
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), excluded) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/idempotency"
+	"github.com/AI1411/golang-admin-api/middleware"
+)
+
+func newIdempotencyRouter(lockTTL time.Duration, handler gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	keeper := idempotency.NewKeeper(idempotency.NewMemoryStore(), lockTTL, time.Hour)
+	r.Use(middleware.Idempotency(keeper, zap.NewNop()))
+	r.POST("/orders", handler)
+	return r
+}
+
+func postWithKey(r *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.IdempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotency(t *testing.T) {
+	t.Parallel()
+
+	t.Run("同じキーの再試行には保存した応答をIdempotent-Replayedを付けて返すこと", func(t *testing.T) {
+		t.Parallel()
+		var calls int32
+		r := newIdempotencyRouter(time.Minute, func(ctx *gin.Context) {
+			n := atomic.AddInt32(&calls, 1)
+			ctx.Header("Location", "/orders/"+strconv.Itoa(int(n)))
+			ctx.JSON(http.StatusCreated, gin.H{"id": n})
+		})
+
+		first := postWithKey(r, "key", `{"quantity":1}`)
+		require.Equal(t, http.StatusCreated, first.Code)
+		assert.Empty(t, first.Header().Get(middleware.IdempotentReplayedHeader))
+
+		second := postWithKey(r, "key", `{"quantity":1}`)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.Equal(t, "true", second.Header().Get(middleware.IdempotentReplayedHeader))
+		assert.Equal(t, "/orders/1", second.Header().Get("Location"))
+		assert.JSONEq(t, first.Body.String(), second.Body.String())
+		assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("同じキーで内容が異なる場合は409を返すこと", func(t *testing.T) {
+		t.Parallel()
+		var calls int32
+		r := newIdempotencyRouter(time.Minute, func(ctx *gin.Context) {
+			atomic.AddInt32(&calls, 1)
+			ctx.JSON(http.StatusCreated, gin.H{})
+		})
+
+		require.Equal(t, http.StatusCreated, postWithKey(r, "key", `{"quantity":1}`).Code)
+		rec := postWithKey(r, "key", `{"quantity":2}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.JSONEq(t, `{"message":"idempotency key was used for a different request","status":409,"error":"conflict","causes":null}`, rec.Body.String())
+		assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("同じキーのリクエストを処理中の場合は409とRetry-Afterを返すこと", func(t *testing.T) {
+		t.Parallel()
+		started := make(chan struct{})
+		release := make(chan struct{})
+		r := newIdempotencyRouter(time.Minute, func(ctx *gin.Context) {
+			close(started)
+			<-release
+			ctx.JSON(http.StatusCreated, gin.H{})
+		})
+
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- postWithKey(r, "key", `{"quantity":1}`) }()
+		<-started
+		rec := postWithKey(r, "key", `{"quantity":1}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+		close(release)
+		assert.Equal(t, http.StatusCreated, (<-done).Code)
+	})
+
+	t.Run("5xxの応答は保存せず、同じキーで再試行できること", func(t *testing.T) {
+		t.Parallel()
+		var calls int32
+		r := newIdempotencyRouter(time.Minute, func(ctx *gin.Context) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				ctx.JSON(http.StatusInternalServerError, gin.H{})
+				return
+			}
+			ctx.JSON(http.StatusCreated, gin.H{})
+		})
+
+		require.Equal(t, http.StatusInternalServerError, postWithKey(r, "key", `{"quantity":1}`).Code)
+		rec := postWithKey(r, "key", `{"quantity":1}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Empty(t, rec.Header().Get(middleware.IdempotentReplayedHeader))
+		assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("処理がロックの期限より長くかかっても、同じキーの再試行で重複して処理しないこと", func(t *testing.T) {
+		t.Parallel()
+		var calls int32
+		started := make(chan struct{})
+		r := newIdempotencyRouter(60*time.Millisecond, func(ctx *gin.Context) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+			}
+			time.Sleep(300 * time.Millisecond)
+			ctx.JSON(http.StatusCreated, gin.H{})
+		})
+
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- postWithKey(r, "key", `{"quantity":1}`) }()
+		<-started
+		time.Sleep(150 * time.Millisecond)
+		assert.Equal(t, http.StatusConflict, postWithKey(r, "key", `{"quantity":1}`).Code)
+		assert.Equal(t, http.StatusCreated, (<-done).Code)
+
+		rec := postWithKey(r, "key", `{"quantity":1}`)
+		assert.Equal(t, "true", rec.Header().Get(middleware.IdempotentReplayedHeader))
+		assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	})
+}
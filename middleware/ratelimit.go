@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AI1411/golang-admin-api/ratelimit"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+// RateLimit nameのグループのリクエストをlimitまでに制限する
+// 認証済みの場合はAPIキーまたはユーザーごとに、それ以外はクライアントのIPアドレスごとに数えるため、認証のミドルウェアの後に置く
+// 制限の状態はX-RateLimit-Limit、X-RateLimit-Remaining、X-RateLimit-Reset(上限まで回復するまでの秒数)で返す
+func RateLimit(limiter *ratelimit.Limiter, name string, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		res, err := limiter.Allow(ctx, name+":"+principalKey(ctx), limit)
+		if err != nil {
+			// 制限の保存先が使えない場合はリクエストを止めない
+			ctx.Next()
+			return
+		}
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		ctx.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter.Seconds())))
+		if !res.Allowed {
+			ctx.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter.Seconds())))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errors.NewTooManyRequestsError("rate limit exceeded"))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// principalKey 認証済みの場合はAPIキーまたはユーザーを、それ以外はクライアントのIPアドレスを表すキー
+func principalKey(ctx *gin.Context) string {
+	if id := appcontext.GetAPIKeyID(ctx); id != "" {
+		return "apikey:" + id
+	}
+	if id := appcontext.GetUserID(ctx); id != "" {
+		return "user:" + id
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+func ceilSeconds(seconds float64) int {
+	return int(math.Ceil(seconds))
+}
@@ -0,0 +1,81 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/middleware"
+	"github.com/AI1411/golang-admin-api/ratelimit"
+)
+
+func newRateLimitRouter(t *testing.T, limit ratelimit.Limit) *gin.Engine {
+	t.Helper()
+	r := gin.New()
+	require.NoError(t, r.SetTrustedProxies(nil))
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.NewMemoryStore(), zap.NewNop())
+	r.Use(middleware.RateLimit(limiter, "test", limit))
+	r.GET("/", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	return r
+}
+
+func requestFrom(r *gin.Engine, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("制限の状態をヘッダーで返し、上限を超えた場合は429とRetry-Afterを返すこと", func(t *testing.T) {
+		t.Parallel()
+		r := newRateLimitRouter(t, ratelimit.Limit{Requests: 2, Per: time.Minute})
+
+		rec := requestFrom(r, "192.0.2.1:1234", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+		assert.NotEmpty(t, rec.Header().Get("X-RateLimit-Reset"))
+		assert.Empty(t, rec.Header().Get("Retry-After"))
+
+		rec = requestFrom(r, "192.0.2.1:1234", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+
+		rec = requestFrom(r, "192.0.2.1:1234", "")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+		// 1分に2回のため、次のトークンは30秒後に補充される
+		assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"message":"rate limit exceeded","status":429,"error":"too_many_requests","causes":null}`, rec.Body.String())
+	})
+
+	t.Run("IPアドレスごとに数えること", func(t *testing.T) {
+		t.Parallel()
+		r := newRateLimitRouter(t, ratelimit.Limit{Requests: 1, Per: time.Minute})
+
+		assert.Equal(t, http.StatusOK, requestFrom(r, "192.0.2.1:1234", "").Code)
+		assert.Equal(t, http.StatusTooManyRequests, requestFrom(r, "192.0.2.1:1234", "").Code)
+		assert.Equal(t, http.StatusOK, requestFrom(r, "192.0.2.2:1234", "").Code)
+	})
+
+	t.Run("信頼していないプロキシのX-Forwarded-Forでは制限を回避できないこと", func(t *testing.T) {
+		t.Parallel()
+		r := newRateLimitRouter(t, ratelimit.Limit{Requests: 1, Per: time.Minute})
+
+		assert.Equal(t, http.StatusOK, requestFrom(r, "192.0.2.1:1234", "198.51.100.1").Code)
+		assert.Equal(t, http.StatusTooManyRequests, requestFrom(r, "192.0.2.1:1234", "198.51.100.2").Code)
+	})
+}
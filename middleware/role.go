@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+
+	"github.com/AI1411/golang-admin-api/apikey"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+)
+
+// RequireAdmin 組織の管理者以外のリクエストを403で拒否する
+// 管理者でも2段階認証を有効にしていない場合、APIキーにadminスコープが無い場合は拒否する。AuthenticateBearerの後に使う
+func RequireAdmin(dbConn *gorm.DB) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var user models.User
+		if err := dbConn.Select("id, role, two_factor_secret, two_factor_enabled_at").
+			Where("id = ?", appcontext.GetUserID(ctx)).First(&user).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errors.NewUnauthorizedError("unauthorized"))
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError,
+				errors.NewInternalServerError("failed to get user", err))
+			return
+		}
+		if !user.IsAdmin() {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errors.NewForbiddenError("admin role is required"))
+			return
+		}
+		if appcontext.GetAPIKeyID(ctx) != "" && !apikey.HasScope(appcontext.GetAPIKeyScopes(ctx), apikey.ScopeAdmin) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errors.NewForbiddenError("api key requires admin scope"))
+			return
+		}
+		if user.RequiresTwoFactor() && !user.IsTwoFactorEnabled() {
+			ctx.AbortWithStatusJSON(http.StatusForbidden,
+				errors.NewForbiddenError("two factor authentication is required for admin"))
+			return
+		}
+		ctx.Next()
+	}
+}
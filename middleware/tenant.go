@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+	"github.com/AI1411/golang-admin-api/util/errors"
+	"github.com/AI1411/golang-admin-api/util/jwt"
+)
+
+// OrganizationHeader 組織を指定するヘッダー。JWTに組織が含まれない場合に使う
+const OrganizationHeader = "X-Organization-ID"
+
+// ResolveOrganization JWTまたはAPIキー、ヘッダーからリクエストの対象となる組織を決め、Contextに設定する
+// AuthenticateBearerの後に使う。ユーザーが所属していない組織を指定した場合は403を返す
+func ResolveOrganization(dbConn *gorm.DB) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var user models.User
+		if err := dbConn.Select("id, organization_id").
+			Where("id = ?", appcontext.GetUserID(ctx)).First(&user).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errors.NewUnauthorizedError("unauthorized"))
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError,
+				errors.NewInternalServerError("failed to get user", err))
+			return
+		}
+
+		var tokenOrganizationID string
+		if appcontext.GetAPIKeyID(ctx) != "" {
+			// APIキーの組織はAuthenticateBearerが設定している
+			tokenOrganizationID = appcontext.GetOrganizationID(ctx)
+		} else if claims, _ := util.ParseJwtClaims(bearerToken(ctx)); claims != nil {
+			tokenOrganizationID = claims.OrganizationID
+		}
+		organizationID, err := models.ResolveOrganizationID(user.OrganizationID,
+			tokenOrganizationID, ctx.GetHeader(OrganizationHeader))
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errors.NewForbiddenError(err.Error()))
+			return
+		}
+		appcontext.SetOrganizationIDIntoContext(ctx, organizationID)
+		ctx.Next()
+	}
+}
+
+func bearerToken(ctx *gin.Context) string {
+	scheme, credential := authorizationHeader(ctx)
+	if !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return credential
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	ActivityFieldStatus    = "status"
+	ActivityFieldAssignee  = "assignee"
+	ActivityFieldMilestone = "milestone"
+)
+
+// ActivityEvent issueやepicの項目変更の履歴
+type ActivityEvent struct {
+	ID         uint64            `json:"id"`
+	TargetType CommentTargetType `json:"target_type"`
+	TargetID   string            `json:"target_id"`
+	UserID     string            `json:"user_id"`
+	Field      string            `json:"field"`
+	FromValue  string            `json:"from_value"`
+	ToValue    string            `json:"to_value"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// NewActivityEvents 変更前後の値を比較し、変更があった項目の履歴を返す
+// before/afterのキーはActivityField*を使う
+func NewActivityEvents(targetType CommentTargetType, targetID, userID string,
+	before, after map[string]string, now time.Time,
+) []ActivityEvent {
+	var events []ActivityEvent
+	for _, field := range []string{ActivityFieldStatus, ActivityFieldAssignee, ActivityFieldMilestone} {
+		from, ok := before[field]
+		if !ok {
+			continue
+		}
+		if to := after[field]; from != to {
+			events = append(events, ActivityEvent{
+				TargetType: targetType,
+				TargetID:   targetID,
+				UserID:     userID,
+				Field:      field,
+				FromValue:  from,
+				ToValue:    to,
+				CreatedAt:  now,
+			})
+		}
+	}
+	return events
+}
+
+const (
+	TimelineItemComment = "comment"
+	TimelineItemEvent   = "event"
+)
+
+// TimelineItem コメントと変更履歴を1つの時系列に並べるための要素
+type TimelineItem struct {
+	Type      string         `json:"type"`
+	CreatedAt time.Time      `json:"created_at"`
+	Comment   *Comment       `json:"comment,omitempty"`
+	Event     *ActivityEvent `json:"event,omitempty"`
+}
+
+// BuildTimeline コメントと変更履歴を作成日時の昇順に並べる
+// 同じ日時の場合は変更履歴を先に並べる
+func BuildTimeline(comments []Comment, events []ActivityEvent) []TimelineItem {
+	items := make([]TimelineItem, 0, len(comments)+len(events))
+	for i := range events {
+		items = append(items, TimelineItem{Type: TimelineItemEvent, CreatedAt: events[i].CreatedAt, Event: &events[i]})
+	}
+	for i := range comments {
+		items = append(items, TimelineItem{Type: TimelineItemComment, CreatedAt: comments[i].CreatedAt, Comment: &comments[i]})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+	return items
+}
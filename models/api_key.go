@@ -0,0 +1,41 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey バッチなどのシステムが発行したユーザーとしてAPIを呼び出すためのキー
+// キーそのものは発行時にだけ返し、ハッシュのみを保存する
+type APIKey struct {
+	ID             string `json:"id"`
+	OrganizationID string `json:"-"`
+	UserID         string `json:"user_id"`
+	Name           string `json:"name"`
+	// Prefix キーを見分けるための先頭部分
+	Prefix  string `json:"prefix"`
+	KeyHash string `json:"-"`
+	// Scopes カンマ区切りのスコープ
+	Scopes     string     `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// ScopeList スコープを配列で返す
+func (k *APIKey) ScopeList() []string {
+	var scopes []string
+	for _, s := range strings.Split(k.Scopes, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// IsActive 失効しておらず、有効期限内かどうか
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
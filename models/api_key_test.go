@@ -0,0 +1,42 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func TestAPIKey_IsActive(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 9, 28, 10, 0, 0, 0, time.Local)
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+	tests := []struct {
+		name string
+		key  models.APIKey
+		want bool
+	}{
+		{name: "有効期限が無いキーは有効であること", key: models.APIKey{}, want: true},
+		{name: "有効期限内のキーは有効であること", key: models.APIKey{ExpiresAt: &future}, want: true},
+		{name: "有効期限を過ぎたキーは無効であること", key: models.APIKey{ExpiresAt: &past}, want: false},
+		{name: "失効したキーは無効であること", key: models.APIKey{RevokedAt: &past}, want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, tt.key.IsActive(now))
+		})
+	}
+}
+
+func TestAPIKey_ScopeList(t *testing.T) {
+	t.Parallel()
+
+	key := models.APIKey{Scopes: "read, write,,"}
+	assert.Equal(t, []string{"read", "write"}, key.ScopeList())
+}
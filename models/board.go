@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// rankDigits 並び順の文字列に使う文字。文字コード順と値の大小が一致する
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// ErrInvalidRankOrder 前後の並び順が逆転または重複しており、間に値を作れない
+var ErrInvalidRankOrder = errors.New("invalid rank order")
+
+// RankBetween prevとnextの間に並ぶ文字列を返す
+// 空文字は先頭(prev)または末尾(next)を表す。末尾が0の文字列は生成しないため、常に間の値を作れる
+func RankBetween(prev, next string) (string, error) {
+	if !validRank(prev) || !validRank(next) {
+		return "", ErrInvalidRankOrder
+	}
+	if next != "" && prev >= next {
+		return "", ErrInvalidRankOrder
+	}
+	return rankMidpoint(prev, next), nil
+}
+
+// RankSequence n件を均等な間隔で並べる文字列を返す
+// 並び順が壊れた列を振り直すときに使う
+func RankSequence(n int) []string {
+	width, space := 1, len(rankDigits)
+	for space < (n+1)*len(rankDigits) {
+		width++
+		space *= len(rankDigits)
+	}
+	step := space / (n + 1)
+	ranks := make([]string, n)
+	for i := range ranks {
+		ranks[i] = encodeRank((i+1)*step, width)
+	}
+	return ranks
+}
+
+func rankMidpoint(prev, next string) string {
+	if next != "" {
+		n := 0
+		for n < len(next) && rankDigitAt(prev, n) == next[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(prev) {
+				rest = prev[n:]
+			}
+			return next[:n] + rankMidpoint(rest, next[n:])
+		}
+	}
+
+	lo := strings.IndexByte(rankDigits, rankDigitAt(prev, 0))
+	hi := len(rankDigits)
+	if next != "" {
+		hi = strings.IndexByte(rankDigits, next[0])
+	}
+	if hi-lo > 1 {
+		return string(rankDigits[(lo+hi)/2])
+	}
+	if len(next) > 1 {
+		return next[:1]
+	}
+	rest := ""
+	if len(prev) > 1 {
+		rest = prev[1:]
+	}
+	return string(rankDigits[lo]) + rankMidpoint(rest, "")
+}
+
+func rankDigitAt(rank string, i int) byte {
+	if i < len(rank) {
+		return rank[i]
+	}
+	return rankDigits[0]
+}
+
+func encodeRank(value, width int) string {
+	b := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		b[i] = rankDigits[value%len(rankDigits)]
+		value /= len(rankDigits)
+	}
+	return strings.TrimRight(string(b), rankDigits[:1])
+}
+
+func validRank(rank string) bool {
+	if strings.HasSuffix(rank, rankDigits[:1]) {
+		return false
+	}
+	for i := 0; i < len(rank); i++ {
+		if strings.IndexByte(rankDigits, rank[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SortIssuesByRank 並び順で並べ替える。並び順が未設定のissueは末尾に作成順で並べる
+func SortIssuesByRank(issues []Issue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if (a.BoardRank == "") != (b.BoardRank == "") {
+			return b.BoardRank == ""
+		}
+		if a.BoardRank != b.BoardRank {
+			return a.BoardRank < b.BoardRank
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+}
+
+// HasValidRanks 並び順が全て設定されており、重複無く昇順になっているかどうか
+func HasValidRanks(sorted []Issue) bool {
+	for i := range sorted {
+		if !validRank(sorted[i].BoardRank) || sorted[i].BoardRank == "" {
+			return false
+		}
+		if i > 0 && sorted[i-1].BoardRank >= sorted[i].BoardRank {
+			return false
+		}
+	}
+	return true
+}
+
+type BoardColumn struct {
+	Status string  `json:"status"`
+	Total  int     `json:"total"`
+	Points uint    `json:"points"`
+	Issues []Issue `json:"issues"`
+}
+
+type Board struct {
+	ProjectID string        `json:"project_id"`
+	Columns   []BoardColumn `json:"columns"`
+}
+
+// BuildBoard ワークフローのステータスごとの列にissueを並び順で振り分ける
+// ワークフローに無いステータスのissueは含めない
+func BuildBoard(projectID string, workflow IssueWorkflow, issues []Issue) Board {
+	board := Board{
+		ProjectID: projectID,
+		Columns:   make([]BoardColumn, len(workflow)),
+	}
+	index := make(map[string]int, len(workflow))
+	for i, status := range workflow {
+		index[status] = i
+		board.Columns[i] = BoardColumn{Status: status, Issues: []Issue{}}
+	}
+	for _, issue := range issues {
+		i, ok := index[issue.IssueStatus]
+		if !ok {
+			continue
+		}
+		column := &board.Columns[i]
+		column.Issues = append(column.Issues, issue)
+		column.Total++
+		column.Points += issue.Points()
+	}
+	for i := range board.Columns {
+		SortIssuesByRank(board.Columns[i].Issues)
+	}
+	return board
+}
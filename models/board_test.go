@@ -0,0 +1,133 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func TestRankBetween(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		prev string
+		next string
+	}{
+		{name: "空の列では中間の値になること", prev: "", next: ""},
+		{name: "先頭に挿入できること", prev: "", next: "i"},
+		{name: "末尾に追加できること", prev: "i", next: ""},
+		{name: "隣り合う文字の間に挿入できること", prev: "a5", next: "a6"},
+		{name: "前方一致する値の間に挿入できること", prev: "a", next: "a1"},
+		{name: "末尾のzの後ろに追加できること", prev: "zz", next: ""},
+		{name: "先頭の値の前に挿入できること", prev: "", next: "01"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := models.RankBetween(tt.prev, tt.next)
+			require.NoError(t, err)
+			assert.Greater(t, got, tt.prev)
+			if tt.next != "" {
+				assert.Less(t, got, tt.next)
+			}
+		})
+	}
+
+	t.Run("同じ位置への挿入を繰り返しても並び順が保たれること", func(t *testing.T) {
+		t.Parallel()
+		prev, next := "a", "b"
+		for i := 0; i < 50; i++ {
+			got, err := models.RankBetween(prev, next)
+			require.NoError(t, err)
+			require.Greater(t, got, prev)
+			require.Less(t, got, next)
+			next = got
+		}
+	})
+
+	t.Run("前後が逆転している場合はエラーになること", func(t *testing.T) {
+		t.Parallel()
+		_, err := models.RankBetween("b", "a")
+		assert.ErrorIs(t, err, models.ErrInvalidRankOrder)
+	})
+
+	t.Run("前後が同じ場合はエラーになること", func(t *testing.T) {
+		t.Parallel()
+		_, err := models.RankBetween("b", "b")
+		assert.ErrorIs(t, err, models.ErrInvalidRankOrder)
+	})
+
+	t.Run("不正な文字を含む場合はエラーになること", func(t *testing.T) {
+		t.Parallel()
+		_, err := models.RankBetween("A", "")
+		assert.ErrorIs(t, err, models.ErrInvalidRankOrder)
+	})
+}
+
+func TestRankSequence(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{0, 1, 35, 36, 1000} {
+		ranks := models.RankSequence(n)
+		require.Len(t, ranks, n)
+		for i := range ranks {
+			_, err := models.RankBetween(ranks[i], "")
+			require.NoError(t, err)
+			if i > 0 {
+				assert.Less(t, ranks[i-1], ranks[i])
+			}
+		}
+	}
+}
+
+func TestBuildBoard(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 9, 23, 0, 0, 0, 0, time.UTC)
+	issues := []models.Issue{
+		{ID: "1", IssueStatus: "todo", BoardRank: "m", StoryPoints: uintPtr(3), CreatedAt: now},
+		{ID: "2", IssueStatus: "todo", BoardRank: "", CreatedAt: now.Add(2 * time.Hour)},
+		{ID: "3", IssueStatus: "todo", BoardRank: "c", StoryPoints: uintPtr(2), CreatedAt: now},
+		{ID: "4", IssueStatus: "todo", BoardRank: "", CreatedAt: now.Add(time.Hour)},
+		{ID: "5", IssueStatus: "done", BoardRank: "i", CreatedAt: now},
+		{ID: "6", IssueStatus: "archived", BoardRank: "i", CreatedAt: now},
+	}
+
+	board := models.BuildBoard("p1", models.IssueWorkflow{"todo", "doing", "done"}, issues)
+
+	assert.Equal(t, "p1", board.ProjectID)
+	require.Len(t, board.Columns, 3)
+
+	t.Run("並び順の昇順、未設定は作成順で末尾に並ぶこと", func(t *testing.T) {
+		t.Parallel()
+		todo := board.Columns[0]
+		assert.Equal(t, "todo", todo.Status)
+		assert.Equal(t, 4, todo.Total)
+		assert.Equal(t, uint(5), todo.Points)
+		var ids []string
+		for _, issue := range todo.Issues {
+			ids = append(ids, issue.ID)
+		}
+		assert.Equal(t, []string{"3", "1", "4", "2"}, ids)
+		assert.False(t, models.HasValidRanks(todo.Issues))
+	})
+
+	t.Run("issueが無い列も空配列で返ること", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, "doing", board.Columns[1].Status)
+		assert.Empty(t, board.Columns[1].Issues)
+		assert.NotNil(t, board.Columns[1].Issues)
+	})
+
+	t.Run("ワークフローに無いステータスのissueは含まれないこと", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, 1, board.Columns[2].Total)
+		assert.True(t, models.HasValidRanks(board.Columns[2].Issues))
+	})
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// CalendarFeed ユーザーごとのカレンダー配信用トークン
+// トークンはURLに含めて配布するため、漏洩に備えてハッシュのみ保存する
+type CalendarFeed struct {
+	UserID    string    `json:"user_id" gorm:"primary_key"`
+	TokenHash string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewCalendarFeedToken 推測できないトークンを生成する
+func NewCalendarFeedToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashCalendarFeedToken 保存・照合に使うトークンのハッシュ
+func HashCalendarFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CommentTargetType コメントや変更履歴の対象
+type CommentTargetType string
+
+const (
+	CommentTargetIssue CommentTargetType = "issue"
+	CommentTargetEpic  CommentTargetType = "epic"
+)
+
+// mentionPattern 本文中の「@メールアドレス」をメンションとして扱う
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.+\-])@([\w.%+\-]+@[\w\-]+(?:\.[\w\-]+)+)`)
+
+type Comment struct {
+	ID             string            `json:"id"`
+	OrganizationID string            `json:"-"`
+	TargetType     CommentTargetType `json:"target_type"`
+	TargetID       string            `json:"target_id"`
+	UserID         string            `json:"user_id"`
+	Body           string            `json:"body"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+
+	Mentions []CommentMention `json:"mentions"`
+}
+
+type CommentMention struct {
+	ID        uint64    `json:"-"`
+	CommentID string    `json:"-"`
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// ParseMentions 本文からメンションされたメールアドレスを出現順に重複なく返す
+// コードブロック内の記述はメンションとして扱わない
+func ParseMentions(body string) []string {
+	var emails []string
+	seen := map[string]struct{}{}
+	for _, text := range splitOutsideCode(body) {
+		for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+			email := strings.ToLower(strings.TrimRight(m[1], "."))
+			if _, ok := seen[email]; ok {
+				continue
+			}
+			seen[email] = struct{}{}
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// splitOutsideCode markdownのコードブロックとインラインコードを除いた部分を返す
+func splitOutsideCode(body string) []string {
+	var parts []string
+	for i, block := range strings.Split(body, "```") {
+		if i%2 == 1 {
+			continue
+		}
+		for j, inline := range strings.Split(block, "`") {
+			if j%2 == 0 {
+				parts = append(parts, inline)
+			}
+		}
+	}
+	return parts
+}
@@ -0,0 +1,97 @@
+package models_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func TestParseMentions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "メンションが出現順に重複なく取得できること",
+			body: "@taro@example.com レビューお願いします。cc @hanako@example.co.jp @Taro@example.com",
+			want: []string{"taro@example.com", "hanako@example.co.jp"},
+		},
+		{
+			name: "文末のピリオドはメールアドレスに含めないこと",
+			body: "確認しました @taro@example.com.",
+			want: []string{"taro@example.com"},
+		},
+		{
+			name: "メールアドレスのみの記述はメンションとして扱わないこと",
+			body: "連絡先は taro@example.com です",
+			want: nil,
+		},
+		{
+			name: "コード内の記述はメンションとして扱わないこと",
+			body: "`@taro@example.com` と\n```\n@hanako@example.com\n```\n@jiro@example.com",
+			want: []string{"jiro@example.com"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, models.ParseMentions(tt.body))
+		})
+	}
+}
+
+func TestNewActivityEvents(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2022, 9, 20, 10, 0, 0, 0, time.UTC)
+
+	events := models.NewActivityEvents(models.CommentTargetIssue, "issue", "user",
+		map[string]string{
+			models.ActivityFieldStatus:    "waiting",
+			models.ActivityFieldAssignee:  "",
+			models.ActivityFieldMilestone: "m1",
+		},
+		map[string]string{
+			models.ActivityFieldStatus:    "in_progress",
+			models.ActivityFieldAssignee:  "",
+			models.ActivityFieldMilestone: "m2",
+		}, now)
+
+	assert.Equal(t, []models.ActivityEvent{
+		{TargetType: "issue", TargetID: "issue", UserID: "user", Field: "status", FromValue: "waiting", ToValue: "in_progress", CreatedAt: now},
+		{TargetType: "issue", TargetID: "issue", UserID: "user", Field: "milestone", FromValue: "m1", ToValue: "m2", CreatedAt: now},
+	}, events)
+}
+
+func TestBuildTimeline(t *testing.T) {
+	t.Parallel()
+	base := time.Date(2022, 9, 20, 10, 0, 0, 0, time.UTC)
+
+	timeline := models.BuildTimeline(
+		[]models.Comment{
+			{ID: "c2", CreatedAt: base.Add(2 * time.Minute)},
+			{ID: "c1", CreatedAt: base},
+		},
+		[]models.ActivityEvent{
+			{ID: 1, CreatedAt: base},
+			{ID: 2, CreatedAt: base.Add(time.Minute)},
+		},
+	)
+
+	var got []string
+	for _, item := range timeline {
+		if item.Comment != nil {
+			got = append(got, item.Type+":"+item.Comment.ID)
+			continue
+		}
+		got = append(got, item.Type+":"+strconv.FormatUint(item.Event.ID, 10))
+	}
+	assert.Equal(t, []string{"event:1", "comment:c1", "event:2", "comment:c2"}, got)
+}
@@ -8,6 +8,7 @@ import (
 
 type Coupon struct {
 	ID                string    `json:"id"`
+	OrganizationID    string    `json:"-"`
 	Title             string    `json:"title" binding:"required"`
 	Remarks           string    `json:"remarks" binding:"omitempty,max=255"`
 	DiscountAmount    uint64    `json:"discount_amount" binding:"omitempty,min=1"`
@@ -19,6 +20,7 @@ type Coupon struct {
 	PublicEndAt       time.Time `json:"public_end_at" binding:"required"`
 	IsPublic          bool      `json:"is_public"`
 	IsPremium         bool      `json:"is_premium"`
+	Version           uint      `json:"version"`
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
 }
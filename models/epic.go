@@ -4,6 +4,7 @@ import "time"
 
 type Epic struct {
 	ID              uint64    `json:"id"`
+	OrganizationID  string    `json:"-"`
 	IsOpen          bool      `json:"is_open" binding:"omitempty,boolean"`
 	AuthorID        string    `json:"author_id" binding:"required"`
 	EpicTitle       string    `json:"epic_title" binding:"required"`
@@ -14,6 +15,8 @@ type Epic struct {
 	ProjectID       string    `json:"project_id" binding:"required"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
+
+	Labels LabelList `json:"labels,omitempty" gorm:"many2many:epic_labels;association_autoupdate:false;association_autocreate:false;association_save_reference:false"`
 }
 
 type EpicList []Epic
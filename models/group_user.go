@@ -2,10 +2,25 @@ package models
 
 import "time"
 
+const (
+	GroupRoleOwner  = "owner"
+	GroupRoleMember = "member"
+)
+
 type GroupUser struct {
 	ID        int64     `json:"id"`
 	GroupID   string    `json:"group_id"`
 	UserID    string    `json:"user_id"`
+	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+func (GroupUser) TableName() string {
+	return "group_user"
+}
+
+// IsOwner グループを管理できる役割かどうか
+func (g *GroupUser) IsOwner() bool {
+	return g.Role == GroupRoleOwner
+}
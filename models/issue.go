@@ -2,15 +2,42 @@ package models
 
 import "time"
 
+const (
+	IssueStatusWaiting    = "waiting"
+	IssueStatusInProgress = "in_progress"
+	IssueStatusReview     = "review"
+	IssueStatusDone       = "done"
+)
+
 type Issue struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title" binding:"required"`
-	Description string    `json:"description" binding:"omitempty,max=255"`
-	UserID      string    `json:"user_id" binding:"omitempty"`
-	MilestoneID string    `json:"milestone_id" binding:"omitempty"`
-	IssueStatus string    `json:"issue_status" binding:"required"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             string    `json:"id"`
+	OrganizationID string    `json:"-"`
+	Title          string    `json:"title" binding:"required"`
+	Description    string    `json:"description" binding:"omitempty,max=255"`
+	UserID         string    `json:"user_id" binding:"omitempty"`
+	ProjectID      string    `json:"project_id" binding:"omitempty"`
+	MilestoneID    string    `json:"milestone_id" binding:"omitempty"`
+	EpicID         *uint64   `json:"epic_id" binding:"omitempty"`
+	IssueStatus    string    `json:"issue_status" binding:"required"`
+	StoryPoints    *uint     `json:"story_points" binding:"omitempty"`
+	BoardRank      string    `json:"board_rank"`
+	Version        uint      `json:"version"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 
 	Milestone *Milestone `json:"milestone"`
+	Labels    LabelList  `json:"labels,omitempty" gorm:"many2many:issue_labels;association_autoupdate:false;association_autocreate:false;association_save_reference:false"`
+}
+
+// IsClosed 完了しているかどうか
+func (i *Issue) IsClosed() bool {
+	return i.IssueStatus == IssueStatusDone
+}
+
+// Points ストーリーポイント。未設定の場合は0を返す
+func (i *Issue) Points() uint {
+	if i.StoryPoints == nil {
+		return 0
+	}
+	return *i.StoryPoints
 }
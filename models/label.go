@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type Label struct {
+	ID             string    `json:"id"`
+	OrganizationID string    `json:"-"`
+	ProjectID      string    `json:"project_id"`
+	Name           string    `json:"name"`
+	Color          string    `json:"color"`
+	Description    string    `json:"description"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type LabelList []Label
+
+// IDs ラベルIDの一覧を返す
+func (l LabelList) IDs() []string {
+	ids := make([]string, len(l))
+	for i, label := range l {
+		ids[i] = label.ID
+	}
+	return ids
+}
+
+// BelongTo 全てのラベルが指定したプロジェクトのものかどうか
+func (l LabelList) BelongTo(projectID string) bool {
+	for _, label := range l {
+		if label.ProjectID != projectID {
+			return false
+		}
+	}
+	return true
+}
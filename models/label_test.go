@@ -0,0 +1,23 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func TestLabelList_BelongTo(t *testing.T) {
+	t.Parallel()
+
+	labels := models.LabelList{
+		{ID: "l1", ProjectID: "p1"},
+		{ID: "l2", ProjectID: "p1"},
+	}
+
+	assert.Equal(t, []string{"l1", "l2"}, labels.IDs())
+	assert.True(t, labels.BelongTo("p1"))
+	assert.False(t, append(labels, models.Label{ID: "l3", ProjectID: "p2"}).BelongTo("p1"))
+	assert.True(t, models.LabelList{}.BelongTo("p1"))
+}
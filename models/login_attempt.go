@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+const (
+	LoginFailureUserNotFound     = "user_not_found"
+	LoginFailureInvalidPassword  = "invalid_password"
+	LoginFailureLocked           = "locked"
+	LoginFailureEmailNotVerified = "email_not_verified"
+	// LoginFailureInvalidTwoFactorCode パスワードは正しいが2段階認証のコードが間違っている
+	LoginFailureInvalidTwoFactorCode = "invalid_two_factor_code"
+)
+
+// LoginAttempt ログイン試行の監査記録
+// 存在しないメールアドレスへの試行も記録するため、UserIDは空になる場合がある
+type LoginAttempt struct {
+	ID            uint64    `json:"id"`
+	UserID        *string   `json:"user_id"`
+	Email         string    `json:"email"`
+	IPAddress     string    `json:"ip_address"`
+	UserAgent     string    `json:"user_agent"`
+	Succeeded     bool      `json:"succeeded"`
+	FailureReason string    `json:"failure_reason"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -7,15 +7,26 @@ import (
 )
 
 type Milestone struct {
-	ID                   string    `json:"id"`
-	MilestoneTitle       string    `json:"milestone_title" binding:"required,max=64"`
-	MilestoneDescription string    `json:"milestone_description" binding:"omitempty,max=255"`
-	ProjectID            string    `json:"project_id" binding:"required"`
-	CreatedAt            time.Time `json:"created_at"`
-	UpdatedAt            time.Time `json:"updated_at"`
+	ID                   string     `json:"id"`
+	OrganizationID       string     `json:"-"`
+	MilestoneTitle       string     `json:"milestone_title" binding:"required,max=64"`
+	MilestoneDescription string     `json:"milestone_description" binding:"omitempty,max=255"`
+	ProjectID            string     `json:"project_id" binding:"required"`
+	StartDate            *time.Time `json:"start_date,omitempty" binding:"omitempty"`
+	DueDate              *time.Time `json:"due_date,omitempty" binding:"omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
 }
 
 func (m *Milestone) CreateUUID() {
 	newUUID, _ := uuid.NewRandom()
 	m.ID = newUUID.String()
 }
+
+// HasValidPeriod 開始日と期日が両方ある場合に、期日が開始日以降かどうか
+func (m *Milestone) HasValidPeriod() bool {
+	if m.StartDate == nil || m.DueDate == nil {
+		return true
+	}
+	return !m.DueDate.Before(*m.StartDate)
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+const reportDateFormat = "2006-01-02"
+
+// MilestoneSnapshot マイルストーンの日次の進捗
+type MilestoneSnapshot struct {
+	ID           uint64    `json:"id"`
+	MilestoneID  string    `json:"milestone_id"`
+	SnapshotDate time.Time `json:"snapshot_date"`
+	OpenCount    int       `json:"open_count"`
+	ClosedCount  int       `json:"closed_count"`
+	OpenPoints   uint      `json:"open_points"`
+	ClosedPoints uint      `json:"closed_points"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// NewMilestoneSnapshot マイルストーンに属するissueから指定日の進捗を集計する
+func NewMilestoneSnapshot(milestoneID string, date time.Time, issues []Issue) MilestoneSnapshot {
+	snapshot := MilestoneSnapshot{
+		MilestoneID:  milestoneID,
+		SnapshotDate: truncateDate(date),
+	}
+	for i := range issues {
+		if issues[i].IsClosed() {
+			snapshot.ClosedCount++
+			snapshot.ClosedPoints += issues[i].Points()
+			continue
+		}
+		snapshot.OpenCount++
+		snapshot.OpenPoints += issues[i].Points()
+	}
+	return snapshot
+}
+
+type BurndownActual struct {
+	OpenCount    int  `json:"open_count"`
+	ClosedCount  int  `json:"closed_count"`
+	OpenPoints   uint `json:"open_points"`
+	ClosedPoints uint `json:"closed_points"`
+}
+
+// BurndownPoint バーンダウンチャートの1日分の値
+// Actualは記録が無い日(開始前や未来の日付)はnullになる
+type BurndownPoint struct {
+	Date            string          `json:"date"`
+	IdealOpenCount  float64         `json:"ideal_open_count"`
+	IdealOpenPoints float64         `json:"ideal_open_points"`
+	Actual          *BurndownActual `json:"actual"`
+}
+
+// BuildBurndown 開始日から期日までの日ごとの残数と理想線を返す
+// 開始日が無い場合は最初のスナップショットの日付、期日が無い場合はtodayまでを対象とする
+// スナップショットが無い日は直前のスナップショットの値を引き継ぎ、todayより後の日は実績を持たない
+func BuildBurndown(milestone *Milestone, snapshots []MilestoneSnapshot, today time.Time) []BurndownPoint {
+	today = truncateDate(today)
+	byDate := make(map[string]*MilestoneSnapshot, len(snapshots))
+	var first *MilestoneSnapshot
+	for i := range snapshots {
+		s := &snapshots[i]
+		byDate[s.SnapshotDate.Format(reportDateFormat)] = s
+		if first == nil || s.SnapshotDate.Before(first.SnapshotDate) {
+			first = s
+		}
+	}
+
+	start, end := today, today
+	switch {
+	case milestone.StartDate != nil:
+		start = truncateDate(*milestone.StartDate)
+	case first != nil:
+		start = truncateDate(first.SnapshotDate)
+	}
+	if milestone.DueDate != nil {
+		end = truncateDate(*milestone.DueDate)
+	}
+	if end.Before(start) {
+		return []BurndownPoint{}
+	}
+
+	days := int(end.Sub(start).Hours()/24) + 1
+	points := make([]BurndownPoint, days)
+	var current *MilestoneSnapshot
+	var initial *MilestoneSnapshot
+	for i := 0; i < days; i++ {
+		date := start.AddDate(0, 0, i)
+		key := date.Format(reportDateFormat)
+		if s, ok := byDate[key]; ok {
+			current = s
+		}
+		points[i].Date = key
+		if current != nil && !date.After(today) {
+			if initial == nil {
+				initial = current
+			}
+			points[i].Actual = &BurndownActual{
+				OpenCount:    current.OpenCount,
+				ClosedCount:  current.ClosedCount,
+				OpenPoints:   current.OpenPoints,
+				ClosedPoints: current.ClosedPoints,
+			}
+		}
+	}
+
+	if initial == nil && first != nil {
+		initial = first
+	}
+	if initial != nil {
+		for i := range points {
+			ratio := 1.0
+			if days > 1 {
+				ratio = 1 - float64(i)/float64(days-1)
+			}
+			points[i].IdealOpenCount = roundOneDecimal(float64(initial.OpenCount) * ratio)
+			points[i].IdealOpenPoints = roundOneDecimal(float64(initial.OpenPoints) * ratio)
+		}
+	}
+	return points
+}
+
+// VelocityPoint マイルストーンごとの計画量と完了量
+type VelocityPoint struct {
+	MilestoneID     string     `json:"milestone_id"`
+	MilestoneTitle  string     `json:"milestone_title"`
+	StartDate       *time.Time `json:"start_date"`
+	DueDate         *time.Time `json:"due_date"`
+	CommittedCount  int        `json:"committed_count"`
+	CompletedCount  int        `json:"completed_count"`
+	CommittedPoints uint       `json:"committed_points"`
+	CompletedPoints uint       `json:"completed_points"`
+}
+
+type Velocity struct {
+	Milestones             []VelocityPoint `json:"milestones"`
+	AverageCompletedCount  float64         `json:"average_completed_count"`
+	AverageCompletedPoints float64         `json:"average_completed_points"`
+}
+
+// BuildVelocity マイルストーンごとの完了量を集計する
+// 平均は期日がtodayより前のマイルストーンのみを対象とする
+func BuildVelocity(milestones []Milestone, issues []Issue, today time.Time) *Velocity {
+	index := make(map[string]int, len(milestones))
+	velocity := &Velocity{Milestones: make([]VelocityPoint, len(milestones))}
+	for i, m := range milestones {
+		index[m.ID] = i
+		velocity.Milestones[i] = VelocityPoint{
+			MilestoneID:    m.ID,
+			MilestoneTitle: m.MilestoneTitle,
+			StartDate:      m.StartDate,
+			DueDate:        m.DueDate,
+		}
+	}
+	for i := range issues {
+		idx, ok := index[issues[i].MilestoneID]
+		if !ok {
+			continue
+		}
+		p := &velocity.Milestones[idx]
+		p.CommittedCount++
+		p.CommittedPoints += issues[i].Points()
+		if issues[i].IsClosed() {
+			p.CompletedCount++
+			p.CompletedPoints += issues[i].Points()
+		}
+	}
+
+	var finished, count int
+	var points uint
+	today = truncateDate(today)
+	for i, m := range milestones {
+		if m.DueDate == nil || !truncateDate(*m.DueDate).Before(today) {
+			continue
+		}
+		finished++
+		count += velocity.Milestones[i].CompletedCount
+		points += velocity.Milestones[i].CompletedPoints
+	}
+	if finished > 0 {
+		velocity.AverageCompletedCount = roundOneDecimal(float64(count) / float64(finished))
+		velocity.AverageCompletedPoints = roundOneDecimal(float64(points) / float64(finished))
+	}
+	return velocity
+}
+
+func truncateDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func roundOneDecimal(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,99 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func uintPtr(v uint) *uint {
+	return &v
+}
+
+func date(day int) time.Time {
+	return time.Date(2022, 10, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestNewMilestoneSnapshot(t *testing.T) {
+	t.Parallel()
+
+	snapshot := models.NewMilestoneSnapshot("m1", date(3).Add(15*time.Hour), []models.Issue{
+		{IssueStatus: models.IssueStatusDone, StoryPoints: uintPtr(3)},
+		{IssueStatus: models.IssueStatusReview, StoryPoints: uintPtr(5)},
+		{IssueStatus: models.IssueStatusWaiting},
+	})
+
+	assert.Equal(t, models.MilestoneSnapshot{
+		MilestoneID:  "m1",
+		SnapshotDate: date(3),
+		OpenCount:    2,
+		ClosedCount:  1,
+		OpenPoints:   5,
+		ClosedPoints: 3,
+	}, snapshot)
+}
+
+func TestBuildBurndown(t *testing.T) {
+	t.Parallel()
+
+	start, due := date(1), date(5)
+	milestone := &models.Milestone{ID: "m1", StartDate: &start, DueDate: &due}
+	snapshots := []models.MilestoneSnapshot{
+		{SnapshotDate: date(1), OpenCount: 4, OpenPoints: 8},
+		{SnapshotDate: date(3), OpenCount: 2, ClosedCount: 2, OpenPoints: 3, ClosedPoints: 5},
+	}
+
+	points := models.BuildBurndown(milestone, snapshots, date(4))
+
+	require.Len(t, points, 5)
+	assert.Equal(t, "2022-10-01", points[0].Date)
+	assert.Equal(t, 8.0, points[0].IdealOpenPoints)
+	assert.Equal(t, 2.0, points[2].IdealOpenCount)
+	assert.Equal(t, 0.0, points[4].IdealOpenPoints)
+	t.Run("スナップショットが無い日は直前の値を引き継ぐこと", func(t *testing.T) {
+		assert.Equal(t, 4, points[1].Actual.OpenCount)
+		assert.Equal(t, 2, points[3].Actual.OpenCount)
+	})
+	t.Run("todayより後の日は実績を持たないこと", func(t *testing.T) {
+		assert.Nil(t, points[4].Actual)
+	})
+}
+
+func TestBuildBurndown_InvalidPeriod(t *testing.T) {
+	t.Parallel()
+
+	start, due := date(5), date(1)
+	points := models.BuildBurndown(&models.Milestone{StartDate: &start, DueDate: &due}, nil, date(3))
+
+	assert.Empty(t, points)
+}
+
+func TestBuildVelocity(t *testing.T) {
+	t.Parallel()
+
+	due1, due2 := date(7), date(14)
+	milestones := []models.Milestone{
+		{ID: "m1", MilestoneTitle: "sprint1", DueDate: &due1},
+		{ID: "m2", MilestoneTitle: "sprint2", DueDate: &due2},
+	}
+	issues := []models.Issue{
+		{MilestoneID: "m1", IssueStatus: models.IssueStatusDone, StoryPoints: uintPtr(3)},
+		{MilestoneID: "m1", IssueStatus: models.IssueStatusDone, StoryPoints: uintPtr(2)},
+		{MilestoneID: "m1", IssueStatus: models.IssueStatusReview, StoryPoints: uintPtr(8)},
+		{MilestoneID: "m2", IssueStatus: models.IssueStatusDone, StoryPoints: uintPtr(1)},
+		{MilestoneID: "other", IssueStatus: models.IssueStatusDone},
+	}
+
+	velocity := models.BuildVelocity(milestones, issues, date(10))
+
+	assert.Equal(t, 3, velocity.Milestones[0].CommittedCount)
+	assert.Equal(t, uint(13), velocity.Milestones[0].CommittedPoints)
+	assert.Equal(t, uint(5), velocity.Milestones[0].CompletedPoints)
+	assert.Equal(t, uint(1), velocity.Milestones[1].CompletedPoints)
+	assert.Equal(t, 5.0, velocity.AverageCompletedPoints)
+	assert.Equal(t, 2.0, velocity.AverageCompletedCount)
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	NotificationTypeTodoReminder = "todo_reminder"
+)
+
+// Notification ユーザーへのアプリ内通知
+type Notification struct {
+	ID               uint64     `json:"id"`
+	UserID           string     `json:"user_id"`
+	NotificationType string     `json:"notification_type"`
+	Title            string     `json:"title"`
+	Body             string     `json:"body"`
+	TargetType       string     `json:"target_type"`
+	TargetID         string     `json:"target_id"`
+	ReadAt           *time.Time `json:"read_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// NewTodoReminderNotification todoのリマインド通知を作成する
+func NewTodoReminderNotification(todo *Todo, now time.Time) *Notification {
+	body := todo.Body
+	if todo.DueAt != nil {
+		body = "期限: " + todo.DueAt.Format("2006-01-02 15:04") + "\n" + body
+	}
+	var userID string
+	if todo.UserID != nil {
+		userID = *todo.UserID
+	}
+	return &Notification{
+		UserID:           userID,
+		NotificationType: NotificationTypeTodoReminder,
+		Title:            todo.Title,
+		Body:             body,
+		TargetType:       "todo",
+		TargetID:         strconv.FormatUint(todo.ID, 10),
+		CreatedAt:        now,
+	}
+}
@@ -20,15 +20,35 @@ const (
 )
 
 type Order struct {
-	ID           string          `json:"id"`
-	UserID       string          `json:"user_id" binding:"required,min=1"`
-	Quantity     int64           `json:"quantity"`
-	TotalPrice   int64           `json:"total_price"`
-	OrderStatus  OrderStatus     `json:"order_status" binding:"required,oneof=new paid cancelled delivered refunded returned partially partially_paid"`
-	Remarks      string          `json:"remarks" binding:"omitempty,max=255"`
-	OrderDetails OrderDetailList `json:"order_details" binding:"omitempty,dive"`
-	CreatedAt    time.Time       `json:"created_at"`
-	UpdatedAt    time.Time       `json:"updated_at"`
+	ID             string          `json:"id"`
+	OrganizationID string          `json:"-"`
+	UserID         string          `json:"user_id" binding:"required,min=1"`
+	Quantity       int64           `json:"quantity"`
+	TotalPrice     int64           `json:"total_price"`
+	OrderStatus    OrderStatus     `json:"order_status" binding:"required,oneof=new paid cancelled delivered refunded returned partially partially_paid"`
+	Remarks        string          `json:"remarks" binding:"omitempty,max=255"`
+	OrderDetails   OrderDetailList `json:"order_details" binding:"omitempty,dive"`
+	Version        uint            `json:"version"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// IsPaymentDrivenStatus 決済結果によってのみ遷移するステータスかどうか
+func (s OrderStatus) IsPaymentDrivenStatus() bool {
+	switch s {
+	case OrderStatusPaid, OrderStatusPartiallyPaid, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// IsRefundStatus 明細の返金・返品によって遷移するステータスかどうか
+func (s OrderStatus) IsRefundStatus() bool {
+	switch s {
+	case OrderStatusPartially, OrderStatusReturned, OrderStatusRefunded:
+		return true
+	}
+	return false
 }
 
 func (p *Order) CreateUUID() string {
@@ -17,6 +17,7 @@ const (
 
 type OrderDetail struct {
 	ID                string            `json:"id"`
+	OrganizationID    string            `json:"-"`
 	OrderID           string            `json:"order_id" binding:"omitempty,len=36"`
 	ProductID         string            `json:"product_id" binding:"required,len=36"`
 	Quantity          int64             `json:"quantity" binding:"required,gte=1"`
@@ -24,6 +25,26 @@ type OrderDetail struct {
 	Price             int64             `json:"price" binding:"required,gte=1"`
 }
 
+// IsClosed 返金・返品・キャンセル済みで注文金額の対象外となる明細かどうか
+func (s OrderDetailStatus) IsClosed() bool {
+	switch s {
+	case OrderDetailStatusCanceled, OrderDetailStatusRefunded, OrderDetailStatusReturned:
+		return true
+	}
+	return false
+}
+
+// Active 金額計算の対象となる明細のみを返す
+func (l *OrderDetailList) Active() OrderDetailList {
+	active := OrderDetailList{}
+	for _, v := range *l {
+		if !v.OrderDetailStatus.IsClosed() {
+			active = append(active, v)
+		}
+	}
+	return active
+}
+
 func (l *OrderDetailList) TotalPrice() int64 {
 	var totalPrice int64
 	for _, v := range *l {
@@ -0,0 +1,26 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func TestOrderDetailListActive(t *testing.T) {
+	t.Parallel()
+
+	details := models.OrderDetailList{
+		{ID: "1", Quantity: 2, Price: 100, OrderDetailStatus: models.OrderDetailStatusNew},
+		{ID: "2", Quantity: 1, Price: 300, OrderDetailStatus: models.OrderDetailStatusRefunded},
+		{ID: "3", Quantity: 1, Price: 500, OrderDetailStatus: models.OrderDetailStatusReturned},
+		{ID: "4", Quantity: 3, Price: 50, OrderDetailStatus: models.OrderDetailStatusDelivered},
+	}
+
+	active := details.Active()
+	assert.Len(t, active, 2)
+	assert.Equal(t, int64(350), active.TotalPrice())
+	assert.Equal(t, int64(5), active.TotalQuantity())
+	assert.Equal(t, int64(1150), details.TotalPrice())
+}
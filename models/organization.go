@@ -0,0 +1,146 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// DefaultOrganizationID 組織の導入前から存在するデータが所属する組織
+const DefaultOrganizationID = "00000000-0000-4000-8000-000000000001"
+
+// organizationScopeKey OrganizationScopeで設定した組織IDをコールバックへ渡すキー
+const organizationScopeKey = "tenant:organization_id"
+
+var (
+	// ErrOrganizationMismatch JWTとヘッダーで異なる組織が指定された
+	ErrOrganizationMismatch = errors.New("organization in token and header do not match")
+	// ErrOrganizationForbidden ユーザーが所属していない組織が指定された
+	ErrOrganizationForbidden = errors.New("user does not belong to the organization")
+)
+
+// tenantTables organization_idで組織ごとに分離するテーブル
+// 親のレコードを経由してのみ参照する子テーブル(チェックリストや履歴など)は親の絞り込みに従う
+var tenantTables = map[string]bool{
+	"users":                 true,
+	"todos":                 true,
+	"products":              true,
+	"orders":                true,
+	"order_details":         true,
+	"coupons":               true,
+	"user_groups":           true,
+	"milestones":            true,
+	"epics":                 true,
+	"projects":              true,
+	"issues":                true,
+	"labels":                true,
+	"comments":              true,
+	"subscription_plans":    true,
+	"subscription_members":  true,
+	"payments":              true,
+	"refunds":               true,
+	"webhook_subscriptions": true,
+	"webhook_deliveries":    true,
+	"api_keys":              true,
+}
+
+type Organization struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (o *Organization) CreateUUID() {
+	newUUID, _ := uuid.NewRandom()
+	o.ID = newUUID.String()
+}
+
+// IsTenantTable 組織ごとに分離するテーブルかどうか
+func IsTenantTable(table string) bool {
+	return tenantTables[table]
+}
+
+// ResolveOrganizationID リクエストの対象となる組織を決める
+// JWTの組織を優先し、無ければヘッダーの組織、どちらも無ければユーザーの所属組織を使う
+func ResolveOrganizationID(userOrganizationID, tokenOrganizationID, headerOrganizationID string) (string, error) {
+	if tokenOrganizationID != "" && headerOrganizationID != "" && tokenOrganizationID != headerOrganizationID {
+		return "", ErrOrganizationMismatch
+	}
+	organizationID := tokenOrganizationID
+	if organizationID == "" {
+		organizationID = headerOrganizationID
+	}
+	if organizationID == "" {
+		organizationID = userOrganizationID
+	}
+	if organizationID == "" || organizationID != userOrganizationID {
+		return "", ErrOrganizationForbidden
+	}
+	return organizationID, nil
+}
+
+// OrganizationScope 以降のクエリを指定した組織のレコードに絞り込むスコープ
+// 絞り込みはRegisterTenantCallbacksで登録したコールバックが行う。組織IDが空の場合は絞り込まない
+func OrganizationScope(organizationID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(organizationScopeKey, organizationID)
+	}
+}
+
+// RegisterTenantCallbacks 組織ごとに分離するテーブルへの参照・更新・削除に組織の条件を加え、作成時に組織を設定する
+func RegisterTenantCallbacks(db *gorm.DB) {
+	db.Callback().Create().Before("gorm:create").Register("tenant:assign_organization", assignCreatingOrganization)
+	db.Callback().Update().Before("gorm:update").Register("tenant:assign_organization", assignOrganization)
+	db.Callback().Update().Before("gorm:update").Register("tenant:scope_organization", scopeOrganization)
+	db.Callback().Query().Before("gorm:query").Register("tenant:scope_organization", scopeOrganization)
+	db.Callback().RowQuery().Before("gorm:row_query").Register("tenant:scope_organization", scopeOrganization)
+	db.Callback().Delete().Before("gorm:delete").Register("tenant:scope_organization", scopeOrganization)
+}
+
+func scopedOrganizationID(scope *gorm.Scope) string {
+	if !IsTenantTable(scope.TableName()) {
+		return ""
+	}
+	v, ok := scope.Get(organizationScopeKey)
+	if !ok {
+		return ""
+	}
+	organizationID, _ := v.(string)
+	return organizationID
+}
+
+func scopeOrganization(scope *gorm.Scope) {
+	if organizationID := scopedOrganizationID(scope); organizationID != "" {
+		scope.Search.Where(fmt.Sprintf("%v.organization_id = ?", scope.QuotedTableName()), organizationID)
+	}
+}
+
+// assignOrganization 絞り込み中の組織をレコードに設定する。別の組織を指定していても上書きする
+func assignOrganization(scope *gorm.Scope) {
+	organizationID := scopedOrganizationID(scope)
+	if organizationID == "" {
+		return
+	}
+	if field, ok := scope.FieldByName("OrganizationID"); ok && field.Field.String() != organizationID {
+		_ = scope.SetColumn("OrganizationID", organizationID)
+	}
+}
+
+// assignCreatingOrganization 作成するレコードに組織を設定する
+// バッチなど組織を絞り込まずに作成し、組織も未設定の場合はデフォルトの組織にする
+func assignCreatingOrganization(scope *gorm.Scope) {
+	if scopedOrganizationID(scope) != "" {
+		assignOrganization(scope)
+		return
+	}
+	if !IsTenantTable(scope.TableName()) {
+		return
+	}
+	if field, ok := scope.FieldByName("OrganizationID"); ok && field.IsBlank {
+		_ = scope.SetColumn("OrganizationID", DefaultOrganizationID)
+	}
+}
@@ -0,0 +1,49 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func TestResolveOrganizationID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		user    string
+		token   string
+		header  string
+		want    string
+		wantErr error
+	}{
+		{name: "JWTの組織を使うこと", user: "org-a", token: "org-a", want: "org-a"},
+		{name: "JWTに組織が無い場合はヘッダーの組織を使うこと", user: "org-a", header: "org-a", want: "org-a"},
+		{name: "どちらも無い場合はユーザーの所属組織を使うこと", user: "org-a", want: "org-a"},
+		{name: "JWTとヘッダーの組織が異なる場合はエラーになること", user: "org-a", token: "org-a", header: "org-b", wantErr: models.ErrOrganizationMismatch},
+		{name: "所属していない組織をヘッダーで指定した場合はエラーになること", user: "org-a", header: "org-b", wantErr: models.ErrOrganizationForbidden},
+		{name: "所属していない組織のJWTはエラーになること", user: "org-a", token: "org-b", wantErr: models.ErrOrganizationForbidden},
+		{name: "組織に所属していないユーザーはエラーになること", wantErr: models.ErrOrganizationForbidden},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := models.ResolveOrganizationID(tt.user, tt.token, tt.header)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsTenantTable(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, models.IsTenantTable("orders"))
+	assert.True(t, models.IsTenantTable("webhook_subscriptions"))
+	// 親のレコードを経由して参照するテーブルは対象外
+	assert.False(t, models.IsTenantTable("todo_checklist_items"))
+	assert.False(t, models.IsTenantTable("organizations"))
+}
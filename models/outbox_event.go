@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type OutboxEvent struct {
+	ID          uint64 `json:"id"`
+	Topic       string `json:"topic"`
+	AggregateID string `json:"aggregate_id"`
+	DedupKey    string `json:"dedup_key"`
+	Payload     string `json:"payload"`
+	Attempts    int    `json:"attempts"`
+	LastError   string `json:"last_error"`
+	// ClaimToken 発行のために取得したリレーの識別子。ClaimedUntilを過ぎると他のリレーが取得できる
+	ClaimToken   *string    `json:"-"`
+	ClaimedUntil *time.Time `json:"-"`
+	PublishedAt  *time.Time `json:"published_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// IsPublished ブローカーへの発行が完了しているかどうか
+func (e *OutboxEvent) IsPublished() bool {
+	return e.PublishedAt != nil
+}
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+type PaymentStatus string
+
+const (
+	PaymentStatusAuthorized        PaymentStatus = "authorized"
+	PaymentStatusCaptured          PaymentStatus = "captured"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+	PaymentStatusFailed            PaymentStatus = "failed"
+)
+
+type Payment struct {
+	ID             string        `json:"id"`
+	OrganizationID string        `json:"-"`
+	OrderID        string        `json:"order_id"`
+	Provider       string        `json:"provider"`
+	TransactionID  string        `json:"transaction_id"`
+	Amount         int64         `json:"amount"`
+	CapturedAmount int64         `json:"captured_amount"`
+	RefundedAmount int64         `json:"refunded_amount"`
+	PaymentStatus  PaymentStatus `json:"payment_status"`
+	FailureReason  string        `json:"failure_reason"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+type PaymentList []Payment
+
+// NetCapturedAmount 売上確定額から返金額を差し引いた実入金額
+func (l PaymentList) NetCapturedAmount() int64 {
+	var amount int64
+	for _, p := range l {
+		amount += p.CapturedAmount - p.RefundedAmount
+	}
+	return amount
+}
+
+// AuthorizedAmount 売上確定していないオーソリ額の合計
+func (l PaymentList) AuthorizedAmount() int64 {
+	var amount int64
+	for _, p := range l {
+		if p.PaymentStatus == PaymentStatusAuthorized {
+			amount += p.Amount
+		}
+	}
+	return amount
+}
+
+// RefundedAmount 返金額の合計
+func (l PaymentList) RefundedAmount() int64 {
+	var amount int64
+	for _, p := range l {
+		amount += p.RefundedAmount
+	}
+	return amount
+}
+
+// OrderStatus 決済の結果から注文ステータスを導出する
+// 決済による変更がない場合と、明細の返金・返品によるステータスの場合はcurrentをそのまま返す
+func (l PaymentList) OrderStatus(totalPrice int64, current OrderStatus) OrderStatus {
+	if current.IsRefundStatus() {
+		return current
+	}
+	net := l.NetCapturedAmount()
+	switch {
+	case net <= 0 && l.RefundedAmount() > 0:
+		return OrderStatusRefunded
+	case net > 0 && net >= totalPrice:
+		return OrderStatusPaid
+	case net > 0:
+		return OrderStatusPartiallyPaid
+	}
+	return current
+}
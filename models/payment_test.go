@@ -0,0 +1,81 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func TestPaymentListOrderStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		payments models.PaymentList
+		want     models.OrderStatus
+	}{
+		{
+			name:     "決済がない場合は現在のステータスのままであること",
+			payments: models.PaymentList{},
+			want:     models.OrderStatusNew,
+		},
+		{
+			name:     "オーソリのみの場合は現在のステータスのままであること",
+			payments: models.PaymentList{{Amount: 1000}},
+			want:     models.OrderStatusNew,
+		},
+		{
+			name:     "合計金額を売上確定した場合は支払済になること",
+			payments: models.PaymentList{{Amount: 1000, CapturedAmount: 1000}},
+			want:     models.OrderStatusPaid,
+		},
+		{
+			name:     "一部のみ売上確定した場合は一部支払済になること",
+			payments: models.PaymentList{{Amount: 400, CapturedAmount: 400}},
+			want:     models.OrderStatusPartiallyPaid,
+		},
+		{
+			name:     "一部返金した場合は一部支払済になること",
+			payments: models.PaymentList{{Amount: 1000, CapturedAmount: 1000, RefundedAmount: 300}},
+			want:     models.OrderStatusPartiallyPaid,
+		},
+		{
+			name:     "全額返金した場合は返金済になること",
+			payments: models.PaymentList{{Amount: 1000, CapturedAmount: 1000, RefundedAmount: 1000}},
+			want:     models.OrderStatusRefunded,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, tt.payments.OrderStatus(1000, models.OrderStatusNew))
+		})
+	}
+}
+
+func TestPaymentListOrderStatusKeepsRefundStatus(t *testing.T) {
+	t.Parallel()
+
+	payments := models.PaymentList{{Amount: 1000, CapturedAmount: 1000, RefundedAmount: 300}}
+	for _, current := range []models.OrderStatus{
+		models.OrderStatusPartially, models.OrderStatusReturned, models.OrderStatusRefunded,
+	} {
+		assert.Equal(t, current, payments.OrderStatus(700, current), "明細の返金・返品によるステータスは決済で上書きされないこと")
+	}
+}
+
+func TestPaymentListAuthorizedAmount(t *testing.T) {
+	t.Parallel()
+
+	payments := models.PaymentList{
+		{Amount: 300, PaymentStatus: models.PaymentStatusAuthorized},
+		{Amount: 200, CapturedAmount: 200, PaymentStatus: models.PaymentStatusCaptured},
+		{Amount: 100, PaymentStatus: models.PaymentStatusFailed},
+		{Amount: 400, PaymentStatus: models.PaymentStatusAuthorized},
+	}
+	assert.Equal(t, int64(700), payments.AuthorizedAmount(), "売上確定していないオーソリのみを合計すること")
+}
@@ -6,8 +6,10 @@ import (
 
 type Project struct {
 	ID                 string `json:"id"`
+	OrganizationID     string `json:"-"`
 	ProjectTitle       string `json:"project_title" binding:"required,max=64"`
 	ProjectDescription string `json:"project_description" binding:"omitempty,max=255"`
+	Version            uint   `json:"version"`
 
 	Epics EpicList `json:"epics" binding:"omitempty,dive"`
 }
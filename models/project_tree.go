@@ -0,0 +1,128 @@
+package models
+
+// Progress 配下の項目の進捗の集計
+type Progress struct {
+	OpenCount       int     `json:"open_count"`
+	ClosedCount     int     `json:"closed_count"`
+	PercentComplete float64 `json:"percent_complete"`
+}
+
+func NewProgress(open, closed int) Progress {
+	p := Progress{OpenCount: open, ClosedCount: closed}
+	if total := open + closed; total > 0 {
+		p.PercentComplete = roundOneDecimal(float64(closed) / float64(total) * 100)
+	}
+	return p
+}
+
+// Add 2つの進捗を合算する
+func (p Progress) Add(other Progress) Progress {
+	return NewProgress(p.OpenCount+other.OpenCount, p.ClosedCount+other.ClosedCount)
+}
+
+type EpicNode struct {
+	Epic
+	Progress Progress `json:"progress"`
+	Issues   []Issue  `json:"issues"`
+}
+
+type MilestoneNode struct {
+	Milestone
+	Progress Progress   `json:"progress"`
+	Epics    []EpicNode `json:"epics"`
+	Issues   []Issue    `json:"issues"`
+}
+
+// ProjectTree プロジェクト配下のマイルストーン・エピック・Issueの階層
+// マイルストーンが未設定のエピックはBacklogにまとめる
+type ProjectTree struct {
+	ID                 string          `json:"id"`
+	ProjectTitle       string          `json:"project_title"`
+	ProjectDescription string          `json:"project_description"`
+	Progress           Progress        `json:"progress"`
+	Milestones         []MilestoneNode `json:"milestones"`
+	Backlog            []EpicNode      `json:"backlog"`
+}
+
+// BuildProjectTree 取得済みの各要素を階層に組み立て、進捗を集計する
+// エピックに紐づくIssueはエピックの配下に、それ以外はマイルストーンの配下に置く
+// エピックの進捗は配下のIssueから、Issueが無い場合はエピック自身の開閉から求める
+func BuildProjectTree(project *Project, milestones []Milestone, epics EpicList, issues []Issue) *ProjectTree {
+	tree := &ProjectTree{
+		ID:                 project.ID,
+		ProjectTitle:       project.ProjectTitle,
+		ProjectDescription: project.ProjectDescription,
+		Milestones:         make([]MilestoneNode, len(milestones)),
+	}
+	index := make(map[string]int, len(milestones))
+	for i, m := range milestones {
+		tree.Milestones[i] = MilestoneNode{Milestone: m, Epics: []EpicNode{}, Issues: []Issue{}}
+		index[m.ID] = i
+	}
+
+	epicIssues := make(map[uint64][]Issue, len(epics))
+	for _, e := range epics {
+		epicIssues[e.ID] = []Issue{}
+	}
+	for _, issue := range issues {
+		if issue.EpicID != nil {
+			if list, ok := epicIssues[*issue.EpicID]; ok {
+				epicIssues[*issue.EpicID] = append(list, issue)
+				continue
+			}
+		}
+		if i, ok := index[issue.MilestoneID]; ok {
+			tree.Milestones[i].Issues = append(tree.Milestones[i].Issues, issue)
+		}
+	}
+
+	tree.Backlog = []EpicNode{}
+	for _, e := range epics {
+		node := EpicNode{Epic: e, Issues: epicIssues[e.ID]}
+		node.Progress = node.rollup()
+		if i, ok := index[e.MilestoneID]; ok {
+			tree.Milestones[i].Epics = append(tree.Milestones[i].Epics, node)
+			continue
+		}
+		tree.Backlog = append(tree.Backlog, node)
+		tree.Progress = tree.Progress.Add(node.Progress)
+	}
+
+	for i := range tree.Milestones {
+		m := &tree.Milestones[i]
+		for _, e := range m.Epics {
+			m.Progress = m.Progress.Add(e.Progress)
+		}
+		for j := range m.Issues {
+			m.Progress = m.Progress.Add(issueProgress(&m.Issues[j]))
+		}
+		tree.Progress = tree.Progress.Add(m.Progress)
+	}
+	return tree
+}
+
+func (n *EpicNode) rollup() Progress {
+	if len(n.Issues) == 0 {
+		return n.itemProgress()
+	}
+	var p Progress
+	for i := range n.Issues {
+		p = p.Add(issueProgress(&n.Issues[i]))
+	}
+	return p
+}
+
+// itemProgress Issueが無いエピック自身を1件として数える
+func (n *EpicNode) itemProgress() Progress {
+	if n.IsOpen {
+		return NewProgress(1, 0)
+	}
+	return NewProgress(0, 1)
+}
+
+func issueProgress(issue *Issue) Progress {
+	if issue.IsClosed() {
+		return NewProgress(0, 1)
+	}
+	return NewProgress(1, 0)
+}
@@ -0,0 +1,84 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func TestNewProgress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		open   int
+		closed int
+		want   float64
+	}{
+		{name: "項目が無い場合は0%になること", want: 0},
+		{name: "完了率が小数第1位で丸められること", open: 2, closed: 1, want: 33.3},
+		{name: "全て完了している場合は100%になること", closed: 4, want: 100},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, models.NewProgress(tt.open, tt.closed).PercentComplete)
+		})
+	}
+}
+
+func TestBuildProjectTree(t *testing.T) {
+	t.Parallel()
+
+	project := &models.Project{ID: "project", ProjectTitle: "title"}
+	milestones := []models.Milestone{{ID: "m1"}, {ID: "m2"}}
+	epics := models.EpicList{
+		{ID: 1, MilestoneID: "m1", IsOpen: true},
+		{ID: 2, MilestoneID: "m1", IsOpen: false},
+		{ID: 3, IsOpen: true},
+	}
+	issues := []models.Issue{
+		{ID: "i1", MilestoneID: "m1", IssueStatus: models.IssueStatusDone},
+		{ID: "i2", MilestoneID: "m2", IssueStatus: "waiting"},
+		{ID: "i3", MilestoneID: "other", IssueStatus: "waiting"},
+	}
+
+	tree := models.BuildProjectTree(project, milestones, epics, issues)
+
+	assert.Equal(t, "project", tree.ID)
+	assert.Len(t, tree.Milestones, 2)
+	assert.Len(t, tree.Milestones[0].Epics, 2)
+	assert.Len(t, tree.Milestones[0].Issues, 1)
+	assert.Equal(t, models.NewProgress(1, 2), tree.Milestones[0].Progress)
+	assert.Equal(t, models.NewProgress(1, 0), tree.Milestones[1].Progress)
+	assert.Equal(t, models.NewProgress(0, 1), tree.Milestones[0].Epics[1].Progress)
+	assert.Len(t, tree.Backlog, 1)
+	assert.Equal(t, uint64(3), tree.Backlog[0].ID)
+	assert.Equal(t, models.NewProgress(3, 2), tree.Progress)
+}
+
+func TestBuildProjectTree_EpicIssues(t *testing.T) {
+	t.Parallel()
+
+	epicID := uint64(1)
+	tree := models.BuildProjectTree(
+		&models.Project{ID: "project"},
+		[]models.Milestone{{ID: "m1"}},
+		models.EpicList{{ID: epicID, MilestoneID: "m1", IsOpen: true}},
+		[]models.Issue{
+			{ID: "i1", MilestoneID: "m1", EpicID: &epicID, IssueStatus: models.IssueStatusDone},
+			{ID: "i2", EpicID: &epicID, IssueStatus: models.IssueStatusReview},
+			{ID: "i3", MilestoneID: "m1", IssueStatus: models.IssueStatusDone},
+		},
+	)
+
+	epic := tree.Milestones[0].Epics[0]
+	assert.Len(t, epic.Issues, 2)
+	assert.Equal(t, models.NewProgress(1, 1), epic.Progress)
+	assert.Len(t, tree.Milestones[0].Issues, 1)
+	assert.Equal(t, models.NewProgress(1, 2), tree.Milestones[0].Progress)
+	assert.Equal(t, models.NewProgress(1, 2), tree.Progress)
+}
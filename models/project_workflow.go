@@ -0,0 +1,85 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultIssueWorkflow ワークフローが設定されていないプロジェクトで使うステータスの並び
+var DefaultIssueWorkflow = IssueWorkflow{
+	IssueStatusWaiting,
+	IssueStatusInProgress,
+	IssueStatusReview,
+	IssueStatusDone,
+}
+
+// ProjectWorkflow プロジェクトごとのIssueステータスの並び
+// Statusesはカンマ区切りで保存する
+type ProjectWorkflow struct {
+	ProjectID string    `json:"project_id" gorm:"primary_key"`
+	Statuses  string    `json:"statuses"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Workflow 保存されたステータスの並びを返す。未設定の場合は既定のワークフローを返す
+func (w *ProjectWorkflow) Workflow() IssueWorkflow {
+	if w == nil || w.Statuses == "" {
+		return DefaultIssueWorkflow
+	}
+	return strings.Split(w.Statuses, ",")
+}
+
+// IssueWorkflow 先頭が初期ステータス、末尾が完了ステータスとなるステータスの並び
+type IssueWorkflow []string
+
+// Initial 作成時のステータス
+func (w IssueWorkflow) Initial() string {
+	return w[0]
+}
+
+func (w IssueWorkflow) Contains(status string) bool {
+	return w.indexOf(status) >= 0
+}
+
+// CanTransition fromからtoへ遷移できるかどうか
+// 隣り合うステータスへの前進と差し戻しのみを許可する
+func (w IssueWorkflow) CanTransition(from, to string) bool {
+	i, j := w.indexOf(from), w.indexOf(to)
+	if i < 0 || j < 0 {
+		return false
+	}
+	return j-i == 1 || i-j == 1 || i == j
+}
+
+// Validate ステータスが2つ以上で重複が無く、末尾が完了ステータスであることを確認する
+// 完了の判定はIssueStatusDoneで行うため、末尾は必ずdoneとする
+func (w IssueWorkflow) Validate() bool {
+	if len(w) < 2 || w[len(w)-1] != IssueStatusDone {
+		return false
+	}
+	seen := make(map[string]struct{}, len(w))
+	for _, s := range w {
+		if s == "" || strings.Contains(s, ",") {
+			return false
+		}
+		if _, ok := seen[s]; ok {
+			return false
+		}
+		seen[s] = struct{}{}
+	}
+	return true
+}
+
+func (w IssueWorkflow) String() string {
+	return strings.Join(w, ",")
+}
+
+func (w IssueWorkflow) indexOf(status string) int {
+	for i, s := range w {
+		if s == status {
+			return i
+		}
+	}
+	return -1
+}
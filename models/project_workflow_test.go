@@ -0,0 +1,71 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func TestProjectWorkflow_Workflow(t *testing.T) {
+	t.Parallel()
+
+	t.Run("未設定の場合は既定のワークフローになること", func(t *testing.T) {
+		t.Parallel()
+		var w *models.ProjectWorkflow
+		assert.Equal(t, models.DefaultIssueWorkflow, w.Workflow())
+	})
+
+	t.Run("保存されたステータスの並びが返ること", func(t *testing.T) {
+		t.Parallel()
+		w := &models.ProjectWorkflow{Statuses: "todo,doing,done"}
+		assert.Equal(t, models.IssueWorkflow{"todo", "doing", "done"}, w.Workflow())
+	})
+}
+
+func TestIssueWorkflow_CanTransition(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want bool
+	}{
+		{name: "次のステータスへ進めること", from: "waiting", to: "in_progress", want: true},
+		{name: "1つ前のステータスへ差し戻せること", from: "review", to: "in_progress", want: true},
+		{name: "同じステータスは許可されること", from: "review", to: "review", want: true},
+		{name: "ステータスを飛ばして進めないこと", from: "waiting", to: "done", want: false},
+		{name: "ワークフローに無いステータスへは遷移できないこと", from: "waiting", to: "closed", want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, models.DefaultIssueWorkflow.CanTransition(tt.from, tt.to))
+		})
+	}
+}
+
+func TestIssueWorkflow_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		workflow models.IssueWorkflow
+		want     bool
+	}{
+		{name: "既定のワークフローは有効であること", workflow: models.DefaultIssueWorkflow, want: true},
+		{name: "ステータスが1つの場合は無効であること", workflow: models.IssueWorkflow{"done"}, want: false},
+		{name: "末尾がdoneでない場合は無効であること", workflow: models.IssueWorkflow{"todo", "closed"}, want: false},
+		{name: "重複がある場合は無効であること", workflow: models.IssueWorkflow{"todo", "todo", "done"}, want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, tt.workflow.Validate())
+		})
+	}
+}
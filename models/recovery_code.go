@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RecoveryCode 認証アプリを使えない場合に2段階認証の代わりに一度だけ使えるコード
+// コードそのものは発行時にだけ返し、ハッシュのみを保存する
+type RecoveryCode struct {
+	ID        uint64     `json:"id"`
+	UserID    string     `json:"user_id"`
+	CodeHash  string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
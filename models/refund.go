@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+type RefundType string
+
+const (
+	RefundTypeRefund RefundType = "refund"
+	RefundTypeReturn RefundType = "return"
+)
+
+type RefundStatus string
+
+const (
+	// RefundStatusPending 決済プロバイダでの返金が完了していない
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusCompleted RefundStatus = "completed"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+type Refund struct {
+	ID             string         `json:"id"`
+	OrganizationID string         `json:"-"`
+	OrderID        string         `json:"order_id"`
+	RefundType     RefundType     `json:"refund_type"`
+	RefundStatus   RefundStatus   `json:"refund_status"`
+	Amount         int64          `json:"amount"`
+	Reason         string         `json:"reason"`
+	CreatedAt      time.Time      `json:"created_at"`
+	RefundItems    RefundItemList `json:"refund_items"`
+}
+
+type RefundItem struct {
+	ID            string `json:"id"`
+	RefundID      string `json:"refund_id"`
+	OrderDetailID string `json:"order_detail_id"`
+	ProductID     string `json:"product_id"`
+	Quantity      int64  `json:"quantity"`
+	Amount        int64  `json:"amount"`
+}
+
+type RefundItemList []RefundItem
+
+func (l RefundItemList) TotalAmount() int64 {
+	var amount int64
+	for _, v := range l {
+		amount += v.Amount
+	}
+	return amount
+}
+
+// DetailStatus 返金種別に対応する注文詳細ステータス
+func (t RefundType) DetailStatus() OrderDetailStatus {
+	if t == RefundTypeReturn {
+		return OrderDetailStatusReturned
+	}
+	return OrderDetailStatusRefunded
+}
+
+// OrderStatus 全明細が対象になった場合の注文ステータス
+func (t RefundType) OrderStatus() OrderStatus {
+	if t == RefundTypeReturn {
+		return OrderStatusReturned
+	}
+	return OrderStatusRefunded
+}
@@ -12,11 +12,14 @@ const (
 )
 
 type SubscriptionMember struct {
-	ID              string
-	UserID          string
-	MemberStatus    MemberStatus
-	MemberStartDate time.Time
-	MemberEndDate   time.Time
+	ID                string
+	OrganizationID    string `json:"-"`
+	UserID            string
+	PlanID            string
+	MemberStatus      MemberStatus
+	MemberStartDate   time.Time
+	MemberEndDate     time.Time
+	CancelAtPeriodEnd bool
 }
 
 func NewSubscriptionMember(userID string, memberStatus MemberStatus, memberStartDate,
@@ -28,3 +31,59 @@ func NewSubscriptionMember(userID string, memberStatus MemberStatus, memberStart
 		MemberEndDate:   memberEndDate,
 	}
 }
+
+// IsActive 課金中(premium, basic)の会員かどうか
+func (m *SubscriptionMember) IsActive() bool {
+	return m.MemberStatus == Premium || m.MemberStatus == Basic
+}
+
+// IsExpired 指定日時時点で契約期間が終了しているかどうか
+func (m *SubscriptionMember) IsExpired(now time.Time) bool {
+	return !m.MemberEndDate.After(now)
+}
+
+// Renew 契約期間の終了日を起点にプランの期間分だけ更新する
+func (m *SubscriptionMember) Renew(plan *SubscriptionPlan) {
+	m.MemberStartDate = m.MemberEndDate
+	m.MemberEndDate = m.MemberEndDate.Add(plan.Period())
+	m.MemberStatus = plan.MemberStatus
+	m.PlanID = plan.ID
+}
+
+// Expire 契約期間の終了に伴いステータスを落とす
+// 期間終了時の解約が予約されていれば停止、それ以外は非アクティブにする
+func (m *SubscriptionMember) Expire() {
+	if m.CancelAtPeriodEnd {
+		m.MemberStatus = Stopped
+		m.CancelAtPeriodEnd = false
+		return
+	}
+	m.MemberStatus = Inactive
+}
+
+// ChangePlan プランを即時に変更し、残り期間に応じた日割り差額を返す
+// 戻り値が正なら追加請求額、負なら返金(クレジット)額となる
+func (m *SubscriptionMember) ChangePlan(current, next *SubscriptionPlan, now time.Time) int64 {
+	amount := m.ProratedAmount(current, next, now)
+	m.PlanID = next.ID
+	m.MemberStatus = next.MemberStatus
+	return amount
+}
+
+// ProratedAmount 現在の契約期間の残りに対するプラン差額を日割りで計算する
+func (m *SubscriptionMember) ProratedAmount(current, next *SubscriptionPlan, now time.Time) int64 {
+	period := int64(m.MemberEndDate.Sub(m.MemberStartDate) / time.Second)
+	remaining := int64(m.MemberEndDate.Sub(now) / time.Second)
+	if period <= 0 || remaining <= 0 {
+		return 0
+	}
+	if remaining > period {
+		remaining = period
+	}
+
+	var currentPrice int64
+	if current != nil {
+		currentPrice = int64(current.Price)
+	}
+	return (int64(next.Price) - currentPrice) * remaining / period
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+const (
+	HistoryReasonCreated    = "created"
+	HistoryReasonRenewed    = "renewed"
+	HistoryReasonExpired    = "expired"
+	HistoryReasonUpgraded   = "upgraded"
+	HistoryReasonDowngraded = "downgraded"
+	// HistoryReasonPlanChanged 差額のないプランへの変更
+	HistoryReasonPlanChanged   = "plan_changed"
+	HistoryReasonCancelReserve = "cancel_reserved"
+	HistoryReasonUpdated       = "updated"
+)
+
+type SubscriptionMemberHistory struct {
+	ID                   uint64       `json:"id"`
+	SubscriptionMemberID string       `json:"subscription_member_id"`
+	FromStatus           MemberStatus `json:"from_status"`
+	ToStatus             MemberStatus `json:"to_status"`
+	FromPlanID           string       `json:"from_plan_id"`
+	ToPlanID             string       `json:"to_plan_id"`
+	Reason               string       `json:"reason"`
+	CreatedAt            time.Time    `json:"created_at"`
+}
+
+func NewSubscriptionMemberHistory(member *SubscriptionMember, from MemberStatus, fromPlanID,
+	reason string) *SubscriptionMemberHistory {
+	return &SubscriptionMemberHistory{
+		SubscriptionMemberID: member.ID,
+		FromStatus:           from,
+		ToStatus:             member.MemberStatus,
+		FromPlanID:           fromPlanID,
+		ToPlanID:             member.PlanID,
+		Reason:               reason,
+		CreatedAt:            time.Now(),
+	}
+}
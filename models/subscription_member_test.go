@@ -0,0 +1,101 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func TestSubscriptionMemberProratedAmount(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+	basic := &models.SubscriptionPlan{ID: "basic", MemberStatus: models.Basic, Price: 500, PeriodDays: 30}
+	premium := &models.SubscriptionPlan{ID: "premium", MemberStatus: models.Premium, Price: 2000, PeriodDays: 30}
+
+	tests := []struct {
+		name    string
+		current *models.SubscriptionPlan
+		next    *models.SubscriptionPlan
+		now     time.Time
+		want    int64
+	}{
+		{
+			name:    "期間の半分でアップグレードした場合は差額の半分が請求されること",
+			current: basic,
+			next:    premium,
+			now:     start.Add(15 * 24 * time.Hour),
+			want:    750,
+		},
+		{
+			name:    "期間の半分でダウングレードした場合は差額の半分が返金されること",
+			current: premium,
+			next:    basic,
+			now:     start.Add(15 * 24 * time.Hour),
+			want:    -750,
+		},
+		{
+			name:    "プラン未設定の会員は新プランの日割り額が請求されること",
+			current: nil,
+			next:    premium,
+			now:     start.Add(15 * 24 * time.Hour),
+			want:    1000,
+		},
+		{
+			name:    "期間終了後は差額が発生しないこと",
+			current: basic,
+			next:    premium,
+			now:     start.Add(31 * 24 * time.Hour),
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			member := models.SubscriptionMember{
+				MemberStatus:    models.Basic,
+				MemberStartDate: start,
+				MemberEndDate:   start.Add(30 * 24 * time.Hour),
+			}
+			assert.Equal(t, tt.want, member.ProratedAmount(tt.current, tt.next, tt.now))
+		})
+	}
+}
+
+func TestSubscriptionMemberLifecycle(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(30 * 24 * time.Hour)
+	plan := &models.SubscriptionPlan{ID: "premium", MemberStatus: models.Premium, Price: 2000, PeriodDays: 30}
+
+	t.Run("更新すると終了日を起点に期間が延長されること", func(t *testing.T) {
+		t.Parallel()
+		member := models.SubscriptionMember{MemberStatus: models.Basic, MemberStartDate: start, MemberEndDate: end}
+		member.Renew(plan)
+		assert.Equal(t, end, member.MemberStartDate)
+		assert.Equal(t, end.Add(30*24*time.Hour), member.MemberEndDate)
+		assert.Equal(t, models.Premium, member.MemberStatus)
+		assert.Equal(t, "premium", member.PlanID)
+	})
+
+	t.Run("解約予約済みの会員は失効時に停止になること", func(t *testing.T) {
+		t.Parallel()
+		member := models.SubscriptionMember{MemberStatus: models.Premium, CancelAtPeriodEnd: true}
+		member.Expire()
+		assert.Equal(t, models.Stopped, member.MemberStatus)
+		assert.False(t, member.CancelAtPeriodEnd)
+	})
+
+	t.Run("プランのない会員は失効時に非アクティブになること", func(t *testing.T) {
+		t.Parallel()
+		member := models.SubscriptionMember{MemberStatus: models.Basic}
+		member.Expire()
+		assert.Equal(t, models.Inactive, member.MemberStatus)
+	})
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type SubscriptionPlan struct {
+	ID             string       `json:"id"`
+	OrganizationID string       `json:"-"`
+	PlanName       string       `json:"plan_name" binding:"required,max=64"`
+	MemberStatus   MemberStatus `json:"member_status" binding:"required,oneof=premium basic"`
+	Price          uint         `json:"price" binding:"gte=0"`
+	PeriodDays     uint         `json:"period_days" binding:"required,min=1"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// Period プランの1契約期間
+func (p *SubscriptionPlan) Period() time.Duration {
+	return time.Duration(p.PeriodDays) * 24 * time.Hour
+}
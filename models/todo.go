@@ -4,20 +4,127 @@ import "time"
 
 type TodoStatus string
 
+// 入力値のバリデーション(oneof=new processing done closed)と同じ並びで定義する
 const (
-	TodoStatusSuccess    TodoStatus = "success"
-	TodoStatusWaiting    TodoStatus = "waiting"
-	TodoStatusCanceled   TodoStatus = "canceled"
+	TodoStatusNew        TodoStatus = "new"
 	TodoStatusProcessing TodoStatus = "processing"
 	TodoStatusDone       TodoStatus = "done"
+	TodoStatusClosed     TodoStatus = "closed"
+)
+
+type RecurrenceRule string
+
+const (
+	RecurrenceDaily   RecurrenceRule = "daily"
+	RecurrenceWeekly  RecurrenceRule = "weekly"
+	RecurrenceMonthly RecurrenceRule = "monthly"
 )
 
 type Todo struct {
-	ID        uint64    `json:"id" gorm:"primaryKey" example:"1"`
-	Title     string    `json:"title" binding:"required,max=64" example:"タイトル"`
-	Body      string    `json:"body" binding:"required,max=64" example:"本文"`
-	Status    string    `json:"status" binding:"required,oneof=new processing done closed" example:"success"`
-	UserID    *string   `json:"user_id" binding:"required" example:"14841545-8a11-47d1-bf95-59a1c7f1d8ec"`
+	ID             uint64         `json:"id" gorm:"primaryKey" example:"1"`
+	OrganizationID string         `json:"-"`
+	Title          string         `json:"title" binding:"required,max=64" example:"タイトル"`
+	Body           string         `json:"body" binding:"required,max=64" example:"本文"`
+	Status         string         `json:"status" binding:"required,oneof=new processing done closed" example:"new"`
+	UserID         *string        `json:"user_id" binding:"required" example:"14841545-8a11-47d1-bf95-59a1c7f1d8ec"`
+	DueAt          *time.Time     `json:"due_at,omitempty" example:"2022-10-01T18:00:00+09:00"`
+	RemindAt       *time.Time     `json:"remind_at,omitempty" example:"2022-10-01T09:00:00+09:00"`
+	RemindedAt     *time.Time     `json:"reminded_at,omitempty"`
+	RecurrenceRule RecurrenceRule `json:"recurrence_rule,omitempty" binding:"omitempty,oneof=daily weekly monthly" example:"weekly"`
+	RecurredAt     *time.Time     `json:"recurred_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+
+	ChecklistItems []TodoChecklistItem `json:"checklist_items,omitempty" gorm:"association_autoupdate:false;association_autocreate:false"`
+}
+
+// TodoChecklistItem todoのサブタスク
+type TodoChecklistItem struct {
+	ID        uint64    `json:"id"`
+	TodoID    uint64    `json:"todo_id"`
+	Title     string    `json:"title"`
+	IsDone    bool      `json:"is_done"`
+	Position  int       `json:"position"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// IsFinished 完了または終了しているかどうか
+func (t *Todo) IsFinished() bool {
+	return t.Status == string(TodoStatusDone) || t.Status == string(TodoStatusClosed)
+}
+
+// IsOverdue 期限を過ぎても終わっていないかどうか
+func (t *Todo) IsOverdue(now time.Time) bool {
+	return t.DueAt != nil && t.DueAt.Before(now) && !t.IsFinished()
+}
+
+// HasValidSchedule 繰り返しには期限が必要で、リマインドは期限より後にできない
+func (t *Todo) HasValidSchedule() bool {
+	if t.RecurrenceRule != "" && t.DueAt == nil {
+		return false
+	}
+	if t.RemindAt != nil && t.DueAt != nil && t.RemindAt.After(*t.DueAt) {
+		return false
+	}
+	return true
+}
+
+// NextRecurrence 繰り返しの次回分のtodoを返す
+// 期限が現在日時を過ぎている回は飛ばし、リマインドは期限との間隔を保ったまま移す
+func (t *Todo) NextRecurrence(now time.Time) *Todo {
+	if t.RecurrenceRule == "" || t.DueAt == nil {
+		return nil
+	}
+	n := 1
+	due := t.RecurrenceRule.add(*t.DueAt, n)
+	for !due.After(now) {
+		n++
+		due = t.RecurrenceRule.add(*t.DueAt, n)
+	}
+
+	next := &Todo{
+		OrganizationID: t.OrganizationID,
+		Title:          t.Title,
+		Body:           t.Body,
+		Status:         string(TodoStatusNew),
+		UserID:         t.UserID,
+		DueAt:          &due,
+		RecurrenceRule: t.RecurrenceRule,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if t.RemindAt != nil {
+		remindAt := due.Add(t.RemindAt.Sub(*t.DueAt))
+		next.RemindAt = &remindAt
+	}
+	for _, item := range t.ChecklistItems {
+		next.ChecklistItems = append(next.ChecklistItems, TodoChecklistItem{
+			Title:     item.Title,
+			Position:  item.Position,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+	return next
+}
+
+// add 基準日時からn回分進めた日時を返す
+// 月次で翌月に同じ日が無い場合は月末日にする(1/31の翌月は2/28)
+func (r RecurrenceRule) add(base time.Time, n int) time.Time {
+	switch r {
+	case RecurrenceDaily:
+		return base.AddDate(0, 0, n)
+	case RecurrenceWeekly:
+		return base.AddDate(0, 0, 7*n)
+	default:
+		firstOfMonth := time.Date(base.Year(), base.Month()+time.Month(n), 1,
+			base.Hour(), base.Minute(), base.Second(), base.Nanosecond(), base.Location())
+		lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+		day := base.Day()
+		if day > lastDay {
+			day = lastDay
+		}
+		return firstOfMonth.AddDate(0, 0, day-1)
+	}
+}
@@ -0,0 +1,150 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestTodo_IsOverdue(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 9, 23, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		todo models.Todo
+		want bool
+	}{
+		{name: "期限を過ぎた未完了のtodoは期限切れになること", todo: models.Todo{Status: "processing", DueAt: timePtr(now.Add(-time.Minute))}, want: true},
+		{name: "完了したtodoは期限切れにならないこと", todo: models.Todo{Status: "done", DueAt: timePtr(now.Add(-time.Minute))}, want: false},
+		{name: "終了したtodoは期限切れにならないこと", todo: models.Todo{Status: "closed", DueAt: timePtr(now.Add(-time.Minute))}, want: false},
+		{name: "期限前は期限切れにならないこと", todo: models.Todo{Status: "new", DueAt: timePtr(now.Add(time.Minute))}, want: false},
+		{name: "期限が無い場合は期限切れにならないこと", todo: models.Todo{Status: "new"}, want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, tt.todo.IsOverdue(now))
+		})
+	}
+}
+
+func TestTodo_HasValidSchedule(t *testing.T) {
+	t.Parallel()
+
+	due := time.Date(2022, 9, 30, 18, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		todo models.Todo
+		want bool
+	}{
+		{name: "期限とリマインドが無い場合は有効であること", todo: models.Todo{}, want: true},
+		{name: "期限より前のリマインドは有効であること", todo: models.Todo{DueAt: &due, RemindAt: timePtr(due.Add(-time.Hour))}, want: true},
+		{name: "期限より後のリマインドは無効であること", todo: models.Todo{DueAt: &due, RemindAt: timePtr(due.Add(time.Hour))}, want: false},
+		{name: "期限の無い繰り返しは無効であること", todo: models.Todo{RecurrenceRule: models.RecurrenceDaily}, want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, tt.todo.HasValidSchedule())
+		})
+	}
+}
+
+func TestTodo_NextRecurrence(t *testing.T) {
+	t.Parallel()
+
+	userID := "e29aa01f-8df4-422e-8341-ec976be91f8d"
+	tests := []struct {
+		name     string
+		rule     models.RecurrenceRule
+		due      time.Time
+		now      time.Time
+		wantDue  time.Time
+		wantNext bool
+	}{
+		{
+			name:     "毎日の場合は翌日になること",
+			rule:     models.RecurrenceDaily,
+			due:      time.Date(2022, 9, 23, 18, 0, 0, 0, time.UTC),
+			now:      time.Date(2022, 9, 23, 19, 0, 0, 0, time.UTC),
+			wantDue:  time.Date(2022, 9, 24, 18, 0, 0, 0, time.UTC),
+			wantNext: true,
+		},
+		{
+			name:     "期限から日数が経っている場合は現在日時より後の回になること",
+			rule:     models.RecurrenceWeekly,
+			due:      time.Date(2022, 9, 1, 18, 0, 0, 0, time.UTC),
+			now:      time.Date(2022, 9, 20, 0, 0, 0, 0, time.UTC),
+			wantDue:  time.Date(2022, 9, 22, 18, 0, 0, 0, time.UTC),
+			wantNext: true,
+		},
+		{
+			name:     "月末の翌月に同じ日が無い場合は月末日になること",
+			rule:     models.RecurrenceMonthly,
+			due:      time.Date(2022, 1, 31, 9, 0, 0, 0, time.UTC),
+			now:      time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC),
+			wantDue:  time.Date(2022, 2, 28, 9, 0, 0, 0, time.UTC),
+			wantNext: true,
+		},
+		{
+			name:     "月次は基準日の日付を保つこと",
+			rule:     models.RecurrenceMonthly,
+			due:      time.Date(2022, 1, 31, 9, 0, 0, 0, time.UTC),
+			now:      time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC),
+			wantDue:  time.Date(2022, 3, 31, 9, 0, 0, 0, time.UTC),
+			wantNext: true,
+		},
+		{
+			name:     "繰り返しが無い場合は作成されないこと",
+			due:      time.Date(2022, 9, 23, 18, 0, 0, 0, time.UTC),
+			now:      time.Date(2022, 9, 24, 0, 0, 0, 0, time.UTC),
+			wantNext: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			todo := models.Todo{
+				ID:             1,
+				OrganizationID: "org-a",
+				Title:          "週報",
+				Body:           "提出する",
+				Status:         string(models.TodoStatusDone),
+				UserID:         &userID,
+				DueAt:          timePtr(tt.due),
+				RemindAt:       timePtr(tt.due.Add(-2 * time.Hour)),
+				RecurrenceRule: tt.rule,
+				ChecklistItems: []models.TodoChecklistItem{
+					{ID: 1, TodoID: 1, Title: "下書き", IsDone: true, Position: 1},
+				},
+			}
+			next := todo.NextRecurrence(tt.now)
+			if !tt.wantNext {
+				assert.Nil(t, next)
+				return
+			}
+			require.NotNil(t, next)
+			assert.Equal(t, tt.wantDue, *next.DueAt)
+			assert.Equal(t, tt.wantDue.Add(-2*time.Hour), *next.RemindAt)
+			assert.Equal(t, string(models.TodoStatusNew), next.Status)
+			assert.Equal(t, tt.rule, next.RecurrenceRule)
+			assert.Zero(t, next.ID)
+			assert.Equal(t, "org-a", next.OrganizationID)
+			require.Len(t, next.ChecklistItems, 1)
+			assert.Equal(t, "下書き", next.ChecklistItems[0].Title)
+			assert.False(t, next.ChecklistItems[0].IsDone)
+		})
+	}
+}
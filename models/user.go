@@ -1,6 +1,7 @@
 package models
 
 import (
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,13 +10,29 @@ import (
 
 const DefaultPasswordCost = 14
 
+const (
+	UserRoleAdmin  = "admin"
+	UserRoleMember = "member"
+)
+
 type User struct {
-	ID        string    `json:"id"`
-	FirstName string    `json:"first_name" binding:"required,max=64"`
-	LastName  string    `json:"last_name" binding:"required,max=64"`
-	Age       uint8     `json:"age" binding:"required,min=18,max=99"`
-	Email     string    `json:"email" binding:"required,email,max=64"`
-	Password  []byte    `json:"password" binding:"required"`
+	ID              string     `json:"id"`
+	OrganizationID  string     `json:"-"`
+	FirstName       string     `json:"first_name" binding:"required,max=64"`
+	LastName        string     `json:"last_name" binding:"required,max=64"`
+	Age             uint8      `json:"age" binding:"required,min=18,max=99"`
+	Email           string     `json:"email" binding:"required,email,max=64"`
+	Password        []byte     `json:"password" binding:"required"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// Role 指定せずに作成した場合は列の既定値のmemberになる
+	Role string `json:"role" gorm:"default:'member'"`
+	// TwoFactorSecret 認証アプリに登録したTOTPの秘密鍵。登録中は設定済みでもTwoFactorEnabledAtは空になる
+	TwoFactorSecret string `json:"-"`
+	// TwoFactorLastStep 最後に使われたTOTPのステップ番号。同じコードの再利用を防ぐ
+	TwoFactorLastStep  int64      `json:"-"`
+	TwoFactorEnabledAt *time.Time `json:"two_factor_enabled_at,omitempty"`
+	// Version 更新するたびに1つ進む。ETagに使い、同時に行われた更新の上書きを防ぐ
+	Version   uint      `json:"version"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Todos     []Todo    `json:"todos" binding:"omitempty"`
@@ -33,6 +50,39 @@ func (u *User) ComparePassword(password string) error {
 	return bcrypt.CompareHashAndPassword(u.Password, []byte(password))
 }
 
+// IsEmailVerified メールアドレスを確認済みかどうか。確認するまでログインできない
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// IsAdmin 組織の管理者かどうか
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
+
+// IsTwoFactorEnabled 2段階認証を有効にしているかどうか
+func (u *User) IsTwoFactorEnabled() bool {
+	return u.TwoFactorEnabledAt != nil && u.TwoFactorSecret != ""
+}
+
+// RequiresTwoFactor 2段階認証を必須とするかどうか。組織の管理者は有効にするまで管理者の操作を行えない
+func (u *User) RequiresTwoFactor() bool {
+	return u.IsAdmin()
+}
+
+var (
+	dummyPasswordHash     []byte
+	dummyPasswordHashOnce sync.Once
+)
+
+// CompareDummyPassword ユーザーが存在しない場合にComparePasswordと同じだけ時間をかける
+func CompareDummyPassword(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), DefaultPasswordCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+}
+
 func (u *User) CreateUUID() {
 	newUUID, _ := uuid.NewRandom()
 	u.ID = newUUID.String()
@@ -1,21 +1,202 @@
 package models
 
 import (
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type UserGroup struct {
-	ID        string    `json:"id"`
-	GroupName string    `json:"group_name" binding:"required"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID             string    `json:"id"`
+	OrganizationID string    `json:"-"`
+	GroupName      string    `json:"group_name" binding:"required"`
+	ParentID       *string   `json:"parent_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 
-	Users Users `json:"users" gorm:"many2many:group_user;"`
+	Users Users `json:"users" gorm:"many2many:group_user;jointable_foreignkey:group_id;association_jointable_foreignkey:user_id;association_autoupdate:false;association_autocreate:false;association_save_reference:false"`
 }
 
 func (u *UserGroup) CreateUUID() {
 	newUUID, _ := uuid.NewRandom()
 	u.ID = newUUID.String()
 }
+
+// GroupMembership 継承を含めたグループへの所属
+// InheritedFromは所属元のサブグループのID。直接所属している場合は空になる
+type GroupMembership struct {
+	GroupID       string `json:"group_id"`
+	UserID        string `json:"user_id"`
+	Role          string `json:"role"`
+	InheritedFrom string `json:"inherited_from,omitempty"`
+}
+
+// GroupHierarchy グループの親子関係
+// サブグループのメンバーは親グループのメンバーにもなり、親グループのオーナーはサブグループも管理できる
+type GroupHierarchy struct {
+	parents  map[string]string
+	children map[string][]string
+}
+
+func NewGroupHierarchy(groups []UserGroup) *GroupHierarchy {
+	h := &GroupHierarchy{
+		parents:  make(map[string]string, len(groups)),
+		children: make(map[string][]string, len(groups)),
+	}
+	for _, g := range groups {
+		if g.ParentID == nil || *g.ParentID == "" {
+			continue
+		}
+		h.parents[g.ID] = *g.ParentID
+		h.children[*g.ParentID] = append(h.children[*g.ParentID], g.ID)
+	}
+	return h
+}
+
+// Ancestors 親から順に祖先のグループIDを返す
+func (h *GroupHierarchy) Ancestors(groupID string) []string {
+	var ids []string
+	seen := map[string]bool{groupID: true}
+	for id := h.parents[groupID]; id != "" && !seen[id]; id = h.parents[id] {
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Descendants 子孫のグループIDを幅優先で返す
+func (h *GroupHierarchy) Descendants(groupID string) []string {
+	var ids []string
+	seen := map[string]bool{groupID: true}
+	queue := []string{groupID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, child := range h.children[id] {
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			ids = append(ids, child)
+			queue = append(queue, child)
+		}
+	}
+	return ids
+}
+
+// CanSetParent groupIDの親をparentIDにしても循環しないかどうか
+func (h *GroupHierarchy) CanSetParent(groupID, parentID string) bool {
+	if parentID == "" {
+		return true
+	}
+	if parentID == groupID {
+		return false
+	}
+	for _, id := range h.Descendants(groupID) {
+		if id == parentID {
+			return false
+		}
+	}
+	return true
+}
+
+// Members サブグループから継承したメンバーを含めたグループのメンバーを返す
+// 同じユーザーが複数の経路で所属する場合は、オーナーを優先し、次に直接の所属を優先する
+func (h *GroupHierarchy) Members(groupID string, memberships []GroupUser) []GroupMembership {
+	inTree := map[string]bool{groupID: true}
+	for _, id := range h.Descendants(groupID) {
+		inTree[id] = true
+	}
+
+	byUser := make(map[string]GroupMembership)
+	for _, m := range memberships {
+		if !inTree[m.GroupID] {
+			continue
+		}
+		candidate := GroupMembership{GroupID: groupID, UserID: m.UserID, Role: m.Role}
+		if m.GroupID != groupID {
+			candidate.InheritedFrom = m.GroupID
+			// 継承したメンバーは親グループを管理できない
+			candidate.Role = GroupRoleMember
+		}
+		current, ok := byUser[m.UserID]
+		if !ok || preferMembership(candidate, current) {
+			byUser[m.UserID] = candidate
+		}
+	}
+	return sortedMemberships(byUser, func(m GroupMembership) string { return m.UserID })
+}
+
+// GroupsOf ユーザーが直接または継承で所属するグループを返す
+// 親グループのオーナーはサブグループでもオーナーとして扱い、複数の経路がある場合はMembersと同じ優先順位で選ぶ
+func (h *GroupHierarchy) GroupsOf(userID string, memberships []GroupUser) []GroupMembership {
+	byGroup := make(map[string]GroupMembership)
+	for _, m := range memberships {
+		if m.UserID != userID {
+			continue
+		}
+		direct := GroupMembership{GroupID: m.GroupID, UserID: userID, Role: m.Role}
+		if current, ok := byGroup[m.GroupID]; !ok || preferMembership(direct, current) {
+			byGroup[m.GroupID] = direct
+		}
+		for _, ancestor := range h.Ancestors(m.GroupID) {
+			inherited := GroupMembership{GroupID: ancestor, UserID: userID, Role: GroupRoleMember, InheritedFrom: m.GroupID}
+			if current, ok := byGroup[ancestor]; !ok || preferMembership(inherited, current) {
+				byGroup[ancestor] = inherited
+			}
+		}
+		if m.Role != GroupRoleOwner {
+			continue
+		}
+		for _, descendant := range h.Descendants(m.GroupID) {
+			inherited := GroupMembership{GroupID: descendant, UserID: userID, Role: GroupRoleOwner, InheritedFrom: m.GroupID}
+			if current, ok := byGroup[descendant]; !ok || preferMembership(inherited, current) {
+				byGroup[descendant] = inherited
+			}
+		}
+	}
+	return sortedMemberships(byGroup, func(m GroupMembership) string { return m.GroupID })
+}
+
+// IsOwner ユーザーがグループまたはその祖先のオーナーかどうか
+func (h *GroupHierarchy) IsOwner(groupID, userID string, memberships []GroupUser) bool {
+	for _, m := range h.GroupsOf(userID, memberships) {
+		if m.GroupID == groupID {
+			return m.Role == GroupRoleOwner
+		}
+	}
+	return false
+}
+
+// HasOwner グループまたはその祖先にオーナーが1人でもいるかどうか
+func (h *GroupHierarchy) HasOwner(groupID string, memberships []GroupUser) bool {
+	ids := map[string]bool{groupID: true}
+	for _, id := range h.Ancestors(groupID) {
+		ids[id] = true
+	}
+	for _, m := range memberships {
+		if ids[m.GroupID] && m.IsOwner() {
+			return true
+		}
+	}
+	return false
+}
+
+func preferMembership(candidate, current GroupMembership) bool {
+	if (candidate.Role == GroupRoleOwner) != (current.Role == GroupRoleOwner) {
+		return candidate.Role == GroupRoleOwner
+	}
+	return candidate.InheritedFrom == "" && current.InheritedFrom != ""
+}
+
+func sortedMemberships(m map[string]GroupMembership, key func(GroupMembership) string) []GroupMembership {
+	result := make([]GroupMembership, 0, len(m))
+	for _, v := range m {
+		result = append(result, v)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return key(result[i]) < key(result[j])
+	})
+	return result
+}
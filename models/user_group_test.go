@@ -0,0 +1,125 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// company
+// ├── dev
+// │   └── backend
+// └── sales
+var testGroups = []models.UserGroup{
+	{ID: "company"},
+	{ID: "dev", ParentID: strPtr("company")},
+	{ID: "backend", ParentID: strPtr("dev")},
+	{ID: "sales", ParentID: strPtr("company")},
+}
+
+var testMemberships = []models.GroupUser{
+	{GroupID: "company", UserID: "ceo", Role: models.GroupRoleOwner},
+	{GroupID: "dev", UserID: "lead", Role: models.GroupRoleOwner},
+	{GroupID: "backend", UserID: "lead", Role: models.GroupRoleMember},
+	{GroupID: "backend", UserID: "alice", Role: models.GroupRoleMember},
+	{GroupID: "sales", UserID: "bob", Role: models.GroupRoleMember},
+	{GroupID: "dev", UserID: "bob", Role: models.GroupRoleMember},
+}
+
+func TestGroupHierarchy_AncestorsAndDescendants(t *testing.T) {
+	t.Parallel()
+
+	h := models.NewGroupHierarchy(testGroups)
+	assert.Equal(t, []string{"dev", "company"}, h.Ancestors("backend"))
+	assert.Empty(t, h.Ancestors("company"))
+	assert.ElementsMatch(t, []string{"dev", "sales", "backend"}, h.Descendants("company"))
+	assert.Empty(t, h.Descendants("backend"))
+}
+
+func TestGroupHierarchy_CanSetParent(t *testing.T) {
+	t.Parallel()
+
+	h := models.NewGroupHierarchy(testGroups)
+	tests := []struct {
+		name     string
+		groupID  string
+		parentID string
+		want     bool
+	}{
+		{name: "別の枝のグループを親にできること", groupID: "sales", parentID: "backend", want: true},
+		{name: "親を外せること", groupID: "dev", parentID: "", want: true},
+		{name: "自分自身は親にできないこと", groupID: "dev", parentID: "dev", want: false},
+		{name: "子孫を親にすると循環するためできないこと", groupID: "company", parentID: "backend", want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, h.CanSetParent(tt.groupID, tt.parentID))
+		})
+	}
+}
+
+func TestGroupHierarchy_Members(t *testing.T) {
+	t.Parallel()
+
+	h := models.NewGroupHierarchy(testGroups)
+
+	t.Run("サブグループのメンバーを継承すること", func(t *testing.T) {
+		t.Parallel()
+		got := h.Members("dev", testMemberships)
+		assert.Equal(t, []models.GroupMembership{
+			{GroupID: "dev", UserID: "alice", Role: models.GroupRoleMember, InheritedFrom: "backend"},
+			{GroupID: "dev", UserID: "bob", Role: models.GroupRoleMember},
+			{GroupID: "dev", UserID: "lead", Role: models.GroupRoleOwner},
+		}, got)
+	})
+
+	t.Run("継承したメンバーは親グループのオーナーにならないこと", func(t *testing.T) {
+		t.Parallel()
+		got := h.Members("company", testMemberships)
+		assert.Len(t, got, 4)
+		for _, m := range got {
+			if m.UserID == "lead" {
+				assert.Equal(t, models.GroupRoleMember, m.Role)
+				assert.NotEmpty(t, m.InheritedFrom)
+			}
+		}
+	})
+}
+
+func TestGroupHierarchy_GroupsOf(t *testing.T) {
+	t.Parallel()
+
+	h := models.NewGroupHierarchy(testGroups)
+
+	t.Run("所属するグループの祖先にもメンバーとして所属すること", func(t *testing.T) {
+		t.Parallel()
+		got := h.GroupsOf("alice", testMemberships)
+		assert.Equal(t, []models.GroupMembership{
+			{GroupID: "backend", UserID: "alice", Role: models.GroupRoleMember},
+			{GroupID: "company", UserID: "alice", Role: models.GroupRoleMember, InheritedFrom: "backend"},
+			{GroupID: "dev", UserID: "alice", Role: models.GroupRoleMember, InheritedFrom: "backend"},
+		}, got)
+	})
+
+	t.Run("親グループのオーナーはサブグループでもオーナーになること", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, h.IsOwner("backend", "lead", testMemberships))
+		assert.True(t, h.IsOwner("sales", "ceo", testMemberships))
+		assert.False(t, h.IsOwner("company", "lead", testMemberships))
+		assert.False(t, h.IsOwner("sales", "bob", testMemberships))
+	})
+
+	t.Run("祖先を含めてオーナーがいるかどうかを判定できること", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, h.HasOwner("backend", testMemberships))
+		assert.False(t, h.HasOwner("backend", testMemberships[2:5]))
+	})
+}
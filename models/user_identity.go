@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// UserIdentity 外部のIDプロバイダーのアカウントとユーザーの紐付け
+// プロバイダーのsubjectで識別し、メールアドレスが変わっても同じユーザーとしてログインできるようにする
+type UserIdentity struct {
+	ID        uint64    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
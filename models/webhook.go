@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "failed"
+)
+
+type WebhookSubscription struct {
+	ID             string    `json:"id"`
+	OrganizationID string    `json:"-"`
+	URL            string    `json:"url"`
+	EventTypes     string    `json:"event_types"`
+	Secret         string    `json:"-"`
+	IsActive       bool      `json:"is_active"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Subscribes 指定したイベント種別を購読しているかどうか
+func (s *WebhookSubscription) Subscribes(eventType string) bool {
+	for _, t := range strings.Split(s.EventTypes, ",") {
+		if strings.TrimSpace(t) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	OrganizationID string                `json:"-"`
+	SubscriptionID string                `json:"subscription_id"`
+	EventID        string                `json:"event_id"`
+	EventType      string                `json:"event_type"`
+	Payload        string                `json:"payload"`
+	DeliveryStatus WebhookDeliveryStatus `json:"delivery_status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at"`
+	LastError      string                `json:"last_error"`
+	DeliveredAt    *time.Time            `json:"delivered_at"`
+	// ClaimToken 送信のために取得した配信処理の識別子。ClaimedUntilを過ぎると他の配信処理が取得できる
+	ClaimToken   *string    `json:"-"`
+	ClaimedUntil *time.Time `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type WebhookDeliveryLog struct {
+	ID           uint64    `json:"id"`
+	DeliveryID   string    `json:"delivery_id"`
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code"`
+	ResponseBody string    `json:"response_body"`
+	Error        string    `json:"error"`
+	DurationMs   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,61 @@
+package oidc
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+// Config OIDCプロバイダーごとの設定
+type Config struct {
+	// Name URLに使うプロバイダーの名前
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// OrganizationID 初回ログイン時に作成するユーザーの所属組織
+	OrganizationID string
+	// AutoProvision 一致するユーザーがいない場合に初回ログインでユーザーを作成するかどうか
+	AutoProvision bool
+}
+
+var defaultScopes = []string{"openid", "email", "profile"}
+
+// ConfigsFromEnv 環境変数からプロバイダーの設定を読み込む
+// OIDC_PROVIDERSにカンマ区切りでプロバイダー名を指定し、OIDC_<名前>_ISSUERなどで各プロバイダーを設定する
+func ConfigsFromEnv() ([]Config, error) {
+	var configs []Config
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		config := Config{
+			Name:           name,
+			Issuer:         os.Getenv(prefix + "ISSUER"),
+			ClientID:       os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret:   os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:    os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:         defaultScopes,
+			OrganizationID: os.Getenv(prefix + "ORGANIZATION_ID"),
+			AutoProvision:  os.Getenv(prefix+"AUTO_PROVISION") != "false",
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			config.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+		if config.OrganizationID == "" {
+			config.OrganizationID = models.DefaultOrganizationID
+		}
+		if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+			return nil, fmt.Errorf("oidc provider %q requires %sISSUER, %sCLIENT_ID and %sREDIRECT_URL",
+				name, prefix, prefix, prefix)
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
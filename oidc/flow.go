@@ -0,0 +1,162 @@
+package oidc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AI1411/golang-admin-api/authtoken"
+)
+
+// StateTTL 認可エンドポイントへリダイレクトしてからコールバックされるまでの有効期限
+const StateTTL = 10 * time.Minute
+
+const stateKeyPrefix = "oidc:state:"
+
+// StateCookieName ログインを開始したブラウザにstateを紐付けるCookie
+const StateCookieName = "oidc_state"
+
+var (
+	ErrUnknownProvider = errors.New("unknown oidc provider")
+	// ErrInvalidState stateが存在しない、期限切れ、使用済み、別のプロバイダーのもの、またはログインを開始したブラウザと異なる
+	ErrInvalidState = errors.New("invalid or expired oidc state")
+)
+
+// pendingLogin 認可エンドポイントへリダイレクトしてからコールバックされるまで保存する値
+type pendingLogin struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// Authorization 認可エンドポイントへのリダイレクト先と、ブラウザのCookieに保存する値
+type Authorization struct {
+	URL string
+	// StateCookie stateと有効期限に署名した値。コールバックで照合し、別のブラウザで開始したログインを拒否する
+	StateCookie string
+}
+
+// Flow 認可コードフロー(PKCE)で複数のプロバイダーによるログインを行う
+type Flow struct {
+	store     authtoken.Store
+	secret    []byte
+	providers map[string]*Provider
+	names     []string
+	now       func() time.Time
+}
+
+// NewFlow secretはstateのCookieの署名に使う
+func NewFlow(store authtoken.Store, secret string, providers ...*Provider) *Flow {
+	return NewFlowWithClock(store, secret, time.Now, providers...)
+}
+
+func NewFlowWithClock(store authtoken.Store, secret string, now func() time.Time, providers ...*Provider) *Flow {
+	f := &Flow{store: store, secret: []byte(secret), providers: map[string]*Provider{}, now: now}
+	for _, p := range providers {
+		f.providers[p.Name()] = p
+		f.names = append(f.names, p.Name())
+	}
+	return f
+}
+
+// ProviderNames 設定されているプロバイダーの名前を設定順に返す
+func (f *Flow) ProviderNames() []string {
+	return f.names
+}
+
+func (f *Flow) Provider(name string) (*Provider, bool) {
+	p, ok := f.providers[name]
+	return p, ok
+}
+
+// Begin state、nonce、code_verifierを生成して保存し、認可エンドポイントのURLとstateのCookieの値を返す
+func (f *Flow) Begin(ctx context.Context, name string) (*Authorization, error) {
+	p, ok := f.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	login := pendingLogin{Provider: name}
+	if login.Nonce, err = randomString(); err != nil {
+		return nil, err
+	}
+	if login.CodeVerifier, err = randomString(); err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(login)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.store.Save(ctx, stateKeyPrefix+state, string(value), StateTTL); err != nil {
+		return nil, err
+	}
+	authURL, err := p.AuthCodeURL(ctx, state, login.Nonce, CodeChallenge(login.CodeVerifier))
+	if err != nil {
+		return nil, err
+	}
+	return &Authorization{URL: authURL, StateCookie: f.signState(state, f.now().Add(StateTTL))}, nil
+}
+
+// Complete コールバックのstateをCookieの値と照合して検証し、認可コードを交換してIDトークンの情報を返す
+// stateは一度だけ使える
+func (f *Flow) Complete(ctx context.Context, name, state, stateCookie, code string) (*Claims, error) {
+	p, ok := f.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	if state == "" || !f.verifyState(state, stateCookie) {
+		return nil, ErrInvalidState
+	}
+	value, err := f.store.Take(ctx, stateKeyPrefix+state)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, ErrInvalidState
+	}
+	var login pendingLogin
+	if err := json.Unmarshal([]byte(value), &login); err != nil || login.Provider != name {
+		return nil, ErrInvalidState
+	}
+	return p.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+}
+
+// signState stateと有効期限をHMAC-SHA256で署名し、"state.有効期限.署名"の形式にする
+func (f *Flow) signState(state string, expiresAt time.Time) string {
+	payload := state + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(f.mac(payload))
+}
+
+// verifyState Cookieの値がstateに対して署名したもので、期限内かどうか
+func (f *Flow) verifyState(state, stateCookie string) bool {
+	i := strings.LastIndex(stateCookie, ".")
+	if i < 0 {
+		return false
+	}
+	payload := stateCookie[:i]
+	signature, err := base64.RawURLEncoding.DecodeString(stateCookie[i+1:])
+	if err != nil || !hmac.Equal(signature, f.mac(payload)) {
+		return false
+	}
+	parts := strings.Split(payload, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[0]), []byte(state)) {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	return err == nil && f.now().Unix() < expiresAt
+}
+
+func (f *Flow) mac(payload string) []byte {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,184 @@
+package oidc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/authtoken"
+	"github.com/AI1411/golang-admin-api/oidc"
+	"github.com/AI1411/golang-admin-api/oidc/oidctest"
+)
+
+const redirectURLForTest = "http://localhost:8080/auth/oidc/corp/callback"
+
+var identityForTest = oidctest.Identity{
+	Subject:       "subject-1",
+	Email:         "a@example.com",
+	EmailVerified: true,
+	GivenName:     "Taro",
+	FamilyName:    "Yamada",
+}
+
+func newFlowForTest(t *testing.T) (*oidc.Flow, *oidctest.Server) {
+	t.Helper()
+	server, err := oidctest.NewServer("client", "secret")
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+	provider := oidc.NewProvider(oidc.Config{
+		Name:         "corp",
+		Issuer:       server.Issuer(),
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  redirectURLForTest,
+		Scopes:       []string{"openid", "email"},
+	}, server.Client())
+	return oidc.NewFlow(authtoken.NewMemoryStore(), "secret", provider), server
+}
+
+func TestFlow(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("認可コードを交換してIDトークンの情報を取得できること", func(t *testing.T) {
+		t.Parallel()
+		flow, server := newFlowForTest(t)
+		authorization, err := flow.Begin(ctx, "corp")
+		require.NoError(t, err)
+		callback, err := server.Authorize(authorization.URL, identityForTest)
+		require.NoError(t, err)
+
+		claims, err := flow.Complete(ctx, "corp", callback.Query().Get("state"), authorization.StateCookie, callback.Query().Get("code"))
+		require.NoError(t, err)
+		assert.Equal(t, &oidc.Claims{
+			Subject:       "subject-1",
+			Email:         "a@example.com",
+			EmailVerified: true,
+			GivenName:     "Taro",
+			FamilyName:    "Yamada",
+		}, claims)
+	})
+
+	t.Run("stateは一度しか使えないこと", func(t *testing.T) {
+		t.Parallel()
+		flow, server := newFlowForTest(t)
+		authorization, err := flow.Begin(ctx, "corp")
+		require.NoError(t, err)
+		callback, err := server.Authorize(authorization.URL, identityForTest)
+		require.NoError(t, err)
+		state := callback.Query().Get("state")
+
+		_, err = flow.Complete(ctx, "corp", state, authorization.StateCookie, callback.Query().Get("code"))
+		require.NoError(t, err)
+		_, err = flow.Complete(ctx, "corp", state, authorization.StateCookie, callback.Query().Get("code"))
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+	})
+
+	t.Run("不明なstateは拒否すること", func(t *testing.T) {
+		t.Parallel()
+		flow, _ := newFlowForTest(t)
+		_, err := flow.Complete(ctx, "corp", "unknown", "unknown", "code")
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+	})
+
+	t.Run("別のブラウザで開始したログインのstateは拒否すること", func(t *testing.T) {
+		t.Parallel()
+		flow, server := newFlowForTest(t)
+		authorization, err := flow.Begin(ctx, "corp")
+		require.NoError(t, err)
+		other, err := flow.Begin(ctx, "corp")
+		require.NoError(t, err)
+		callback, err := server.Authorize(authorization.URL, identityForTest)
+		require.NoError(t, err)
+		state := callback.Query().Get("state")
+
+		_, err = flow.Complete(ctx, "corp", state, other.StateCookie, callback.Query().Get("code"))
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+		_, err = flow.Complete(ctx, "corp", state, "", callback.Query().Get("code"))
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+		_, err = flow.Complete(ctx, "corp", state, authorization.StateCookie+"x", callback.Query().Get("code"))
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+		// 照合に失敗してもstateは消費しない
+		_, err = flow.Complete(ctx, "corp", state, authorization.StateCookie, callback.Query().Get("code"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("期限切れのstateのCookieは拒否すること", func(t *testing.T) {
+		t.Parallel()
+		server, err := oidctest.NewServer("client", "secret")
+		require.NoError(t, err)
+		t.Cleanup(server.Close)
+		now := time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)
+		flow := oidc.NewFlowWithClock(authtoken.NewMemoryStore(), "secret", func() time.Time { return now },
+			oidc.NewProvider(oidc.Config{
+				Name:         "corp",
+				Issuer:       server.Issuer(),
+				ClientID:     "client",
+				ClientSecret: "secret",
+				RedirectURL:  redirectURLForTest,
+				Scopes:       []string{"openid", "email"},
+			}, server.Client()))
+		authorization, err := flow.Begin(ctx, "corp")
+		require.NoError(t, err)
+		callback, err := server.Authorize(authorization.URL, identityForTest)
+		require.NoError(t, err)
+
+		now = now.Add(oidc.StateTTL)
+		_, err = flow.Complete(ctx, "corp", callback.Query().Get("state"), authorization.StateCookie, callback.Query().Get("code"))
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+	})
+
+	t.Run("設定されていないプロバイダーは拒否すること", func(t *testing.T) {
+		t.Parallel()
+		flow, _ := newFlowForTest(t)
+		_, err := flow.Begin(ctx, "unknown")
+		assert.ErrorIs(t, err, oidc.ErrUnknownProvider)
+	})
+
+	t.Run("認可コードが不正な場合は交換に失敗すること", func(t *testing.T) {
+		t.Parallel()
+		flow, server := newFlowForTest(t)
+		authorization, err := flow.Begin(ctx, "corp")
+		require.NoError(t, err)
+		callback, err := server.Authorize(authorization.URL, identityForTest)
+		require.NoError(t, err)
+		_, err = flow.Complete(ctx, "corp", callback.Query().Get("state"), authorization.StateCookie, "invalid")
+		assert.ErrorIs(t, err, oidc.ErrExchangeFailed)
+	})
+
+	invalidTokenTests := []struct {
+		name string
+		hook func(claims jwt.MapClaims)
+	}{
+		{name: "nonceが一致しないIDトークンは拒否すること", hook: func(c jwt.MapClaims) { c["nonce"] = "other" }},
+		{name: "対象が異なるIDトークンは拒否すること", hook: func(c jwt.MapClaims) { c["aud"] = "other" }},
+		{name: "発行者が異なるIDトークンは拒否すること", hook: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "期限切れのIDトークンは拒否すること", hook: func(c jwt.MapClaims) { c["exp"] = 1 }},
+		{name: "有効期限の無いIDトークンは拒否すること", hook: func(c jwt.MapClaims) { delete(c, "exp") }},
+	}
+	for _, tt := range invalidTokenTests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			flow, server := newFlowForTest(t)
+			server.IDTokenHook = tt.hook
+			authorization, err := flow.Begin(ctx, "corp")
+			require.NoError(t, err)
+			callback, err := server.Authorize(authorization.URL, identityForTest)
+			require.NoError(t, err)
+			_, err = flow.Complete(ctx, "corp", callback.Query().Get("state"), authorization.StateCookie, callback.Query().Get("code"))
+			assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+		})
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	t.Parallel()
+
+	// SHA-256("abc")をパディング無しのBase64URLにしたもの
+	assert.Equal(t, "ungWv48Bz-pBQUDeXa4iI7ADYaOWF3qctBD_YfIAFa0", oidc.CodeChallenge("abc"))
+}
@@ -0,0 +1,205 @@
+// Package oidctest テスト用のローカルで動くOIDCプロバイダー
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const keyID = "oidctest"
+
+// Identity 認可したことにする利用者の情報
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+type grant struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	identity      Identity
+}
+
+// Server 認可エンドポイントの画面は持たず、Authorizeで利用者が同意した状態を作る
+type Server struct {
+	ClientID     string
+	ClientSecret string
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+	// IDTokenHook 発行するIDトークンのクレームを書き換えて、不正なトークンを試す
+	IDTokenHook func(claims jwt.MapClaims)
+}
+
+func NewServer(clientID, clientSecret string) (*Server, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		grants:       map[string]grant{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("/jwks", s.handleJWKS)
+	mux.HandleFunc("/token", s.handleToken)
+	s.server = httptest.NewServer(mux)
+	return s, nil
+}
+
+func (s *Server) Issuer() string {
+	return s.server.URL
+}
+
+func (s *Server) Client() *http.Client {
+	return s.server.Client()
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Authorize 認可エンドポイントのURLに対して利用者が同意したものとして、コールバックのURLを返す
+func (s *Server) Authorize(authURL string, identity Identity) (*url.URL, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	if q.Get("client_id") != s.ClientID || q.Get("response_type") != "code" {
+		return nil, errors.New("invalid authorization request")
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		return nil, errors.New("pkce is required")
+	}
+	code := randomString()
+	s.mu.Lock()
+	s.grants[code] = grant{
+		clientID:      q.Get("client_id"),
+		redirectURI:   q.Get("redirect_uri"),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+		identity:      identity,
+	}
+	s.mu.Unlock()
+	callback, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		return nil, err
+	}
+	cq := callback.Query()
+	cq.Set("code", code)
+	cq.Set("state", q.Get("state"))
+	callback.RawQuery = cq.Encode()
+	return callback, nil
+}
+
+func (s *Server) handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.Issuer(),
+		"authorization_endpoint": s.Issuer() + "/authorize",
+		"token_endpoint":         s.Issuer() + "/token",
+		"jwks_uri":               s.Issuer() + "/jwks",
+	})
+}
+
+func (s *Server) handleJWKS(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": keyID,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	}
+	if clientID != s.ClientID || clientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	code := r.PostForm.Get("code")
+	s.mu.Lock()
+	g, ok := s.grants[code]
+	delete(s.grants, code)
+	s.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || g.redirectURI != r.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != g.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	claims := jwt.MapClaims{
+		"iss":            s.Issuer(),
+		"sub":            g.identity.Subject,
+		"aud":            g.clientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          g.nonce,
+		"email":          g.identity.Email,
+		"email_verified": g.identity.EmailVerified,
+		"given_name":     g.identity.GivenName,
+		"family_name":    g.identity.FamilyName,
+	}
+	if s.IDTokenHook != nil {
+		s.IDTokenHook(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,279 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	// ErrInvalidIDToken 署名、発行者、対象、有効期限、nonceのいずれかが一致しないIDトークン
+	ErrInvalidIDToken = errors.New("invalid id token")
+	// ErrExchangeFailed 認可コードをトークンに交換できなかった
+	ErrExchangeFailed = errors.New("failed to exchange authorization code")
+)
+
+// Claims IDトークンから取り出すユーザーの情報
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Name          string
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+}
+
+// Provider OIDCプロバイダーとのやり取りを行う
+// エンドポイントと署名鍵は初回の利用時にディスカバリーで取得し、未知の鍵IDが現れた場合は鍵を取得し直す
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]*rsa.PublicKey
+}
+
+func NewProvider(config Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{config: config, client: client}
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+func (p *Provider) Config() Config {
+	return p.config
+}
+
+// AuthCodeURL 利用者をリダイレクトする認可エンドポイントのURLを返す
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.config.ClientID)
+	v.Set("redirect_uri", p.config.RedirectURL)
+	v.Set("scope", strings.Join(p.config.Scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", codeChallenge)
+	v.Set("code_challenge_method", "S256")
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + v.Encode(), nil
+}
+
+// Exchange 認可コードをトークンに交換し、IDトークンを検証して情報を返す
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.config.ClientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var token tokenResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchangeFailed, err)
+	}
+	if res.StatusCode != http.StatusOK || token.IDToken == "" {
+		return nil, fmt.Errorf("%w: status %d %s", ErrExchangeFailed, res.StatusCode, token.Error)
+	}
+	return p.verifyIDToken(ctx, d, token.IDToken, nonce)
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, d *discovery, rawIDToken, nonce string) (*Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != jwt.SigningMethodRS256.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.getKey(ctx, d, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if !claims.VerifyIssuer(d.Issuer, true) || !hasAudience(claims["aud"], p.config.ClientID) {
+		return nil, fmt.Errorf("%w: issuer or audience mismatch", ErrInvalidIDToken)
+	}
+	// jwt-goは有効期限が無いトークンも有効とするため、ここで必須にする
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: exp is required", ErrInvalidIDToken)
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	c := &Claims{}
+	c.Subject, _ = claims["sub"].(string)
+	c.Email, _ = claims["email"].(string)
+	c.GivenName, _ = claims["given_name"].(string)
+	c.FamilyName, _ = claims["family_name"].(string)
+	c.Name, _ = claims["name"].(string)
+	// email_verifiedを文字列で返すプロバイダーもある
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		c.EmailVerified = v
+	case string:
+		c.EmailVerified = v == "true"
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: sub is required", ErrInvalidIDToken)
+	}
+	return c, nil
+}
+
+func hasAudience(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if s, _ := a.(string); s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	var d discovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("failed to discover oidc provider %s: %w", p.config.Name, err)
+	}
+	if d.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("oidc provider %s returned issuer %s", p.config.Name, d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc provider %s returned incomplete discovery document", p.config.Name)
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func (p *Provider) getKey(ctx context.Context, d *discovery, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, d.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to get jwks: %w", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		key, err := parseRSAKey(k)
+		if err != nil {
+			return nil, err
+		}
+		keys[k.Kid] = key
+	}
+	p.keys = keys
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", res.StatusCode, rawURL)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
+
+func parseRSAKey(k jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk exponent: %w", err)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
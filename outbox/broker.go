@@ -0,0 +1,36 @@
+package outbox
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+// Message ブローカーに発行される1件のイベント
+// 配信は少なくとも1回(at-least-once)のため、購読側はDedupKeyで重複を除外する
+type Message struct {
+	ID        string    `json:"id"`
+	Topic     string    `json:"topic"`
+	DedupKey  string    `json:"dedup_key"`
+	Payload   string    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewMessage アウトボックスの行から発行するメッセージを作成する
+func NewMessage(event *models.OutboxEvent) Message {
+	return Message{
+		ID:        strconv.FormatUint(event.ID, 10),
+		Topic:     event.Topic,
+		DedupKey:  event.DedupKey,
+		Payload:   event.Payload,
+		CreatedAt: event.CreatedAt,
+	}
+}
+
+// Broker メッセージの発行先
+// 既に発行済みのDedupKeyを受け取った場合はエラーにせず成功として扱う
+type Broker interface {
+	Publish(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,42 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBroker テストやローカル実行用のインメモリブローカー
+type MemoryBroker struct {
+	mu       sync.Mutex
+	messages []Message
+	seen     map[string]struct{}
+	// Err nil以外を設定するとPublishがそのエラーを返す
+	Err error
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{seen: map[string]struct{}{}}
+}
+
+func (b *MemoryBroker) Publish(_ context.Context, msg Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Err != nil {
+		return b.Err
+	}
+	if _, ok := b.seen[msg.DedupKey]; ok {
+		return nil
+	}
+	b.seen[msg.DedupKey] = struct{}{}
+	b.messages = append(b.messages, msg)
+	return nil
+}
+
+// Messages 発行されたメッセージを発行順に返す
+func (b *MemoryBroker) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	messages := make([]Message, len(b.messages))
+	copy(messages, b.messages)
+	return messages
+}
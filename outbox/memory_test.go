@@ -0,0 +1,61 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/outbox"
+)
+
+func TestMemoryBroker_Publish(t *testing.T) {
+	t.Parallel()
+
+	t.Run("同じ重複排除キーのメッセージは1件だけ発行される", func(t *testing.T) {
+		t.Parallel()
+		broker := outbox.NewMemoryBroker()
+		msg := outbox.Message{ID: "1", Topic: outbox.TopicOrderCreated, DedupKey: "order.created:a"}
+
+		require.NoError(t, broker.Publish(context.Background(), msg))
+		require.NoError(t, broker.Publish(context.Background(), msg))
+
+		assert.Equal(t, []outbox.Message{msg}, broker.Messages())
+	})
+
+	t.Run("エラーを設定した場合は発行されない", func(t *testing.T) {
+		t.Parallel()
+		broker := outbox.NewMemoryBroker()
+		broker.Err = errors.New("unavailable")
+
+		err := broker.Publish(context.Background(), outbox.Message{DedupKey: "x"})
+
+		assert.EqualError(t, err, "unavailable")
+		assert.Empty(t, broker.Messages())
+	})
+}
+
+func TestNewMessage(t *testing.T) {
+	t.Parallel()
+	createdAt := time.Date(2022, 9, 18, 10, 0, 0, 0, time.UTC)
+
+	got := outbox.NewMessage(&models.OutboxEvent{
+		ID:        42,
+		Topic:     outbox.TopicCouponAcquired,
+		DedupKey:  outbox.DedupKey(outbox.TopicCouponAcquired, "coupon", "user"),
+		Payload:   `{"coupon_id":"coupon"}`,
+		CreatedAt: createdAt,
+	})
+
+	assert.Equal(t, outbox.Message{
+		ID:        "42",
+		Topic:     "coupon.acquired",
+		DedupKey:  "coupon.acquired:coupon:user",
+		Payload:   `{"coupon_id":"coupon"}`,
+		CreatedAt: createdAt,
+	}, got)
+}
@@ -0,0 +1,41 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+const (
+	TopicOrderCreated   = "order.created"
+	TopicCouponAcquired = "coupon.acquired"
+)
+
+// Record イベントをアウトボックスに書き込む
+// 業務データと同じトランザクションを渡すことで、コミットされたイベントだけが後からリレーされる
+// dedupKeyは同じ出来事に対して常に同じ値になるようにする
+func Record(tx *gorm.DB, topic, aggregateID, dedupKey string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxEvent{
+		Topic:       topic,
+		AggregateID: aggregateID,
+		DedupKey:    dedupKey,
+		Payload:     string(payload),
+		CreatedAt:   time.Now(),
+	}).Error
+}
+
+// DedupKey トピックと識別子から重複排除キーを組み立てる
+func DedupKey(topic string, ids ...string) string {
+	key := topic
+	for _, id := range ids {
+		key += ":" + id
+	}
+	return key
+}
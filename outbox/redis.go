@@ -0,0 +1,54 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+const (
+	DefaultStreamPrefix = "events:"
+	DefaultDedupTTL     = 24 * time.Hour
+)
+
+// publishScript 重複排除キーの登録とストリームへの追加をアトミックに行う
+// キーが既に存在する場合は追加せずnilを返す
+var publishScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'EX', ARGV[2]) then
+  return redis.call('XADD', KEYS[2], '*',
+    'id', ARGV[1], 'topic', ARGV[3], 'dedup_key', ARGV[4], 'payload', ARGV[5], 'created_at', ARGV[6])
+end
+return false
+`)
+
+// RedisStreamBroker トピックごとのRedis Streamsにメッセージを追加するブローカー
+type RedisStreamBroker struct {
+	client       *redis.Client
+	StreamPrefix string
+	DedupTTL     time.Duration
+}
+
+func NewRedisStreamBroker(client *redis.Client) *RedisStreamBroker {
+	return &RedisStreamBroker{
+		client:       client,
+		StreamPrefix: DefaultStreamPrefix,
+		DedupTTL:     DefaultDedupTTL,
+	}
+}
+
+func (b *RedisStreamBroker) Publish(ctx context.Context, msg Message) error {
+	err := publishScript.Run(ctx, b.client,
+		[]string{b.dedupKey(msg.DedupKey), b.StreamPrefix + msg.Topic},
+		msg.ID, int(b.DedupTTL.Seconds()), msg.Topic, msg.DedupKey, msg.Payload,
+		msg.CreatedAt.Format(time.RFC3339Nano),
+	).Err()
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+func (b *RedisStreamBroker) dedupKey(key string) string {
+	return b.StreamPrefix + "dedup:" + key
+}
@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+const (
+	DefaultBatchSize = 100
+	// ClaimTTL リレーが取得したイベントを他のリレーから取得されないようにする期間。1回の発行にかかる時間より十分長くする
+	ClaimTTL       = 5 * time.Minute
+	maxErrorLength = 255
+)
+
+// Relay 未発行のアウトボックスをブローカーへ発行する
+type Relay struct {
+	db        *gorm.DB
+	broker    Broker
+	logger    *zap.Logger
+	BatchSize int
+}
+
+func NewRelay(db *gorm.DB, broker Broker, logger *zap.Logger) *Relay {
+	return &Relay{
+		db:        db,
+		broker:    broker,
+		logger:    logger,
+		BatchSize: DefaultBatchSize,
+	}
+}
+
+// RelayPending 未発行のイベントを作成順に取得して発行し、発行できた件数を返す
+// 取得したイベントはClaimTTLの間は他のリレーから取得されないため、複数のリレーを動かしても同じイベントを同時に発行しない
+// 発行に失敗した場合は順序を保つためそこで打ち切り、残りの取得を解除して次回の実行で再試行する
+// 発行後に発行日時の更新が失敗した場合は再度発行されるが、DedupKeyにより購読側で除外できる
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	token, err := uuid.NewRandom()
+	if err != nil {
+		return 0, err
+	}
+	events, err := r.claim(token.String())
+	if err != nil {
+		return 0, err
+	}
+	defer r.release(token.String())
+
+	for i := range events {
+		event := &events[i]
+		if err := r.broker.Publish(ctx, NewMessage(event)); err != nil {
+			r.logger.Warn("failed to publish outbox event",
+				zap.Uint64("outbox_event_id", event.ID),
+				zap.String("topic", event.Topic),
+				zap.Error(err))
+			if updateErr := r.db.Table("outbox_events").Where("id = ?", event.ID).
+				Updates(map[string]interface{}{
+					"attempts":   event.Attempts + 1,
+					"last_error": truncate(err.Error(), maxErrorLength),
+				}).Error; updateErr != nil {
+				return i, updateErr
+			}
+			return i, err
+		}
+		if err := r.db.Table("outbox_events").Where("id = ?", event.ID).
+			Updates(map[string]interface{}{
+				"attempts":      event.Attempts + 1,
+				"last_error":    "",
+				"published_at":  time.Now(),
+				"claim_token":   nil,
+				"claimed_until": nil,
+			}).Error; err != nil {
+			return i, err
+		}
+	}
+	return len(events), nil
+}
+
+// claim 未発行で他のリレーが取得していないイベントを作成順にBatchSize件まで取得する
+// 取得は条件付きの更新で行うため、同じイベントを複数のリレーが取得することはない
+func (r *Relay) claim(token string) ([]models.OutboxEvent, error) {
+	now := time.Now()
+	if err := r.db.Exec("UPDATE outbox_events SET claim_token = ?, claimed_until = ?"+
+		" WHERE published_at IS NULL AND (claimed_until IS NULL OR claimed_until < ?)"+
+		" ORDER BY id LIMIT ?", token, now.Add(ClaimTTL), now, r.BatchSize).Error; err != nil {
+		return nil, err
+	}
+	var events []models.OutboxEvent
+	if err := r.db.Where("claim_token = ? AND published_at IS NULL", token).
+		Order("id").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// release 発行しなかったイベントの取得を解除する。失敗しても取得の有効期限が過ぎれば他のリレーが取得できる
+func (r *Relay) release(token string) {
+	if err := r.db.Table("outbox_events").
+		Where("claim_token = ? AND published_at IS NULL", token).
+		Updates(map[string]interface{}{"claim_token": nil, "claimed_until": nil}).Error; err != nil {
+		r.logger.Warn("failed to release outbox events", zap.Error(err))
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,118 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/outbox"
+)
+
+func setupRelay(t *testing.T, broker outbox.Broker) (*gorm.DB, *outbox.Relay) {
+	t.Helper()
+	dbConn := db.Init()
+	require.NoError(t, dbConn.Exec("TRUNCATE TABLE outbox_events").Error)
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	return dbConn, outbox.NewRelay(dbConn, broker, zapLogger)
+}
+
+func recordEvents(t *testing.T, dbConn *gorm.DB, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		require.NoError(t, outbox.Record(dbConn, outbox.TopicOrderCreated, id,
+			outbox.DedupKey(outbox.TopicOrderCreated, id), map[string]string{"id": id}))
+	}
+}
+
+func findEvents(t *testing.T, dbConn *gorm.DB) []models.OutboxEvent {
+	t.Helper()
+	var events []models.OutboxEvent
+	require.NoError(t, dbConn.Order("id").Find(&events).Error)
+	return events
+}
+
+func TestRelayPending(t *testing.T) {
+	t.Run("未発行のイベントを作成順に発行し、発行済みにすること", func(t *testing.T) {
+		broker := outbox.NewMemoryBroker()
+		dbConn, relay := setupRelay(t, broker)
+		recordEvents(t, dbConn, "a", "b")
+
+		count, err := relay.RelayPending(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		messages := broker.Messages()
+		require.Len(t, messages, 2)
+		assert.Equal(t, "order.created:a", messages[0].DedupKey)
+		assert.Equal(t, "order.created:b", messages[1].DedupKey)
+		for _, event := range findEvents(t, dbConn) {
+			assert.True(t, event.IsPublished())
+			assert.Equal(t, 1, event.Attempts)
+			assert.Nil(t, event.ClaimToken)
+		}
+
+		count, err = relay.RelayPending(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+		assert.Len(t, broker.Messages(), 2)
+	})
+
+	t.Run("他のリレーが取得中のイベントは発行せず、取得の期限が切れたイベントは発行すること", func(t *testing.T) {
+		broker := outbox.NewMemoryBroker()
+		dbConn, relay := setupRelay(t, broker)
+		recordEvents(t, dbConn, "claimed", "expired")
+		require.NoError(t, dbConn.Exec("UPDATE outbox_events SET claim_token = 'other', claimed_until = ? WHERE aggregate_id = 'claimed'",
+			time.Now().Add(time.Minute)).Error)
+		require.NoError(t, dbConn.Exec("UPDATE outbox_events SET claim_token = 'other', claimed_until = ? WHERE aggregate_id = 'expired'",
+			time.Now().Add(-time.Minute)).Error)
+
+		count, err := relay.RelayPending(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		messages := broker.Messages()
+		require.Len(t, messages, 1)
+		assert.Equal(t, "order.created:expired", messages[0].DedupKey)
+		events := findEvents(t, dbConn)
+		assert.False(t, events[0].IsPublished())
+		require.NotNil(t, events[0].ClaimToken)
+		assert.Equal(t, "other", *events[0].ClaimToken)
+	})
+
+	t.Run("発行に失敗した場合は打ち切ってエラーを記録し、次回の実行で再試行すること", func(t *testing.T) {
+		broker := outbox.NewMemoryBroker()
+		dbConn, relay := setupRelay(t, broker)
+		recordEvents(t, dbConn, "a", "b")
+		broker.Err = errors.New("unavailable")
+
+		count, err := relay.RelayPending(context.Background())
+		assert.EqualError(t, err, "unavailable")
+		assert.Equal(t, 0, count)
+		events := findEvents(t, dbConn)
+		assert.False(t, events[0].IsPublished())
+		assert.Equal(t, 1, events[0].Attempts)
+		assert.Equal(t, "unavailable", events[0].LastError)
+		// 後続のイベントは試行せず、取得も解除する
+		assert.Equal(t, 0, events[1].Attempts)
+		for _, event := range events {
+			assert.Nil(t, event.ClaimToken)
+		}
+
+		broker.Err = nil
+		count, err = relay.RelayPending(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		events = findEvents(t, dbConn)
+		assert.True(t, events[0].IsPublished())
+		assert.Equal(t, 2, events[0].Attempts)
+		assert.Empty(t, events[0].LastError)
+		assert.True(t, events[1].IsPublished())
+	})
+}
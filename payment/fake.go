@@ -0,0 +1,121 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FakeProviderName = "fake"
+	// FakeDeclinedToken このトークンでオーソリすると必ず拒否される
+	FakeDeclinedToken = "tok_declined"
+)
+
+// FakeProvider 開発環境とテストで使うインメモリの決済プロバイダ
+type FakeProvider struct {
+	secret       []byte
+	mu           sync.Mutex
+	transactions map[string]*Result
+}
+
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{
+		secret:       []byte(secret),
+		transactions: map[string]*Result{},
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return FakeProviderName
+}
+
+func (p *FakeProvider) Authorize(_ context.Context, req AuthorizeRequest) (*Result, error) {
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if req.Token == FakeDeclinedToken {
+		return nil, ErrDeclined
+	}
+	newUUID, _ := uuid.NewRandom()
+	result := &Result{
+		TransactionID: "fake_" + newUUID.String(),
+		Status:        StatusAuthorized,
+		Amount:        req.Amount,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.transactions[result.TransactionID] = result
+	copied := *result
+	return &copied, nil
+}
+
+func (p *FakeProvider) Capture(_ context.Context, transactionID string, amount int64) (*Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tx, ok := p.transactions[transactionID]
+	if !ok {
+		return nil, ErrTransactionMissing
+	}
+	if tx.Status != StatusAuthorized || amount <= 0 || amount > tx.Amount {
+		return nil, ErrInvalidAmount
+	}
+	tx.CapturedAmount = amount
+	tx.Status = StatusCaptured
+	copied := *tx
+	return &copied, nil
+}
+
+func (p *FakeProvider) Refund(_ context.Context, transactionID string, amount int64) (*Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tx, ok := p.transactions[transactionID]
+	if !ok {
+		return nil, ErrTransactionMissing
+	}
+	if tx.Status != StatusCaptured && tx.Status != StatusPartiallyRefunded {
+		return nil, ErrInvalidAmount
+	}
+	if amount <= 0 || tx.RefundedAmount+amount > tx.CapturedAmount {
+		return nil, ErrInvalidAmount
+	}
+	tx.RefundedAmount += amount
+	tx.Status = StatusPartiallyRefunded
+	if tx.RefundedAmount == tx.CapturedAmount {
+		tx.Status = StatusRefunded
+	}
+	copied := *tx
+	return &copied, nil
+}
+
+func (p *FakeProvider) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	if len(p.secret) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, p.sign(payload)) {
+		return nil, ErrInvalidSignature
+	}
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// SignWebhook テストやローカル動作確認用にWebhookの署名を生成する
+func (p *FakeProvider) SignWebhook(payload []byte) string {
+	return hex.EncodeToString(p.sign(payload))
+}
+
+func (p *FakeProvider) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,82 @@
+package payment_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/payment"
+)
+
+func TestFakeProviderLifecycle(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	provider := payment.NewFakeProvider("secret")
+
+	authorized, err := provider.Authorize(ctx, payment.AuthorizeRequest{OrderID: "order", Amount: 1000, Token: "tok_visa"})
+	require.NoError(t, err)
+	assert.Equal(t, payment.StatusAuthorized, authorized.Status)
+
+	captured, err := provider.Capture(ctx, authorized.TransactionID, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, payment.StatusCaptured, captured.Status)
+	assert.Equal(t, int64(1000), captured.CapturedAmount)
+
+	partially, err := provider.Refund(ctx, authorized.TransactionID, 400)
+	require.NoError(t, err)
+	assert.Equal(t, payment.StatusPartiallyRefunded, partially.Status)
+
+	_, err = provider.Refund(ctx, authorized.TransactionID, 601)
+	assert.ErrorIs(t, err, payment.ErrInvalidAmount)
+
+	refunded, err := provider.Refund(ctx, authorized.TransactionID, 600)
+	require.NoError(t, err)
+	assert.Equal(t, payment.StatusRefunded, refunded.Status)
+	assert.Equal(t, int64(1000), refunded.RefundedAmount)
+}
+
+func TestFakeProviderErrors(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	provider := payment.NewFakeProvider("secret")
+
+	t.Run("拒否トークンの場合はオーソリが失敗すること", func(t *testing.T) {
+		t.Parallel()
+		_, err := provider.Authorize(ctx, payment.AuthorizeRequest{Amount: 1000, Token: payment.FakeDeclinedToken})
+		assert.ErrorIs(t, err, payment.ErrDeclined)
+	})
+
+	t.Run("存在しない取引は売上確定できないこと", func(t *testing.T) {
+		t.Parallel()
+		_, err := provider.Capture(ctx, "missing", 100)
+		assert.ErrorIs(t, err, payment.ErrTransactionMissing)
+	})
+
+	t.Run("オーソリ額を超えて売上確定できないこと", func(t *testing.T) {
+		t.Parallel()
+		authorized, err := provider.Authorize(ctx, payment.AuthorizeRequest{Amount: 100, Token: "tok_visa"})
+		require.NoError(t, err)
+		_, err = provider.Capture(ctx, authorized.TransactionID, 101)
+		assert.ErrorIs(t, err, payment.ErrInvalidAmount)
+	})
+}
+
+func TestFakeProviderVerifyWebhook(t *testing.T) {
+	t.Parallel()
+	provider := payment.NewFakeProvider("secret")
+	payload := []byte(`{"transaction_id":"fake_1","status":"captured","captured_amount":500}`)
+
+	event, err := provider.VerifyWebhook(payload, provider.SignWebhook(payload))
+	require.NoError(t, err)
+	assert.Equal(t, "fake_1", event.TransactionID)
+	assert.Equal(t, payment.StatusCaptured, event.Status)
+	assert.Equal(t, int64(500), event.CapturedAmount)
+
+	_, err = provider.VerifyWebhook(payload, "deadbeef")
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+
+	_, err = payment.NewFakeProvider("other").VerifyWebhook(payload, provider.SignWebhook(payload))
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+}
@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Status 決済プロバイダ上の取引ステータス
+type Status string
+
+const (
+	StatusAuthorized        Status = "authorized"
+	StatusCaptured          Status = "captured"
+	StatusPartiallyRefunded Status = "partially_refunded"
+	StatusRefunded          Status = "refunded"
+	StatusFailed            Status = "failed"
+)
+
+var (
+	ErrDeclined           = errors.New("payment declined")
+	ErrTransactionMissing = errors.New("transaction not found")
+	ErrInvalidAmount      = errors.New("invalid amount")
+	ErrInvalidSignature   = errors.New("invalid webhook signature")
+	// ErrWebhookSecretMissing 署名の鍵が空だと誰でもWebhookを偽造できるため、起動時に検出する
+	ErrWebhookSecretMissing = errors.New("PAYMENT_WEBHOOK_SECRET is not set")
+)
+
+// Provider 決済プロバイダの抽象
+// 実装はオーソリ、売上確定、返金、Webhook署名検証を提供する
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error)
+	Capture(ctx context.Context, transactionID string, amount int64) (*Result, error)
+	Refund(ctx context.Context, transactionID string, amount int64) (*Result, error)
+	VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
+
+type AuthorizeRequest struct {
+	OrderID string
+	Amount  int64
+	// Token カード情報などをトークン化したもの
+	Token string
+}
+
+type Result struct {
+	TransactionID  string
+	Status         Status
+	Amount         int64
+	CapturedAmount int64
+	RefundedAmount int64
+}
+
+// WebhookEvent プロバイダから非同期に通知される取引結果
+type WebhookEvent struct {
+	TransactionID  string `json:"transaction_id"`
+	Status         Status `json:"status"`
+	CapturedAmount int64  `json:"captured_amount"`
+	RefundedAmount int64  `json:"refunded_amount"`
+}
+
+// ProviderFromEnv 環境変数PAYMENT_PROVIDERで決済プロバイダを選ぶ。未設定の場合はfake
+// Webhookの署名を検証する鍵PAYMENT_WEBHOOK_SECRETは必須とする
+func ProviderFromEnv() (Provider, error) {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, ErrWebhookSecretMissing
+	}
+	name := os.Getenv("PAYMENT_PROVIDER")
+	if name == "" {
+		name = FakeProviderName
+	}
+	switch name {
+	case FakeProviderName:
+		return NewFakeProvider(secret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider: %s", name)
+	}
+}
@@ -0,0 +1,42 @@
+package payment_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/payment"
+)
+
+func TestProviderFromEnv(t *testing.T) {
+	t.Run("Webhookの署名鍵が未設定の場合はエラーになること", func(t *testing.T) {
+		t.Setenv("PAYMENT_WEBHOOK_SECRET", "")
+		t.Setenv("PAYMENT_PROVIDER", "")
+		_, err := payment.ProviderFromEnv()
+		assert.ErrorIs(t, err, payment.ErrWebhookSecretMissing)
+	})
+
+	t.Run("未設定の場合はfakeを使うこと", func(t *testing.T) {
+		t.Setenv("PAYMENT_WEBHOOK_SECRET", "secret")
+		t.Setenv("PAYMENT_PROVIDER", "")
+		provider, err := payment.ProviderFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, payment.FakeProviderName, provider.Name())
+	})
+
+	t.Run("不明なプロバイダはエラーになること", func(t *testing.T) {
+		t.Setenv("PAYMENT_WEBHOOK_SECRET", "secret")
+		t.Setenv("PAYMENT_PROVIDER", "unknown")
+		_, err := payment.ProviderFromEnv()
+		assert.EqualError(t, err, "unknown payment provider: unknown")
+	})
+}
+
+func TestFakeProviderWithoutSecret(t *testing.T) {
+	t.Parallel()
+	provider := payment.NewFakeProvider("")
+	payload := []byte(`{"transaction_id":"fake","status":"captured"}`)
+	_, err := provider.VerifyWebhook(payload, provider.SignWebhook(payload))
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+}
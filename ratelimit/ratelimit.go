@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const keyPrefix = "ratelimit:"
+
+// Limit トークンバケットの設定。Per の間にRequests回までリクエストでき、トークンは一定の速さで補充される
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// rate 1ミリ秒あたりに補充されるトークンの数
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / float64(l.Per.Milliseconds())
+}
+
+// ParseLimit "100/1m" の形式の設定を解釈する
+func ParseLimit(v string) (Limit, error) {
+	parts := strings.SplitN(v, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", v)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", v)
+	}
+	per, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || per < time.Millisecond {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", v)
+	}
+	return Limit{Requests: requests, Per: per}, nil
+}
+
+// LimitFromEnv 環境変数RATE_LIMIT_<NAME>が設定されていればその値を、なければdefで返す
+func LimitFromEnv(name string, def Limit) (Limit, error) {
+	key := "RATE_LIMIT_" + strings.ToUpper(name)
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	limit, err := ParseLimit(v)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return limit, nil
+}
+
+// Result リクエストを受け付けてよいかの判定結果
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter 次のリクエストを受け付けられるまでの時間。受け付けた場合は0
+	RetryAfter time.Duration
+	// ResetAfter トークンが上限まで補充されるまでの時間
+	ResetAfter time.Duration
+}
+
+// Limiter キーごとのトークンバケットでリクエストを制限する
+// 保存先でエラーが起きた場合はfallbackで判定し、保存先の障害でAPI全体を止めないようにする
+type Limiter struct {
+	store    Store
+	fallback Store
+	logger   *zap.Logger
+	now      func() time.Time
+}
+
+func NewLimiter(store, fallback Store, logger *zap.Logger) *Limiter {
+	return NewLimiterWithClock(store, fallback, logger, time.Now)
+}
+
+func NewLimiterWithClock(store, fallback Store, logger *zap.Logger, now func() time.Time) *Limiter {
+	return &Limiter{store: store, fallback: fallback, logger: logger, now: now}
+}
+
+// Allow keyのトークンを1つ消費する
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := l.now()
+	key = keyPrefix + key
+	allowed, tokens, err := l.store.Take(ctx, key, limit, now)
+	if err != nil && l.fallback != nil {
+		l.logger.Warn("failed to take rate limit token, falling back", zap.Error(err), zap.String("key", key))
+		allowed, tokens, err = l.fallback.Take(ctx, key, limit, now)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(allowed, tokens, limit), nil
+}
+
+func newResult(allowed bool, tokens float64, limit Limit) Result {
+	rate := limit.rate()
+	res := Result{
+		Allowed:    allowed,
+		Limit:      limit.Requests,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: millis((float64(limit.Requests) - tokens) / rate),
+	}
+	if !allowed {
+		res.RetryAfter = millis((1 - tokens) / rate)
+	}
+	return res
+}
+
+func millis(ms float64) time.Duration {
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
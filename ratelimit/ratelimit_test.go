@@ -0,0 +1,128 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/ratelimit"
+)
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Limit, time.Time) (bool, float64, error) {
+	return false, 0, errors.New("connection refused")
+}
+
+func TestParseLimit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		value   string
+		want    ratelimit.Limit
+		wantErr bool
+	}{
+		{name: "回数と期間を解釈できること", value: "100/1m", want: ratelimit.Limit{Requests: 100, Per: time.Minute}},
+		{name: "空白を無視すること", value: " 5 / 10s ", want: ratelimit.Limit{Requests: 5, Per: 10 * time.Second}},
+		{name: "区切りがない場合はエラーになること", value: "100", wantErr: true},
+		{name: "回数が0の場合はエラーになること", value: "0/1m", wantErr: true},
+		{name: "期間が不正な場合はエラーになること", value: "10/minute", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ratelimit.ParseLimit(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	limit := ratelimit.Limit{Requests: 3, Per: 3 * time.Second}
+
+	t.Run("上限までは受け付け、超えると再試行までの時間を返すこと", func(t *testing.T) {
+		t.Parallel()
+		c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+		limiter := ratelimit.NewLimiterWithClock(ratelimit.NewMemoryStore(), nil, zap.NewNop(), c.Now)
+
+		for i := 2; i >= 0; i-- {
+			res, err := limiter.Allow(ctx, "user:a", limit)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 3, res.Limit)
+			assert.Equal(t, i, res.Remaining)
+		}
+		res, err := limiter.Allow(ctx, "user:a", limit)
+		require.NoError(t, err)
+		assert.False(t, res.Allowed)
+		assert.Equal(t, 0, res.Remaining)
+		assert.Equal(t, time.Second, res.RetryAfter)
+		assert.Equal(t, 3*time.Second, res.ResetAfter)
+
+		// 別のキーは影響を受けない
+		res, err = limiter.Allow(ctx, "user:b", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	})
+
+	t.Run("時間の経過でトークンが補充されること", func(t *testing.T) {
+		t.Parallel()
+		c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+		limiter := ratelimit.NewLimiterWithClock(ratelimit.NewMemoryStore(), nil, zap.NewNop(), c.Now)
+		for i := 0; i < 4; i++ {
+			_, err := limiter.Allow(ctx, "ip:192.0.2.1", limit)
+			require.NoError(t, err)
+		}
+
+		c.now = c.now.Add(time.Second)
+		res, err := limiter.Allow(ctx, "ip:192.0.2.1", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 0, res.Remaining)
+
+		c.now = c.now.Add(time.Hour)
+		res, err = limiter.Allow(ctx, "ip:192.0.2.1", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 2, res.Remaining)
+	})
+
+	t.Run("保存先でエラーが起きた場合は代わりの保存先で判定すること", func(t *testing.T) {
+		t.Parallel()
+		c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+		limiter := ratelimit.NewLimiterWithClock(failingStore{}, ratelimit.NewMemoryStore(), zap.NewNop(), c.Now)
+		for i := 0; i < 3; i++ {
+			res, err := limiter.Allow(ctx, "apikey:a", limit)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+		}
+		res, err := limiter.Allow(ctx, "apikey:a", limit)
+		require.NoError(t, err)
+		assert.False(t, res.Allowed)
+	})
+
+	t.Run("代わりの保存先がない場合はエラーを返すこと", func(t *testing.T) {
+		t.Parallel()
+		limiter := ratelimit.NewLimiter(failingStore{}, nil, zap.NewNop())
+		_, err := limiter.Allow(ctx, "apikey:a", limit)
+		assert.Error(t, err)
+	})
+}
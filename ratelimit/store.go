@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+// Store トークンバケットの保存先
+type Store interface {
+	// Take 経過時間に応じてトークンを補充してから1つ消費する。消費できたかと残りのトークンを返す
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, float64, error)
+}
+
+// takeScript 補充と消費を1回の操作で行い、複数のサーバーから同時に呼ばれても数がずれないようにする
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = capacity
+  ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore 複数のサーバーでトークンを共有する
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, float64, error) {
+	res, err := takeScript.Run(ctx, s.client, []string{key},
+		limit.Requests,
+		strconv.FormatFloat(limit.rate(), 'g', -1, 64),
+		now.UnixNano()/int64(time.Millisecond),
+		limit.Per.Milliseconds(),
+	).Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	allowed, _ := res[0].(int64)
+	str, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed == 1, tokens, nil
+}
+
+// maxMemoryBuckets MemoryStoreが不要なバケットを捨て始める数
+const maxMemoryBuckets = 10000
+
+// MemoryStore テストやRedisに接続できない場合のインメモリの保存先。サーバーごとに数える
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+}
+
+type bucket struct {
+	tokens float64
+	ts     time.Time
+	// per この期間使われなければ上限まで補充されている
+	per time.Duration
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]bucket{}}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (bool, float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	capacity := float64(limit.Requests)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = bucket{tokens: capacity, ts: now}
+	}
+	elapsed := float64(now.Sub(b.ts).Milliseconds())
+	b.tokens = math.Min(capacity, b.tokens+math.Max(0, elapsed)*limit.rate())
+	b.ts = now
+	b.per = limit.Per
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	s.buckets[key] = b
+	s.evict(now)
+	return allowed, b.tokens, nil
+}
+
+// evict 上限まで補充済みのバケットは作り直しても結果が変わらないため、定期的に捨ててメモリを抑える
+func (s *MemoryStore) evict(now time.Time) {
+	if len(s.buckets) < maxMemoryBuckets {
+		return
+	}
+	for key, b := range s.buckets {
+		if now.Sub(b.ts) >= b.per {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -1,8 +1,26 @@
 package server
 
 import (
-	"github.com/AI1411/golang-admin-api/logger"
 	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+
+	"github.com/AI1411/golang-admin-api/authtoken"
+	"github.com/AI1411/golang-admin-api/idempotency"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/loginguard"
+	"github.com/AI1411/golang-admin-api/mailer"
+	"github.com/AI1411/golang-admin-api/oidc"
+	"github.com/AI1411/golang-admin-api/payment"
+	"github.com/AI1411/golang-admin-api/ratelimit"
+	"github.com/AI1411/golang-admin-api/session"
+	"github.com/AI1411/golang-admin-api/util"
+	jwtutil "github.com/AI1411/golang-admin-api/util/jwt"
+	"github.com/AI1411/golang-admin-api/webhook"
 
 	"github.com/AI1411/golang-admin-api/db"
 	"github.com/AI1411/golang-admin-api/handler"
@@ -22,34 +40,131 @@ func Router() *gin.Engine {
 	dbConn := db.Init()
 	uuidGen := &models.RandomUUIDGenerator{}
 	todoHandler := handler.NewTodoHandler(dbConn, zapLogger)
+	notificationHandler := handler.NewNotificationHandler(dbConn, zapLogger)
+	calendarHandler := handler.NewCalendarHandler(dbConn, zapLogger)
 	userHandler := handler.NewUserHandler(dbConn, zapLogger)
-	authHandler := handler.NewAuthHandler(dbConn, zapLogger)
+	organizationHandler := handler.NewOrganizationHandler(dbConn, zapLogger)
+	redisClient := redis.NewClient(&redis.Options{Addr: util.GetEnv("REDIS_ADDR", "localhost:6379")})
+	cookieConfig, err := session.CookieConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sessionManager := session.NewManager(session.NewRedisStore(redisClient), cookieConfig, jwtutil.TokenLifetime)
+	mail, err := mailer.NewFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	authTokenSecret, err := authtoken.SecretFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	authHandler := handler.NewAuthHandler(dbConn, zapLogger, mail,
+		authtoken.NewIssuer(authtoken.NewRedisStore(redisClient), authTokenSecret),
+		loginguard.New(loginguard.NewRedisStore(redisClient), loginguard.DefaultPolicy),
+		sessionManager, util.GetEnv("APP_BASE_URL", "http://localhost:3000"))
+	oidcConfigs, err := oidc.ConfigsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var oidcProviders []*oidc.Provider
+	for _, config := range oidcConfigs {
+		oidcProviders = append(oidcProviders, oidc.NewProvider(config, nil))
+	}
+	oidcHandler := handler.NewOIDCHandler(dbConn, zapLogger,
+		oidc.NewFlow(authtoken.NewRedisStore(redisClient), authTokenSecret, oidcProviders...), authHandler)
+	apiKeyHandler := handler.NewAPIKeyHandler(dbConn, zapLogger, uuidGen)
+	sessionHandler := handler.NewSessionHandler(zapLogger, sessionManager)
 	productHandler := handler.NewProductHandler(dbConn, uuidGen, zapLogger)
 	orderHandler := handler.NewOrderHandler(dbConn, zapLogger)
 	orderDetailHandler := handler.NewOrderDetailHandler(dbConn, zapLogger)
 	couponHandler := handler.NewCouponHandler(dbConn, zapLogger)
 	qrcodeHandler := handler.NewQrcodeHandler(dbConn)
-	userGroupHandler := handler.NewUserGroupHandler(dbConn)
+	userGroupHandler := handler.NewUserGroupHandler(dbConn, zapLogger)
 	milestoneHandler := handler.NewMilestoneHandler(dbConn, zapLogger)
 	epicHandler := handler.NewEpicHandler(dbConn, zapLogger)
 	projectHandler := handler.NewProjectHandler(dbConn, uuidGen, zapLogger)
 	subscriptionMemberHandler := handler.NewSubscriptionMemberHandler(dbConn, zapLogger, uuidGen)
-	issueHandler := handler.NewIssueHandler(dbConn, zapLogger)
+	subscriptionPlanHandler := handler.NewSubscriptionPlanHandler(dbConn, zapLogger, uuidGen)
+	issueHandler := handler.NewIssueHandler(dbConn, zapLogger, uuidGen)
+	commentHandler := handler.NewCommentHandler(dbConn, zapLogger, uuidGen)
+	labelHandler := handler.NewLabelHandler(dbConn, zapLogger, uuidGen)
+	paymentProvider, err := payment.ProviderFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	refundHandler := handler.NewRefundHandler(dbConn, zapLogger, uuidGen, paymentProvider)
+	paymentHandler := handler.NewPaymentHandler(dbConn, zapLogger, uuidGen, paymentProvider)
+	webhookHandler := handler.NewWebhookHandler(dbConn, zapLogger, uuidGen,
+		webhook.NewDispatcher(dbConn, &http.Client{Timeout: 10 * time.Second}, zapLogger))
+
+	limiter := ratelimit.NewLimiter(ratelimit.NewRedisStore(redisClient), ratelimit.NewMemoryStore(), zapLogger)
+	// グループごとの既定値。環境変数RATE_LIMIT_<グループ名>="100/1m"で上書きできる
+	rateLimits := map[string]ratelimit.Limit{
+		"auth":   {Requests: 20, Per: time.Minute},
+		"api":    {Requests: 600, Per: time.Minute},
+		"public": {Requests: 60, Per: time.Minute},
+	}
+	for name, def := range rateLimits {
+		limit, err := ratelimit.LimitFromEnv(name, def)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rateLimits[name] = limit
+	}
+	// 処理中のキーは1分、処理結果は1日保持する
+	idempotencyKeeper := idempotency.NewKeeper(idempotency.NewRedisStore(redisClient), time.Minute, 24*time.Hour)
+	publicRateLimit := middleware.RateLimit(limiter, "public", rateLimits["public"])
 
 	r := gin.Default()
+	// X-Forwarded-Forを信頼するとIPアドレスごとのレート制限を回避でき、セッションにも偽のIPアドレスが残る
+	if err := r.SetTrustedProxies(trustedProxiesFromEnv()); err != nil {
+		log.Fatal(err)
+	}
 
 	r.Use(middleware.Cors())
 	r.Use(func(_ *gin.Context) { binding.EnableDecoderUseNumber = true })
 	r.Use(middleware.NewTracing())
 	r.Use(middleware.NewLogging(zapLogger))
 	authorized := r.Group("/")
-	authorized.Use(middleware.AuthenticateBearer())
+	authorized.Use(middleware.AuthenticateBearer(dbConn, sessionManager))
+	authorized.Use(middleware.ResolveOrganization(dbConn))
+	authorized.Use(middleware.RateLimit(limiter, "api", rateLimits["api"]))
+	authorized.Use(middleware.Idempotency(idempotencyKeeper, zapLogger))
 	auth := r.Group("/auth")
+	auth.Use(middleware.RateLimit(limiter, "auth", rateLimits["auth"]))
 	{
 		auth.POST("/login", authHandler.Login)
 		auth.POST("/logout", authHandler.Logout)
 		auth.POST("/register", authHandler.Register)
+		auth.POST("/verify-email", authHandler.VerifyEmail)
+		auth.POST("/verify-email/resend", authHandler.ResendVerificationEmail)
+		auth.POST("/password/forgot", authHandler.ForgotPassword)
+		auth.POST("/password/reset", authHandler.ResetPassword)
 		authorized.POST("/auth/me", authHandler.Me)
+		auth.POST("/2fa/verify", authHandler.VerifyTwoFactor)
+		auth.GET("/oidc/providers", oidcHandler.GetOIDCProviders)
+		auth.GET("/oidc/:provider/login", oidcHandler.BeginOIDCLogin)
+		auth.GET("/oidc/:provider/callback", oidcHandler.OIDCCallback)
+		authorized.POST("/auth/2fa/setup", authHandler.SetupTwoFactor)
+		authorized.POST("/auth/2fa/enable", authHandler.EnableTwoFactor)
+		authorized.POST("/auth/2fa/disable", authHandler.DisableTwoFactor)
+		authorized.POST("/auth/2fa/recoveryCodes", authHandler.RegenerateRecoveryCodes)
+	}
+	organization := authorized.Group("/organization")
+	{
+		organization.GET("", organizationHandler.GetOrganization)
+		organization.PUT("", organizationHandler.UpdateOrganization)
+	}
+	apiKeys := authorized.Group("/apiKeys")
+	{
+		apiKeys.GET("", apiKeyHandler.GetAPIKeys)
+		apiKeys.POST("", apiKeyHandler.CreateAPIKey)
+		apiKeys.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+	}
+	sessions := authorized.Group("/sessions")
+	{
+		sessions.GET("", sessionHandler.GetSessions)
+		sessions.DELETE("/:id", sessionHandler.RevokeSession)
 	}
 	todos := authorized.Group("/todos")
 	{
@@ -58,14 +173,35 @@ func Router() *gin.Engine {
 		todos.POST("", todoHandler.CreateTodo)
 		todos.PUT("/:id", todoHandler.UpdateTodo)
 		todos.DELETE("/:id", todoHandler.DeleteTodo)
+		todos.POST("/:id/checklist", todoHandler.CreateChecklistItem)
+		todos.PUT("/:id/checklist/:item_id", todoHandler.UpdateChecklistItem)
+		todos.DELETE("/:id/checklist/:item_id", todoHandler.DeleteChecklistItem)
+	}
+	// カレンダーアプリから購読するため、配信URLはトークンのみで認証する
+	r.GET("/calendar/:token", publicRateLimit, calendarHandler.GetCalendarFeed)
+	calendar := authorized.Group("/calendar")
+	{
+		calendar.POST("/token", calendarHandler.IssueCalendarToken)
+		calendar.DELETE("/token", calendarHandler.RevokeCalendarToken)
+		calendar.POST("/import", calendarHandler.ImportCalendar)
+	}
+	notifications := authorized.Group("/notifications")
+	{
+		notifications.GET("", notificationHandler.GetNotifications)
+		notifications.POST("/:id/read", notificationHandler.ReadNotification)
 	}
 	users := authorized.Group("/users")
 	{
 		users.GET("", userHandler.GetAllUser)
 		users.GET("/:id", userHandler.GetUserDetail)
 		users.PUT("/:id", userHandler.UpdateUser)
+		users.PATCH("/:id", userHandler.PatchUser)
 		users.DELETE("/:id", userHandler.DeleteUser)
 		users.POST("/exportCsv", userHandler.ExportCSV)
+		users.GET("/:id/groups", userGroupHandler.GetUserGroupMemberships)
+		users.POST("/:id/unlock", middleware.RequireAdmin(dbConn), authHandler.UnlockUser)
+		users.GET("/:id/loginAttempts", middleware.RequireAdmin(dbConn), authHandler.GetLoginAttempts)
+		users.PUT("/:id/role", middleware.RequireAdmin(dbConn), authHandler.UpdateUserRole)
 	}
 	products := authorized.Group("/products")
 	{
@@ -73,6 +209,7 @@ func Router() *gin.Engine {
 		products.GET("/:id", productHandler.GetProductDetail)
 		products.POST("", productHandler.CreateProduct)
 		products.PUT("/:id", productHandler.UpdateProduct)
+		products.PATCH("/:id", productHandler.PatchProduct)
 		products.DELETE("/:id", productHandler.DeleteProduct)
 	}
 	orders := authorized.Group("/orders")
@@ -81,8 +218,18 @@ func Router() *gin.Engine {
 		orders.GET("", orderHandler.GetOrders)
 		orders.GET("/:id", orderHandler.GetOrder)
 		orders.PUT("/:id", orderHandler.UpdateOrder)
+		orders.PATCH("/:id", orderHandler.PatchOrder)
 		orders.DELETE("/:id", orderHandler.DeleteOrder)
 		orders.POST("/exportPDF", orderHandler.ExportPDF)
+		orders.GET("/:id/payments", paymentHandler.GetOrderPayments)
+		orders.POST("/:id/payments", paymentHandler.CreatePayment)
+		orders.POST("/:id/refunds", refundHandler.CreateRefund)
+		orders.POST("/:id/returns", refundHandler.CreateReturn)
+	}
+	payments := authorized.Group("/payments")
+	{
+		payments.POST("/:id/capture", paymentHandler.CapturePayment)
+		payments.POST("/:id/refund", paymentHandler.RefundPayment)
 	}
 	orderDetails := authorized.Group("/orderDetails")
 	{
@@ -97,6 +244,7 @@ func Router() *gin.Engine {
 		coupons.GET("/:id", couponHandler.GetCouponDetail)
 		coupons.POST("", couponHandler.CreateCoupon)
 		coupons.PUT("/:id", couponHandler.UpdateCoupon)
+		coupons.PATCH("/:id", couponHandler.PatchCoupon)
 		coupons.POST("/acquire", couponHandler.AcquireCoupon)
 		coupons.POST("/discounted", couponHandler.DiscountedList)
 	}
@@ -105,13 +253,21 @@ func Router() *gin.Engine {
 		userGroups.GET("", userGroupHandler.GetAllUserGroups)
 		userGroups.GET("/:id", userGroupHandler.GetUserGroupsDetail)
 		userGroups.POST("", userGroupHandler.CreateUserGroup)
+		userGroups.PUT("/:id", userGroupHandler.UpdateUserGroup)
+		userGroups.DELETE("/:id", userGroupHandler.DeleteUserGroup)
+		userGroups.GET("/:id/members", userGroupHandler.GetGroupMembers)
+		userGroups.POST("/:id/members", userGroupHandler.AddGroupMember)
+		userGroups.PUT("/:id/members/:user_id", userGroupHandler.UpdateGroupMember)
+		userGroups.DELETE("/:id/members/:user_id", userGroupHandler.RemoveGroupMember)
 	}
 	milestones := authorized.Group("/milestones")
 	{
 		milestones.GET("", milestoneHandler.GetMilestones)
 		milestones.GET("/:id", milestoneHandler.GetMilestoneDetail)
+		milestones.GET("/:id/burndown", milestoneHandler.GetMilestoneBurndown)
 		milestones.POST("", milestoneHandler.CreateMilestone)
 		milestones.PUT("/:id", milestoneHandler.UpdateMileStone)
+		milestones.PATCH("/:id", milestoneHandler.PatchMileStone)
 	}
 	epics := authorized.Group("/epics")
 	{
@@ -119,14 +275,27 @@ func Router() *gin.Engine {
 		epics.GET("/:id", epicHandler.GetEpicDetail)
 		epics.POST("", epicHandler.CreateEpic)
 		epics.PUT("/:id", epicHandler.UpdateEpic)
+		epics.PATCH("/:id", epicHandler.PatchEpic)
 		epics.DELETE("/:id", epicHandler.DeleteEpic)
+		epics.GET("/:id/comments", commentHandler.GetEpicComments)
+		epics.POST("/:id/comments", commentHandler.CreateEpicComment)
+		epics.GET("/:id/timeline", commentHandler.GetEpicTimeline)
+		epics.PUT("/:id/labels", labelHandler.SetEpicLabels)
 	}
 	projects := authorized.Group("/projects")
 	{
 		projects.GET("", projectHandler.GetProjects)
 		projects.GET("/:id", projectHandler.GetProjectDetail)
+		projects.GET("/:id/tree", projectHandler.GetProjectTree)
+		projects.GET("/:id/velocity", projectHandler.GetProjectVelocity)
+		projects.GET("/:id/board", projectHandler.GetProjectBoard)
+		projects.GET("/:id/workflow", projectHandler.GetProjectWorkflow)
+		projects.PUT("/:id/workflow", projectHandler.UpdateProjectWorkflow)
+		projects.GET("/:id/labels", labelHandler.GetLabels)
+		projects.POST("/:id/labels", labelHandler.CreateLabel)
 		projects.POST("", projectHandler.CreateProject)
 		projects.PUT("/:id", projectHandler.UpdateProject)
+		projects.PATCH("/:id", projectHandler.PatchProject)
 		projects.DELETE("/:id", projectHandler.DeleteProject)
 	}
 	subscriptionMembers := authorized.Group("/subscriptionMembers")
@@ -134,13 +303,57 @@ func Router() *gin.Engine {
 		subscriptionMembers.GET("", subscriptionMemberHandler.GetSubscriptionMember)
 		subscriptionMembers.GET("/:id", subscriptionMemberHandler.GetSubscriptionMemberDetail)
 		subscriptionMembers.POST("", subscriptionMemberHandler.CreateSubscriptionMember)
+		subscriptionMembers.PUT("/:id", subscriptionMemberHandler.UpdateSubscriptionMember)
+		subscriptionMembers.POST("/:id/changePlan", subscriptionMemberHandler.ChangeSubscriptionPlan)
+		subscriptionMembers.POST("/:id/cancel", subscriptionMemberHandler.CancelSubscriptionMember)
+		subscriptionMembers.GET("/:id/histories", subscriptionMemberHandler.GetSubscriptionMemberHistories)
+	}
+	subscriptionPlans := authorized.Group("/subscriptionPlans")
+	{
+		subscriptionPlans.GET("", subscriptionPlanHandler.GetSubscriptionPlans)
+		subscriptionPlans.POST("", subscriptionPlanHandler.CreateSubscriptionPlan)
+		subscriptionPlans.PUT("/:id", subscriptionPlanHandler.UpdateSubscriptionPlan)
 	}
 	issues := authorized.Group("/issues")
 	{
 		issues.GET("", issueHandler.GetIssues)
+		issues.GET("/:id", issueHandler.GetIssueDetail)
+		issues.POST("", issueHandler.CreateIssue)
+		issues.PUT("/:id", issueHandler.UpdateIssue)
+		issues.DELETE("/:id", issueHandler.DeleteIssue)
+		issues.POST("/:id/assign", issueHandler.AssignIssue)
+		issues.POST("/:id/move", issueHandler.MoveIssue)
+		issues.GET("/:id/comments", commentHandler.GetIssueComments)
+		issues.POST("/:id/comments", commentHandler.CreateIssueComment)
+		issues.GET("/:id/timeline", commentHandler.GetIssueTimeline)
+		issues.PUT("/:id/labels", labelHandler.SetIssueLabels)
+	}
+	labels := authorized.Group("/labels")
+	{
+		labels.PUT("/:id", labelHandler.UpdateLabel)
+		labels.DELETE("/:id", labelHandler.DeleteLabel)
+	}
+	comments := authorized.Group("/comments")
+	{
+		comments.PUT("/:id", commentHandler.UpdateComment)
+		comments.DELETE("/:id", commentHandler.DeleteComment)
+	}
+	webhooks := authorized.Group("/webhooks")
+	{
+		webhooks.GET("", webhookHandler.GetWebhookSubscriptions)
+		webhooks.POST("", webhookHandler.CreateWebhookSubscription)
+		webhooks.PUT("/:id", webhookHandler.UpdateWebhookSubscription)
+		webhooks.DELETE("/:id", webhookHandler.DeleteWebhookSubscription)
+		webhooks.GET("/:id/deliveries", webhookHandler.GetWebhookDeliveries)
+	}
+	webhookDeliveries := authorized.Group("/webhookDeliveries")
+	{
+		webhookDeliveries.GET("/:id/logs", webhookHandler.GetWebhookDeliveryLogs)
+		webhookDeliveries.POST("/:id/replay", webhookHandler.ReplayWebhookDelivery)
 	}
 
-	r.GET("/qrcode", qrcodeHandler.GenerateQrcode)
+	r.GET("/qrcode", publicRateLimit, qrcodeHandler.GenerateQrcode)
+	r.POST("/payments/webhook", paymentHandler.HandleWebhook)
 
 	if err := r.Run(); err != nil {
 		panic(err)
@@ -148,3 +361,15 @@ func Router() *gin.Engine {
 
 	return r
 }
+
+// trustedProxiesFromEnv 環境変数TRUSTED_PROXIESに","区切りで指定したIPアドレス・CIDRを返す
+// 指定がなければどのプロキシも信頼せず、接続元のIPアドレスをクライアントのIPアドレスとする
+func trustedProxiesFromEnv() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedProxiesFromEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{name: "指定がない場合はどのプロキシも信頼しないこと", value: "", want: nil},
+		{name: "カンマ区切りで指定できること", value: "10.0.0.1, 192.168.0.0/16", want: []string{"10.0.0.1", "192.168.0.0/16"}},
+		{name: "空の要素は無視すること", value: "10.0.0.1,,", want: []string{"10.0.0.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.value)
+			assert.Equal(t, tt.want, trustedProxiesFromEnv())
+		})
+	}
+}
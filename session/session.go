@@ -0,0 +1,252 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// touchInterval 最終利用日時を更新する間隔。リクエストごとに書き込まないようにする
+const touchInterval = time.Minute
+
+// Session ログインごとに作成し、JWTのjtiで参照する。削除するとそのJWTでは認証できなくなる
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Device     string    `json:"device"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// CookieConfig セッションIDを保存するCookieの属性
+type CookieConfig struct {
+	Name string
+	// Domain 空の場合はリクエストしたホストのみに送られる
+	Domain   string
+	Path     string
+	Secure   bool
+	HTTPOnly bool
+	SameSite http.SameSite
+}
+
+var DefaultCookieConfig = CookieConfig{
+	Name:     "session_id",
+	Path:     "/",
+	Secure:   true,
+	HTTPOnly: true,
+	SameSite: http.SameSiteLaxMode,
+}
+
+// CookieConfigFromEnv 環境変数SESSION_COOKIE_*でCookieの属性を上書きする
+// ローカルでHTTPを使う場合はSESSION_COOKIE_SECURE=falseにする
+func CookieConfigFromEnv() (CookieConfig, error) {
+	config := DefaultCookieConfig
+	if v := os.Getenv("SESSION_COOKIE_NAME"); v != "" {
+		config.Name = v
+	}
+	config.Domain = os.Getenv("SESSION_COOKIE_DOMAIN")
+	if v := os.Getenv("SESSION_COOKIE_PATH"); v != "" {
+		config.Path = v
+	}
+	for key, dst := range map[string]*bool{
+		"SESSION_COOKIE_SECURE":    &config.Secure,
+		"SESSION_COOKIE_HTTP_ONLY": &config.HTTPOnly,
+	} {
+		if v := os.Getenv(key); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return config, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*dst = b
+		}
+	}
+	if v := os.Getenv("SESSION_COOKIE_SAME_SITE"); v != "" {
+		sameSite, err := ParseSameSite(v)
+		if err != nil {
+			return config, err
+		}
+		config.SameSite = sameSite
+	}
+	// SameSite=NoneはSecureでないとブラウザに拒否される
+	if config.SameSite == http.SameSiteNoneMode && !config.Secure {
+		return config, fmt.Errorf("SESSION_COOKIE_SAME_SITE=none requires SESSION_COOKIE_SECURE=true")
+	}
+	return config, nil
+}
+
+func ParseSameSite(v string) (http.SameSite, error) {
+	switch strings.ToLower(v) {
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return http.SameSiteDefaultMode, fmt.Errorf("invalid same site %q", v)
+	}
+}
+
+// Manager セッションの作成・検証・一覧・削除とCookieの設定を行う
+type Manager struct {
+	store  Store
+	cookie CookieConfig
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func NewManager(store Store, cookie CookieConfig, ttl time.Duration) *Manager {
+	return NewManagerWithClock(store, cookie, ttl, time.Now)
+}
+
+func NewManagerWithClock(store Store, cookie CookieConfig, ttl time.Duration, now func() time.Time) *Manager {
+	return &Manager{store: store, cookie: cookie, ttl: ttl, now: now}
+}
+
+// Start ユーザーのセッションを作成し、セッションIDをCookieに設定する
+func (m *Manager) Start(ctx *gin.Context, userID string) (*Session, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	now := m.now()
+	userAgent := ctx.Request.UserAgent()
+	sess := &Session{
+		ID:         hex.EncodeToString(b),
+		UserID:     userID,
+		Device:     DeviceFromUserAgent(userAgent),
+		IPAddress:  ctx.ClientIP(),
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(m.ttl),
+	}
+	if err := m.store.Save(ctx, sess); err != nil {
+		return nil, err
+	}
+	m.setCookie(ctx, sess.ID, int(m.ttl.Seconds()))
+	return sess, nil
+}
+
+// Validate セッションが存在し、ユーザーのものであるかを確認する。最終利用日時と接続元も更新する
+func (m *Manager) Validate(ctx *gin.Context, userID, id string) (bool, error) {
+	sess, err := m.store.Get(ctx, id)
+	if err != nil || sess == nil || sess.UserID != userID {
+		return false, err
+	}
+	now := m.now()
+	if now.Sub(sess.LastSeenAt) >= touchInterval {
+		sess.LastSeenAt = now
+		sess.IPAddress = ctx.ClientIP()
+		// 取得した後に削除されていた場合は無効とする
+		return m.store.Touch(ctx, sess)
+	}
+	return true, nil
+}
+
+// List ユーザーの有効なセッションを新しい順に返す
+func (m *Manager) List(ctx context.Context, userID string) ([]Session, error) {
+	return m.store.ListByUser(ctx, userID)
+}
+
+// Revoke ユーザーのセッションを削除する。存在しないか他のユーザーのものの場合はfalseを返す
+func (m *Manager) Revoke(ctx context.Context, userID, id string) (bool, error) {
+	sess, err := m.store.Get(ctx, id)
+	if err != nil || sess == nil || sess.UserID != userID {
+		return false, err
+	}
+	return true, m.store.Delete(ctx, userID, id)
+}
+
+// RevokeAll ユーザーのセッションをexceptIDs以外すべて削除する。パスワードの再設定など、既存のログインを無効にする場合に使う
+func (m *Manager) RevokeAll(ctx context.Context, userID string, exceptIDs ...string) error {
+	return m.store.DeleteByUser(ctx, userID, exceptIDs...)
+}
+
+// End ログアウトしたセッションを削除し、Cookieを消す
+func (m *Manager) End(ctx *gin.Context, id string) error {
+	defer m.setCookie(ctx, "", -1)
+	if id == "" {
+		return nil
+	}
+	sess, err := m.store.Get(ctx, id)
+	if err != nil || sess == nil {
+		return err
+	}
+	return m.store.Delete(ctx, sess.UserID, id)
+}
+
+// Cookie セッションIDを保存するCookieの属性。他のCookieの属性を合わせるのに使う
+func (m *Manager) Cookie() CookieConfig {
+	return m.cookie
+}
+
+// CookieSessionID Cookieに保存したセッションIDを返す
+func (m *Manager) CookieSessionID(ctx *gin.Context) string {
+	id, _ := ctx.Cookie(m.cookie.Name)
+	return id
+}
+
+func (m *Manager) setCookie(ctx *gin.Context, value string, maxAge int) {
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     m.cookie.Name,
+		Value:    value,
+		Path:     m.cookie.Path,
+		Domain:   m.cookie.Domain,
+		MaxAge:   maxAge,
+		Secure:   m.cookie.Secure,
+		HttpOnly: m.cookie.HTTPOnly,
+		SameSite: m.cookie.SameSite,
+	})
+}
+
+// DeviceFromUserAgent セッション一覧で見分けられるよう、User-Agentからブラウザ(クライアント)とOSを大まかに判定する
+func DeviceFromUserAgent(userAgent string) string {
+	client := firstMatch(userAgent, []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"Go-http-client/", "Go"},
+	})
+	platform := firstMatch(userAgent, []struct{ token, name string }{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	})
+	switch {
+	case client != "" && platform != "":
+		return client + " on " + platform
+	case client != "":
+		return client
+	case platform != "":
+		return platform
+	default:
+		return "Unknown"
+	}
+}
+
+func firstMatch(s string, candidates []struct{ token, name string }) string {
+	for _, c := range candidates {
+		if strings.Contains(s, c.token) {
+			return c.name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,252 @@
+package session_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/session"
+)
+
+const userAgentForTest = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/105.0.0.0 Safari/537.36"
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newContext() (*gin.Context, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+	ctx.Request.Header.Set("User-Agent", userAgentForTest)
+	ctx.Request.RemoteAddr = "192.0.2.1:12345"
+	return ctx, rec
+}
+
+func newManager(c *clock) *session.Manager {
+	cookie := session.DefaultCookieConfig
+	cookie.Domain = "example.com"
+	return session.NewManagerWithClock(session.NewMemoryStoreWithClock(c.Now), cookie, time.Hour, c.Now)
+}
+
+func TestManager(t *testing.T) {
+	t.Parallel()
+
+	t.Run("セッションを作成してCookieに設定すること", func(t *testing.T) {
+		t.Parallel()
+		c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+		m := newManager(c)
+		ctx, rec := newContext()
+
+		sess, err := m.Start(ctx, "user-1")
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", sess.UserID)
+		assert.Equal(t, "Chrome on macOS", sess.Device)
+		assert.Equal(t, "192.0.2.1", sess.IPAddress)
+		assert.Equal(t, c.now.Add(time.Hour), sess.ExpiresAt)
+
+		cookies := rec.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, "session_id", cookies[0].Name)
+		assert.Equal(t, sess.ID, cookies[0].Value)
+		assert.Equal(t, "example.com", cookies[0].Domain)
+		assert.Equal(t, 3600, cookies[0].MaxAge)
+		assert.True(t, cookies[0].Secure)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	})
+
+	t.Run("有効期限が過ぎたセッションは無効になること", func(t *testing.T) {
+		t.Parallel()
+		c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+		m := newManager(c)
+		ctx, _ := newContext()
+		sess, err := m.Start(ctx, "user-1")
+		require.NoError(t, err)
+
+		ok, err := m.Validate(ctx, "user-1", sess.ID)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		c.now = c.now.Add(time.Hour)
+		ok, err = m.Validate(ctx, "user-1", sess.ID)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("他のユーザーのセッションは無効であり削除できないこと", func(t *testing.T) {
+		t.Parallel()
+		c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+		m := newManager(c)
+		ctx, _ := newContext()
+		sess, err := m.Start(ctx, "user-1")
+		require.NoError(t, err)
+
+		ok, err := m.Validate(ctx, "user-2", sess.ID)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		revoked, err := m.Revoke(ctx, "user-2", sess.ID)
+		require.NoError(t, err)
+		assert.False(t, revoked)
+		ok, err = m.Validate(ctx, "user-1", sess.ID)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("一覧は新しい順に並び、削除したセッションは含まれないこと", func(t *testing.T) {
+		t.Parallel()
+		c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+		m := newManager(c)
+		ctx, _ := newContext()
+		first, err := m.Start(ctx, "user-1")
+		require.NoError(t, err)
+		c.now = c.now.Add(time.Minute)
+		second, err := m.Start(ctx, "user-1")
+		require.NoError(t, err)
+		_, err = m.Start(ctx, "user-2")
+		require.NoError(t, err)
+
+		sessions, err := m.List(ctx, "user-1")
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		assert.Equal(t, second.ID, sessions[0].ID)
+		assert.Equal(t, first.ID, sessions[1].ID)
+
+		revoked, err := m.Revoke(ctx, "user-1", first.ID)
+		require.NoError(t, err)
+		assert.True(t, revoked)
+		sessions, err = m.List(ctx, "user-1")
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, second.ID, sessions[0].ID)
+	})
+
+	t.Run("指定したセッション以外のユーザーのセッションを全て削除すること", func(t *testing.T) {
+		t.Parallel()
+		c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+		m := newManager(c)
+		ctx, _ := newContext()
+		first, err := m.Start(ctx, "user-1")
+		require.NoError(t, err)
+		second, err := m.Start(ctx, "user-1")
+		require.NoError(t, err)
+		other, err := m.Start(ctx, "user-2")
+		require.NoError(t, err)
+
+		require.NoError(t, m.RevokeAll(ctx, "user-1", second.ID))
+		ok, err := m.Validate(ctx, "user-1", first.ID)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		ok, err = m.Validate(ctx, "user-1", second.ID)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		require.NoError(t, m.RevokeAll(ctx, "user-1"))
+		sessions, err := m.List(ctx, "user-1")
+		require.NoError(t, err)
+		assert.Empty(t, sessions)
+		ok, err = m.Validate(ctx, "user-2", other.ID)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("ログアウトでセッションとCookieを消すこと", func(t *testing.T) {
+		t.Parallel()
+		c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+		m := newManager(c)
+		ctx, _ := newContext()
+		sess, err := m.Start(ctx, "user-1")
+		require.NoError(t, err)
+
+		logout, rec := newContext()
+		require.NoError(t, m.End(logout, sess.ID))
+		ok, err := m.Validate(ctx, "user-1", sess.ID)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		cookies := rec.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, "", cookies[0].Value)
+		assert.True(t, cookies[0].MaxAge < 0)
+	})
+}
+
+func TestMemoryStore_Touch(t *testing.T) {
+	t.Parallel()
+	c := &clock{now: time.Date(2022, 9, 29, 10, 0, 0, 0, time.UTC)}
+	store := session.NewMemoryStoreWithClock(c.Now)
+	ctx, _ := newContext()
+	sess := &session.Session{ID: "session-1", UserID: "user-1", ExpiresAt: c.now.Add(time.Hour)}
+	require.NoError(t, store.Save(ctx, sess))
+
+	sess.LastSeenAt = c.now.Add(time.Minute)
+	ok, err := store.Touch(ctx, sess)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	got, err := store.Get(ctx, sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sess.LastSeenAt, got.LastSeenAt)
+
+	require.NoError(t, store.Delete(ctx, "user-1", sess.ID))
+	ok, err = store.Touch(ctx, sess)
+	require.NoError(t, err)
+	assert.False(t, ok, "削除されたセッションは作成し直さないこと")
+	got, err = store.Get(ctx, sess.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestParseSameSite(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   string
+		want    http.SameSite
+		wantErr bool
+	}{
+		{value: "Lax", want: http.SameSiteLaxMode},
+		{value: "strict", want: http.SameSiteStrictMode},
+		{value: "none", want: http.SameSiteNoneMode},
+		{value: "invalid", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.value+"を解釈できること", func(t *testing.T) {
+			t.Parallel()
+			got, err := session.ParseSameSite(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDeviceFromUserAgent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{name: "macOSのChrome", userAgent: userAgentForTest, want: "Chrome on macOS"},
+		{name: "WindowsのEdge", userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/105.0.0.0 Safari/537.36 Edg/105.0.1343.42", want: "Edge on Windows"},
+		{name: "iPhoneのSafari", userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 15_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.6 Mobile/15E148 Safari/604.1", want: "Safari on iOS"},
+		{name: "curl", userAgent: "curl/7.79.1", want: "curl"},
+		{name: "不明", userAgent: "", want: "Unknown"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name+"を判定できること", func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, session.DeviceFromUserAgent(tt.userAgent))
+		})
+	}
+}
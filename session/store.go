@@ -0,0 +1,242 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+const keyPrefix = "session:"
+
+// Store セッションの保存先
+// Saveは作成時のみ、Touchは既存のセッションの更新に使い、削除されたセッションは作成し直さない
+// Getは存在しないか期限切れの場合にnilを返す。Deleteは所有者を確認しないため、呼び出し側で確認する
+type Store interface {
+	Save(ctx context.Context, s *Session) error
+	Touch(ctx context.Context, s *Session) (bool, error)
+	Get(ctx context.Context, id string) (*Session, error)
+	ListByUser(ctx context.Context, userID string) ([]Session, error)
+	Delete(ctx context.Context, userID, id string) error
+	DeleteByUser(ctx context.Context, userID string, exceptIDs ...string) error
+}
+
+// RedisStore セッションはキーの有効期限で、ユーザーごとの一覧は有効期限をスコアにしたソート済みセットで管理する
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Save(ctx context.Context, sess *Session) error {
+	ttl := time.Until(sess.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	value, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, sessionKey(sess.ID), value, ttl)
+		pipe.ZAdd(ctx, userKey(sess.UserID), redis.Z{Score: float64(sess.ExpiresAt.Unix()), Member: sess.ID})
+		// セッションの有効期間は一定のため、作成したばかりのセッションに合わせれば一覧が先に消えることはない
+		// 作成時のみ呼ばれるため、既存のセッションの更新で短くなることもない
+		pipe.Expire(ctx, userKey(sess.UserID), ttl)
+		return nil
+	})
+	return err
+}
+
+// Touch セッションのキーが残っている場合のみ上書きする。有効期限は変えない
+// 同時に削除されたセッションを作成し直さないよう、存在の確認と上書きをSET XXで同時に行う
+func (s *RedisStore) Touch(ctx context.Context, sess *Session) (bool, error) {
+	value, err := json.Marshal(sess)
+	if err != nil {
+		return false, err
+	}
+	err = s.client.SetArgs(ctx, sessionKey(sess.ID), value, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+	return err == nil, err
+}
+func (s *RedisStore) Get(ctx context.Context, id string) (*Session, error) {
+	value, err := s.client.Get(ctx, sessionKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sess Session
+	if err := json.Unmarshal(value, &sess); err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+func (s *RedisStore) ListByUser(ctx context.Context, userID string) ([]Session, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := s.client.ZRemRangeByScore(ctx, userKey(userID), "-inf", now).Err(); err != nil {
+		return nil, err
+	}
+	ids, err := s.client.ZRange(ctx, userKey(userID), 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = sessionKey(id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, 0, len(values))
+	for _, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var sess Session
+		if err := json.Unmarshal([]byte(str), &sess); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, userID, id string) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(id))
+		pipe.ZRem(ctx, userKey(userID), id)
+		return nil
+	})
+	return err
+}
+
+// deleteByUserScript ユーザーのセッションを一覧ごと削除する。一覧の取得と削除の間に作成されたセッションを残さないよう、スクリプトで行う
+// KEYS[1]: ユーザーごとの一覧, ARGV[1]: セッションのキーの接頭辞, ARGV[2:]: 残すセッションID
+var deleteByUserScript = redis.NewScript(`
+local keep = {}
+for i = 2, #ARGV do
+  keep[ARGV[i]] = true
+end
+local ids = redis.call("ZRANGE", KEYS[1], 0, -1)
+for _, id in ipairs(ids) do
+  if not keep[id] then
+    redis.call("DEL", ARGV[1] .. id)
+    redis.call("ZREM", KEYS[1], id)
+  end
+end
+return 0
+`)
+
+func (s *RedisStore) DeleteByUser(ctx context.Context, userID string, exceptIDs ...string) error {
+	args := []interface{}{keyPrefix}
+	for _, id := range exceptIDs {
+		args = append(args, id)
+	}
+	return deleteByUserScript.Run(ctx, s.client, []string{userKey(userID)}, args...).Err()
+}
+
+// MemoryStore テストやローカル実行用のインメモリの保存先
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+	now      func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithClock(time.Now)
+}
+
+func NewMemoryStoreWithClock(now func() time.Time) *MemoryStore {
+	return &MemoryStore{sessions: map[string]Session{}, now: now}
+}
+
+func (s *MemoryStore) Save(_ context.Context, sess *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sess.ID] = *sess
+	return nil
+}
+
+func (s *MemoryStore) Touch(_ context.Context, sess *Session) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[sess.ID]; !ok {
+		return false, nil
+	}
+	s.sessions[sess.ID] = *sess
+	return true, nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok || !s.now().Before(sess.ExpiresAt) {
+		return nil, nil
+	}
+	return &sess, nil
+}
+
+func (s *MemoryStore) ListByUser(_ context.Context, userID string) ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sessions []Session
+	for _, sess := range s.sessions {
+		if sess.UserID == userID && s.now().Before(sess.ExpiresAt) {
+			sessions = append(sessions, sess)
+		}
+	}
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, _, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *MemoryStore) DeleteByUser(_ context.Context, userID string, exceptIDs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keep := map[string]bool{}
+	for _, id := range exceptIDs {
+		keep[id] = true
+	}
+	for id, sess := range s.sessions {
+		if sess.UserID == userID && !keep[id] {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+// sortSessions 新しいセッションから順に並べる
+func sortSessions(sessions []Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+}
+
+func sessionKey(id string) string {
+	return keyPrefix + id
+}
+
+func userKey(userID string) string {
+	return keyPrefix + "user:" + userID
+}
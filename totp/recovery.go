@@ -0,0 +1,43 @@
+package totp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// RecoveryCodeCount 一度に発行するリカバリーコードの数
+const RecoveryCodeCount = 10
+
+// recoveryAlphabet 読み間違えやすい0/O、1/I/Lを除いた文字
+const recoveryAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// GenerateRecoveryCodes 認証アプリを使えない場合に一度だけ使えるコードを発行する
+// 形式は "XXXXX-XXXXX"
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		for j, c := range b {
+			if j == 5 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(recoveryAlphabet[int(c)%len(recoveryAlphabet)])
+		}
+		codes = append(codes, sb.String())
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode 保存用のハッシュを返す。入力時の大文字小文字やハイフンの有無は区別しない
+// コードは十分な長さの乱数のため、パスワードと違い低速なハッシュは使わない
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,100 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RFC 6238の既定値。Google Authenticatorなど主要な認証アプリはこの値にしか対応していない
+const (
+	Digits     = 6
+	Period     = 30 * time.Second
+	SecretSize = 20
+	// Skew 端末の時計のずれを考慮し、前後何ステップまでのコードを受け付けるか
+	Skew = 1
+)
+
+var (
+	ErrInvalidSecret = errors.New("invalid totp secret")
+
+	encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// GenerateSecret 認証アプリに登録する秘密鍵をBase32で返す
+func GenerateSecret() (string, error) {
+	b := make([]byte, SecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// ProvisioningURI 認証アプリがQRコードから読み取るotpauth URIを返す
+func ProvisioningURI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(Digits))
+	v.Set("period", fmt.Sprint(int(Period.Seconds())))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+// Step 時刻に対応するステップ番号
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Code ステップ番号に対応するコードを返す
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(key) == 0 {
+		return "", ErrInvalidSecret
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%1000000), nil
+}
+
+// Validate 時刻tの前後Skewステップの範囲でコードを検証し、一致したステップ番号を返す
+// lastStep以前のステップは受け付けず、一度使ったコードを再利用できないようにする
+func Validate(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+	current := Step(t)
+	for step := current - Skew; step <= current+Skew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
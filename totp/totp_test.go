@@ -0,0 +1,118 @@
+package totp_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/totp"
+)
+
+// rfcSecret RFC 6238のテストベクタの秘密鍵 "12345678901234567890" をBase32にしたもの
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		unix int64
+		want string
+	}{
+		{name: "RFC 6238のテストベクタと一致すること(59)", unix: 59, want: "287082"},
+		{name: "RFC 6238のテストベクタと一致すること(1111111109)", unix: 1111111109, want: "081804"},
+		{name: "RFC 6238のテストベクタと一致すること(1234567890)", unix: 1234567890, want: "005924"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := totp.Code(rfcSecret, totp.Step(time.Unix(tt.unix, 0)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("不正な秘密鍵はエラーになること", func(t *testing.T) {
+		t.Parallel()
+		_, err := totp.Code("!!", 1)
+		assert.ErrorIs(t, err, totp.ErrInvalidSecret)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1234567890, 0)
+	current := totp.Step(now)
+	code := func(step int64) string {
+		c, err := totp.Code(rfcSecret, step)
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("現在のコードを受け付けること", func(t *testing.T) {
+		step, ok := totp.Validate(rfcSecret, code(current), now, 0)
+		assert.True(t, ok)
+		assert.Equal(t, current, step)
+	})
+	t.Run("前後1ステップのコードを受け付けること", func(t *testing.T) {
+		_, ok := totp.Validate(rfcSecret, code(current-1), now, 0)
+		assert.True(t, ok)
+		_, ok = totp.Validate(rfcSecret, code(current+1), now, 0)
+		assert.True(t, ok)
+	})
+	t.Run("2ステップ以上ずれたコードは受け付けないこと", func(t *testing.T) {
+		_, ok := totp.Validate(rfcSecret, code(current-2), now, 0)
+		assert.False(t, ok)
+	})
+	t.Run("使用済みのステップのコードは受け付けないこと", func(t *testing.T) {
+		_, ok := totp.Validate(rfcSecret, code(current), now, current)
+		assert.False(t, ok)
+	})
+	t.Run("桁数が違うコードは受け付けないこと", func(t *testing.T) {
+		_, ok := totp.Validate(rfcSecret, "12345", now, 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestGenerateSecret(t *testing.T) {
+	t.Parallel()
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+	_, err = totp.Code(secret, 1)
+	assert.NoError(t, err)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	t.Parallel()
+
+	got := totp.ProvisioningURI("golang-admin-api", "a@example.com", rfcSecret)
+	assert.True(t, strings.HasPrefix(got, "otpauth://totp/golang-admin-api:a@example.com?"))
+	assert.Contains(t, got, "secret="+rfcSecret)
+	assert.Contains(t, got, "issuer=golang-admin-api")
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	codes, err := totp.GenerateRecoveryCodes(totp.RecoveryCodeCount)
+	require.NoError(t, err)
+	require.Len(t, codes, totp.RecoveryCodeCount)
+	seen := map[string]bool{}
+	for _, c := range codes {
+		assert.Regexp(t, `^[2-9A-HJKMNP-Z]{5}-[2-9A-HJKMNP-Z]{5}$`, c)
+		assert.False(t, seen[c])
+		seen[c] = true
+	}
+
+	t.Run("大文字小文字やハイフンの有無に関わらず同じハッシュになること", func(t *testing.T) {
+		assert.Equal(t, totp.HashRecoveryCode("ABCDE-FGHJK"), totp.HashRecoveryCode(" abcdefghjk "))
+		assert.NotEqual(t, totp.HashRecoveryCode("ABCDE-FGHJK"), totp.HashRecoveryCode("ABCDE-FGHJM"))
+	})
+}
@@ -0,0 +1,32 @@
+package appcontext
+
+import "github.com/gin-gonic/gin"
+
+const (
+	apiKeyIDKey     = "api-key-id"
+	apiKeyScopesKey = "api-key-scopes"
+)
+
+// SetAPIKeyIntoContext 認証に使ったAPIキーのIDとスコープを設定する
+func SetAPIKeyIntoContext(c *gin.Context, apiKeyID string, scopes []string) {
+	c.Set(apiKeyIDKey, apiKeyID)
+	c.Set(apiKeyScopesKey, scopes)
+}
+
+// GetAPIKeyID 認証に使ったAPIキーのIDを返す。JWTで認証した場合は空文字を返す
+func GetAPIKeyID(c *gin.Context) string {
+	apiKeyID, exists := c.Get(apiKeyIDKey)
+	if !exists {
+		return ""
+	}
+	return apiKeyID.(string)
+}
+
+// GetAPIKeyScopes 認証に使ったAPIキーのスコープを返す。JWTで認証した場合はnilを返す
+func GetAPIKeyScopes(c *gin.Context) []string {
+	scopes, exists := c.Get(apiKeyScopesKey)
+	if !exists {
+		return nil
+	}
+	return scopes.([]string)
+}
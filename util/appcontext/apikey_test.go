@@ -0,0 +1,34 @@
+package appcontext_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+)
+
+func TestGetAPIKey(t *testing.T) {
+	t.Parallel()
+	t.Run("ContextにAPIキーがある場合に取得できること", func(t *testing.T) {
+		t.Parallel()
+		con := newContext()
+		appcontext.SetAPIKeyIntoContext(con, "api-key-id", []string{"read"})
+		if got := appcontext.GetAPIKeyID(con); got != "api-key-id" {
+			t.Errorf("want= %v, got = %v", "api-key-id", got)
+		}
+		if got := appcontext.GetAPIKeyScopes(con); !reflect.DeepEqual(got, []string{"read"}) {
+			t.Errorf("want= %v, got = %v", []string{"read"}, got)
+		}
+	})
+
+	t.Run("ContextにAPIキーがない場合に空の値が取得できること", func(t *testing.T) {
+		t.Parallel()
+		con := newContext()
+		if got := appcontext.GetAPIKeyID(con); got != "" {
+			t.Errorf("want= \"\", got = %v", got)
+		}
+		if got := appcontext.GetAPIKeyScopes(con); got != nil {
+			t.Errorf("want= nil, got = %v", got)
+		}
+	})
+}
@@ -0,0 +1,19 @@
+package appcontext
+
+import "github.com/gin-gonic/gin"
+
+const organizationIDKey = "api-organization-id"
+
+// SetOrganizationIDIntoContext リクエストの対象となる組織IDを設定する
+func SetOrganizationIDIntoContext(c *gin.Context, organizationID string) {
+	c.Set(organizationIDKey, organizationID)
+}
+
+// GetOrganizationID リクエストの対象となる組織IDを返す。認証されていない場合は空文字を返す
+func GetOrganizationID(c *gin.Context) string {
+	organizationID, exists := c.Get(organizationIDKey)
+	if !exists {
+		return ""
+	}
+	return organizationID.(string)
+}
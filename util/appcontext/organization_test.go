@@ -0,0 +1,26 @@
+package appcontext_test
+
+import (
+	"testing"
+
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+)
+
+func TestGetOrganizationID(t *testing.T) {
+	t.Parallel()
+	t.Run("Contextに組織IDがある場合に取得できること", func(t *testing.T) {
+		t.Parallel()
+		con := newContext()
+		appcontext.SetOrganizationIDIntoContext(con, "organization-id")
+		if got := appcontext.GetOrganizationID(con); got != "organization-id" {
+			t.Errorf("want= %v, got = %v", "organization-id", got)
+		}
+	})
+
+	t.Run("Contextに組織IDがない場合に空文字が取得できること", func(t *testing.T) {
+		t.Parallel()
+		if got := appcontext.GetOrganizationID(newContext()); got != "" {
+			t.Errorf("want= \"\", got = %v", got)
+		}
+	})
+}
@@ -0,0 +1,19 @@
+package appcontext
+
+import "github.com/gin-gonic/gin"
+
+const sessionIDKey = "session-id"
+
+// SetSessionIDIntoContext 認証に使ったJWTのセッションIDを設定する
+func SetSessionIDIntoContext(c *gin.Context, sessionID string) {
+	c.Set(sessionIDKey, sessionID)
+}
+
+// GetSessionID 認証に使ったJWTのセッションIDを返す。APIキーで認証した場合は空文字を返す
+func GetSessionID(c *gin.Context) string {
+	sessionID, exists := c.Get(sessionIDKey)
+	if !exists {
+		return ""
+	}
+	return sessionID.(string)
+}
@@ -0,0 +1,27 @@
+package appcontext_test
+
+import (
+	"testing"
+
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+)
+
+func TestGetSessionID(t *testing.T) {
+	t.Parallel()
+	t.Run("ContextにセッションIDがある場合に取得できること", func(t *testing.T) {
+		t.Parallel()
+		con := newContext()
+		appcontext.SetSessionIDIntoContext(con, "session-id")
+		if got := appcontext.GetSessionID(con); got != "session-id" {
+			t.Errorf("want= %v, got = %v", "session-id", got)
+		}
+	})
+
+	t.Run("ContextにセッションIDがない場合に空文字が取得できること", func(t *testing.T) {
+		t.Parallel()
+		con := newContext()
+		if got := appcontext.GetSessionID(con); got != "" {
+			t.Errorf("want= \"\", got = %v", got)
+		}
+	})
+}
@@ -0,0 +1,19 @@
+package appcontext
+
+import "github.com/gin-gonic/gin"
+
+const userIDKey = "api-user-id"
+
+// SetUserIDIntoContext 認証済みのユーザIDを設定する
+func SetUserIDIntoContext(c *gin.Context, userID string) {
+	c.Set(userIDKey, userID)
+}
+
+// GetUserID 認証済みのユーザIDを返す。認証されていない場合は空文字を返す
+func GetUserID(c *gin.Context) string {
+	userID, exists := c.Get(userIDKey)
+	if !exists {
+		return ""
+	}
+	return userID.(string)
+}
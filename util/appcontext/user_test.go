@@ -0,0 +1,26 @@
+package appcontext_test
+
+import (
+	"testing"
+
+	"github.com/AI1411/golang-admin-api/util/appcontext"
+)
+
+func TestGetUserID(t *testing.T) {
+	t.Parallel()
+	t.Run("ContextにユーザIDがある場合に取得できること", func(t *testing.T) {
+		t.Parallel()
+		con := newContext()
+		appcontext.SetUserIDIntoContext(con, "user-id")
+		if got := appcontext.GetUserID(con); got != "user-id" {
+			t.Errorf("want= %v, got = %v", "user-id", got)
+		}
+	})
+
+	t.Run("ContextにユーザIDがない場合に空文字が取得できること", func(t *testing.T) {
+		t.Parallel()
+		if got := appcontext.GetUserID(newContext()); got != "" {
+			t.Errorf("want= \"\", got = %v", got)
+		}
+	})
+}
@@ -62,6 +62,46 @@ func NewNotFoundError(message string) RestErr {
 	}
 }
 
+func NewUnauthorizedError(message string) RestErr {
+	return restErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusUnauthorized,
+		ErrError:   "unauthorized",
+	}
+}
+
+func NewForbiddenError(message string) RestErr {
+	return restErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusForbidden,
+		ErrError:   "forbidden",
+	}
+}
+
+func NewConflictError(message string) RestErr {
+	return restErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusConflict,
+		ErrError:   "conflict",
+	}
+}
+
+func NewPreconditionFailedError(message string) RestErr {
+	return restErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusPreconditionFailed,
+		ErrError:   "precondition_failed",
+	}
+}
+
+func NewTooManyRequestsError(message string) RestErr {
+	return restErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusTooManyRequests,
+		ErrError:   "too_many_requests",
+	}
+}
+
 func NewInternalServerError(message string, err error) RestErr {
 	result := restErr{
 		ErrMessage: message,
@@ -8,27 +8,49 @@ import (
 
 const SecretKey = "secret"
 
-func GenerateJwt(issuer string) (string, error) {
-	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(time.Hour * 24).Unix(),
-		Issuer:    issuer,
+// TokenLifetime JWTの有効期限。セッションも同じ期間だけ保持する
+const TokenLifetime = 24 * time.Hour
+
+// Claims ユーザIDはIssuerに、ログインした組織はOrganizationIDに、セッションIDはId(jti)に入れる
+type Claims struct {
+	jwt.StandardClaims
+	OrganizationID string `json:"organization_id,omitempty"`
+}
+
+func GenerateJwt(issuer, organizationID, sessionID string) (string, error) {
+	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(TokenLifetime).Unix(),
+			Issuer:    issuer,
+			Id:        sessionID,
+		},
+		OrganizationID: organizationID,
 	})
 
 	return claims.SignedString([]byte(SecretKey))
 }
 
 func ParseJwt(cookie string) (string, error) {
+	claims, err := ParseJwtClaims(cookie)
+	if err != nil || claims == nil {
+		return "", err
+	}
+
+	return claims.Issuer, nil
+}
+
+// ParseJwtClaims 検証に失敗した場合はnilを返す
+// 組織の導入前に発行されたトークンはOrganizationIDが、セッションの導入前に発行されたトークンはIdが空になる
+func ParseJwtClaims(cookie string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(cookie,
-		&jwt.StandardClaims{},
+		&Claims{},
 		func(token *jwt.Token) (interface{}, error) {
 			return []byte(SecretKey), nil
 		})
 
 	if err != nil || !token.Valid {
-		return "", nil
+		return nil, nil
 	}
 
-	claims := token.Claims.(*jwt.StandardClaims)
-
-	return claims.Issuer, err
+	return token.Claims.(*Claims), nil
 }
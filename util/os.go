@@ -21,3 +21,11 @@ func CheckDir(fileDir string) error {
 	}
 	return nil
 }
+
+// GetEnv 環境変数が未設定の場合はdefaultValueを返す
+func GetEnv(key, defaultValue string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return defaultValue
+}
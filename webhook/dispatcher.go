@@ -0,0 +1,240 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+const (
+	DefaultMaxAttempts = 8
+	// ClaimTTL 配信処理が取得した配信を他の配信処理から取得されないようにする期間。1回の送信のタイムアウトより十分長くする
+	ClaimTTL        = 5 * time.Minute
+	baseBackoff     = 30 * time.Second
+	maxBackoff      = 6 * time.Hour
+	maxResponseBody = 1024
+	maxErrorLength  = 255
+)
+
+// Attempt 1回分の送信結果
+type Attempt struct {
+	StatusCode   int
+	ResponseBody string
+	Err          error
+	Duration     time.Duration
+}
+
+// Succeeded 2xxが返ってきたかどうか
+func (a *Attempt) Succeeded() bool {
+	return a.Err == nil && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// Backoff 試行回数に応じた次回試行までの待ち時間(指数バックオフ)
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	d := baseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+// Send 署名付きでペイロードを送信する
+func Send(ctx context.Context, client *http.Client, subscription *models.WebhookSubscription,
+	delivery *models.WebhookDelivery, now time.Time,
+) *Attempt {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return &Attempt{Err: err}
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, body))
+
+	start := time.Now()
+	res, err := client.Do(req)
+	attempt := &Attempt{Duration: time.Since(start)}
+	if err != nil {
+		attempt.Err = err
+		return attempt
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(res.Body, maxResponseBody))
+	attempt.StatusCode = res.StatusCode
+	attempt.ResponseBody = string(b)
+	return attempt
+}
+
+// Dispatcher 配信キューから送信期限を迎えたWebhookを送信する
+type Dispatcher struct {
+	db          *gorm.DB
+	client      *http.Client
+	logger      *zap.Logger
+	MaxAttempts int
+}
+
+func NewDispatcher(db *gorm.DB, client *http.Client, logger *zap.Logger) *Dispatcher {
+	return &Dispatcher{
+		db:          db,
+		client:      client,
+		logger:      logger,
+		MaxAttempts: DefaultMaxAttempts,
+	}
+}
+
+// DeliverDue 送信期限を迎えた配信をlimit件まで送信し、処理した件数を返す
+// 取得した配信はClaimTTLの間は他の配信処理から取得されないため、複数の配信処理を動かしても同じ配信を重複して送信しない
+// 1件の配信の保存に失敗しても、他の購読の配信を止めないようログに残して続ける
+func (d *Dispatcher) DeliverDue(ctx context.Context, limit int) (int, error) {
+	token, err := uuid.NewRandom()
+	if err != nil {
+		return 0, err
+	}
+	deliveries, err := d.claim(token.String(), limit)
+	if err != nil {
+		return 0, err
+	}
+	defer d.release(token.String())
+
+	count := 0
+	for i := range deliveries {
+		if err := d.Deliver(ctx, &deliveries[i]); err != nil {
+			d.logger.Error("failed to deliver webhook",
+				zap.String("delivery_id", deliveries[i].ID),
+				zap.Error(err))
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// claim 送信期限を迎え、他の配信処理が取得していない配信を送信期限の順にlimit件まで取得する
+// 取得は条件付きの更新で行うため、同じ配信を複数の配信処理が取得することはない
+func (d *Dispatcher) claim(token string, limit int) ([]models.WebhookDelivery, error) {
+	now := time.Now()
+	if err := d.db.Exec("UPDATE webhook_deliveries SET claim_token = ?, claimed_until = ?"+
+		" WHERE delivery_status = ? AND next_attempt_at <= ? AND (claimed_until IS NULL OR claimed_until < ?)"+
+		" ORDER BY next_attempt_at LIMIT ?", token, now.Add(ClaimTTL),
+		models.WebhookDeliveryStatusPending, now, now, limit).Error; err != nil {
+		return nil, err
+	}
+	var deliveries []models.WebhookDelivery
+	if err := d.db.Where("claim_token = ?", token).
+		Order("next_attempt_at").
+		Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// release 送信しなかった配信の取得を解除する。失敗しても取得の有効期限が過ぎれば他の配信処理が取得できる
+func (d *Dispatcher) release(token string) {
+	if err := d.db.Table("webhook_deliveries").Where("claim_token = ?", token).
+		Updates(map[string]interface{}{"claim_token": nil, "claimed_until": nil}).Error; err != nil {
+		d.logger.Warn("failed to release webhook deliveries", zap.Error(err))
+	}
+}
+
+// Deliver 1件の配信を試行し、結果をログと配信ステータスに反映する。保存時に配信の取得も解除する
+// DeliverDueを経由せずに呼ぶ場合は、作成時に取得しておき他の配信処理と重複して送信しないようにする
+func (d *Dispatcher) Deliver(ctx context.Context, delivery *models.WebhookDelivery) error {
+	var subscription models.WebhookSubscription
+	if err := d.db.Where("id = ?", delivery.SubscriptionID).First(&subscription).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			// 購読が削除された配信は送信先がないため、再試行せずに失敗とする
+			return d.giveUp(delivery, "webhook subscription not found")
+		}
+		return err
+	}
+
+	now := time.Now()
+	attempt := Send(ctx, d.client, &subscription, delivery, now)
+	delivery.Attempts++
+	delivery.UpdatedAt = now
+	delivery.ClaimToken = nil
+	delivery.ClaimedUntil = nil
+	log := models.WebhookDeliveryLog{
+		DeliveryID:   delivery.ID,
+		Attempt:      delivery.Attempts,
+		StatusCode:   attempt.StatusCode,
+		ResponseBody: attempt.ResponseBody,
+		DurationMs:   attempt.Duration.Milliseconds(),
+		CreatedAt:    now,
+	}
+
+	switch {
+	case attempt.Succeeded():
+		delivery.DeliveryStatus = models.WebhookDeliveryStatusSucceeded
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.LastError = ""
+	default:
+		delivery.LastError = truncate(attemptError(attempt), maxErrorLength)
+		log.Error = delivery.LastError
+		if delivery.Attempts >= d.MaxAttempts || !subscription.IsActive {
+			delivery.DeliveryStatus = models.WebhookDeliveryStatusFailed
+			delivery.NextAttemptAt = nil
+		} else {
+			next := now.Add(Backoff(delivery.Attempts))
+			delivery.NextAttemptAt = &next
+		}
+		d.logger.Warn("failed to deliver webhook",
+			zap.String("delivery_id", delivery.ID),
+			zap.Int("attempts", delivery.Attempts),
+			zap.String("error", delivery.LastError))
+	}
+
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&log).Error; err != nil {
+			return err
+		}
+		return tx.Save(delivery).Error
+	})
+}
+
+// giveUp 送信せずに配信を失敗とし、取得を解除する
+func (d *Dispatcher) giveUp(delivery *models.WebhookDelivery, reason string) error {
+	d.logger.Warn("gave up webhook delivery",
+		zap.String("delivery_id", delivery.ID),
+		zap.String("reason", reason))
+	delivery.DeliveryStatus = models.WebhookDeliveryStatusFailed
+	delivery.NextAttemptAt = nil
+	delivery.LastError = reason
+	delivery.ClaimToken = nil
+	delivery.ClaimedUntil = nil
+	delivery.UpdatedAt = time.Now()
+	return d.db.Save(delivery).Error
+}
+
+func attemptError(attempt *Attempt) string {
+	if attempt.Err != nil {
+		return attempt.Err.Error()
+	}
+	return "unexpected status code: " + strconv.Itoa(attempt.StatusCode)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,258 @@
+package webhook_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/AI1411/golang-admin-api/db"
+	"github.com/AI1411/golang-admin-api/logger"
+	"github.com/AI1411/golang-admin-api/models"
+	"github.com/AI1411/golang-admin-api/webhook"
+)
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+
+	const secret = "whsec_test"
+	var gotBody []byte
+	var gotHeader http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeader = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	subscription := &models.WebhookSubscription{URL: server.URL, Secret: secret, IsActive: true}
+	delivery := &models.WebhookDelivery{
+		ID:        "delivery-1",
+		EventType: webhook.EventOrderCreated,
+		Payload:   `{"id":"event-1","type":"order.created","data":{}}`,
+	}
+
+	attempt := webhook.Send(context.Background(), server.Client(), subscription, delivery, time.Now())
+	require.NoError(t, attempt.Err)
+	assert.True(t, attempt.Succeeded())
+	assert.Equal(t, "ok", attempt.ResponseBody)
+	assert.Equal(t, delivery.Payload, string(gotBody))
+	assert.Equal(t, webhook.EventOrderCreated, gotHeader.Get(webhook.HeaderEvent))
+	assert.Equal(t, "delivery-1", gotHeader.Get(webhook.HeaderDelivery))
+
+	timestamp, err := strconv.ParseInt(gotHeader.Get(webhook.HeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	assert.True(t, webhook.Verify(secret, timestamp, gotBody, gotHeader.Get(webhook.HeaderSignature)))
+	assert.False(t, webhook.Verify("other", timestamp, gotBody, gotHeader.Get(webhook.HeaderSignature)))
+}
+
+func TestSendFailure(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	subscription := &models.WebhookSubscription{URL: server.URL, Secret: "secret"}
+	attempt := webhook.Send(context.Background(), server.Client(), subscription,
+		&models.WebhookDelivery{Payload: "{}"}, time.Now())
+	assert.NoError(t, attempt.Err)
+	assert.Equal(t, http.StatusInternalServerError, attempt.StatusCode)
+	assert.False(t, attempt.Succeeded())
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: 0},
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 3, want: 2 * time.Minute},
+		{attempts: 8, want: 64 * time.Minute},
+		{attempts: 20, want: 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(strconv.Itoa(tt.attempts), func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, webhook.Backoff(tt.attempts))
+		})
+	}
+}
+
+func TestSubscribes(t *testing.T) {
+	t.Parallel()
+
+	subscription := models.WebhookSubscription{EventTypes: "order.created, coupon.acquired"}
+	assert.True(t, subscription.Subscribes(webhook.EventOrderCreated))
+	assert.True(t, subscription.Subscribes(webhook.EventCouponAcquired))
+	assert.False(t, subscription.Subscribes(webhook.EventUserRegistered))
+}
+
+const (
+	subscriptionIDForTest = "7dc41179-824e-4b8a-b894-2082ca5eac5b"
+	deliveryIDForTest     = "218c51c0-904e-4743-a2ae-94f0e34a0d6f"
+)
+
+func setupDispatcher(t *testing.T, status int) (*gorm.DB, *webhook.Dispatcher, *int32) {
+	t.Helper()
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	dbConn := db.Init()
+	require.NoError(t, dbConn.Exec("TRUNCATE TABLE webhook_subscriptions").Error)
+	require.NoError(t, dbConn.Exec("TRUNCATE TABLE webhook_deliveries").Error)
+	require.NoError(t, dbConn.Exec("TRUNCATE TABLE webhook_delivery_logs").Error)
+	require.NoError(t, dbConn.Exec("INSERT INTO webhook_subscriptions (id, url, event_types, secret, is_active) VALUES (?, ?, 'order.created', 'secret', true)",
+		subscriptionIDForTest, server.URL).Error)
+	zapLogger, err := logger.NewLoggerForTest(true)
+	require.NoError(t, err)
+	return dbConn, webhook.NewDispatcher(dbConn, server.Client(), zapLogger), &received
+}
+
+func insertDelivery(t *testing.T, dbConn *gorm.DB, id string, attempts int, nextAttemptAt time.Time) {
+	t.Helper()
+	require.NoError(t, dbConn.Exec("INSERT INTO webhook_deliveries (id, subscription_id, event_id, event_type, payload, delivery_status, attempts, next_attempt_at)"+
+		" VALUES (?, ?, 'event', 'order.created', '{}', 'pending', ?, ?)", id, subscriptionIDForTest, attempts, nextAttemptAt).Error)
+}
+
+func findDelivery(t *testing.T, dbConn *gorm.DB, id string) models.WebhookDelivery {
+	t.Helper()
+	var delivery models.WebhookDelivery
+	require.NoError(t, dbConn.Where("id = ?", id).First(&delivery).Error)
+	return delivery
+}
+
+func TestDeliverDue(t *testing.T) {
+	t.Run("送信に成功した場合は配信済みにし、ログを記録すること", func(t *testing.T) {
+		dbConn, dispatcher, received := setupDispatcher(t, http.StatusOK)
+		insertDelivery(t, dbConn, deliveryIDForTest, 0, time.Now().Add(-time.Minute))
+
+		count, err := dispatcher.DeliverDue(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.EqualValues(t, 1, atomic.LoadInt32(received))
+		delivery := findDelivery(t, dbConn, deliveryIDForTest)
+		assert.Equal(t, models.WebhookDeliveryStatusSucceeded, delivery.DeliveryStatus)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.NotNil(t, delivery.DeliveredAt)
+		assert.Nil(t, delivery.ClaimToken)
+		var logs []models.WebhookDeliveryLog
+		require.NoError(t, dbConn.Where("delivery_id = ?", deliveryIDForTest).Find(&logs).Error)
+		require.Len(t, logs, 1)
+		assert.Equal(t, http.StatusOK, logs[0].StatusCode)
+	})
+
+	t.Run("送信期限前の配信と、他の配信処理が取得中の配信は送信しないこと", func(t *testing.T) {
+		dbConn, dispatcher, received := setupDispatcher(t, http.StatusOK)
+		insertDelivery(t, dbConn, "not-due", 0, time.Now().Add(time.Minute))
+		insertDelivery(t, dbConn, "claimed", 0, time.Now().Add(-time.Minute))
+		insertDelivery(t, dbConn, "expired", 0, time.Now().Add(-time.Minute))
+		require.NoError(t, dbConn.Exec("UPDATE webhook_deliveries SET claim_token = 'other', claimed_until = ? WHERE id = 'claimed'",
+			time.Now().Add(time.Minute)).Error)
+		require.NoError(t, dbConn.Exec("UPDATE webhook_deliveries SET claim_token = 'other', claimed_until = ? WHERE id = 'expired'",
+			time.Now().Add(-time.Minute)).Error)
+
+		count, err := dispatcher.DeliverDue(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.EqualValues(t, 1, atomic.LoadInt32(received))
+		assert.Equal(t, models.WebhookDeliveryStatusPending, findDelivery(t, dbConn, "not-due").DeliveryStatus)
+		claimed := findDelivery(t, dbConn, "claimed")
+		assert.Equal(t, models.WebhookDeliveryStatusPending, claimed.DeliveryStatus)
+		require.NotNil(t, claimed.ClaimToken)
+		assert.Equal(t, "other", *claimed.ClaimToken)
+		assert.Equal(t, models.WebhookDeliveryStatusSucceeded, findDelivery(t, dbConn, "expired").DeliveryStatus)
+	})
+
+	t.Run("送信に失敗した場合は試行回数に応じて待ってから再試行すること", func(t *testing.T) {
+		dbConn, dispatcher, received := setupDispatcher(t, http.StatusInternalServerError)
+		insertDelivery(t, dbConn, deliveryIDForTest, 0, time.Now().Add(-time.Minute))
+
+		start := time.Now()
+		count, err := dispatcher.DeliverDue(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		delivery := findDelivery(t, dbConn, deliveryIDForTest)
+		assert.Equal(t, models.WebhookDeliveryStatusPending, delivery.DeliveryStatus)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.Equal(t, "unexpected status code: 500", delivery.LastError)
+		assert.Nil(t, delivery.ClaimToken)
+		require.NotNil(t, delivery.NextAttemptAt)
+		assert.WithinDuration(t, start.Add(webhook.Backoff(1)), *delivery.NextAttemptAt, 2*time.Second)
+
+		// 次回の試行日時までは再試行しない
+		count, err = dispatcher.DeliverDue(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		require.NoError(t, dbConn.Exec("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?",
+			time.Now().Add(-time.Second), deliveryIDForTest).Error)
+		start = time.Now()
+		count, err = dispatcher.DeliverDue(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.EqualValues(t, 2, atomic.LoadInt32(received))
+		delivery = findDelivery(t, dbConn, deliveryIDForTest)
+		assert.Equal(t, 2, delivery.Attempts)
+		require.NotNil(t, delivery.NextAttemptAt)
+		assert.WithinDuration(t, start.Add(webhook.Backoff(2)), *delivery.NextAttemptAt, 2*time.Second)
+	})
+
+	t.Run("購読が削除された配信は失敗とし、他の配信の送信を続けること", func(t *testing.T) {
+		dbConn, dispatcher, received := setupDispatcher(t, http.StatusOK)
+		insertDelivery(t, dbConn, "orphaned", 0, time.Now().Add(-2*time.Minute))
+		insertDelivery(t, dbConn, deliveryIDForTest, 0, time.Now().Add(-time.Minute))
+		require.NoError(t, dbConn.Exec("UPDATE webhook_deliveries SET subscription_id = 'deleted' WHERE id = 'orphaned'").Error)
+
+		count, err := dispatcher.DeliverDue(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.EqualValues(t, 1, atomic.LoadInt32(received))
+		orphaned := findDelivery(t, dbConn, "orphaned")
+		assert.Equal(t, models.WebhookDeliveryStatusFailed, orphaned.DeliveryStatus)
+		assert.Equal(t, "webhook subscription not found", orphaned.LastError)
+		assert.Nil(t, orphaned.NextAttemptAt)
+		assert.Nil(t, orphaned.ClaimToken)
+		assert.Equal(t, models.WebhookDeliveryStatusSucceeded, findDelivery(t, dbConn, deliveryIDForTest).DeliveryStatus)
+
+		count, err = dispatcher.DeliverDue(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("最大試行回数に達した場合は再試行をやめること", func(t *testing.T) {
+		dbConn, dispatcher, _ := setupDispatcher(t, http.StatusInternalServerError)
+		insertDelivery(t, dbConn, deliveryIDForTest, dispatcher.MaxAttempts-1, time.Now().Add(-time.Minute))
+
+		count, err := dispatcher.DeliverDue(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		delivery := findDelivery(t, dbConn, deliveryIDForTest)
+		assert.Equal(t, models.WebhookDeliveryStatusFailed, delivery.DeliveryStatus)
+		assert.Equal(t, dispatcher.MaxAttempts, delivery.Attempts)
+		assert.Nil(t, delivery.NextAttemptAt)
+
+		count, err = dispatcher.DeliverDue(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
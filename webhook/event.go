@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+
+	"github.com/AI1411/golang-admin-api/models"
+)
+
+const (
+	EventOrderCreated       = "order.created"
+	EventOrderStatusChanged = "order.status_changed"
+	EventUserRegistered     = "user.registered"
+	EventCouponAcquired     = "coupon.acquired"
+	EventIssueUpdated       = "issue.updated"
+)
+
+// EventTypes 購読可能なイベント種別
+var EventTypes = []string{
+	EventOrderCreated,
+	EventOrderStatusChanged,
+	EventUserRegistered,
+	EventCouponAcquired,
+	EventIssueUpdated,
+}
+
+// Envelope 送信されるペイロードの共通形式
+type Envelope struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// OrderStatusChanged order.status_changedイベントのデータ
+type OrderStatusChanged struct {
+	OrderID    string             `json:"order_id"`
+	FromStatus models.OrderStatus `json:"from_status"`
+	ToStatus   models.OrderStatus `json:"to_status"`
+}
+
+// UserRegistered user.registeredイベントのデータ
+// パスワードなどの秘匿情報は含めない
+type UserRegistered struct {
+	UserID    string `json:"user_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+}
+
+// CouponAcquired coupon.acquiredイベントのデータ
+type CouponAcquired struct {
+	CouponID string `json:"coupon_id"`
+	UserID   string `json:"user_id"`
+}
+
+// Enqueue イベントを購読している有効なWebhookごとに配信キューへ登録する
+// 呼び出し元のトランザクションを渡すことで、業務データと同時にコミットされる
+func Enqueue(tx *gorm.DB, eventType string, data interface{}) error {
+	var subscriptions []models.WebhookSubscription
+	if err := tx.Where("is_active = ?", true).Find(&subscriptions).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	eventID, _ := uuid.NewRandom()
+	payload, err := json.Marshal(Envelope{
+		ID:        eventID.String(),
+		Type:      eventType,
+		CreatedAt: now,
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, s := range subscriptions {
+		if !s.Subscribes(eventType) {
+			continue
+		}
+		deliveryID, _ := uuid.NewRandom()
+		if err := tx.Create(&models.WebhookDelivery{
+			ID:             deliveryID.String(),
+			SubscriptionID: s.ID,
+			EventID:        eventID.String(),
+			EventType:      eventType,
+			Payload:        string(payload),
+			DeliveryStatus: models.WebhookDeliveryStatusPending,
+			NextAttemptAt:  &now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsValidEventType 購読可能なイベント種別かどうか
+func IsValidEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}